@@ -179,6 +179,7 @@ func init() {
 	viper.RegisterAlias("db_path", command.KeyAddress)
 	viper.RegisterAlias("read_only", command.KeyReadOnly)
 	viper.RegisterAlias("db_options", command.KeyOptions)
+	viper.RegisterAlias("replication", command.KeyReplication)
 
 	{ // re-register standard Go flags to cobra
 		rf := rootCmd.PersistentFlags()
@@ -0,0 +1,30 @@
+//go:build !appengine
+// +build !appengine
+
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryFlags(t *testing.T) {
+	// http command defines the same flags and is registered after the query command,
+	// so flags must be bound to config keys only for the command that runs
+	run := func(args ...string) error {
+		rootCmd.SetArgs(append([]string{"query", "--db", "memstore", "-i", "../../data/testdata.nq"}, args...))
+		return rootCmd.Execute()
+	}
+	require.NoError(t, run(`g.V("<alice>").all()`))
+
+	err := run("--parallel", "3", "--memory_limit", "1024", `g.V("<alice>").all()`)
+	require.NoError(t, err)
+	require.Equal(t, 3, viper.GetInt("query.parallel"))
+	require.Equal(t, int64(1024), viper.GetInt64("query.memory_limit"))
+
+	err = run("--max_scanned", "1", `g.V().all()`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "scanned values")
+}
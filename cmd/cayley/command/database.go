@@ -26,6 +26,8 @@ const (
 	KeyReadOnly = "store.read_only"
 	KeyOptions  = "store.options"
 
+	KeyReplication = "store.replication"
+
 	KeyLoadBatch = "load.batch"
 )
 
@@ -111,7 +113,20 @@ func NewLoadDatabaseCmd() *cobra.Command {
 			}
 			defer h.Close()
 
-			qw, err := h.NewQuadWriter()
+			var qw quad.WriteCloser
+			if bulk, _ := cmd.Flags().GetBool("bulk"); bulk {
+				// bulk loader writes to the store directly, so the writer can't replicate the quads
+				if repl := viper.GetString(KeyReplication); repl != "" && repl != "single" {
+					return fmt.Errorf("bulk loading is not supported with %q replication", repl)
+				}
+				bl, ok := graph.Unwrap(h.QuadStore).(graph.BulkLoader)
+				if !ok {
+					return fmt.Errorf("bulk loading is not supported by %q backend", viper.GetString(KeyBackend))
+				}
+				qw, err = bl.NewBulkWriter()
+			} else {
+				qw, err = h.NewQuadWriter()
+			}
 			if err != nil {
 				return err
 			}
@@ -133,6 +148,7 @@ func NewLoadDatabaseCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
+	cmd.Flags().Bool("bulk", false, "use a faster bulk import, if supported by the backend (indexes are rebuilt after the load, changes are not replicated)")
 	registerLoadFlags(cmd)
 	registerDumpFlags(cmd)
 	return cmd
@@ -208,8 +224,13 @@ func openDatabase() (*graph.Handle, error) {
 	if err != nil {
 		return nil, err
 	}
-	qw, err := graph.NewQuadWriter("single", qs, opts)
+	repl := viper.GetString(KeyReplication)
+	if repl == "" {
+		repl = "single"
+	}
+	qw, err := graph.NewQuadWriter(repl, qs, opts)
 	if err != nil {
+		qs.Close()
 		return nil, err
 	}
 	return &graph.Handle{QuadStore: qs, QuadWriter: qw}, nil
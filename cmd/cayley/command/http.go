@@ -9,13 +9,33 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
 	chttp "github.com/cayleygraph/cayley/internal/http"
 )
 
+const keyACL = "http.acl"
+
+// withPolicy restricts access to the database for HTTP requests, if the access policy file is set.
+// The writer of the database is shared by all requests.
+func withPolicy(h *graph.Handle) (*graph.Handle, error) {
+	path := viper.GetString(keyACL)
+	if path == "" {
+		return h, nil
+	}
+	p, err := acl.LoadPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	clog.Infof("using access policy from %s", path)
+	return &graph.Handle{QuadStore: acl.NewStore(h.QuadStore, p), QuadWriter: h.QuadWriter}, nil
+}
+
 func NewHTTPCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "http",
-		Short: "Serve an HTTP endpoint on the given host and port.",
+		Use:     "http",
+		Short:   "Serve an HTTP endpoint on the given host and port.",
+		PreRunE: bindFlags,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			printBackendInfo()
 			p := mustSetupProfile(cmd)
@@ -26,10 +46,17 @@ func NewHTTPCmd() *cobra.Command {
 				return err
 			}
 			defer h.Close()
+			sh, err := withPolicy(h)
+			if err != nil {
+				return err
+			}
 
-			err = chttp.SetupRoutes(h, &chttp.Config{
-				Timeout:  viper.GetDuration(keyQueryTimeout),
-				ReadOnly: viper.GetBool(KeyReadOnly),
+			err = chttp.SetupRoutes(sh, &chttp.Config{
+				Timeout:     viper.GetDuration(keyQueryTimeout),
+				ReadOnly:    viper.GetBool(KeyReadOnly),
+				Parallel:    viper.GetInt(keyQueryParallel),
+				MemoryLimit: viper.GetInt64(keyQueryMemoryLimit),
+				Limits:      queryLimits(),
 			})
 			if err != nil {
 				return err
@@ -46,7 +73,14 @@ func NewHTTPCmd() *cobra.Command {
 	cmd.Flags().String("host", "127.0.0.1:64210", "host:port to listen on")
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
 	cmd.Flags().DurationP("timeout", "t", 30*time.Second, "elapsed time until an individual query times out")
+	cmd.Flags().String("acl", "", "access policy file for HTTP requests")
+	cmd.Flags().Int("parallel", 0, "number of concurrent workers used to evaluate each query")
+	cmd.Flags().Int64("memory_limit", 0, "memory budget in bytes for intermediate results of each query; spilled to disk beyond it")
 	registerLoadFlags(cmd)
-	viper.BindPFlag(keyQueryTimeout, cmd.Flags().Lookup("timeout"))
+	registerLimitFlags(cmd)
+	bindFlag(cmd, keyQueryTimeout, "timeout")
+	bindFlag(cmd, keyACL, "acl")
+	bindFlag(cmd, keyQueryParallel, "parallel")
+	bindFlag(cmd, keyQueryMemoryLimit, "memory_limit")
 	return cmd
 }
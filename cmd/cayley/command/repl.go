@@ -11,15 +11,22 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/internal/repl"
 	"github.com/cayleygraph/cayley/query"
 )
 
 const (
-	keyQueryTimeout = "query.timeout"
+	keyQueryTimeout     = "query.timeout"
+	keyQueryParallel    = "query.parallel"
+	keyQueryMemoryLimit = "query.memory_limit"
+	keyQueryMaxScanned  = "query.max_scanned"
+	keyQueryMaxResults  = "query.max_results"
+	keyQueryMaxFrontier = "query.max_frontier"
 )
 
 func getContext() (context.Context, func()) {
@@ -37,19 +44,59 @@ func getContext() (context.Context, func()) {
 	return ctx, cancel
 }
 
+// flagConfigKey is an annotation of flags that sets config keys for them. See bindFlag.
+const flagConfigKey = "config_key"
+
+// bindFlag associates a flag of a command with a config key. Flags are bound to their keys by bindFlags
+// only when the command runs, since several commands define flags for the same keys.
+func bindFlag(cmd *cobra.Command, key, name string) {
+	cmd.Flags().SetAnnotation(name, flagConfigKey, []string{key})
+}
+
+// bindFlags binds flags of a running command to their config keys. It is used as PreRunE of commands.
+func bindFlags(cmd *cobra.Command, args []string) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if keys := f.Annotations[flagConfigKey]; len(keys) != 0 && err == nil {
+			err = viper.BindPFlag(keys[0], f)
+		}
+	})
+	return err
+}
+
 func registerQueryFlags(cmd *cobra.Command) {
 	langs := query.Languages()
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
 	cmd.Flags().String("lang", "gizmo", `query language to use ("`+strings.Join(langs, `", "`)+`")`)
 	cmd.Flags().DurationP("timeout", "t", 30*time.Second, "elapsed time until an individual query times out")
-	viper.BindPFlag(keyQueryTimeout, cmd.Flags().Lookup("timeout"))
+	bindFlag(cmd, keyQueryTimeout, "timeout")
 	registerLoadFlags(cmd)
 }
 
+// registerLimitFlags registers flags for resource limits of each query (see queryLimits).
+func registerLimitFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64("max_scanned", 0, "maximal number of values scanned by each query")
+	cmd.Flags().Int64("max_results", 0, "maximal number of intermediate results produced by each query")
+	cmd.Flags().Int64("max_frontier", 0, "maximal number of new values found on a single step of a recursive query")
+	bindFlag(cmd, keyQueryMaxScanned, "max_scanned")
+	bindFlag(cmd, keyQueryMaxResults, "max_results")
+	bindFlag(cmd, keyQueryMaxFrontier, "max_frontier")
+}
+
+// queryLimits returns resource limits of each query set by flags or in the config.
+func queryLimits() iterator.ResourceLimits {
+	return iterator.ResourceLimits{
+		MaxScanned:  viper.GetInt64(keyQueryMaxScanned),
+		MaxResults:  viper.GetInt64(keyQueryMaxResults),
+		MaxFrontier: viper.GetInt64(keyQueryMaxFrontier),
+	}
+}
+
 func NewReplCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "repl",
-		Short: "Drop into a REPL of the given query language.",
+		Use:     "repl",
+		Short:   "Drop into a REPL of the given query language.",
+		PreRunE: bindFlags,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			printBackendInfo()
 			p := mustSetupProfile(cmd)
@@ -64,7 +111,7 @@ func NewReplCmd() *cobra.Command {
 			ctx, cancel := getContext()
 			defer cancel()
 
-			timeout := viper.GetDuration("timeout")
+			timeout := viper.GetDuration(keyQueryTimeout)
 			lang, _ := cmd.Flags().GetString("lang")
 			return repl.Repl(ctx, h, lang, timeout)
 		},
@@ -78,6 +125,7 @@ func NewQueryCmd() *cobra.Command {
 		Use:     "query",
 		Aliases: []string{"qu"},
 		Short:   "Run a query in a specified database and print results.",
+		PreRunE: bindFlags,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var querystr string
 			if len(args) == 0 {
@@ -105,7 +153,7 @@ func NewQueryCmd() *cobra.Command {
 			ctx, cancel := getContext()
 			defer cancel()
 
-			timeout := viper.GetDuration("timeout")
+			timeout := viper.GetDuration(keyQueryTimeout)
 			if timeout > 0 {
 				ctx, cancel = context.WithTimeout(ctx, timeout)
 				defer cancel()
@@ -115,10 +163,22 @@ func NewQueryCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			var prof *iterator.Profile
+			if ok, _ := cmd.Flags().GetBool("profile"); ok {
+				prof = iterator.NewProfile()
+			}
+			var lim *iterator.Limiter
+			if l := queryLimits(); !l.IsZero() {
+				lim = iterator.NewLimiter(l)
+			}
 			enc := json.NewEncoder(os.Stdout)
 			it, err := query.Execute(ctx, h, lang, querystr, query.Options{
-				Collation: query.JSON,
-				Limit:     limit,
+				Collation:   query.JSON,
+				Limit:       limit,
+				Parallel:    viper.GetInt(keyQueryParallel),
+				MemoryLimit: viper.GetInt64(keyQueryMemoryLimit),
+				Profile:     prof,
+				Limiter:     lim,
 			})
 			if err != nil {
 				return err
@@ -129,10 +189,24 @@ func NewQueryCmd() *cobra.Command {
 					return err
 				}
 			}
-			return it.Err()
+			if err = it.Err(); err != nil {
+				return err
+			}
+			if prof != nil {
+				penc := json.NewEncoder(os.Stderr)
+				penc.SetIndent("", "  ")
+				return penc.Encode(prof)
+			}
+			return nil
 		},
 	}
 	registerQueryFlags(cmd)
 	cmd.Flags().IntP("limit", "n", 100, "limit a number of results")
+	cmd.Flags().Int("parallel", 0, "number of concurrent workers used to evaluate the query")
+	cmd.Flags().Int64("memory_limit", 0, "memory budget in bytes for intermediate results; spilled to disk beyond it")
+	cmd.Flags().Bool("profile", false, "print runtime statistics of query iterators to stderr")
+	registerLimitFlags(cmd)
+	bindFlag(cmd, keyQueryParallel, "parallel")
+	bindFlag(cmd, keyQueryMemoryLimit, "memory_limit")
 	return cmd
 }
@@ -0,0 +1,138 @@
+// Package acl implements graph-level access control for quad stores.
+//
+// A QuadStore wraps another store and hides quads by label and predicate from all iterators,
+// so the same restrictions apply to every query language. Writes of hidden quads are rejected.
+// Store selects the rule for each HTTP request from a Policy.
+package acl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// ErrAccessDenied is returned when a principal is not allowed to access the graph, or to write a quad.
+var ErrAccessDenied = errors.New("acl: access denied")
+
+// DefaultHeader is the HTTP header that carries the principal name, if the policy does not set one.
+const DefaultHeader = "X-Cayley-Principal"
+
+// Rule defines what part of the graph a principal can access.
+//
+// Labels and predicates are written in the same format as in the HTTP API, for example "<http://example.com/p>".
+// An empty string refers to the default graph (quads without a label).
+type Rule struct {
+	// Labels is a list of visible labels. All labels are visible if it is empty.
+	Labels []string `json:"labels,omitempty"`
+	// DenyLabels is a list of hidden labels.
+	DenyLabels []string `json:"deny_labels,omitempty"`
+	// DenyPredicates is a list of hidden predicates.
+	DenyPredicates []string `json:"deny_predicates,omitempty"`
+	// ReadOnly rejects all writes.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// Restricted returns whether the rule hides any quads.
+func (r Rule) Restricted() bool {
+	return len(r.Labels) != 0 || len(r.DenyLabels) != 0 || len(r.DenyPredicates) != 0
+}
+
+type hashSet map[refs.ValueHash]struct{}
+
+func newHashSet(vals []string) hashSet {
+	if len(vals) == 0 {
+		return nil
+	}
+	m := make(hashSet, len(vals))
+	for _, s := range vals {
+		m[refs.HashOf(quad.StringToValue(s))] = struct{}{}
+	}
+	return m
+}
+
+func (m hashSet) has(v quad.Value) bool {
+	_, ok := m[refs.HashOf(v)]
+	return ok
+}
+
+// filter is a compiled Rule.
+type filter struct {
+	labels     hashSet // nil if all labels are visible
+	denyLabels hashSet
+	denyPreds  hashSet
+}
+
+func newFilter(r Rule) *filter {
+	return &filter{
+		labels:     newHashSet(r.Labels),
+		denyLabels: newHashSet(r.DenyLabels),
+		denyPreds:  newHashSet(r.DenyPredicates),
+	}
+}
+
+// allows checks if a quad is visible.
+func (f *filter) allows(q quad.Quad) bool {
+	if f.labels != nil && !f.labels.has(q.Label) {
+		return false
+	}
+	return !f.denyLabels.has(q.Label) && !f.denyPreds.has(q.Predicate)
+}
+
+// Policy maps principals to access rules.
+type Policy struct {
+	// Header is the HTTP header that carries the principal name. DefaultHeader is used if it is empty.
+	// The header must be set by a trusted proxy that authenticates clients.
+	Header string `json:"header,omitempty"`
+	// Default is a rule for requests without a principal and for unknown principals.
+	// These requests are denied if it is not set.
+	Default *Rule `json:"default,omitempty"`
+	// Principals maps principal names to their rules.
+	Principals map[string]Rule `json:"principals,omitempty"`
+}
+
+// ParsePolicy reads a policy in JSON format.
+func ParsePolicy(r io.Reader) (*Policy, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("acl: cannot parse policy: %w", err)
+	}
+	return &p, nil
+}
+
+// LoadPolicy reads a policy from a JSON file.
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePolicy(f)
+}
+
+// HeaderName returns the HTTP header that carries the principal name.
+func (p *Policy) HeaderName() string {
+	if p.Header != "" {
+		return p.Header
+	}
+	return DefaultHeader
+}
+
+// Rule returns a rule for a given principal. An empty name means that the request has no principal.
+//
+// It returns ErrAccessDenied if the principal is unknown and the policy has no default rule.
+func (p *Policy) Rule(principal string) (Rule, error) {
+	if r, ok := p.Principals[principal]; ok && principal != "" {
+		return r, nil
+	} else if p.Default != nil {
+		return *p.Default, nil
+	}
+	return Rule{}, ErrAccessDenied
+}
@@ -0,0 +1,279 @@
+package acl_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/query/gizmo"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/cayleygraph/quad"
+)
+
+var (
+	bob    = quad.IRI("bob")
+	alice  = quad.IRI("alice")
+	carol  = quad.IRI("carol")
+	secret = quad.IRI("secret")
+	salary = quad.IRI("salary")
+)
+
+var testQuads = []quad.Quad{
+	quad.Make(bob, "follows", alice, nil),
+	quad.Make(alice, "follows", bob, nil),
+	quad.Make(bob, salary, 100, nil),
+	quad.Make(alice, "follows", carol, secret),
+	quad.Make(carol, "name", "Carol", secret),
+}
+
+func readAll(t testing.TB, qs graph.QuadStore) []quad.Quad {
+	r := graph.NewQuadStoreReader(qs)
+	defer r.Close()
+	quads, err := quad.ReadAll(r)
+	require.NoError(t, err)
+	return quads
+}
+
+func nodes(t testing.TB, qs graph.QuadStore) []string {
+	ctx := context.TODO()
+	it := qs.NodesAllIterator().Iterate()
+	defer it.Close()
+	var out []string
+	for it.Next(ctx) {
+		v, err := qs.NameOf(it.Result())
+		require.NoError(t, err)
+		out = append(out, quad.ToString(v))
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(out)
+	return out
+}
+
+func TestQuadStore(t *testing.T) {
+	base := memstore.New(testQuads...)
+	qs := acl.New(base, acl.Rule{
+		DenyLabels:     []string{"<secret>"},
+		DenyPredicates: []string{"<salary>"},
+	})
+
+	require.ElementsMatch(t, testQuads[:2], readAll(t, qs))
+	require.Equal(t, []string{"<alice>", "<bob>", "follows"}, nodes(t, qs))
+
+	ref, err := qs.ValueOf(carol)
+	require.NoError(t, err)
+	require.Nil(t, ref, "node used only by hidden quads must be hidden")
+
+	ref, err = qs.ValueOf(bob)
+	require.NoError(t, err)
+	require.NotNil(t, ref)
+	var out []quad.Quad
+	it := qs.QuadIterator(quad.Subject, ref).Iterate()
+	for it.Next(context.TODO()) {
+		q, err := qs.Quad(it.Result())
+		require.NoError(t, err)
+		out = append(out, q)
+	}
+	require.NoError(t, it.Err())
+	it.Close()
+	require.Equal(t, testQuads[:1], out)
+
+	st, err := qs.Stats(context.TODO(), true)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), st.Quads.Value)
+	require.Equal(t, int64(3), st.Nodes.Value)
+
+	// the same restrictions apply to queries
+	ses := gizmo.NewSession(qs)
+	res, err := ses.Execute(context.TODO(), `g.V("<alice>").out("follows").all()`, query.Options{Collation: query.JSON})
+	require.NoError(t, err)
+	var vals []string
+	for res.Next(context.TODO()) {
+		vals = append(vals, fmt.Sprint(res.Result()))
+	}
+	require.NoError(t, res.Err())
+	res.Close()
+	require.Equal(t, []string{"map[id:<bob>]"}, vals)
+
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: quad.Make(carol, "name", "C", secret), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: testQuads[2], Action: graph.Delete}}, graph.IgnoreOpts{})
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+	err = graph.ApplyDeltasIf(qs, []graph.Precondition{{Quad: testQuads[3], Kind: graph.QuadExists}}, nil, graph.IgnoreOpts{})
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: quad.Make(carol, "follows", bob, nil), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"<alice>", "<bob>", "<carol>", "follows"}, nodes(t, qs))
+	require.Len(t, readAll(t, base), len(testQuads)+1)
+}
+
+func TestQuadStoreLabels(t *testing.T) {
+	qs := acl.New(memstore.New(testQuads...), acl.Rule{Labels: []string{"<secret>"}, ReadOnly: true})
+	require.ElementsMatch(t, testQuads[3:], readAll(t, qs))
+	require.Equal(t, []string{"<alice>", "<carol>", "<secret>", "Carol", "follows", "name"}, nodes(t, qs))
+
+	err := qs.ApplyDeltas([]graph.Delta{{Quad: testQuads[3], Action: graph.Delete}}, graph.IgnoreOpts{})
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+	_, err = qs.NewQuadWriter()
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+}
+
+func TestWriter(t *testing.T) {
+	base := memstore.New(testQuads...)
+	qs := acl.New(base, acl.Rule{DenyLabels: []string{"<secret>"}})
+	qw, err := writer.NewSingle(base, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	defer qw.Close()
+	w := qs.WrapWriter(qw)
+
+	require.ErrorIs(t, w.AddQuad(quad.Make(bob, "likes", carol, secret)), acl.ErrAccessDenied)
+	require.ErrorIs(t, w.RemoveQuad(testQuads[4]), acl.ErrAccessDenied)
+	require.NoError(t, w.AddQuad(quad.Make(bob, "likes", carol, nil)))
+
+	// only visible quads of a node are removed
+	require.NoError(t, w.RemoveNode(carol))
+	require.ElementsMatch(t, []quad.Quad{
+		testQuads[0], testQuads[1], testQuads[2], testQuads[3], testQuads[4],
+	}, readAll(t, base))
+	require.Equal(t, graph.ErrNodeNotExists, w.RemoveNode(carol))
+
+	// changes of hidden quads are not visible
+	deltas := []graph.Delta{
+		{Quad: testQuads[0], Action: graph.Delete},
+		{Quad: testQuads[3], Action: graph.Delete},
+	}
+	require.Equal(t, deltas[:1], qs.FilterDeltas(deltas))
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := acl.ParsePolicy(strings.NewReader(`{
+		"header": "X-User",
+		"principals": {
+			"admin": {},
+			"guest": {"labels": [""], "read_only": true}
+		}
+	}`))
+	require.NoError(t, err)
+
+	_, err = acl.ParsePolicy(strings.NewReader(`{"principal": {}}`))
+	require.Error(t, err)
+
+	s := acl.NewStore(memstore.New(testQuads...), p)
+	forUser := func(name string) (graph.QuadStore, error) {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, err)
+		if name != "" {
+			r.Header.Set("X-User", name)
+		}
+		return s.ForRequest(r)
+	}
+
+	qs, err := forUser("admin")
+	require.NoError(t, err)
+	require.True(t, qs == graph.QuadStore(s), "unrestricted principal must get the store itself")
+
+	qs, err = forUser("guest")
+	require.NoError(t, err)
+	require.ElementsMatch(t, testQuads[:3], readAll(t, qs))
+
+	_, err = forUser("")
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+	_, err = forUser("eve")
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+
+	p.Default = &acl.Rule{DenyPredicates: []string{"<salary>", "name"}}
+	qs, err = forUser("eve")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []quad.Quad{testQuads[0], testQuads[1], testQuads[3]}, readAll(t, qs))
+}
+
+func TestAsOf(t *testing.T) {
+	ctx := context.TODO()
+	base := memstore.NewVersioned(testQuads...)
+	v := graph.Version{Horizon: base.Horizon()}
+	err := base.ApplyDeltas([]graph.Delta{
+		{Quad: testQuads[0], Action: graph.Delete},
+		{Quad: testQuads[3], Action: graph.Delete},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	qs := acl.New(base, acl.Rule{DenyLabels: []string{"<secret>"}})
+	snap, err := graph.AsOf(ctx, qs, v)
+	require.NoError(t, err)
+	require.ElementsMatch(t, testQuads[:3], readAll(t, snap), "snapshot must keep the rule")
+	err = snap.ApplyDeltas([]graph.Delta{{Quad: testQuads[0], Action: graph.Add}}, graph.IgnoreOpts{})
+	require.ErrorIs(t, err, acl.ErrAccessDenied)
+
+	// the same applies to queries
+	ses := gizmo.NewSession(qs)
+	res, err := ses.Execute(ctx, `g.V("<alice>").out("follows").all()`, query.Options{Collation: query.JSON, AsOf: v})
+	require.NoError(t, err)
+	var vals []string
+	for res.Next(ctx) {
+		vals = append(vals, fmt.Sprint(res.Result()))
+	}
+	require.NoError(t, res.Err())
+	res.Close()
+	require.Equal(t, []string{"map[id:<bob>]"}, vals)
+
+	_, err = graph.AsOf(ctx, acl.New(memstore.New(testQuads...), acl.Rule{}), v)
+	require.ErrorIs(t, err, graph.ErrNoHistory)
+
+	p, err := acl.ParsePolicy(strings.NewReader(`{
+		"header": "X-User",
+		"principals": {
+			"admin": {},
+			"guest": {"deny_labels": ["<secret>"]}
+		}
+	}`))
+	require.NoError(t, err)
+	s := acl.NewStore(base, p)
+	sv, err := graph.AsOf(ctx, s, v)
+	require.NoError(t, err)
+	require.ElementsMatch(t, testQuads, readAll(t, sv))
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	r.Header.Set("X-User", "guest")
+	gs, err := sv.(*acl.Store).ForRequest(r)
+	require.NoError(t, err)
+	require.ElementsMatch(t, testQuads[:3], readAll(t, gs))
+}
+
+// replicated is a quad store that reads from a lagging replica.
+type replicated struct {
+	graph.QuadStore
+	primary graph.QuadStore
+}
+
+func (qs *replicated) Primary() graph.QuadStore {
+	return qs.primary
+}
+
+func TestPrimary(t *testing.T) {
+	primary := memstore.New(testQuads...)
+	qs := &replicated{QuadStore: memstore.New(), primary: primary}
+
+	s := acl.NewStore(qs, &acl.Policy{Default: &acl.Rule{}})
+	require.Empty(t, readAll(t, s))
+	require.ElementsMatch(t, testQuads, readAll(t, graph.PrimaryOf(s)))
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	ps, err := graph.PrimaryOf(s).(*acl.Store).ForRequest(r)
+	require.NoError(t, err)
+	require.ElementsMatch(t, testQuads, readAll(t, ps))
+
+	rqs := acl.New(qs, acl.Rule{DenyLabels: []string{"<secret>"}})
+	require.Empty(t, readAll(t, rqs))
+	require.ElementsMatch(t, testQuads[:3], readAll(t, graph.PrimaryOf(rqs)))
+}
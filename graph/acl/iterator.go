@@ -0,0 +1,165 @@
+package acl
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// visibleFunc checks if a value returned by the sub-iterator is visible.
+type visibleFunc func(ctx context.Context, v refs.Ref) (bool, error)
+
+var _ iterator.Shape = (*filterShape)(nil)
+
+// filterShape hides values of the sub-iterator that are not visible to a principal.
+type filterShape struct {
+	sub     iterator.Shape
+	name    string
+	visible visibleFunc
+}
+
+func newFilterShape(sub iterator.Shape, name string, visible visibleFunc) *filterShape {
+	return &filterShape{sub: sub, name: name, visible: visible}
+}
+
+func (it *filterShape) Iterate() iterator.Scanner {
+	return &filterNext{sub: it.sub.Iterate(), name: it.name, visible: it.visible}
+}
+
+func (it *filterShape) Lookup() iterator.Index {
+	return &filterContains{sub: it.sub.Lookup(), name: it.name, visible: it.visible}
+}
+
+func (it *filterShape) SubIterators() []iterator.Shape {
+	return []iterator.Shape{it.sub}
+}
+
+var _ iterator.Composite = (*filterShape)(nil)
+
+// MapSubIterators implements iterator.Composite.
+func (it *filterShape) MapSubIterators(m iterator.Morphism) iterator.Shape {
+	nit := *it
+	nit.sub = m(it.sub)
+	return &nit
+}
+
+func (it *filterShape) String() string {
+	return it.name
+}
+
+// Optimize only optimizes the sub-iterator. The filter itself must never be removed.
+func (it *filterShape) Optimize(ctx context.Context) (iterator.Shape, bool) {
+	sub, changed := it.sub.Optimize(ctx)
+	if !changed {
+		return it, false
+	}
+	nit := *it
+	nit.sub = sub
+	return &nit, true
+}
+
+// Stats returns the stats of the sub-iterator, since it's not known how many values are hidden.
+func (it *filterShape) Stats(ctx context.Context) (iterator.Costs, error) {
+	st, err := it.sub.Stats(ctx)
+	st.NextCost *= 2
+	st.ContainsCost *= 2
+	st.Size.Exact = false
+	return st, err
+}
+
+type filterNext struct {
+	sub     iterator.Scanner
+	name    string
+	visible visibleFunc
+	result  refs.Ref
+	err     error
+}
+
+func (it *filterNext) Next(ctx context.Context) bool {
+	for it.sub.Next(ctx) {
+		v := it.sub.Result()
+		ok, err := it.visible(ctx, v)
+		if err != nil {
+			it.err = err
+			return false
+		} else if ok {
+			it.result = v
+			return true
+		}
+	}
+	it.err = it.sub.Err()
+	return false
+}
+
+func (it *filterNext) Paths(ctx context.Context) iterator.Scanner {
+	return iterator.Paths(ctx, it.sub)
+}
+
+func (it *filterNext) TagResults(dst map[string]refs.Ref) {
+	it.sub.TagResults(dst)
+}
+
+func (it *filterNext) Result() refs.Ref {
+	return it.result
+}
+
+func (it *filterNext) Err() error {
+	return it.err
+}
+
+func (it *filterNext) Close() error {
+	return it.sub.Close()
+}
+
+func (it *filterNext) String() string {
+	return it.name + "Next"
+}
+
+type filterContains struct {
+	sub     iterator.Index
+	name    string
+	visible visibleFunc
+	result  refs.Ref
+	err     error
+}
+
+func (it *filterContains) Contains(ctx context.Context, v refs.Ref) bool {
+	ok, err := it.visible(ctx, v)
+	if err != nil {
+		it.err = err
+		return false
+	} else if !ok {
+		return false
+	}
+	if !it.sub.Contains(ctx, v) {
+		it.err = it.sub.Err()
+		return false
+	}
+	it.result = it.sub.Result()
+	return true
+}
+
+func (it *filterContains) Paths(ctx context.Context) iterator.Scanner {
+	return iterator.Paths(ctx, it.sub)
+}
+
+func (it *filterContains) TagResults(dst map[string]refs.Ref) {
+	it.sub.TagResults(dst)
+}
+
+func (it *filterContains) Result() refs.Ref {
+	return it.result
+}
+
+func (it *filterContains) Err() error {
+	return it.err
+}
+
+func (it *filterContains) Close() error {
+	return it.sub.Close()
+}
+
+func (it *filterContains) String() string {
+	return it.name + "Contains"
+}
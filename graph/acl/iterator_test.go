@@ -0,0 +1,30 @@
+package acl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+func TestFilterOptimize(t *testing.T) {
+	ctx := context.TODO()
+	visible := func(ctx context.Context, v refs.Ref) (bool, error) { return true, nil }
+
+	it := newFilterShape(iterator.NewFixed(iterator.Int64Node(1)), "filter", visible)
+	nit, changed := it.Optimize(ctx)
+	require.False(t, changed)
+	require.True(t, nit == iterator.Shape(it))
+
+	sub := iterator.NewAnd(iterator.NewFixed(iterator.Int64Node(1)))
+	it = newFilterShape(sub, "filter", visible)
+	nit, changed = it.Optimize(ctx)
+	require.True(t, changed)
+	require.IsType(t, (*filterShape)(nil), nit)
+	require.NotEqual(t, sub, nit.(*filterShape).sub)
+	// the original shape is not modified
+	require.True(t, it.sub == iterator.Shape(sub))
+}
@@ -0,0 +1,335 @@
+package acl
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+var (
+	_ graph.QuadStore          = (*QuadStore)(nil)
+	_ graph.ConditionalApplier = (*QuadStore)(nil)
+	_ graph.PrimaryReader      = (*QuadStore)(nil)
+	_ graph.Historian          = (*QuadStore)(nil)
+	_ graph.PrimaryReader      = (*Store)(nil)
+	_ graph.Historian          = (*Store)(nil)
+)
+
+// QuadStore restricts access to another quad store according to a Rule.
+//
+// Hidden quads are never returned by iterators, and nodes are hidden if all their quads are hidden.
+// Writes of hidden quads fail with ErrAccessDenied. Optional interfaces of the underlying store
+// (such as query optimizers) are not exposed, since they would bypass the rule. History and reads
+// from the primary database are exposed through views with the same rule.
+//
+// Closing the store does not close the underlying store.
+type QuadStore struct {
+	qs       graph.QuadStore
+	f        *filter
+	readOnly bool
+	all      bool // the rule doesn't hide any quads
+}
+
+// New creates a quad store that restricts access to qs according to a given rule.
+func New(qs graph.QuadStore, r Rule) *QuadStore {
+	return &QuadStore{qs: qs, f: newFilter(r), readOnly: r.ReadOnly, all: !r.Restricted()}
+}
+
+// quadVisible checks if a quad is visible by its reference.
+func (qs *QuadStore) quadVisible(ctx context.Context, v refs.Ref) (bool, error) {
+	q, err := qs.qs.Quad(v)
+	if err != nil {
+		return false, err
+	}
+	return qs.f.allows(q), nil
+}
+
+// nodeVisible checks if a node is used by at least one visible quad.
+func (qs *QuadStore) nodeVisible(ctx context.Context, v refs.Ref) (bool, error) {
+	for _, d := range quad.Directions {
+		it := qs.QuadIterator(d, v).Iterate()
+		ok := it.Next(ctx)
+		err := it.Err()
+		it.Close()
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// checkQuad returns ErrAccessDenied if a quad cannot be written.
+func (qs *QuadStore) checkQuad(q quad.Quad) error {
+	if qs.readOnly || !qs.f.allows(q) {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// checkDeltas returns ErrAccessDenied if any of the deltas or preconditions uses a hidden quad.
+func (qs *QuadStore) checkDeltas(conds []graph.Precondition, deltas []graph.Delta) error {
+	if qs.readOnly && (len(conds) != 0 || len(deltas) != 0) {
+		return ErrAccessDenied
+	}
+	for i, c := range conds {
+		if !qs.f.allows(c.Quad) {
+			return &graph.DeltaError{Cond: &conds[i], Err: ErrAccessDenied}
+		}
+	}
+	for _, d := range deltas {
+		// replace deltas only affect quads with the same predicate and label, so checking the quad is enough
+		if !qs.f.allows(d.Quad) {
+			return &graph.DeltaError{Delta: d, Err: ErrAccessDenied}
+		}
+	}
+	return nil
+}
+
+func (qs *QuadStore) ValueOf(v quad.Value) (graph.Ref, error) {
+	ref, err := qs.qs.ValueOf(v)
+	if err != nil || ref == nil || qs.all {
+		return ref, err
+	}
+	ok, err := qs.nodeVisible(context.TODO(), ref)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return ref, nil
+}
+
+func (qs *QuadStore) NameOf(v graph.Ref) (quad.Value, error) {
+	return qs.qs.NameOf(v)
+}
+
+func (qs *QuadStore) Quad(v graph.Ref) (quad.Quad, error) {
+	q, err := qs.qs.Quad(v)
+	if err != nil || !qs.f.allows(q) {
+		return quad.Quad{}, err
+	}
+	return q, nil
+}
+
+func (qs *QuadStore) QuadIterator(d quad.Direction, v graph.Ref) iterator.Shape {
+	it := qs.qs.QuadIterator(d, v)
+	if qs.all {
+		return it
+	}
+	return newFilterShape(it, "ACLQuads", qs.quadVisible)
+}
+
+func (qs *QuadStore) QuadIteratorSize(ctx context.Context, d quad.Direction, v graph.Ref) (refs.Size, error) {
+	sz, err := qs.qs.QuadIteratorSize(ctx, d, v)
+	if !qs.all {
+		sz.Exact = false
+	}
+	return sz, err
+}
+
+func (qs *QuadStore) QuadDirection(v graph.Ref, d quad.Direction) (graph.Ref, error) {
+	return qs.qs.QuadDirection(v, d)
+}
+
+// Stats returns the stats of the underlying store as an upper bound estimate if the rule hides any quads.
+// If exact stats are requested, visible quads and nodes are counted.
+func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error) {
+	if qs.all {
+		return qs.qs.Stats(ctx, exact)
+	} else if !exact {
+		st, err := qs.qs.Stats(ctx, false)
+		st.Nodes.Exact = false
+		st.Quads.Exact = false
+		return st, err
+	}
+	var (
+		st  graph.Stats
+		err error
+	)
+	st.Quads.Value, err = count(ctx, qs.QuadsAllIterator())
+	if err != nil {
+		return st, err
+	}
+	st.Nodes.Value, err = count(ctx, qs.NodesAllIterator())
+	if err != nil {
+		return st, err
+	}
+	st.Nodes.Exact, st.Quads.Exact = true, true
+	return st, nil
+}
+
+func count(ctx context.Context, s iterator.Shape) (int64, error) {
+	it := s.Iterate()
+	defer it.Close()
+	var n int64
+	for it.Next(ctx) {
+		n++
+	}
+	return n, it.Err()
+}
+
+func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error {
+	if err := qs.checkDeltas(nil, in); err != nil {
+		return err
+	}
+	return qs.qs.ApplyDeltas(in, opts)
+}
+
+// ApplyDeltasIf implements graph.ConditionalApplier. Preconditions on hidden quads are rejected,
+// so they cannot be used to discover hidden quads.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, in []graph.Delta, opts graph.IgnoreOpts) error {
+	if err := qs.checkDeltas(conds, in); err != nil {
+		return err
+	}
+	return graph.ApplyDeltasIf(qs.qs, conds, in, opts)
+}
+
+func (qs *QuadStore) NewQuadWriter() (quad.WriteCloser, error) {
+	if qs.readOnly {
+		return nil, ErrAccessDenied
+	}
+	w, err := qs.qs.NewQuadWriter()
+	if err != nil {
+		return nil, err
+	}
+	return &quadWriter{qs: qs, w: w}, nil
+}
+
+func (qs *QuadStore) NodesAllIterator() iterator.Shape {
+	it := qs.qs.NodesAllIterator()
+	if qs.all {
+		return it
+	}
+	return newFilterShape(it, "ACLNodes", qs.nodeVisible)
+}
+
+func (qs *QuadStore) QuadsAllIterator() iterator.Shape {
+	it := qs.qs.QuadsAllIterator()
+	if qs.all {
+		return it
+	}
+	return newFilterShape(it, "ACLQuads", qs.quadVisible)
+}
+
+// Primary returns a view of the quad store with the same rule that reads from the primary database.
+func (qs *QuadStore) Primary() graph.QuadStore {
+	p := graph.PrimaryOf(qs.qs)
+	if p == qs.qs {
+		return qs
+	}
+	nqs := *qs
+	nqs.qs = p
+	return &nqs
+}
+
+// AsOf returns a read-only view of the graph at a given version with the same rule.
+// It implements graph.Historian and returns graph.ErrNoHistory if the underlying store doesn't record history.
+func (qs *QuadStore) AsOf(ctx context.Context, v graph.Version) (graph.QuadStore, error) {
+	snap, err := graph.AsOf(ctx, qs.qs, v)
+	if err != nil {
+		return nil, err
+	}
+	nqs := *qs
+	nqs.qs = snap
+	nqs.readOnly = true
+	return &nqs, nil
+}
+
+// WrapWriter returns a writer that checks all changes against the rule before passing them to qw.
+// It implements httpgraph.WriterWrapper.
+func (qs *QuadStore) WrapWriter(qw graph.QuadWriter) graph.QuadWriter {
+	return &Writer{qs: qs, qw: qw}
+}
+
+// FilterDeltas removes deltas with hidden quads. It implements httpgraph.DeltaFilter.
+func (qs *QuadStore) FilterDeltas(deltas []graph.Delta) []graph.Delta {
+	if qs.all {
+		return deltas
+	}
+	out := make([]graph.Delta, 0, len(deltas))
+	for _, d := range deltas {
+		if qs.f.allows(d.Quad) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Close does nothing. The underlying store must be closed separately.
+func (qs *QuadStore) Close() error {
+	return nil
+}
+
+// quadWriter checks quads before writing them to the underlying store.
+type quadWriter struct {
+	qs *QuadStore
+	w  quad.WriteCloser
+}
+
+func (w *quadWriter) WriteQuad(q quad.Quad) error {
+	if err := w.qs.checkQuad(q); err != nil {
+		return err
+	}
+	return w.w.WriteQuad(q)
+}
+
+func (w *quadWriter) WriteQuads(buf []quad.Quad) (int, error) {
+	for _, q := range buf {
+		if err := w.qs.checkQuad(q); err != nil {
+			return 0, err
+		}
+	}
+	return w.w.WriteQuads(buf)
+}
+
+func (w *quadWriter) Close() error {
+	return w.w.Close()
+}
+
+// Store selects a rule for each HTTP request according to a policy. It implements httpgraph.QuadStore.
+//
+// When used directly, it gives full access to the underlying store. Principals with a rule that doesn't
+// restrict anything get the Store itself for their requests.
+type Store struct {
+	graph.QuadStore
+	p *Policy
+}
+
+// NewStore wraps a quad store with a given policy.
+func NewStore(qs graph.QuadStore, p *Policy) *Store {
+	return &Store{QuadStore: qs, p: p}
+}
+
+// ForRequest returns a quad store for the principal of a given request.
+// It returns ErrAccessDenied if the policy has no rule for the principal.
+func (s *Store) ForRequest(r *http.Request) (graph.QuadStore, error) {
+	rule, err := s.p.Rule(r.Header.Get(s.p.HeaderName()))
+	if err != nil {
+		return nil, err
+	} else if !rule.Restricted() && !rule.ReadOnly {
+		return s, nil
+	}
+	return New(s.QuadStore, rule), nil
+}
+
+// AsOf returns a view of the graph at a given version with the same policy.
+// It implements graph.Historian and returns graph.ErrNoHistory if the underlying store doesn't record history.
+func (s *Store) AsOf(ctx context.Context, v graph.Version) (graph.QuadStore, error) {
+	snap, err := graph.AsOf(ctx, s.QuadStore, v)
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(snap, s.p), nil
+}
+
+// Primary returns a view of the store with the same policy that reads from the primary database.
+func (s *Store) Primary() graph.QuadStore {
+	p := graph.PrimaryOf(s.QuadStore)
+	if p == s.QuadStore {
+		return s
+	}
+	return NewStore(p, s.p)
+}
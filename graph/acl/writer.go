@@ -0,0 +1,83 @@
+package acl
+
+import (
+	"context"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var (
+	_ graph.QuadWriter   = (*Writer)(nil)
+	_ graph.QuadReplacer = (*Writer)(nil)
+)
+
+// Writer checks changes against the rule of a QuadStore before passing them to another writer.
+// This allows all principals to share a single writer of the database, together with its change feed.
+//
+// Closing the writer does not close the underlying writer.
+type Writer struct {
+	qs *QuadStore
+	qw graph.QuadWriter
+}
+
+func (w *Writer) AddQuad(q quad.Quad) error {
+	if err := w.qs.checkQuad(q); err != nil {
+		return err
+	}
+	return w.qw.AddQuad(q)
+}
+
+func (w *Writer) AddQuadSet(set []quad.Quad) error {
+	for _, q := range set {
+		if err := w.qs.checkQuad(q); err != nil {
+			return err
+		}
+	}
+	return w.qw.AddQuadSet(set)
+}
+
+func (w *Writer) RemoveQuad(q quad.Quad) error {
+	if err := w.qs.checkQuad(q); err != nil {
+		return err
+	}
+	return w.qw.RemoveQuad(q)
+}
+
+// ReplaceQuad removes all quads with the same subject, predicate and label and adds a given quad.
+func (w *Writer) ReplaceQuad(q quad.Quad) error {
+	tx := graph.NewTransactionN(1)
+	tx.ReplaceQuad(q)
+	return w.ApplyTransaction(tx)
+}
+
+func (w *Writer) ApplyTransaction(tx *graph.Transaction) error {
+	if err := w.qs.checkDeltas(tx.Preconds, tx.Deltas); err != nil {
+		return err
+	}
+	return w.qw.ApplyTransaction(tx)
+}
+
+// RemoveNode removes all visible quads with the given value. Hidden quads are left unchanged.
+//
+// It returns ErrNodeNotExists if node is missing.
+func (w *Writer) RemoveNode(v quad.Value) error {
+	if w.qs.readOnly {
+		return ErrAccessDenied
+	}
+	rm, err := graph.PlanNodeRemoval(context.TODO(), graph.PrimaryOf(w.qs), v, graph.RemoveNodeOptions{})
+	if err != nil {
+		return err
+	}
+	tx := graph.NewTransactionN(len(rm.Quads))
+	for _, q := range rm.Quads {
+		tx.RemoveQuad(q)
+	}
+	return w.ApplyTransaction(tx)
+}
+
+// Close does nothing. The underlying writer must be closed separately.
+func (w *Writer) Close() error {
+	return nil
+}
@@ -4,5 +4,6 @@ package all
 
 import (
 	// backends requiring cgo
+	_ "github.com/cayleygraph/cayley/graph/sql/duckdb"
 	_ "github.com/cayleygraph/cayley/graph/sql/sqlite"
 )
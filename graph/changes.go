@@ -0,0 +1,332 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+var (
+	ErrNoChangeFeed     = errors.New("quadwriter: change feed is not supported")
+	ErrChangesTruncated = errors.New("changes: requested sequence is no longer available")
+	ErrChangesReset     = errors.New("changes: feed was restarted, sequence numbers are no longer valid")
+)
+
+// DefaultChangeBuffer is the default number of deltas kept by a ChangeFeed for resuming subscribers.
+const DefaultChangeBuffer = 16 * 1024
+
+// Change is a batch of deltas committed to the graph.
+type Change struct {
+	// Seq is a sequence number of the batch. It starts at 1 and increases monotonically.
+	Seq uint64
+	// Time is the time when the batch was committed.
+	Time time.Time
+	// Deltas is a list of deltas that changed the graph (see EffectiveDeltas).
+	Deltas []Delta
+}
+
+// ChangeFeed keeps a bounded log of committed changes and delivers them to subscribers.
+//
+// Publishing never blocks: subscribers that fall behind the log are stopped with ErrChangesTruncated
+// and may resume from their last sequence if it is still available.
+//
+// The log is kept in memory, thus sequence numbers start at 1 again when the feed is recreated
+// (for example, after a restart). Each feed has a random ID that allows subscribers to detect it.
+type ChangeFeed struct {
+	id     string
+	mu     sync.Mutex
+	seq    uint64
+	log    []Change
+	size   int // number of deltas in the log
+	max    int
+	notify chan struct{}
+}
+
+// NewChangeFeed creates a feed that keeps at least n last deltas for resuming subscribers.
+// The last batch is always kept, regardless of its size. DefaultChangeBuffer is used if n is not positive.
+func NewChangeFeed(n int) *ChangeFeed {
+	if n <= 0 {
+		n = DefaultChangeBuffer
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return &ChangeFeed{id: hex.EncodeToString(id[:]), max: n, notify: make(chan struct{})}
+}
+
+// ID returns a random id of this feed. Sequence numbers of feeds with different IDs are not related.
+func (f *ChangeFeed) ID() string {
+	return f.id
+}
+
+// Seq returns the sequence number of the last committed batch.
+func (f *ChangeFeed) Seq() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seq
+}
+
+// Publish records a committed batch of deltas and returns its sequence number.
+func (f *ChangeFeed) Publish(deltas []Delta) uint64 {
+	c := Change{Time: time.Now(), Deltas: append([]Delta{}, deltas...)}
+	f.mu.Lock()
+	f.seq++
+	c.Seq = f.seq
+	f.log = append(f.log, c)
+	f.size += len(c.Deltas)
+	for len(f.log) > 1 && f.size-len(f.log[0].Deltas) >= f.max {
+		f.size -= len(f.log[0].Deltas)
+		f.log[0] = Change{}
+		f.log = f.log[1:]
+	}
+	notify := f.notify
+	f.notify = make(chan struct{})
+	f.mu.Unlock()
+	close(notify)
+	return c.Seq
+}
+
+// Since returns all committed changes with a sequence number greater than seq.
+// It returns ErrChangesTruncated if some of these changes are no longer in the log.
+func (f *ChangeFeed) Since(seq uint64) ([]Change, error) {
+	changes, _, err := f.since(seq)
+	return changes, err
+}
+
+// since returns all changes after a given sequence and a channel that will be closed on the next publish.
+func (f *ChangeFeed) since(seq uint64) ([]Change, <-chan struct{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if seq > f.seq {
+		return nil, nil, fmt.Errorf("changes: sequence %d is in the future", seq)
+	}
+	n := int(f.seq - seq)
+	if n > len(f.log) {
+		return nil, nil, ErrChangesTruncated
+	}
+	out := append([]Change{}, f.log[len(f.log)-n:]...)
+	return out, f.notify, nil
+}
+
+// Watch calls fn for each batch with a sequence number greater than since, including batches that
+// will be committed in the future. It blocks until the context is cancelled or fn returns an error.
+//
+// Use Seq as since to only watch for new changes.
+func (f *ChangeFeed) Watch(ctx context.Context, since uint64, fn func(Change) error) error {
+	for {
+		changes, notify, err := f.since(since)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if err = fn(c); err != nil {
+				return err
+			}
+			since = c.Seq
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// Subscription delivers committed changes over a channel.
+type Subscription struct {
+	// C receives changes in the order of their sequence numbers. It is closed when the subscription ends.
+	C   <-chan Change
+	err error
+}
+
+// Err returns an error that stopped the subscription. It must be called only after C is closed.
+func (s *Subscription) Err() error {
+	if s.err == context.Canceled {
+		return nil
+	}
+	return s.err
+}
+
+// Subscribe is similar to Watch, but delivers changes over a channel.
+// The subscription ends when the context is cancelled.
+func (f *ChangeFeed) Subscribe(ctx context.Context, since uint64) *Subscription {
+	c := make(chan Change)
+	s := &Subscription{C: c}
+	go func() {
+		defer close(c)
+		s.err = f.Watch(ctx, since, func(ch Change) error {
+			select {
+			case c <- ch:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return s
+}
+
+// EffectiveDeltas returns deltas that will change the quad store if applied with given options.
+// Replace deltas are expanded (see ExpandReplace). Adds of existing quads and deletes of missing quads
+// are dropped if the options ignore them, unless the add refreshes the expiry time of the quad.
+//
+// It must be called before applying deltas.
+func EffectiveDeltas(qs QuadStore, deltas []Delta, opts IgnoreOpts) ([]Delta, error) {
+	deltas, err := ExpandReplace(deltas, func(s, p, l quad.Value) ([]quad.Value, error) {
+		return objectsOf(qs, s, p, l)
+	})
+	if err != nil || (!opts.IgnoreDup && !opts.IgnoreMissing) {
+		// without ignore options, a no-op delta fails the whole batch
+		return deltas, err
+	}
+	type key struct {
+		s, p, o, l refs.ValueHash
+	}
+	// state of quads changed earlier in the batch
+	exists := make(map[key]bool)
+	out := make([]Delta, 0, len(deltas))
+	for _, d := range deltas {
+		q := d.Quad
+		k := key{refs.HashOf(q.Subject), refs.HashOf(q.Predicate), refs.HashOf(q.Object), refs.HashOf(q.Label)}
+		ok, seen := exists[k]
+		if !seen && q.Subject != nil && q.Predicate != nil && q.Object != nil {
+			err = eachQuad(qs, q, func(quad.Quad) error {
+				ok = true
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		switch d.Action {
+		case Add:
+			if ok && opts.IgnoreDup && d.Expires.IsZero() {
+				continue
+			}
+			exists[k] = true
+		case Delete:
+			if !ok && opts.IgnoreMissing {
+				continue
+			}
+			exists[k] = false
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// objectsOf lists objects of all quads with a given subject, predicate and label in a quad store.
+func objectsOf(qs QuadStore, s, p, l quad.Value) ([]quad.Value, error) {
+	var out []quad.Value
+	err := eachQuad(qs, quad.Quad{Subject: s, Predicate: p, Label: l}, func(q quad.Quad) error {
+		out = append(out, q.Object)
+		return nil
+	})
+	return out, err
+}
+
+// eachQuad calls fn for all quads that have the same values as q. A nil subject, predicate or object
+// matches any value, while the label is always matched exactly.
+//
+// Only quads of the least frequent value of q are read, thus the cost does not depend on
+// the degree of the other values.
+func eachQuad(qs QuadStore, q quad.Quad, fn func(quad.Quad) error) error {
+	ctx := context.TODO()
+	var (
+		it   iterator.Shape
+		size int64
+	)
+	for _, d := range quad.Directions {
+		v := q.Get(d)
+		if v == nil {
+			continue
+		}
+		ref, err := qs.ValueOf(v)
+		if err != nil || ref == nil {
+			return err
+		}
+		sub := qs.QuadIterator(d, ref)
+		st, err := sub.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		if it == nil || st.Size.Value < size {
+			it, size = sub, st.Size.Value
+		}
+	}
+	if it == nil {
+		return fmt.Errorf("changes: no values to look up quads for: %v", q)
+	}
+	var hashes [4]refs.ValueHash
+	for _, d := range quad.Directions {
+		hashes[d-quad.Subject] = refs.HashOf(q.Get(d))
+	}
+	sc := it.Iterate()
+	defer sc.Close()
+	for sc.Next(ctx) {
+		cur, err := qs.Quad(sc.Result())
+		if err != nil {
+			return err
+		}
+		match := true
+		for _, d := range quad.Directions {
+			if (d == quad.Label || q.Get(d) != nil) && refs.HashOf(cur.Get(d)) != hashes[d-quad.Subject] {
+				match = false
+				break
+			}
+		}
+		if match {
+			if err = fn(cur); err != nil {
+				return err
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// ChangeSource is an optional interface for quad writers that publish committed changes.
+type ChangeSource interface {
+	// Changes returns a feed of changes committed by the writer.
+	Changes() *ChangeFeed
+}
+
+// Changes returns a feed of changes committed through the handle.
+// It returns ErrNoChangeFeed if the writer does not publish changes.
+func (h *Handle) Changes() (*ChangeFeed, error) {
+	if s, ok := h.QuadWriter.(ChangeSource); ok {
+		if f := s.Changes(); f != nil {
+			return f, nil
+		}
+	}
+	return nil, ErrNoChangeFeed
+}
+
+// Watch calls fn for each batch of changes committed through the handle after a given sequence number.
+// See ChangeFeed.Watch for details.
+func (h *Handle) Watch(ctx context.Context, since uint64, fn func(Change) error) error {
+	f, err := h.Changes()
+	if err != nil {
+		return err
+	}
+	return f.Watch(ctx, since, fn)
+}
+
+// Subscribe delivers batches of changes committed through the handle after a given sequence number over a channel.
+// See ChangeFeed.Subscribe for details.
+func (h *Handle) Subscribe(ctx context.Context, since uint64) (*Subscription, error) {
+	f, err := h.Changes()
+	if err != nil {
+		return nil, err
+	}
+	return f.Subscribe(ctx, since), nil
+}
@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/quad"
+)
+
+func TestChangeFeed(t *testing.T) {
+	f := NewChangeFeed(3)
+	d := func(s string) []Delta {
+		return []Delta{{Quad: quad.MakeRaw(s, "p", "o", ""), Action: Add}}
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		f.Publish(d(s))
+	}
+	if n := f.Seq(); n != 3 {
+		t.Fatalf("unexpected sequence: %d", n)
+	}
+	changes, err := f.Since(1)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 2 || changes[0].Seq != 2 || changes[1].Seq != 3 {
+		t.Fatalf("unexpected changes: %v", changes)
+	}
+	f.Publish(d("d"))
+	if _, err = f.Since(0); err != ErrChangesTruncated {
+		t.Fatalf("expected truncation, got: %v", err)
+	}
+	if _, err = f.Since(5); err == nil {
+		t.Fatal("expected an error for a future sequence")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := f.Subscribe(ctx, 3)
+	if c := <-sub.C; c.Seq != 4 || c.Deltas[0].Quad.Subject != quad.Raw("d") {
+		t.Fatalf("unexpected change: %v", c)
+	}
+	f.Publish(d("e"))
+	if c := <-sub.C; c.Seq != 5 {
+		t.Fatalf("unexpected change: %v", c)
+	}
+	cancel()
+	for range sub.C {
+	}
+	if err = sub.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cayleygraph/quad"
+)
+
+var ErrExpiryUnsupported = errors.New("quadstore: expiring quads are not supported")
+
+// Expirer is an optional interface for quad stores that support expiring quads.
+//
+// A quad is added with an expiry time by setting Delta.Expires. Once this time passes, the quad becomes
+// invisible to iterators, but it is still kept in the store. Expired quads must be removed with regular
+// Delete deltas, so that changes feeds, history and stats observe them. This is done by the quad writer
+// in the background, concurrently with queries, thus the store must be safe for concurrent use.
+//
+// Adding an expiring quad that already exists is not an error, it sets a new expiry time for the quad.
+// Adding a regular quad that already exists does not change the expiry time, unless the quad has already
+// expired, in which case it becomes permanent. Expired quads are considered missing by preconditions
+// and duplicate checks.
+type Expirer interface {
+	// ExpiredQuads returns up to n quads that expired at a given time.
+	ExpiredQuads(ctx context.Context, now time.Time, n int) ([]quad.Quad, error)
+	// NextExpiry returns the earliest expiry time of quads in the store.
+	// It returns false if there are no expiring quads.
+	NextExpiry(ctx context.Context) (time.Time, bool, error)
+}
+
+// HasExpiring checks if any of the deltas adds an expiring quad.
+func HasExpiring(deltas []Delta) bool {
+	for _, d := range deltas {
+		if !d.Expires.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExpiry returns ErrExpiryUnsupported if deltas add expiring quads and the store cannot expire them.
+func checkExpiry(qs QuadStore, deltas []Delta) error {
+	if !HasExpiring(deltas) {
+		return nil
+	} else if _, ok := Unwrap(qs).(Expirer); !ok {
+		return ErrExpiryUnsupported
+	}
+	return nil
+}
+
+// NewExpiringWriter creates a quad writer for a given QuadStore which adds quads that expire after a given duration.
+// The expiry time is calculated when the quads are flushed.
+func NewExpiringWriter(qs QuadWriter, ttl time.Duration) BatchWriter {
+	return &expiringWriter{qs: qs, ttl: ttl}
+}
+
+type expiringWriter struct {
+	qs  QuadWriter
+	ttl time.Duration
+}
+
+func (w *expiringWriter) WriteQuad(q quad.Quad) error {
+	_, err := w.WriteQuads([]quad.Quad{q})
+	return err
+}
+func (w *expiringWriter) WriteQuads(quads []quad.Quad) (int, error) {
+	tx := NewTransactionN(len(quads))
+	expires := time.Now().Add(w.ttl)
+	for _, q := range quads {
+		tx.AddExpiringQuad(q, expires)
+	}
+	if err := w.qs.ApplyTransaction(tx); err != nil {
+		return 0, err
+	}
+	return len(quads), nil
+}
+func (w *expiringWriter) Flush() error { return nil }
+func (w *expiringWriter) Close() error { return nil }
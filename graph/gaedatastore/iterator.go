@@ -221,10 +221,6 @@ func (it *iteratorNext) Close() error {
 
 func (it *iteratorNext) TagResults(dst map[string]graph.Ref) {}
 
-func (it *iteratorNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *iteratorNext) Result() graph.Ref {
 	return it.result
 }
@@ -416,10 +412,6 @@ func (it *iteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 
 func (it *iteratorContains) TagResults(dst map[string]graph.Ref) {}
 
-func (it *iteratorContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *iteratorContains) Result() graph.Ref {
 	return it.result
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"os"
 	"sort"
 	"testing"
 	"time"
@@ -36,6 +37,10 @@ type Config struct {
 
 	SkipDeletedFromIterator  bool
 	SkipSizeCheckAfterDelete bool
+
+	Versioned bool // quad store records history, see graph.Historian
+
+	NativeBatches bool // scanners of quad store iterators implement iterator.BatchScanner
 }
 
 var graphTests = []struct {
@@ -50,6 +55,11 @@ var graphTests = []struct {
 	{"sizes", TestSizes},
 	{"iterator", TestIterator},
 	{"hasa", TestHasA},
+	{"batch iterator", TestBatchIterator},
+	{"parallel iterator", TestParallelIterator},
+	{"spill iterator", TestSpillIterator},
+	{"join iterator", TestJoinIterator},
+	{"direction stats", TestDirectionStats},
 	{"set iterator", TestSetIterator},
 	{"deleted from iterator", TestDeletedFromIterator},
 	{"load typed quad", TestLoadTypedQuads},
@@ -74,6 +84,20 @@ func TestAll(t *testing.T, gen testutil.DatabaseFunc, conf *Config) {
 	t.Run("writers", func(t *testing.T) {
 		TestWriters(t, gen, conf)
 	})
+	t.Run("preconditions", func(t *testing.T) {
+		TestPreconditions(t, gen, conf)
+	})
+	t.Run("replace", func(t *testing.T) {
+		TestReplace(t, gen, conf)
+	})
+	t.Run("expiry", func(t *testing.T) {
+		TestExpiry(t, gen, conf)
+	})
+	if conf.Versioned {
+		t.Run("as of", func(t *testing.T) {
+			TestAsOf(t, gen, conf)
+		})
+	}
 	t.Run("1k", func(t *testing.T) {
 		t.Run("tx", func(t *testing.T) {
 			Test1K(t, gen, conf)
@@ -415,6 +439,260 @@ func TestWriters(t *testing.T, gen testutil.DatabaseFunc, c *Config) {
 	}
 }
 
+func TestPreconditions(t *testing.T, gen testutil.DatabaseFunc, c *Config) {
+	qs, _ := gen(t)
+	if _, ok := qs.(graph.ConditionalApplier); !ok {
+		t.Skip("preconditions are not supported")
+	}
+	w, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	s, p, l := quad.IRI("a"), quad.IRI("name"), quad.IRI("g")
+	q1 := quad.Make(s, p, "bob", nil)
+	q2 := quad.Make(s, p, "alice", nil)
+	quads := func(arr ...quad.Quad) {
+		ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), arr, false)
+	}
+	failed := func(err error) {
+		require.True(t, graph.IsPreconditionFailed(err), "expected failed precondition, got: %v", err)
+		e, ok := err.(*graph.DeltaError)
+		require.True(t, ok, "expected delta error, got: %T (%v)", err, err)
+		require.NotNil(t, e.Cond)
+	}
+
+	// set a value only if it's not set
+	tx := graph.NewTransaction()
+	tx.RequireNoValue(s, p, nil)
+	tx.AddQuad(q1)
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+	quads(q1)
+
+	err = w.ApplyTransaction(tx)
+	failed(err)
+	quads(q1)
+
+	// value in a different graph is not affected
+	tx = graph.NewTransaction()
+	tx.RequireNoValue(s, p, l)
+	tx.RequireQuad(q1)
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+
+	// compare-and-set
+	tx = graph.NewTransaction()
+	tx.RequireValue(s, p, quad.String("alice"), nil)
+	tx.RemoveQuad(q1)
+	tx.AddQuad(q2)
+	err = w.ApplyTransaction(tx)
+	failed(err)
+	quads(q1)
+
+	tx = graph.NewTransaction()
+	tx.RequireValue(s, p, quad.String("bob"), nil)
+	tx.RemoveQuad(q1)
+	tx.AddQuad(q2)
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+	quads(q2)
+
+	// quad must exist
+	tx = graph.NewTransaction()
+	tx.RequireQuad(q1)
+	tx.AddQuad(quad.Make("b", "c", "d", nil))
+	err = w.ApplyTransaction(tx)
+	failed(err)
+	quads(q2)
+
+	// multiple values
+	err = w.AddQuad(q1)
+	require.NoError(t, err)
+	tx = graph.NewTransaction()
+	tx.RequireValue(s, p, quad.String("bob"), nil)
+	err = w.ApplyTransaction(tx)
+	failed(err)
+}
+
+func TestReplace(t *testing.T, gen testutil.DatabaseFunc, c *Config) {
+	qs, _ := gen(t)
+	w, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	rw := w.(graph.QuadReplacer)
+
+	quads := func(arr ...quad.Quad) {
+		ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), arr, true)
+	}
+
+	s, p := quad.IRI("a"), quad.IRI("name")
+	other := quad.Make(s, "age", 30, nil)
+	inGraph := quad.Make(s, p, "carol", "g")
+	err = w.AddQuadSet([]quad.Quad{
+		quad.Make(s, p, "bob", nil),
+		quad.Make(s, p, "bobby", nil),
+		other, inGraph,
+	})
+	require.NoError(t, err)
+
+	q := quad.Make(s, p, "alice", nil)
+	err = rw.ReplaceQuad(q)
+	if graph.IsInvalidAction(err) {
+		t.Skip("replace is not supported")
+	}
+	require.NoError(t, err)
+	quads(q, other, inGraph)
+
+	// replace with the same value
+	err = rw.ReplaceQuad(q)
+	require.NoError(t, err)
+	quads(q, other, inGraph)
+
+	// set a new value
+	q2 := quad.Make("b", p, "dave", nil)
+	err = rw.ReplaceQuad(q2)
+	require.NoError(t, err)
+	quads(q, q2, other, inGraph)
+
+	// only the last replace in a transaction takes effect
+	q3 := quad.Make(s, p, "eve", nil)
+	tx := graph.NewTransaction()
+	tx.ReplaceQuad(quad.Make(s, p, "mallory", nil))
+	tx.ReplaceQuad(q3)
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+	quads(q3, q2, other, inGraph)
+
+	// replace overrides values added or removed earlier in a transaction
+	feed := w.(graph.ChangeSource).Changes()
+	since := feed.Seq()
+	q4 := quad.Make(s, p, "grace", nil)
+	tx = graph.NewTransaction()
+	tx.AddQuad(quad.Make(s, p, "frank", nil))
+	tx.ReplaceQuad(q4)
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+	quads(q4, q2, other, inGraph)
+
+	tx = graph.NewTransaction()
+	tx.RemoveQuad(q4)
+	tx.ReplaceQuad(q4)
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+	quads(q4, q2, other, inGraph)
+
+	// only effective deltas are published
+	w2, err := writer.NewSingle(qs, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+	require.NoError(t, err)
+	defer w2.Close()
+	err = w2.AddQuad(q4)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), w2.(graph.ChangeSource).Changes().Seq())
+
+	changes, err := feed.Since(since)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, []graph.Delta{
+		{Action: graph.Delete, Quad: q3},
+		{Action: graph.Add, Quad: q4},
+	}, changes[0].Deltas)
+}
+
+func TestExpiry(t *testing.T, gen testutil.DatabaseFunc, c *Config) {
+	qs, _ := gen(t)
+	exp, ok := qs.(graph.Expirer)
+	if !ok {
+		t.Skip("expiring quads are not supported")
+	}
+	ctx := context.TODO()
+
+	quads := func(arr ...quad.Quad) {
+		ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), arr, true)
+	}
+	expired := func(arr ...quad.Quad) {
+		got, err := exp.ExpiredQuads(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		require.ElementsMatch(t, arr, got)
+	}
+
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	s := quad.IRI("a")
+	q1 := quad.Make(s, "name", "bob", nil)
+	q2 := quad.Make(s, "session", "1", nil)
+	q3 := quad.Make(s, "session", "2", nil)
+	err := qs.ApplyDeltas([]graph.Delta{
+		{Quad: q1, Action: graph.Add},
+		{Quad: q2, Action: graph.Add, Expires: past},
+		{Quad: q3, Action: graph.Add, Expires: future},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	quads(q1, q3)
+	expired(q2)
+
+	ref, err := qs.ValueOf(s)
+	require.NoError(t, err)
+	ExpectIteratedQuads(t, qs, qs.QuadIterator(quad.Subject, ref), []quad.Quad{q1, q3}, true)
+
+	next, ok, err := exp.NextExpiry(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, next.Equal(past), "%v vs %v", next, past)
+
+	// expired quad is considered missing, adding it again makes it permanent
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: q2, Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	quads(q1, q2, q3)
+	expired()
+
+	// adding an existing quad with an expiry time sets a new expiry time
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: q1, Action: graph.Add, Expires: past}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	quads(q2, q3)
+	expired(q1)
+
+	// expired quads are removed with regular deletes
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: q1, Action: graph.Delete}}, graph.IgnoreOpts{IgnoreMissing: true})
+	require.NoError(t, err)
+	expired()
+	next, ok, err = exp.NextExpiry(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, next.Equal(future), "%v vs %v", next, future)
+
+	// writer removes expired quads in background and publishes deletes
+	w, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	defer w.Close()
+	feed := w.(graph.ChangeSource).Changes()
+
+	q4 := quad.Make(s, "session", "3", nil)
+	tx := graph.NewTransaction()
+	tx.AddExpiringQuad(q4, future)
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+	quads(q2, q3, q4)
+
+	// adding an expiring quad again sets a new expiry time
+	since := feed.Seq()
+	tx = graph.NewTransaction()
+	tx.AddExpiringQuad(q4, time.Now().Add(100*time.Millisecond))
+	err = w.ApplyTransaction(tx)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	errFound := fmt.Errorf("found")
+	err = feed.Watch(ctx, since, func(c graph.Change) error {
+		for _, d := range c.Deltas {
+			if d.Action == graph.Delete && d.Quad == q4 {
+				return errFound
+			}
+		}
+		return nil
+	})
+	require.Equal(t, errFound, err)
+	quads(q2, q3)
+	expired()
+}
+
 func Test1K(t *testing.T, gen testutil.DatabaseFunc, c *Config) {
 	qs, _ := gen(t)
 
@@ -637,6 +915,275 @@ func TestHasA(t testing.TB, gen testutil.DatabaseFunc, conf *Config) {
 	ExpectIteratedValues(t, qs, it, exp, false)
 }
 
+func TestBatchIterator(t testing.TB, gen testutil.DatabaseFunc, conf *Config) {
+	ctx := context.TODO()
+	qs, opts := gen(t)
+
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	iterated := func(s iterator.Shape, quads bool, size int) []string {
+		it := iterator.AsBatchScanner(s.Iterate())
+		defer it.Close()
+		var res []string
+		buf := make([]refs.Ref, max(size, -size))
+		add := func(r refs.Ref) {
+			if quads {
+				q, err := qs.Quad(r)
+				require.NoError(t, err)
+				res = append(res, q.String())
+				return
+			}
+			v, err := qs.NameOf(r)
+			require.NoError(t, err)
+			res = append(res, quad.ToString(v))
+		}
+		if size == 0 {
+			for it.Next(ctx) {
+				add(it.Result())
+			}
+		} else if size < 0 {
+			// alternate between Next and NextBatch
+			buf = buf[:-size]
+			for i := 0; ; i++ {
+				if i%2 == 0 {
+					if !it.Next(ctx) {
+						break
+					}
+					add(it.Result())
+					continue
+				}
+				n := it.NextBatch(ctx, buf)
+				if n == 0 {
+					break
+				}
+				for _, r := range buf[:n] {
+					add(r)
+				}
+			}
+		} else {
+			for n := it.NextBatch(ctx, buf); n > 0; n = it.NextBatch(ctx, buf) {
+				require.True(t, n <= size)
+				for _, r := range buf[:n] {
+					add(r)
+				}
+			}
+		}
+		require.NoError(t, it.Err())
+		sort.Strings(res)
+		return res
+	}
+
+	fixed := func(vals ...quad.Value) iterator.Shape {
+		f := iterator.NewFixed()
+		for _, v := range vals {
+			ref, err := qs.ValueOf(v)
+			require.NoError(t, err)
+			f.Add(ref)
+		}
+		return f
+	}
+
+	cases := []struct {
+		name  string
+		quads bool
+		leaf  bool
+		shape func() iterator.Shape
+	}{
+		{"quads", true, true, func() iterator.Shape { return qs.QuadsAllIterator() }},
+		{"nodes", false, true, func() iterator.Shape { return qs.NodesAllIterator() }},
+		{"quad iterator", true, true, func() iterator.Shape {
+			ref, err := qs.ValueOf(quad.Raw("follows"))
+			require.NoError(t, err)
+			return qs.QuadIterator(quad.Predicate, ref)
+		}},
+		{"hasa", false, false, func() iterator.Shape {
+			return graph.NewHasA(qs, graph.NewLinksTo(qs, fixed(quad.Raw("B"), quad.Raw("D")), quad.Object), quad.Subject)
+		}},
+		{"and", false, false, func() iterator.Shape {
+			return iterator.NewAnd(
+				graph.NewHasA(qs, graph.NewLinksTo(qs, fixed(quad.Raw("follows")), quad.Predicate), quad.Subject),
+				graph.NewHasA(qs, graph.NewLinksTo(qs, fixed(quad.Raw("status")), quad.Predicate), quad.Subject),
+			)
+		}},
+	}
+	for _, c := range cases {
+		if c.leaf && conf.NativeBatches {
+			it := c.shape().Iterate()
+			_, ok := it.(iterator.BatchScanner)
+			require.True(t, ok, "%s: %T doesn't implement iterator.BatchScanner", c.name, it)
+			it.Close()
+		}
+		expect := iterated(c.shape(), c.quads, 0)
+		require.NotEmpty(t, expect, c.name)
+		for _, size := range []int{1, 3, iterator.DefaultBatchSize, -1, -3} {
+			require.Equal(t, expect, iterated(c.shape(), c.quads, size), "%s, batch size: %d", c.name, size)
+		}
+	}
+}
+
+func TestParallelIterator(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts := gen(t)
+
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	iterated := func(ctx context.Context, s iterator.Shape) []string {
+		var res []string
+		err := iterator.Iterate(ctx, s).On(qs).TagValues(qs, func(m map[string]quad.Value) error {
+			res = append(res, fmt.Sprint(m))
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Strings(res)
+		return res
+	}
+
+	follows := func(dir, other quad.Direction, tag string) iterator.Shape {
+		ref, err := qs.ValueOf(quad.Raw("follows"))
+		require.NoError(t, err)
+		fixed := iterator.NewFixed(ref)
+		return graph.NewHasA(qs, iterator.NewAnd(
+			graph.NewLinksTo(qs, fixed, quad.Predicate),
+			graph.NewLinksTo(qs, iterator.Tag(qs.NodesAllIterator(), tag), other),
+		), dir)
+	}
+
+	cases := []struct {
+		name  string
+		shape func() iterator.Shape
+	}{
+		{"or", func() iterator.Shape {
+			return iterator.NewOr(follows(quad.Subject, quad.Object, "to"), follows(quad.Object, quad.Subject, "from"), qs.NodesAllIterator())
+		}},
+		{"and", func() iterator.Shape {
+			return iterator.NewAnd(qs.NodesAllIterator(), follows(quad.Subject, quad.Object, "to"), follows(quad.Object, quad.Subject, "from"))
+		}},
+	}
+	for _, c := range cases {
+		expect := iterated(context.TODO(), c.shape())
+		require.NotEmpty(t, expect, c.name)
+		got := iterated(iterator.WithParallel(context.TODO(), 4), c.shape())
+		require.Equal(t, expect, got, c.name)
+	}
+}
+
+func TestSpillIterator(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts := gen(t)
+
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	iterated := func(ctx context.Context, s iterator.Shape) []string {
+		var res []string
+		err := iterator.Iterate(ctx, s).On(qs).TagValues(qs, func(m map[string]quad.Value) error {
+			res = append(res, fmt.Sprint(m))
+			return nil
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	nodes := func(dir quad.Direction, tag string) iterator.Shape {
+		return iterator.Tag(graph.NewHasA(qs, qs.QuadsAllIterator(), dir), tag)
+	}
+
+	cases := []struct {
+		name  string
+		shape func() iterator.Shape
+	}{
+		{"sort", func() iterator.Shape {
+			return iterator.NewSort(qs, iterator.NewOr(nodes(quad.Subject, "s"), nodes(quad.Object, "o"), qs.NodesAllIterator()))
+		}},
+		{"unique", func() iterator.Shape {
+			return iterator.NewUnique(iterator.NewOr(nodes(quad.Object, "o"), nodes(quad.Subject, "s")))
+		}},
+	}
+	for _, c := range cases {
+		expect := iterated(context.TODO(), c.shape())
+		require.NotEmpty(t, expect, c.name)
+
+		b := iterator.NewMemoryBudget(256)
+		b.Dir = t.TempDir()
+		got := iterated(iterator.WithMemoryBudget(context.TODO(), b), c.shape())
+		require.Equal(t, expect, got, c.name)
+		require.Equal(t, int64(0), b.Used(), c.name)
+		files, err := os.ReadDir(b.Dir)
+		require.NoError(t, err)
+		require.Empty(t, files, "%s: temporary files were not removed", c.name)
+	}
+}
+
+func TestJoinIterator(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts := gen(t)
+
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	quads := func(d quad.Direction, v quad.Value) iterator.Shape {
+		ref, err := qs.ValueOf(v)
+		require.NoError(t, err)
+		return qs.QuadIterator(d, ref)
+	}
+	pred := func() iterator.Shape { return quads(quad.Predicate, quad.Raw("follows")) }
+	obj := func() iterator.Shape { return quads(quad.Object, quad.Raw("B")) }
+
+	expect := IteratedQuads(t, qs, iterator.NewAnd(obj(), pred()))
+	require.Len(t, expect, 3)
+
+	ExpectIteratedQuads(t, qs, iterator.NewHashJoin(obj(), pred()), expect, true)
+	ExpectIteratedQuads(t, qs, iterator.NewHashJoin(pred(), obj()), expect, true)
+
+	if o := iterator.OrderOf(obj()); o != nil && o == iterator.OrderOf(pred()) {
+		ExpectIteratedQuads(t, qs, iterator.NewMergeJoin(o, obj(), pred()), expect, true)
+	}
+}
+
+func TestDirectionStats(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts := gen(t)
+	st, ok := qs.(graph.Statistician)
+	if !ok {
+		t.SkipNow()
+	}
+
+	quads := MakeQuadSet()
+	testutil.MakeWriter(t, qs, opts, quads...)
+
+	ctx := context.TODO()
+	for _, d := range []quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label} {
+		counts := make(map[string]int64)
+		var total int64
+		for _, q := range quads {
+			if v := q.Get(d); v != nil {
+				counts[v.String()]++
+				total++
+			}
+		}
+		ds, err := st.DirectionStats(ctx, d, true)
+		require.NoError(t, err)
+		if ds.Values.Value == 0 {
+			// not supported for this direction
+			require.Empty(t, ds.Top, "%v", d)
+			continue
+		}
+		require.Equal(t, refs.Size{Value: total, Exact: true}, ds.Quads, "%v", d)
+		require.Equal(t, refs.Size{Value: int64(len(counts)), Exact: true}, ds.Values, "%v", d)
+		require.NotEmpty(t, ds.Top, "%v", d)
+		for i, v := range ds.Top {
+			if i > 0 {
+				require.True(t, ds.Top[i-1].Quads >= v.Quads, "%v: top values are not sorted", d)
+			}
+			name, err := qs.NameOf(v.Value)
+			require.NoError(t, err)
+			require.Equal(t, counts[name.String()], v.Quads, "%v: %v", d, name)
+		}
+	}
+
+	// exact statistics must include new quads, even if they are cached
+	before, err := st.DirectionStats(ctx, quad.Predicate, true)
+	require.NoError(t, err)
+	testutil.MakeWriter(t, qs, opts, quad.MakeIRI("stats", "follows", "bob", ""))
+	after, err := st.DirectionStats(ctx, quad.Predicate, true)
+	require.NoError(t, err)
+	require.Equal(t, before.Quads.Value+1, after.Quads.Value)
+}
+
 func TestSetIterator(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
 	qs, opts := gen(t)
 
@@ -994,34 +1541,29 @@ func TestIteratorsAndNextResultOrderA(t testing.TB, gen testutil.DatabaseFunc, c
 	)
 
 	hasa := graph.NewHasA(qs, innerAnd, quad.Subject)
-	outerAnd := iterator.NewAnd(fixed, hasa).Iterate()
-
-	require.True(t, outerAnd.Next(ctx), "Expected one matching subtree")
-
-	val := outerAnd.Result()
-	qsn, err := qs.NameOf(val)
-	require.NoError(t, err)
-	require.Equal(t, quad.Raw("C"), qsn)
+	// each path of the only matching subtree is returned as a separate result
+	outerAnd := iterator.Bindings(iterator.NewAnd(fixed, hasa).Iterate())
+	defer outerAnd.Close()
 
 	var (
 		got    []string
 		expect = []string{"B", "D"}
 	)
-	for {
+	for outerAnd.Next(ctx) {
+		qsn, err := qs.NameOf(outerAnd.Result())
+		require.NoError(t, err)
+		require.Equal(t, quad.Raw("C"), qsn)
+
 		m := make(map[string]graph.Ref, 1)
 		outerAnd.TagResults(m)
 		qsn, err = qs.NameOf(m[allTag])
 		require.NoError(t, err)
 		got = append(got, quad.ToString(qsn))
-		if !outerAnd.NextPath(ctx) {
-			break
-		}
 	}
+	require.NoError(t, outerAnd.Err())
 	sort.Strings(got)
 
 	require.Equal(t, expect, got)
-
-	require.True(t, !outerAnd.Next(ctx), "More than one possible top level output?")
 }
 
 const lt, lte, gt, gte = iterator.CompareLT, iterator.CompareLTE, iterator.CompareGT, iterator.CompareGTE
@@ -1287,6 +1829,70 @@ func TestDeleteReinsertedDup(t testing.TB, gen testutil.DatabaseFunc, _ *Config)
 	}
 }
 
+// TestAsOf checks that a versioned quad store can be queried as it was at a past time.
+func TestAsOf(t *testing.T, gen testutil.DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	qs, opts := gen(t)
+
+	// make sure all changes get distinct timestamps
+	now := func() time.Time {
+		time.Sleep(10 * time.Millisecond)
+		t := time.Now()
+		time.Sleep(10 * time.Millisecond)
+		return t
+	}
+	q := quad.Make("E", "follows", "F", nil)
+
+	t0 := now()
+	w := testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	t1 := now()
+	require.NoError(t, w.RemoveQuad(q))
+	t2 := now()
+	require.NoError(t, w.AddQuad(quad.Make("E", "follows", "G", nil)))
+	t3 := now()
+	require.NoError(t, w.AddQuad(q))
+
+	follows := func(qs graph.QuadStore) []quad.Value {
+		return IteratedValues(t, qs, shape.BuildIterator(ctx, qs, shape.NodesFrom{
+			Dir: quad.Object,
+			Quads: shape.Quads{
+				{Dir: quad.Subject, Values: shape.Lookup{quad.String("E")}},
+			},
+		}))
+	}
+	for _, c := range []struct {
+		name   string
+		t      time.Time
+		expect []quad.Value
+		quads  int64
+	}{
+		{"before", t0, nil, 0},
+		{"loaded", t1, []quad.Value{quad.String("F")}, 11},
+		{"deleted", t2, nil, 10},
+		{"added", t3, []quad.Value{quad.String("G")}, 11},
+		{"re-added", time.Now(), []quad.Value{quad.String("F"), quad.String("G")}, 12},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			snap, err := graph.AsOf(ctx, qs, graph.Version{Time: c.t})
+			require.NoError(t, err)
+			require.Equal(t, c.expect, follows(snap))
+
+			st, err := snap.Stats(ctx, true)
+			require.NoError(t, err)
+			require.Equal(t, c.quads, st.Quads.Value)
+
+			e, err := snap.ValueOf(quad.String("E"))
+			require.NoError(t, err)
+			require.Equal(t, c.expect != nil, e != nil, "node without quads must not be visible")
+
+			err = snap.ApplyDeltas([]graph.Delta{
+				{Quad: quad.Make("A", "follows", "E", nil), Action: graph.Add},
+			}, graph.IgnoreOpts{})
+			require.Equal(t, graph.ErrReadOnly, err)
+		})
+	}
+}
+
 func irif(format string, args ...interface{}) quad.IRI {
 	return quad.IRI(fmt.Sprintf(format, args...))
 }
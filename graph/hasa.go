@@ -27,9 +27,9 @@ package graph
 // value to check, it means "Check all predicates that have this value for your
 // direction against the subiterator." This would imply that there's more than
 // one possibility for the same Contains()ed value. While we could return the
-// number of options, it's simpler to return one, and then call NextPath()
-// enough times to enumerate the options. (In fact, one could argue that the
-// raison d'etre for NextPath() is this iterator).
+// number of options, it's simpler to return one, and then list the other
+// options with Paths(). (In fact, one could argue that the raison d'etre for
+// Paths() is this iterator).
 //
 // Alternatively, can be seen as the dual of the LinksTo iterator.
 
@@ -75,6 +75,15 @@ func (it *HasA) SubIterators() []iterator.Shape {
 	return []iterator.Shape{it.primary}
 }
 
+var _ iterator.Composite = (*HasA)(nil)
+
+// MapSubIterators implements iterator.Composite.
+func (it *HasA) MapSubIterators(m iterator.Morphism) iterator.Shape {
+	nit := *it
+	nit.primary = m(it.primary)
+	return &nit
+}
+
 // Direction accessor.
 func (it *HasA) Direction() quad.Direction { return it.dir }
 
@@ -109,11 +118,19 @@ func (it *HasA) Stats(ctx context.Context) (iterator.Costs, error) {
 	fanoutFactor := int64(30)
 	nextConstant := int64(2)
 	quadConstant := int64(1)
+	size := faninFactor * subitStats.Size.Value
+	if ds, ok := StatsOf(ctx, it.qs, it.dir); ok {
+		// each node is checked by scanning its quads; there can't be more nodes than distinct values
+		fanoutFactor = ds.Fanout()
+		if size > ds.Values.Value {
+			size = ds.Values.Value
+		}
+	}
 	return iterator.Costs{
 		NextCost:     quadConstant + subitStats.NextCost,
 		ContainsCost: (fanoutFactor * nextConstant) * subitStats.ContainsCost,
 		Size: refs.Size{
-			Value: faninFactor * subitStats.Size.Value,
+			Value: size,
 			Exact: false,
 		},
 	}, err
@@ -152,9 +169,9 @@ func (it *hasANext) String() string {
 	return fmt.Sprintf("HasANext(%v)", it.dir)
 }
 
-// Get the next result that matches this branch.
-func (it *hasANext) NextPath(ctx context.Context) bool {
-	return it.primary.NextPath(ctx)
+// Paths lists other paths of the current quad.
+func (it *hasANext) Paths(ctx context.Context) iterator.Scanner {
+	return iterator.SubPaths(ctx, it.primary, it.result, nil)
 }
 
 // Next advances the iterator. This is simpler than Contains. We have a
@@ -173,6 +190,23 @@ func (it *hasANext) Next(ctx context.Context) bool {
 	return true
 }
 
+// NextBatch is similar to Next, but resolves a batch of quads from the subiterator at once.
+func (it *hasANext) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	if it.err != nil {
+		return 0
+	}
+	n := iterator.NextBatch(ctx, it.primary, buf)
+	for i, q := range buf[:n] {
+		v, err := it.qs.QuadDirection(q, it.dir)
+		if err != nil {
+			it.err = err
+			return i
+		}
+		buf[i] = v
+	}
+	return n
+}
+
 func (it *hasANext) Err() error {
 	if it.err != nil {
 		return it.err
@@ -274,33 +308,77 @@ func (it *hasAContains) nextContains(ctx context.Context) bool {
 	return false
 }
 
-// Get the next result that matches this branch.
-func (it *hasAContains) NextPath(ctx context.Context) bool {
-	// Order here is important. If the subiterator has a NextPath, then we
-	// need do nothing -- there is a next result, and we shouldn't move forward.
-	// However, we then need to get the next result from our last Contains().
-	//
-	// The upshot is, the end of NextPath() bubbles up from the bottom of the
-	// iterator tree up, and we need to respect that.
-	if clog.V(4) {
-		clog.Infof("HASA %p NextPath", it)
+// Paths lists other results that match this branch for the last checked value.
+//
+// Order here is important. Other paths of the current quad are listed first,
+// and only then we get the next result from our last Contains(). The returned
+// scanner advances the HasA.
+func (it *hasAContains) Paths(ctx context.Context) iterator.Scanner {
+	return &hasAPaths{it: it, sub: iterator.Paths(ctx, it.primary)}
+}
+
+// hasAPaths lists paths of a HasA for the last checked value.
+type hasAPaths struct {
+	it  *hasAContains
+	sub iterator.Scanner // other paths of the current quad
+	cur iterator.Base
+	err error
+}
+
+func (p *hasAPaths) Next(ctx context.Context) bool {
+	if p.err != nil {
+		return false
+	}
+	if p.sub != nil {
+		if p.sub.Next(ctx) {
+			p.cur = p.sub
+			return true
+		}
+		p.err = p.sub.Err()
+		p.sub.Close()
+		p.sub = nil
+		if p.err != nil {
+			return false
+		}
 	}
-	if it.primary.NextPath(ctx) {
-		return true
+	if clog.V(4) {
+		clog.Infof("HASA %p Paths", p.it)
 	}
-	it.err = it.primary.Err()
-	if it.err != nil {
+	if !p.it.nextContains(ctx) { // Sets it.err if there's an error
 		return false
 	}
+	p.cur = p.it.primary
+	p.sub = iterator.Paths(ctx, p.it.primary)
+	return true
+}
 
-	result := it.nextContains(ctx) // Sets it.err if there's an error
-	if it.err != nil {
-		return false
+func (p *hasAPaths) Result() refs.Ref {
+	return p.it.result
+}
+
+func (p *hasAPaths) TagResults(dst map[string]refs.Ref) {
+	if p.cur != nil {
+		p.cur.TagResults(dst)
 	}
-	if clog.V(4) {
-		clog.Infof("HASA %p NextPath Returns %v", it, result)
+}
+
+func (p *hasAPaths) Err() error {
+	if p.err != nil {
+		return p.err
 	}
-	return result
+	return p.it.err
+}
+
+func (p *hasAPaths) Close() error {
+	if p.sub != nil {
+		p.sub.Close()
+		p.sub = nil
+	}
+	return nil
+}
+
+func (p *hasAPaths) String() string {
+	return fmt.Sprintf("HasAPaths(%v)", p.it.dir)
 }
 
 func (it *hasAContains) Err() error {
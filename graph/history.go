@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNoHistory = errors.New("quadstore: history is not recorded")
+	ErrReadOnly  = errors.New("quadstore: read-only snapshot")
+)
+
+// Version identifies a past state of the graph, either by time or by horizon (transaction id).
+// Zero value means the current state.
+type Version struct {
+	// Time selects the state of the graph at a given point in time.
+	Time time.Time
+	// Horizon selects the state of the graph right after applying a transaction with a given horizon.
+	// It takes precedence over Time.
+	Horizon int64
+}
+
+// IsZero checks if the version refers to the current state of the graph.
+func (v Version) IsZero() bool {
+	return v.Horizon == 0 && v.Time.IsZero()
+}
+
+// Includes checks if a change made in a transaction with a given horizon and time is visible in this version.
+func (v Version) Includes(horizon int64, t time.Time) bool {
+	if v.IsZero() {
+		return true
+	} else if v.Horizon != 0 {
+		return horizon <= v.Horizon
+	}
+	return !t.After(v.Time)
+}
+
+func (v Version) String() string {
+	if v.Horizon != 0 {
+		return strconv.FormatInt(v.Horizon, 10)
+	} else if !v.Time.IsZero() {
+		return v.Time.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+// ParseVersion parses a version from a string. It accepts an integer horizon, an RFC 3339 timestamp or a date.
+func ParseVersion(s string) (Version, error) {
+	if s == "" {
+		return Version{}, nil
+	}
+	if h, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if h <= 0 {
+			return Version{}, fmt.Errorf("invalid horizon: %d", h)
+		}
+		return Version{Horizon: h}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if layout == "2006-01-02" {
+				// the whole day is included
+				t = t.Add(24*time.Hour - time.Nanosecond)
+			}
+			return Version{Time: t}, nil
+		}
+	}
+	return Version{}, fmt.Errorf("invalid version: %q; expected a horizon, RFC 3339 time or a date", s)
+}
+
+// Historian is an optional interface for quad stores that keep a history of changes.
+type Historian interface {
+	// AsOf returns a read-only view of the graph as it was at a given version.
+	AsOf(ctx context.Context, v Version) (QuadStore, error)
+}
+
+// AsOf returns a read-only view of the graph as it was at a given version.
+// It returns the quad store itself for a zero version and ErrNoHistory if the quad store does not record history.
+func AsOf(ctx context.Context, qs QuadStore, v Version) (QuadStore, error) {
+	if v.IsZero() {
+		return qs, nil
+	}
+	h, ok := Unwrap(qs).(Historian)
+	if !ok {
+		return nil, ErrNoHistory
+	}
+	return h.AsOf(ctx, v)
+}
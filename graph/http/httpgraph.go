@@ -10,3 +10,19 @@ type QuadStore interface {
 	graph.QuadStore
 	ForRequest(r *http.Request) (graph.QuadStore, error)
 }
+
+// WriterWrapper is an optional interface for quad stores returned by QuadStore.ForRequest.
+//
+// If implemented, the writer of the database is wrapped for the request instead of creating a new writer
+// for the request's store. This way, all requests share the change feed and replication of the database.
+type WriterWrapper interface {
+	WrapWriter(qw graph.QuadWriter) graph.QuadWriter
+}
+
+// DeltaFilter is an optional interface for quad stores returned by QuadStore.ForRequest.
+//
+// If implemented, the change feed of the database is available for the request, and only deltas
+// returned by FilterDeltas are sent to the client.
+type DeltaFilter interface {
+	FilterDeltas(deltas []graph.Delta) []graph.Delta
+}
@@ -53,7 +53,7 @@ func (it *And) Iterate() Scanner {
 	for _, s := range it.opt {
 		opt = append(opt, s.Lookup())
 	}
-	return newAndNext(it.sub[0].Iterate(), newAndContains(sub, opt))
+	return newAndNext(it.sub[0].Iterate(), newAndContains(sub, opt), it.sub[1:])
 }
 
 func (it *And) Lookup() Index {
@@ -83,10 +83,45 @@ func (it *And) SubIterators() []Shape {
 	return iters
 }
 
+// MapSubIterators implements Composite. The order of checks is preserved.
+func (it *And) MapSubIterators(m Morphism) Shape {
+	nit := &And{
+		sub: make([]Shape, 0, len(it.sub)),
+		opt: make([]Shape, 0, len(it.opt)),
+	}
+	for _, sub := range it.sub {
+		nit.sub = append(nit.sub, m(sub))
+	}
+	for _, sub := range it.opt {
+		nit.opt = append(nit.opt, m(sub))
+	}
+	if it.checkList != nil {
+		// check list is a permutation of sub-iterators
+		nit.checkList = make([]Shape, 0, len(it.checkList))
+		for _, c := range it.checkList {
+			for i, sub := range it.sub {
+				if sub == c {
+					nit.checkList = append(nit.checkList, nit.sub[i])
+					break
+				}
+			}
+		}
+	}
+	return nit
+}
+
 func (it *And) String() string {
 	return "And"
 }
 
+// Order returns the order of the primary iterator, since only it is scanned.
+func (it *And) Order() Order {
+	if len(it.sub) == 0 {
+		return nil
+	}
+	return OrderOf(it.sub[0])
+}
+
 // Add a subiterator to this And iterator.
 //
 // The first iterator that is added becomes the primary iterator. This is
@@ -112,15 +147,19 @@ func (it *And) AddOptionalIterator(sub Shape) *And {
 type andNext struct {
 	primary   Scanner
 	secondary Index
+	checks    []Shape // secondary iterators; used to create an index for each parallel worker
+	workers   []Index // indexes of parallel workers; the first one is always the secondary
 	result    refs.Ref
+	err       error
 }
 
 // NewAnd creates an And iterator. `qs` is only required when needing a handle
 // for QuadStore-specific optimizations, otherwise nil is acceptable.
-func newAndNext(pri Scanner, sec Index) Scanner {
+func newAndNext(pri Scanner, sec Index, checks []Shape) Scanner {
 	return &andNext{
 		primary:   pri,
 		secondary: sec,
+		checks:    checks,
 	}
 }
 
@@ -150,7 +189,90 @@ func (it *andNext) Next(ctx context.Context) bool {
 	return false
 }
 
+// NextBatch is similar to Next, but checks a batch of candidates from the primary iterator at once.
+func (it *andNext) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	if it.Err() != nil {
+		return 0
+	}
+	for {
+		m := NextBatch(ctx, it.primary, buf)
+		if m == 0 {
+			return 0
+		}
+		if w := workersFrom(ctx); w != nil && m > andBatchChunk && len(it.checks) != 0 {
+			n := it.checkParallel(ctx, w, buf[:m])
+			if n > 0 || it.err != nil {
+				return n
+			}
+			continue
+		}
+		// matching values are moved to the beginning of the buffer
+		n := 0
+		for _, cur := range buf[:m] {
+			if it.secondary.Contains(ctx, cur) {
+				buf[n] = cur
+				n++
+			} else if it.secondary.Err() != nil {
+				return n
+			}
+		}
+		if n > 0 {
+			return n
+		}
+	}
+}
+
+// andBatchChunk is a number of candidates checked by a parallel worker at once.
+const andBatchChunk = 16
+
+// checkParallel checks a batch of candidates concurrently, each worker using its own index.
+// Matching values are moved to the beginning of the buffer, preserving the order.
+func (it *andNext) checkParallel(ctx context.Context, w *Workers, buf []refs.Ref) int {
+	chunks := (len(buf) + andBatchChunk - 1) / andBatchChunk
+	if len(it.workers) == 0 {
+		it.workers = append(it.workers, it.secondary)
+	}
+	// indexes are created in advance, since workers cannot modify the list
+	for len(it.workers) < chunks && len(it.workers) <= cap(w.slots) {
+		sub := make([]Index, 0, len(it.checks))
+		for _, s := range it.checks {
+			sub = append(sub, s.Lookup())
+		}
+		it.workers = append(it.workers, newAndContains(sub, nil))
+	}
+	ok := make([]bool, len(buf))
+	w.parallelFor(chunks, func(id, c int) {
+		idx := it.workers[id]
+		end := (c + 1) * andBatchChunk
+		if end > len(buf) {
+			end = len(buf)
+		}
+		for i := c * andBatchChunk; i < end; i++ {
+			if ok[i] = idx.Contains(ctx, buf[i]); !ok[i] && idx.Err() != nil {
+				return
+			}
+		}
+	})
+	for _, idx := range it.workers {
+		if err := idx.Err(); err != nil {
+			it.err = err
+			return 0
+		}
+	}
+	n := 0
+	for i, cur := range buf {
+		if ok[i] {
+			buf[n] = cur
+			n++
+		}
+	}
+	return n
+}
+
 func (it *andNext) Err() error {
+	if it.err != nil {
+		return it.err
+	}
 	if err := it.primary.Err(); err != nil {
 		return err
 	}
@@ -164,21 +286,11 @@ func (it *andNext) Result() refs.Ref {
 	return it.result
 }
 
-// An And has no NextPath of its own -- that is, there are no other values
+// An And has no paths of its own -- that is, there are no other values
 // which satisfy our previous result that are not the result itself. Our
-// subiterators might, however, so just pass the call recursively.
-func (it *andNext) NextPath(ctx context.Context) bool {
-	if it.primary.NextPath(ctx) {
-		return true
-	} else if err := it.primary.Err(); err != nil {
-		return false
-	}
-	if it.secondary.NextPath(ctx) {
-		return true
-	} else if err := it.secondary.Err(); err != nil {
-		return false
-	}
-	return false
+// subiterators might, however, so all combinations of their paths are listed.
+func (it *andNext) Paths(ctx context.Context) Scanner {
+	return joinPaths(ctx, it.result, it.primary, it.secondary)
 }
 
 // Close this iterator, and, by extension, close the subiterators.
@@ -190,6 +302,14 @@ func (it *andNext) Close() error {
 	if err2 := it.secondary.Close(); err2 != nil && err == nil {
 		err = err2
 	}
+	for i, idx := range it.workers {
+		if i == 0 {
+			continue // secondary
+		}
+		if err2 := idx.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
 	return err
 }
 
@@ -200,6 +320,7 @@ type andContains struct {
 	sub      []Index
 	opt      []Index
 	optCheck []bool
+	ok       []bool // results of parallel checks
 
 	result refs.Ref
 	err    error
@@ -255,6 +376,9 @@ func (it *andContains) Result() refs.Ref {
 
 // Check a value against the entire iterator, in order.
 func (it *andContains) Contains(ctx context.Context, val refs.Ref) bool {
+	if w := workersFrom(ctx); w != nil && len(it.sub) > 1 {
+		return it.containsParallel(ctx, w, val)
+	}
 	prev := it.result
 	for i, sub := range it.sub {
 		if !sub.Contains(ctx, val) {
@@ -289,30 +413,62 @@ func (it *andContains) Contains(ctx context.Context, val refs.Ref) bool {
 	return true
 }
 
-// An And has no NextPath of its own -- that is, there are no other values
-// which satisfy our previous result that are not the result itself. Our
-// subiterators might, however, so just pass the call recursively.
-func (it *andContains) NextPath(ctx context.Context) bool {
-	for _, sub := range it.sub {
-		if sub.NextPath(ctx) {
-			return true
-		} else if err := sub.Err(); err != nil {
+// containsParallel is similar to Contains, but checks a value against all subiterators concurrently.
+func (it *andContains) containsParallel(ctx context.Context, w *Workers, val refs.Ref) bool {
+	prev := it.result
+	if len(it.ok) != len(it.sub) {
+		it.ok = make([]bool, len(it.sub))
+	}
+	ok := it.ok
+	w.parallelFor(len(it.sub), func(_, i int) {
+		ok[i] = it.sub[i].Contains(ctx, val)
+	})
+	match := true
+	for i, sub := range it.sub {
+		if err := sub.Err(); err != nil {
 			it.err = err
 			return false
 		}
+		match = match && ok[i]
 	}
-	for i, sub := range it.opt {
-		if !it.optCheck[i] {
-			continue
+	if !match {
+		// Same as in Contains, the iterators that matched the value
+		// must be reset to the previous result to restore their tags.
+		if prev != nil {
+			for i, sub := range it.sub {
+				if !ok[i] {
+					continue
+				}
+				sub.Contains(ctx, prev)
+				if err := sub.Err(); err != nil {
+					it.err = err
+					return false
+				}
+			}
 		}
-		if sub.NextPath(ctx) {
-			return true
-		} else if err := sub.Err(); err != nil {
-			it.err = err
-			return false
+		return false
+	}
+	it.result = val
+	for i, sub := range it.opt {
+		it.optCheck[i] = sub.Contains(ctx, val)
+	}
+	return true
+}
+
+// An And has no paths of its own -- that is, there are no other values
+// which satisfy our previous result that are not the result itself. Our
+// subiterators might, however, so all combinations of their paths are listed.
+func (it *andContains) Paths(ctx context.Context) Scanner {
+	subs := make([]Base, 0, len(it.sub)+len(it.opt))
+	for _, sub := range it.sub {
+		subs = append(subs, sub)
+	}
+	for i, sub := range it.opt {
+		if it.optCheck[i] {
+			subs = append(subs, sub)
 		}
 	}
-	return false
+	return joinPaths(ctx, it.result, subs...)
 }
 
 // Close this iterator, and, by extension, close the subiterators.
@@ -16,7 +16,6 @@ package iterator
 
 import (
 	"context"
-	"sort"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph/refs"
@@ -55,6 +54,12 @@ func (it *And) Optimize(ctx context.Context) (Shape, bool) {
 	// changed.
 	its := optimizeSubIterators(ctx, old)
 
+	// Sort cannot be used to check values, and it only defines the order if it's the primary iterator.
+	// Thus, apply it to the whole intersection instead.
+	if out, ok := it.hoistSort(ctx, its); ok {
+		return out, true
+	}
+
 	// If we can find only one subiterator which is equivalent to this whole and,
 	// we can replace the And...
 	if out := optimizeReplacement(its); out != nil && len(it.opt) == 0 {
@@ -66,6 +71,12 @@ func (it *And) Optimize(ctx context.Context) (Shape, bool) {
 	// now a permutation of itself, but the contents are unchanged.
 	its = optimizeOrder(ctx, its)
 
+	// The primary iterator and the first check might be replaced with a join, if it is cheaper.
+	its = optimizeJoin(ctx, its)
+	if len(its) == 1 && len(it.opt) == 0 {
+		return its[0], true
+	}
+
 	its, _ = materializeIts(ctx, its)
 
 	// Okay! At this point we have an optimized order.
@@ -87,6 +98,25 @@ func (it *And) Optimize(ctx context.Context) (Shape, bool) {
 	return newAnd, true
 }
 
+// hoistSort moves the first Sort from the list of iterators above the intersection of all iterators.
+func (it *And) hoistSort(ctx context.Context, its []Shape) (Shape, bool) {
+	for i, sub := range its {
+		s, ok := sub.(*Sort)
+		if !ok || s.limit > 0 {
+			// sorted top values are a subset of values, so they must stay in the intersection
+			continue
+		}
+		nits := append([]Shape{}, its...)
+		nits[i] = s.subIt
+		and := NewAnd(nits...)
+		and.opt = it.opt
+		ns := *s
+		ns.subIt, _ = and.Optimize(ctx)
+		return &ns, true
+	}
+	return nil, false
+}
+
 // Find if there is a single subiterator which is a valid replacement for this
 // And.
 func optimizeReplacement(its []Shape) Shape {
@@ -109,95 +139,128 @@ func optimizeReplacement(its []Shape) Shape {
 
 // optimizeOrder(l) takes a list and returns a list, containing the same contents
 // but with a new ordering, however it wishes.
+//
+// The first iterator is the one that will be Next()ed, and the rest will be Contains()ed
+// in order. Both are chosen by the cost model associated with the context (see CostModel).
 func optimizeOrder(ctx context.Context, its []Shape) []Shape {
-	var (
-		best     Shape
-		bestCost = int64(1 << 62)
-	)
-
-	// Find the iterator with the projected "best" total cost.
-	// Total cost is defined as The Next()ed iterator's cost to Next() out
-	// all of it's contents, and to Contains() each of those against everyone
-	// else.
+	if len(its) == 0 {
+		return its
+	}
+	model := costModelFrom(ctx)
 	costs := make([]Costs, 0, len(its))
 	for _, it := range its {
 		st, _ := it.Stats(ctx)
 		costs = append(costs, st)
 	}
-	for i, root := range its {
-		rootStats := costs[i]
-		cost := rootStats.NextCost
-		for j, f := range its {
-			if f == root {
-				continue
+	var (
+		best     []int
+		bestCost int64
+		checks   = make([]Costs, 0, len(its)-1)
+		idx      = make([]int, 0, len(its)-1)
+	)
+	// Find the iterator with the projected "best" total cost.
+	// Total cost is defined as The Next()ed iterator's cost to Next() out
+	// all of it's contents, and to Contains() each of those against everyone
+	// else, in the best order.
+	for i, root := range costs {
+		checks, idx = checks[:0], idx[:0]
+		for j, c := range costs {
+			if j != i {
+				checks = append(checks, c)
+				idx = append(idx, j)
 			}
-			stats := costs[j]
-			cost += stats.ContainsCost * (1 + (rootStats.Size.Value / (stats.Size.Value + 1)))
 		}
-		cost *= rootStats.Size.Value
+		order := orderChecks(checks, func(checks []Costs) int64 {
+			return model.ScanCost(root, checks)
+		})
+		plan := make([]int, 0, len(its))
+		plan = append(plan, i)
+		ordered := make([]Costs, 0, len(order))
+		for _, j := range order {
+			plan = append(plan, idx[j])
+			ordered = append(ordered, checks[j])
+		}
+		cost := model.ScanCost(root, ordered)
 		if clog.V(3) {
-			clog.Infof("And: Root: %p Total Cost: %v Best: %v", root, cost, bestCost)
+			clog.Infof("And: Root: %p Total Cost: %v Best: %v", its[i], cost, bestCost)
 		}
-		if cost < bestCost {
-			best = root
+		if best == nil || cost < bestCost {
+			best = plan
 			bestCost = cost
 		}
 	}
 	if clog.V(3) {
-		clog.Infof("And: Choosing: %p Best: %v", best, bestCost)
+		clog.Infof("And: Choosing: %p Best: %v", its[best[0]], bestCost)
 	}
-
-	// TODO(barakmich): Optimization of order need not stop here. Picking a smart
-	// Contains() order based on probability of getting a false Contains() first is
-	// useful (fail faster).
-
-	var out []Shape
-	// Put the best iterator (the one we wish to Next()) at the front...
-	if best != nil {
-		out = append(out, best)
+	out := make([]Shape, 0, len(its))
+	for _, i := range best {
+		out = append(out, its[i])
 	}
+	return out
+}
 
-	// ... push everyone else after...
+// optimizeJoin replaces the primary iterator and the first check with a MergeJoin or a HashJoin,
+// if the cost model estimates the join as cheaper than checking each value of the primary iterator.
+//
+// MergeJoin is only considered if both iterators list values in the same order. HashJoin builds
+// the hash table from the first check.
+func optimizeJoin(ctx context.Context, its []Shape) []Shape {
+	if len(its) < 2 {
+		return its
+	}
+	model := costModelFrom(ctx)
+	costs := make([]Costs, 0, len(its))
 	for _, it := range its {
-		if it != best {
-			out = append(out, it)
+		st, _ := it.Stats(ctx)
+		costs = append(costs, st)
+	}
+	var (
+		best     Shape
+		bestCost = model.ScanCost(costs[0], costs[1:])
+	)
+	try := func(join Shape) {
+		st, _ := join.Stats(ctx)
+		cost := model.ScanCost(st, costs[2:])
+		if clog.V(3) {
+			clog.Infof("And: %v Total Cost: %v Best: %v", join, cost, bestCost)
+		}
+		if cost < bestCost {
+			best, bestCost = join, cost
 		}
 	}
-	return out
+	if o := OrderOf(its[0]); o != nil && o == OrderOf(its[1]) {
+		try(NewMergeJoin(o, its[0], its[1]))
+	}
+	// The primary iterator is always scanned by the join, since it determines the number of results.
+	try(NewHashJoin(its[1], its[0]))
+	if best == nil {
+		return its
+	}
+	return append([]Shape{best}, its[2:]...)
 }
 
+// sortByContainsCost orders iterators for Contains() using the cost model associated with the context.
+// Iterators that are cheap to check and filter out most of the values are checked first.
 func sortByContainsCost(ctx context.Context, arr []Shape) error {
-	cost := make([]Costs, 0, len(arr))
+	model := costModelFrom(ctx)
+	costs := make([]Costs, 0, len(arr))
 	var last error
 	for _, s := range arr {
 		c, err := s.Stats(ctx)
 		if err != nil {
 			last = err
 		}
-		cost = append(cost, c)
+		costs = append(costs, c)
 	}
-	sort.Sort(byCost{
-		list: arr,
-		cost: cost,
-	})
+	order := orderChecks(costs, model.LookupCost)
+	sorted := make([]Shape, 0, len(arr))
+	for _, i := range order {
+		sorted = append(sorted, arr[i])
+	}
+	copy(arr, sorted)
 	return last
 }
 
-// TODO(dennwc): store stats slice once
-type byCost struct {
-	list []Shape
-	cost []Costs
-}
-
-func (c byCost) Len() int { return len(c.list) }
-func (c byCost) Less(i, j int) bool {
-	return c.cost[i].ContainsCost < c.cost[j].ContainsCost
-}
-func (c byCost) Swap(i, j int) {
-	c.list[i], c.list[j] = c.list[j], c.list[i]
-	c.cost[i], c.cost[j] = c.cost[j], c.cost[i]
-}
-
 // optimizeContains() creates an alternate check list, containing the same contents
 // but with a new ordering, however it wishes.
 func (it *And) optimizeContains(ctx context.Context) error {
@@ -0,0 +1,66 @@
+package iterator
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// DefaultBatchSize is a default number of results requested with NextBatch.
+const DefaultBatchSize = 256
+
+// BatchScanner is an optional interface for scanners that can return multiple results per call.
+//
+// Iterating deep iterator trees with Next costs a few dynamic calls per level for each result.
+// NextBatch amortizes this cost by passing blocks of results through the tree.
+type BatchScanner interface {
+	Scanner
+
+	// NextBatch advances the iterator and fills buf with the next results. It returns the number of results.
+	//
+	// Results are the same as the ones returned by calling Next repeatedly. Alternative paths
+	// (see PathIterator) are not listed. Result and TagResults are undefined after NextBatch,
+	// thus it should not be used if tags are required.
+	//
+	// The batch may be shorter than buf even if there are more results. It returns 0 if no further
+	// advancement is possible, or if an error was encountered during iteration. Err should be consulted
+	// to distinguish between the two cases. Results returned together with an error are still valid.
+	NextBatch(ctx context.Context, buf []refs.Ref) int
+}
+
+// AsBatchScanner returns a BatchScanner for a given scanner.
+// If the scanner does not support batches natively, the batches are filled by calling Next.
+func AsBatchScanner(it Scanner) BatchScanner {
+	if b, ok := it.(BatchScanner); ok {
+		return b
+	}
+	return batchScanner{it}
+}
+
+// NextBatch fills buf with the next results of a given scanner and returns their number.
+// See BatchScanner for details.
+func NextBatch(ctx context.Context, it Scanner, buf []refs.Ref) int {
+	if b, ok := it.(BatchScanner); ok {
+		return b.NextBatch(ctx, buf)
+	}
+	return nextBatch(ctx, it, buf)
+}
+
+// nextBatch fills buf by calling Next. It can be used by scanners to implement NextBatch.
+func nextBatch(ctx context.Context, it Scanner, buf []refs.Ref) int {
+	n := 0
+	for n < len(buf) && it.Next(ctx) {
+		buf[n] = it.Result()
+		n++
+	}
+	return n
+}
+
+// batchScanner is an adapter that implements BatchScanner for any scanner.
+type batchScanner struct {
+	Scanner
+}
+
+func (it batchScanner) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	return nextBatch(ctx, it.Scanner, buf)
+}
@@ -0,0 +1,89 @@
+package iterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+func iteratedBatch(t testing.TB, s Shape, size int) []int {
+	ctx := context.TODO()
+	var res []int
+	it := AsBatchScanner(s.Iterate())
+	defer it.Close()
+	buf := make([]refs.Ref, size)
+	for n := it.NextBatch(ctx, buf); n > 0; n = it.NextBatch(ctx, buf) {
+		require.True(t, n <= size)
+		for _, v := range buf[:n] {
+			res = append(res, int(v.(Int64Node)))
+		}
+	}
+	require.NoError(t, it.Err())
+	return res
+}
+
+func fixedRange(from, to int) *Fixed {
+	f := NewFixed()
+	for i := from; i < to; i++ {
+		f.Add(Int64Node(i))
+	}
+	return f
+}
+
+var batchTests = []struct {
+	name  string
+	shape func() Shape
+}{
+	{"fixed", func() Shape { return fixedRange(0, 10) }},
+	{"and", func() Shape { return NewAnd(fixedRange(0, 10), fixedRange(5, 20)) }},
+	{"and empty", func() Shape { return NewAnd(fixedRange(0, 10), fixedRange(10, 20)) }},
+	{"or", func() Shape { return NewOr(fixedRange(0, 3), NewFixed(), fixedRange(2, 7)) }},
+	{"short-circuit or", func() Shape { return NewShortCircuitOr(NewFixed(), fixedRange(0, 3), fixedRange(2, 7)) }},
+	{"unique", func() Shape { return NewUnique(NewOr(fixedRange(0, 5), fixedRange(3, 8), fixedRange(0, 8))) }},
+	{"limit", func() Shape { return NewLimit(NewOr(fixedRange(0, 5), fixedRange(3, 8)), 7) }},
+	{"nested", func() Shape {
+		return NewLimit(NewUnique(NewAnd(NewOr(fixedRange(0, 5), fixedRange(3, 15)), fixedRange(2, 12))), 6)
+	}},
+}
+
+func TestNextBatch(t *testing.T) {
+	for _, c := range batchTests {
+		t.Run(c.name, func(t *testing.T) {
+			expect := iterated(c.shape())
+			for _, size := range []int{1, 2, 3, DefaultBatchSize} {
+				require.Equal(t, expect, iteratedBatch(t, c.shape(), size), "batch size: %d", size)
+			}
+		})
+	}
+}
+
+func TestChainBatch(t *testing.T) {
+	ctx := context.TODO()
+	s := NewAnd(fixedRange(0, 1000), fixedRange(500, 2000))
+
+	out, err := Iterate(ctx, s).Paths(false).All()
+	require.NoError(t, err)
+	require.Len(t, out, 500)
+	require.Equal(t, Int64Node(500), out[0])
+	require.Equal(t, Int64Node(999), out[499])
+
+	out, err = Iterate(ctx, s).Paths(false).Limit(300).All()
+	require.NoError(t, err)
+	require.Len(t, out, 300)
+
+	n, err := Iterate(ctx, NewUnique(s)).Paths(false).Limit(10).Count()
+	require.NoError(t, err)
+	require.Equal(t, int64(10), n)
+
+	var sum int64
+	err = Iterate(ctx, s).Paths(false).Each(func(v refs.Ref) error {
+		sum += int64(v.(Int64Node))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64((500+999)*500/2), sum)
+}
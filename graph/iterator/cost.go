@@ -0,0 +1,114 @@
+package iterator
+
+import (
+	"context"
+	"math"
+)
+
+// CostModel estimates costs of intersection plans. And.Optimize uses it to choose the primary iterator
+// that will be scanned, and the order in which the rest of iterators are checked.
+//
+// Models only compare plans, thus costs are relative and have no unit. Statistics of iterators
+// are provided by their Stats method, which may consult statistics of the quad store.
+type CostModel interface {
+	// ScanCost returns an estimated cost of scanning the primary iterator and checking
+	// each of its results against other iterators in a given order.
+	ScanCost(primary Costs, checks []Costs) int64
+	// LookupCost returns an estimated cost of checking a single value against iterators in a given order.
+	LookupCost(checks []Costs) int64
+}
+
+type costModelKey struct{}
+
+// WithCostModel returns a context that makes the optimizer use a given cost model.
+// Nil model means the default one, which is SelectivityModel.
+func WithCostModel(ctx context.Context, m CostModel) context.Context {
+	return context.WithValue(ctx, costModelKey{}, m)
+}
+
+// costModelFrom returns a cost model associated with the context, or the default model.
+func costModelFrom(ctx context.Context) CostModel {
+	if m, _ := ctx.Value(costModelKey{}).(CostModel); m != nil {
+		return m
+	}
+	return SelectivityModel{}
+}
+
+// SelectivityModel is the default cost model.
+//
+// It assumes that iterators are independent and that a value is contained in an iterator with a probability
+// proportional to its size, relative to the largest iterator of the intersection. Thus, small iterators are
+// preferred as primary, and cheap iterators that filter out most of the values are checked first.
+type SelectivityModel struct{}
+
+func (SelectivityModel) ScanCost(primary Costs, checks []Costs) int64 {
+	rows := float64(primary.Size.Value)
+	cost := rows * float64(primary.NextCost)
+	cost += checksCost(rows, universeSize(primary, checks), checks)
+	return clampCost(cost)
+}
+
+func (SelectivityModel) LookupCost(checks []Costs) int64 {
+	return clampCost(checksCost(1, universeSize(Costs{}, checks), checks))
+}
+
+// universeSize returns the size of the largest iterator.
+func universeSize(primary Costs, checks []Costs) float64 {
+	max := primary.Size.Value
+	for _, c := range checks {
+		if c.Size.Value > max {
+			max = c.Size.Value
+		}
+	}
+	return float64(max)
+}
+
+// checksCost estimates the cost of checking a given number of rows against iterators in order.
+func checksCost(rows, universe float64, checks []Costs) float64 {
+	var cost float64
+	for _, c := range checks {
+		cost += rows * float64(c.ContainsCost)
+		if universe > 0 {
+			rows *= math.Min(1, float64(c.Size.Value)/universe)
+		}
+	}
+	return cost
+}
+
+func clampCost(v float64) int64 {
+	if v >= math.MaxInt64/2 {
+		return math.MaxInt64 / 2
+	}
+	return int64(v)
+}
+
+// orderChecks returns the order in which iterators with given costs should be checked.
+// The order is built greedily: on each step it picks the iterator which gives the cheapest plan,
+// assuming that the rest of iterators will be checked after it in their current order.
+func orderChecks(costs []Costs, planCost func(checks []Costs) int64) []int {
+	order := make([]int, 0, len(costs))
+	used := make([]bool, len(costs))
+	plan := make([]Costs, 0, len(costs))
+	cand := make([]Costs, 0, len(costs))
+	for len(order) < len(costs) {
+		best, bestCost := -1, int64(0)
+		for i, c := range costs {
+			if used[i] {
+				continue
+			}
+			cand = append(append(cand[:0], plan...), c)
+			for j, c2 := range costs {
+				if !used[j] && j != i {
+					cand = append(cand, c2)
+				}
+			}
+			if cost := planCost(cand); best < 0 || cost < bestCost {
+				best, bestCost = i, cost
+			}
+		}
+		used[best] = true
+		order = append(order, best)
+		plan = append(plan, costs[best])
+	}
+	return order
+}
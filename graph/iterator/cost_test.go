@@ -0,0 +1,108 @@
+package iterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// costShape is a fixed iterator that reports given costs.
+type costShape struct {
+	*Fixed
+	name  string
+	costs Costs
+}
+
+func newCostShape(name string, size, next, contains int64) *costShape {
+	return &costShape{
+		Fixed: fixedRange(0, 10),
+		name:  name,
+		costs: Costs{
+			NextCost:     next,
+			ContainsCost: contains,
+			Size:         refs.Size{Value: size},
+		},
+	}
+}
+
+func (s *costShape) Stats(ctx context.Context) (Costs, error) {
+	return s.costs, nil
+}
+
+func (s *costShape) Optimize(ctx context.Context) (Shape, bool) {
+	return s, false
+}
+
+func (s *costShape) String() string {
+	return s.name
+}
+
+func shapeNames(its []Shape) []string {
+	var out []string
+	for _, it := range its {
+		out = append(out, it.String())
+	}
+	return out
+}
+
+func TestSelectivityModel(t *testing.T) {
+	var m SelectivityModel
+	small := Costs{NextCost: 1, ContainsCost: 1, Size: refs.Size{Value: 10}}
+	large := Costs{NextCost: 1, ContainsCost: 1, Size: refs.Size{Value: 10000}}
+	require.True(t, m.ScanCost(small, []Costs{large}) < m.ScanCost(large, []Costs{small}))
+
+	// selective check first
+	require.True(t, m.LookupCost([]Costs{small, large}) < m.LookupCost([]Costs{large, small}))
+
+	// cheap check first, if selectivity is the same
+	cheap := Costs{NextCost: 1, ContainsCost: 1, Size: refs.Size{Value: 100}}
+	costly := Costs{NextCost: 1, ContainsCost: 50, Size: refs.Size{Value: 100}}
+	require.True(t, m.ScanCost(large, []Costs{cheap, costly}) < m.ScanCost(large, []Costs{costly, cheap}))
+}
+
+func TestAndOptimizeOrder(t *testing.T) {
+	ctx := context.TODO()
+	a := NewAnd(
+		newCostShape("all", 100000, 1, 1),
+		newCostShape("costly", 5000, 1, 100),
+		newCostShape("small", 50, 1, 1),
+		newCostShape("medium", 5000, 1, 1),
+	)
+	s, _ := a.Optimize(ctx)
+	and, ok := s.(*And)
+	require.True(t, ok, "%T", s)
+	require.Equal(t, []string{"small", "medium", "costly", "all"}, shapeNames(and.SubIterators()))
+}
+
+// reverseModel prefers plans that scan the largest iterator and check the most expensive iterators first.
+type reverseModel struct{}
+
+func (reverseModel) ScanCost(primary Costs, checks []Costs) int64 {
+	cost := -primary.Size.Value * 1000
+	for i, c := range checks {
+		cost += int64(i) * c.ContainsCost
+	}
+	return cost
+}
+
+func (reverseModel) LookupCost(checks []Costs) int64 {
+	return reverseModel{}.ScanCost(Costs{}, checks)
+}
+
+func TestCostModel(t *testing.T) {
+	ctx := WithCostModel(context.TODO(), reverseModel{})
+	a := NewAnd(
+		newCostShape("small", 50, 1, 1),
+		newCostShape("costly", 5000, 1, 100),
+		newCostShape("all", 100000, 1, 1),
+		newCostShape("medium", 5000, 1, 2),
+	)
+	s, _ := a.Optimize(ctx)
+	and, ok := s.(*And)
+	require.True(t, ok, "%T", s)
+	require.Equal(t, []string{"all", "costly", "medium", "small"}, shapeNames(and.SubIterators()))
+}
@@ -34,6 +34,13 @@ func (it *Count) SubIterators() []Shape {
 	return []Shape{it.it}
 }
 
+// MapSubIterators implements Composite.
+func (it *Count) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.it = m(it.it)
+	return &nit
+}
+
 func (it *Count) Optimize(ctx context.Context) (Shape, bool) {
 	sub, optimized := it.it.Optimize(ctx)
 	it.it = sub
@@ -80,19 +87,17 @@ func (it *countNext) Next(ctx context.Context) bool {
 	if it.done {
 		return false
 	}
-	// TODO(dennwc): this most likely won't include the NextPath
+	// TODO(dennwc): this most likely won't include the paths
 	st, err := it.it.Stats(ctx)
 	if err != nil {
 		it.err = err
 		return false
 	}
 	if !st.Size.Exact {
-		sit := it.it.Iterate()
+		// TODO(dennwc): it's unclear if we should count paths here or not
+		sit := Bindings(it.it.Iterate())
 		defer sit.Close()
 		for st.Size.Value = 0; sit.Next(ctx); st.Size.Value++ {
-			// TODO(dennwc): it's unclear if we should call it here or not
-			for ; sit.NextPath(ctx); st.Size.Value++ {
-			}
 		}
 		it.err = sit.Err()
 	}
@@ -112,10 +117,6 @@ func (it *countNext) Result() refs.Ref {
 	return refs.PreFetched(it.result)
 }
 
-func (it *countNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *countNext) Close() error {
 	return nil
 }
@@ -169,10 +170,6 @@ func (it *countContains) Contains(ctx context.Context, val refs.Ref) bool {
 	return false
 }
 
-func (it *countContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *countContains) Close() error {
 	return it.it.Close()
 }
@@ -138,10 +138,6 @@ func (it *fixedNext) Result() refs.Ref {
 	return it.result
 }
 
-func (it *fixedNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // A Fixed iterator consists of it's values, an index (where it is in the process of Next()ing) and
 // an equality function.
 type fixedContains struct {
@@ -194,7 +190,3 @@ func (it *fixedContains) Err() error {
 func (it *fixedContains) Result() refs.Ref {
 	return it.result
 }
-
-func (it *fixedContains) NextPath(ctx context.Context) bool {
-	return false
-}
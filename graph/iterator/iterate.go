@@ -22,7 +22,8 @@ type Chain struct {
 	n     int
 }
 
-// Iterate is a set of helpers for iteration. Context may be used to cancel execution.
+// Iterate is a set of helpers for iteration. Context may be used to cancel execution, in which case
+// the context error is returned.
 // Iterator will be optimized and closed after execution.
 //
 // By default, iteration has no limit and includes sub-paths.
@@ -48,23 +49,43 @@ func (c *Chain) next() bool {
 	}
 	return ok
 }
-func (c *Chain) nextPath() bool {
+
+// nextBatch fills buf with the next results. It can only be used if sub-paths are disabled.
+func (c *Chain) nextBatch(buf []refs.Ref) int {
 	select {
 	case <-c.ctx.Done():
-		return false
+		return 0
 	default:
 	}
-	ok := c.paths && (c.limit < 0 || c.n < c.limit) && c.it.NextPath(c.ctx)
-	if ok {
-		c.n++
+	if c.limit >= 0 {
+		if c.n >= c.limit {
+			return 0
+		} else if left := c.limit - c.n; len(buf) > left {
+			buf = buf[:left]
+		}
 	}
-	return ok
+	n := NextBatch(c.ctx, c.it, buf)
+	c.n += n
+	return n
+}
+
+// err returns the error of the iterator, or the context error if the iteration was canceled.
+func (c *Chain) err() error {
+	if err := c.it.Err(); err != nil {
+		return err
+	}
+	return c.ctx.Err()
 }
+
 func (c *Chain) start() {
 	if c.optimize {
 		c.s, _ = c.s.Optimize(c.ctx)
 	}
+	c.s = Instrument(c.ctx, Enforce(c.ctx, c.s))
 	c.it = c.s.Iterate()
+	if c.paths {
+		c.it = Bindings(c.it)
+	}
 }
 
 func (c *Chain) end() {
@@ -77,8 +98,8 @@ func (c *Chain) Limit(n int) *Chain {
 	return c
 }
 
-// Paths switches iteration over sub-paths (with it.NextPath).
-// Defaults to true.
+// Paths switches iteration over sub-paths (see Bindings).
+// Defaults to true. If disabled, results are read in batches (see BatchScanner).
 func (c *Chain) Paths(enable bool) *Chain {
 	c.paths = enable
 	return c
@@ -102,6 +123,23 @@ func (c *Chain) Each(fnc func(refs.Ref) error) error {
 	defer c.end()
 	done := c.ctx.Done()
 
+	if !c.paths {
+		// tags are not available to the callback, so results can be read in batches
+		buf := make([]refs.Ref, DefaultBatchSize)
+		for n := c.nextBatch(buf); n > 0; n = c.nextBatch(buf) {
+			for _, v := range buf[:n] {
+				select {
+				case <-done:
+					return c.ctx.Err()
+				default:
+				}
+				if err := fnc(v); err != nil {
+					return err
+				}
+			}
+		}
+		return c.err()
+	}
 	for c.next() {
 		select {
 		case <-done:
@@ -112,16 +150,8 @@ func (c *Chain) Each(fnc func(refs.Ref) error) error {
 		if err != nil {
 			return err
 		}
-		for c.nextPath() {
-			select {
-			case <-done:
-				return c.ctx.Err()
-			default:
-			}
-			fnc(c.it.Result())
-		}
 	}
-	return c.it.Err()
+	return c.err()
 }
 
 // All will return all results of an iterator.
@@ -142,6 +172,13 @@ func (c *Chain) Count() (int64, error) {
 	}
 	done := c.ctx.Done()
 	var cnt int64
+	if !c.paths {
+		buf := make([]refs.Ref, DefaultBatchSize)
+		for n := c.nextBatch(buf); n > 0; n = c.nextBatch(buf) {
+			cnt += int64(n)
+		}
+		return cnt, c.err()
+	}
 iteration:
 	for c.next() {
 		select {
@@ -150,16 +187,8 @@ iteration:
 		default:
 		}
 		cnt++
-		for c.nextPath() {
-			select {
-			case <-done:
-				break iteration
-			default:
-			}
-			cnt++
-		}
 	}
-	return cnt, c.it.Err()
+	return cnt, c.err()
 }
 
 // All will return all results of an iterator.
@@ -168,6 +197,13 @@ func (c *Chain) All() ([]refs.Ref, error) {
 	defer c.end()
 	done := c.ctx.Done()
 	var out []refs.Ref
+	if !c.paths {
+		buf := make([]refs.Ref, DefaultBatchSize)
+		for n := c.nextBatch(buf); n > 0; n = c.nextBatch(buf) {
+			out = append(out, buf[:n]...)
+		}
+		return out, c.err()
+	}
 iteration:
 	for c.next() {
 		select {
@@ -176,16 +212,8 @@ iteration:
 		default:
 		}
 		out = append(out, c.it.Result())
-		for c.nextPath() {
-			select {
-			case <-done:
-				break iteration
-			default:
-			}
-			out = append(out, c.it.Result())
-		}
 	}
-	return out, c.it.Err()
+	return out, c.err()
 }
 
 // First will return a first result of an iterator. It returns nil if iterator is empty.
@@ -193,7 +221,7 @@ func (c *Chain) First() (refs.Ref, error) {
 	c.start()
 	defer c.end()
 	if !c.next() {
-		return nil, c.it.Err()
+		return nil, c.err()
 	}
 	return c.it.Result(), nil
 }
@@ -205,21 +233,27 @@ func (c *Chain) Send(out chan<- refs.Ref) error {
 	c.start()
 	defer c.end()
 	done := c.ctx.Done()
+	if !c.paths {
+		buf := make([]refs.Ref, DefaultBatchSize)
+		for n := c.nextBatch(buf); n > 0; n = c.nextBatch(buf) {
+			for _, v := range buf[:n] {
+				select {
+				case <-done:
+					return c.ctx.Err()
+				case out <- v:
+				}
+			}
+		}
+		return c.err()
+	}
 	for c.next() {
 		select {
 		case <-done:
 			return c.ctx.Err()
 		case out <- c.it.Result():
 		}
-		for c.nextPath() {
-			select {
-			case <-done:
-				return c.ctx.Err()
-			case out <- c.it.Result():
-			}
-		}
 	}
-	return c.it.Err()
+	return c.err()
 }
 
 // TagEach will run a provided tag map callback for each result of the iterator.
@@ -244,24 +278,8 @@ func (c *Chain) TagEach(fnc func(map[string]refs.Ref) error) error {
 		if err != nil {
 			return err
 		}
-		for c.nextPath() {
-			select {
-			case <-done:
-				return c.ctx.Err()
-			default:
-			}
-			tags := make(map[string]refs.Ref, mn)
-			c.it.TagResults(tags)
-			if n := len(tags); n > mn {
-				mn = n
-			}
-			err = fnc(tags)
-			if err != nil {
-				return err
-			}
-		}
 	}
-	return c.it.Err()
+	return c.err()
 }
 
 var errNoQuadStore = fmt.Errorf("no quad store in Iterate")
@@ -362,13 +380,8 @@ func (c *Chain) SendValues(qs refs.Namer, out chan<- quad.Value) error {
 		if err := send(c.it.Result()); err != nil {
 			return err
 		}
-		for c.nextPath() {
-			if err := send(c.it.Result()); err != nil {
-				return err
-			}
-		}
 	}
-	return c.it.Err()
+	return c.err()
 }
 
 // TagValues is an analog of TagEach, but it will additionally call NameOf
@@ -47,26 +47,6 @@ type Base interface {
 	// Returns the current result.
 	Result() refs.Ref
 
-	// These methods are the heart and soul of the iterator, as they constitute
-	// the iteration interface.
-	//
-	// To get the full results of iteration, do the following:
-	//
-	//  for it.Next(ctx) {
-	//  	val := it.Result()
-	//  	... do things with val.
-	//  	for it.NextPath(ctx) {
-	//  		... find other paths to iterate
-	//  	}
-	//  }
-	//
-	// All of them should set iterator.result to be the last returned value, to
-	// make results work.
-	//
-	// NextPath() advances iterators that may have more than one valid result,
-	// from the bottom up.
-	NextPath(ctx context.Context) bool
-
 	// Err returns any error that was encountered by the Iterator.
 	Err() error
 
@@ -224,10 +204,6 @@ func (it *Null) SubIterators() []Shape {
 	return nil
 }
 
-func (it *Null) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *Null) Reset() {}
 
 func (it *Null) Close() error {
@@ -285,10 +261,6 @@ func (it *Error) SubIterators() []Shape {
 	return nil
 }
 
-func (it *Error) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *Error) Reset() {}
 
 func (it *Error) Close() error {
@@ -201,10 +201,6 @@ func (it *int64Next) Result() refs.Ref {
 	return it.toValue(it.result)
 }
 
-func (it *int64Next) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // An All iterator across a range of int64 values, from `max` to `min`.
 type int64Contains struct {
 	node     bool
@@ -248,10 +244,6 @@ func (it *int64Contains) Result() refs.Ref {
 	return it.toValue(it.result)
 }
 
-func (it *int64Contains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // No sub-iterators.
 func (it *int64Contains) SubIterators() []Shape {
 	return nil
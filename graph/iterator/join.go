@@ -0,0 +1,484 @@
+package iterator
+
+// Defines HashJoin and MergeJoin iterators. Both are intersections of two iterators, same as And,
+// but instead of checking each value of the primary iterator against the other one, they scan both.
+//
+// HashJoin scans the build iterator first and keeps its values in a hash table, then scans
+// the probe iterator and looks up each value in the table. MergeJoin requires both iterators
+// to list values in the same order, and advances them in lockstep.
+//
+// Both joins are chosen by And.Optimize when the cost model estimates them as cheaper than And,
+// which is usually the case when both iterators are large and Contains is expensive for them.
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// Order is a total order of values. It is used by MergeJoin to intersect sorted iterators.
+//
+// Orders are compared with ==, thus implementations must be comparable.
+type Order interface {
+	// Compare returns a negative number if a < b, zero if a == b and a positive number if a > b.
+	Compare(a, b refs.Ref) int
+}
+
+// OrderedShape is an optional interface for shapes that list values in a specific order.
+type OrderedShape interface {
+	Shape
+	// Order returns the order of values returned by the scanner, or nil if values are not sorted.
+	Order() Order
+}
+
+// OrderOf returns the order of values listed by a given shape, or nil if values are not sorted.
+func OrderOf(s Shape) Order {
+	if s, ok := s.(OrderedShape); ok {
+		return s.Order()
+	}
+	return nil
+}
+
+var (
+	_ OrderedShape = (*HashJoin)(nil)
+	_ OrderedShape = (*MergeJoin)(nil)
+)
+
+// joinCosts returns costs of a join of two iterators with a given total cost of scanning it.
+func joinCosts(a, b Costs, total int64) Costs {
+	size := a.Size
+	if b.Size.Value < size.Value {
+		size = b.Size
+	}
+	n := size.Value
+	if n < 1 {
+		n = 1
+	}
+	return Costs{
+		ContainsCost: a.ContainsCost + b.ContainsCost,
+		NextCost:     (total + n - 1) / n,
+		Size:         size,
+	}
+}
+
+// joinLookup returns an index for an intersection of two iterators. Both joins check values in the same way as And.
+func joinLookup(a, b Shape) Index {
+	return newAndContains([]Index{a.Lookup(), b.Lookup()}, nil)
+}
+
+// HashJoin is an intersection of two iterators that keeps all values of one (build) iterator
+// in memory, and looks up values of the other (probe) iterator in it.
+//
+// Results and tags are the same as for And with the probe iterator as primary. If the build iterator
+// does not fit into the memory budget of the query, HashJoin falls back to checking values against it.
+type HashJoin struct {
+	build Shape
+	probe Shape
+}
+
+// NewHashJoin creates a HashJoin of two iterators. It is equivalent to NewAnd(probe, build).
+func NewHashJoin(build, probe Shape) *HashJoin {
+	return &HashJoin{build: build, probe: probe}
+}
+
+func (it *HashJoin) Iterate() Scanner {
+	return newHashJoinNext(it.build, it.probe.Iterate())
+}
+
+func (it *HashJoin) Lookup() Index {
+	return joinLookup(it.build, it.probe)
+}
+
+func (it *HashJoin) String() string {
+	return "HashJoin"
+}
+
+// SubIterators returns the build and the probe iterators, in this order.
+func (it *HashJoin) SubIterators() []Shape {
+	return []Shape{it.build, it.probe}
+}
+
+// MapSubIterators implements Composite.
+func (it *HashJoin) MapSubIterators(m Morphism) Shape {
+	return NewHashJoin(m(it.build), m(it.probe))
+}
+
+// Order returns the order of the probe iterator, since it is scanned sequentially.
+func (it *HashJoin) Order() Order {
+	return OrderOf(it.probe)
+}
+
+func (it *HashJoin) Optimize(ctx context.Context) (Shape, bool) {
+	build, ok1 := it.build.Optimize(ctx)
+	probe, ok2 := it.probe.Optimize(ctx)
+	if IsNull(build) || IsNull(probe) {
+		return NewNull(), true
+	}
+	if !ok1 && !ok2 {
+		return it, false
+	}
+	return NewHashJoin(build, probe), true
+}
+
+// Stats estimates costs of the join. The cost of building the hash table is amortized
+// over the results, as well as the cost of scanning the probe iterator.
+func (it *HashJoin) Stats(ctx context.Context) (Costs, error) {
+	bs, err := it.build.Stats(ctx)
+	ps, err2 := it.probe.Stats(ctx)
+	if err == nil {
+		err = err2
+	}
+	total := bs.Size.Value*(bs.NextCost+1) + ps.Size.Value*(ps.NextCost+1)
+	return joinCosts(bs, ps, total), err
+}
+
+type hashJoinNext struct {
+	build Shape
+	probe Scanner
+
+	built    bool
+	table    map[interface{}][]result // paths of the build iterator for each value
+	fallback Index                    // set if the table does not fit into the memory budget
+	paths    []result
+	result   refs.Ref
+	err      error
+
+	budget *MemoryBudget
+	used   int64 // memory accounted in the budget
+}
+
+func newHashJoinNext(build Shape, probe Scanner) *hashJoinNext {
+	return &hashJoinNext{
+		build: build,
+		probe: probe,
+	}
+}
+
+func (it *hashJoinNext) String() string {
+	return "HashJoinNext"
+}
+
+// grow accounts n bytes of the hash table in the memory budget.
+func (it *hashJoinNext) grow(n int64) bool {
+	it.used += n
+	return it.budget.grow(n)
+}
+
+// buildTable loads all values of the build iterator with their paths into the hash table.
+//
+// Iterators may list the same value multiple times in scanning mode, while listing them as paths
+// in lookup mode (for example, HasA lists a node for each quad). Thus, all occurrences are paths
+// of a single value, as they would be for And.
+func (it *hashJoinNext) buildTable(ctx context.Context) {
+	it.built = true
+	it.budget = memoryBudgetFrom(ctx)
+	it.table = make(map[interface{}][]result)
+	sc := it.build.Iterate()
+	aborted := false
+	// add records a path of the build iterator
+	add := func(key interface{}, p result) bool {
+		it.table[key] = append(it.table[key], p)
+		return it.grow(refCost + tagsCost(p.tags))
+	}
+	for !aborted && sc.Next(ctx) {
+		id := sc.Result()
+		key := refs.ToKey(id)
+		if _, ok := it.table[key]; !ok && !it.grow(entryCost) {
+			aborted = true
+			break
+		}
+		paths, err := pathsOf(ctx, sc)
+		if err != nil {
+			it.err = err
+			break
+		}
+		for _, p := range paths {
+			if aborted = !add(key, p); aborted {
+				break
+			}
+		}
+	}
+	if it.err == nil {
+		it.err = sc.Err()
+	}
+	if err := sc.Close(); err != nil && it.err == nil {
+		it.err = err
+	}
+	if it.err == nil && aborted {
+		if clog.V(2) {
+			clog.Infof("HashJoin: memory budget exceeded, checking values of the build iterator instead")
+		}
+		it.fallback = it.build.Lookup()
+	}
+	if aborted {
+		it.table = nil
+		it.budget.release(it.used)
+		it.used = 0
+	}
+}
+
+func (it *hashJoinNext) Next(ctx context.Context) bool {
+	if !it.built {
+		it.buildTable(ctx)
+	}
+	it.result, it.paths = nil, nil
+	if it.err != nil {
+		return false
+	}
+	for it.probe.Next(ctx) {
+		cur := it.probe.Result()
+		if it.fallback != nil {
+			if it.fallback.Contains(ctx, cur) {
+				it.result = cur
+				return true
+			} else if err := it.fallback.Err(); err != nil {
+				it.err = err
+				return false
+			}
+			continue
+		}
+		if paths, ok := it.table[refs.ToKey(cur)]; ok {
+			it.result, it.paths = cur, paths
+			return true
+		}
+	}
+	it.err = it.probe.Err()
+	return false
+}
+
+func (it *hashJoinNext) TagResults(dst map[string]refs.Ref) {
+	it.probe.TagResults(dst)
+	if it.fallback != nil {
+		it.fallback.TagResults(dst)
+		return
+	}
+	if len(it.paths) != 0 {
+		for tag, v := range it.paths[0].tags {
+			dst[tag] = v
+		}
+	}
+}
+
+// Paths lists all combinations of paths of the probe and the build iterators, same as And.
+func (it *hashJoinNext) Paths(ctx context.Context) Scanner {
+	if it.result == nil {
+		return nil
+	}
+	if it.fallback != nil {
+		return joinPaths(ctx, it.result, it.probe, it.fallback)
+	}
+	probe, err := pathsOf(ctx, it.probe)
+	if err != nil {
+		return &pathRows{err: err}
+	}
+	return joinSets(it.result, probe, it.paths)
+}
+
+func (it *hashJoinNext) Result() refs.Ref {
+	return it.result
+}
+
+func (it *hashJoinNext) Err() error {
+	return it.err
+}
+
+func (it *hashJoinNext) Close() error {
+	err := it.probe.Close()
+	if it.fallback != nil {
+		if err2 := it.fallback.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	it.table, it.paths = nil, nil
+	it.budget.release(it.used)
+	it.used = 0
+	return err
+}
+
+// MergeJoin is an intersection of two iterators that list values in the same order.
+// Both iterators are scanned in lockstep, thus values are never checked with Contains.
+// Paths of the second iterator are kept in memory for the current value only.
+//
+// Results and tags are the same as for And with the first iterator as primary.
+type MergeJoin struct {
+	order Order
+	a, b  Shape
+}
+
+// NewMergeJoin creates a MergeJoin of two iterators. Both of them must list values in a given order.
+func NewMergeJoin(order Order, a, b Shape) *MergeJoin {
+	return &MergeJoin{order: order, a: a, b: b}
+}
+
+func (it *MergeJoin) Iterate() Scanner {
+	return newMergeJoinNext(it.order, it.a.Iterate(), it.b.Iterate())
+}
+
+func (it *MergeJoin) Lookup() Index {
+	return joinLookup(it.a, it.b)
+}
+
+func (it *MergeJoin) String() string {
+	return "MergeJoin"
+}
+
+func (it *MergeJoin) SubIterators() []Shape {
+	return []Shape{it.a, it.b}
+}
+
+// MapSubIterators implements Composite.
+func (it *MergeJoin) MapSubIterators(m Morphism) Shape {
+	return NewMergeJoin(it.order, m(it.a), m(it.b))
+}
+
+// Order returns the order of values of the join, which is the same as the order of both iterators.
+func (it *MergeJoin) Order() Order {
+	return it.order
+}
+
+func (it *MergeJoin) Optimize(ctx context.Context) (Shape, bool) {
+	a, ok1 := it.a.Optimize(ctx)
+	b, ok2 := it.b.Optimize(ctx)
+	if IsNull(a) || IsNull(b) {
+		return NewNull(), true
+	}
+	if !ok1 && !ok2 {
+		return it, false
+	}
+	if OrderOf(a) != it.order || OrderOf(b) != it.order {
+		// optimized iterators are no longer sorted
+		return NewAnd(a, b), true
+	}
+	return NewMergeJoin(it.order, a, b), true
+}
+
+// Stats estimates costs of the join. The cost of scanning both iterators is amortized over the results.
+func (it *MergeJoin) Stats(ctx context.Context) (Costs, error) {
+	as, err := it.a.Stats(ctx)
+	bs, err2 := it.b.Stats(ctx)
+	if err == nil {
+		err = err2
+	}
+	total := as.Size.Value*as.NextCost + bs.Size.Value*bs.NextCost
+	return joinCosts(as, bs, total), err
+}
+
+type mergeJoinNext struct {
+	order Order
+	a, b  Scanner
+
+	started bool
+	bOK     bool     // b has a current value
+	runVal  refs.Ref // the value of the current run
+	run     []result // paths of all occurrences of the current value in b
+	result  refs.Ref
+	err     error
+}
+
+func newMergeJoinNext(order Order, a, b Scanner) *mergeJoinNext {
+	return &mergeJoinNext{order: order, a: a, b: b}
+}
+
+func (it *mergeJoinNext) String() string {
+	return "MergeJoinNext"
+}
+
+// loadRun records paths of all occurrences of the current value of b, and advances b past them.
+//
+// Iterators may list the same value multiple times in scanning mode, while listing them as paths
+// in lookup mode (for example, HasA lists a node for each quad). Thus, all occurrences are paths
+// of a single value, as they would be for And.
+func (it *mergeJoinNext) loadRun(ctx context.Context) {
+	it.runVal = it.b.Result()
+	it.run = it.run[:0]
+	for it.bOK && it.order.Compare(it.b.Result(), it.runVal) == 0 {
+		paths, err := pathsOf(ctx, it.b)
+		if err != nil {
+			it.err = err
+			return
+		}
+		it.run = append(it.run, paths...)
+		it.bOK = it.b.Next(ctx)
+	}
+}
+
+// Next advances the first iterator and then advances the second one until it catches up.
+func (it *mergeJoinNext) Next(ctx context.Context) bool {
+	it.result = nil
+	if it.err != nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		it.bOK = it.b.Next(ctx)
+	}
+	for it.a.Next(ctx) {
+		cur := it.a.Result()
+		if it.runVal != nil && it.order.Compare(cur, it.runVal) == 0 {
+			// duplicate value of a; paths of b are already known
+			it.result = cur
+			return true
+		}
+		for it.bOK && it.order.Compare(it.b.Result(), cur) < 0 {
+			it.bOK = it.b.Next(ctx)
+		}
+		if !it.bOK {
+			break
+		}
+		if it.order.Compare(it.b.Result(), cur) == 0 {
+			if it.loadRun(ctx); it.err == nil {
+				it.err = it.b.Err()
+			}
+			if it.err != nil {
+				return false
+			}
+			it.result = cur
+			return true
+		}
+	}
+	if err := it.a.Err(); err != nil {
+		it.err = err
+	} else {
+		it.err = it.b.Err()
+	}
+	return false
+}
+
+func (it *mergeJoinNext) TagResults(dst map[string]refs.Ref) {
+	it.a.TagResults(dst)
+	if it.result != nil && len(it.run) != 0 {
+		for tag, v := range it.run[0].tags {
+			dst[tag] = v
+		}
+	}
+}
+
+// Paths lists all combinations of paths of the first and the second iterators, same as And.
+func (it *mergeJoinNext) Paths(ctx context.Context) Scanner {
+	if it.result == nil {
+		return nil
+	}
+	a, err := pathsOf(ctx, it.a)
+	if err != nil {
+		return &pathRows{err: err}
+	}
+	return joinSets(it.result, a, it.run)
+}
+
+func (it *mergeJoinNext) Result() refs.Ref {
+	return it.result
+}
+
+func (it *mergeJoinNext) Err() error {
+	return it.err
+}
+
+func (it *mergeJoinNext) Close() error {
+	it.run = nil
+	err := it.a.Close()
+	if err2 := it.b.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
+}
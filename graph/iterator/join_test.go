@@ -0,0 +1,149 @@
+package iterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// int64Order orders Int64Node values.
+type int64Order struct{}
+
+func (int64Order) Compare(a, b refs.Ref) int {
+	return int(a.(Int64Node) - b.(Int64Node))
+}
+
+// sortedShape is a shape that declares its values to be sorted by int64Order.
+type sortedShape struct {
+	Shape
+}
+
+func (s sortedShape) Order() Order {
+	return int64Order{}
+}
+
+func (s sortedShape) Optimize(ctx context.Context) (Shape, bool) {
+	return s, false
+}
+
+func sorted(s Shape) Shape {
+	return sortedShape{s}
+}
+
+var joinTests = []struct {
+	name string
+	a, b func() Shape
+}{
+	{
+		name: "plain",
+		a:    func() Shape { return fixedRange(0, 50) },
+		b:    func() Shape { return fixedRange(20, 100) },
+	},
+	{
+		name: "empty",
+		a:    func() Shape { return fixedRange(0, 10) },
+		b:    func() Shape { return fixedRange(10, 20) },
+	},
+	{
+		name: "tags",
+		a:    func() Shape { return Tag(fixedRange(0, 30), "a") },
+		b:    func() Shape { return Tag(fixedRange(10, 40), "b") },
+	},
+	{
+		name: "paths",
+		a:    func() Shape { return newPathsShape(fixedRange(0, 30), "a", 2) },
+		b:    func() Shape { return newPathsShape(fixedRange(10, 40), "b", 3) },
+	},
+	{
+		name: "duplicates",
+		a:    func() Shape { return NewFixed(Int64Node(1), Int64Node(2), Int64Node(2), Int64Node(3), Int64Node(5)) },
+		b:    func() Shape { return NewFixed(Int64Node(2), Int64Node(3), Int64Node(4), Int64Node(5)) },
+	},
+}
+
+func TestJoin(t *testing.T) {
+	ctx := context.TODO()
+	for _, c := range joinTests {
+		t.Run(c.name, func(t *testing.T) {
+			expect := iteratedTags(t, ctx, NewAnd(c.a(), c.b()))
+
+			got := iteratedTags(t, ctx, NewHashJoin(c.b(), c.a()))
+			require.Equal(t, expect, got, "hash join")
+
+			got = iteratedTags(t, ctx, NewMergeJoin(int64Order{}, sorted(c.a()), sorted(c.b())))
+			require.Equal(t, expect, got, "merge join")
+		})
+	}
+}
+
+func TestJoinContains(t *testing.T) {
+	ctx := context.TODO()
+	for _, s := range []Shape{
+		NewHashJoin(fixedRange(0, 10), fixedRange(5, 20)),
+		NewMergeJoin(int64Order{}, sorted(fixedRange(0, 10)), sorted(fixedRange(5, 20))),
+	} {
+		it := s.Lookup()
+		require.True(t, it.Contains(ctx, Int64Node(7)), "%v", s)
+		require.False(t, it.Contains(ctx, Int64Node(3)), "%v", s)
+		require.False(t, it.Contains(ctx, Int64Node(15)), "%v", s)
+		require.NoError(t, it.Close())
+	}
+}
+
+func TestHashJoinBudget(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		return NewHashJoin(Tag(fixedRange(0, 100), "a"), Tag(fixedRange(50, 200), "b"))
+	}
+	expect := iteratedTags(t, ctx, shape())
+	require.Len(t, expect, 50)
+
+	b := NewMemoryBudget(1 << 10)
+	got := iteratedTags(t, WithMemoryBudget(ctx, b), shape())
+	require.Equal(t, expect, got)
+	require.Equal(t, int64(0), b.Used())
+}
+
+func TestAndOptimizeJoin(t *testing.T) {
+	ctx := context.TODO()
+	cases := []struct {
+		name   string
+		a, b   Shape
+		expect string
+	}{
+		{
+			name:   "cheap contains",
+			a:      newCostShape("a", 10000, 1, 1),
+			b:      newCostShape("b", 20000, 1, 1),
+			expect: "And",
+		},
+		{
+			name:   "hash join",
+			a:      newCostShape("a", 10000, 1, 100),
+			b:      newCostShape("b", 20000, 1, 100),
+			expect: "HashJoin",
+		},
+		{
+			name:   "merge join",
+			a:      sorted(newCostShape("a", 10000, 1, 100)),
+			b:      sorted(newCostShape("b", 20000, 1, 100)),
+			expect: "MergeJoin",
+		},
+		{
+			name:   "different orders",
+			a:      sorted(newCostShape("a", 10000, 1, 100)),
+			b:      newCostShape("b", 20000, 1, 100),
+			expect: "HashJoin",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, _ := NewAnd(c.a, c.b).Optimize(ctx)
+			require.Equal(t, c.expect, s.String())
+		})
+	}
+}
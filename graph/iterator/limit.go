@@ -34,12 +34,19 @@ func (it *Limit) SubIterators() []Shape {
 	return []Shape{it.it}
 }
 
+// MapSubIterators implements Composite.
+func (it *Limit) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.it = m(it.it)
+	return &nit
+}
+
 func (it *Limit) Optimize(ctx context.Context) (Shape, bool) {
 	nit, optimized := it.it.Optimize(ctx)
 	if it.limit <= 0 { // no Limit
 		return nit, true
 	}
-	it.it = nit
+	it.it = limitSort(nit, it.limit)
 	return it, optimized
 }
 
@@ -86,6 +93,20 @@ func (it *limitNext) Next(ctx context.Context) bool {
 	return false
 }
 
+// NextBatch is similar to Next, but returns a batch of results. The batch is truncated if Limit is reached.
+func (it *limitNext) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	if it.limit > 0 {
+		if it.count >= it.limit {
+			return 0
+		} else if left := it.limit - it.count; int64(len(buf)) > left {
+			buf = buf[:left]
+		}
+	}
+	n := NextBatch(ctx, it.it, buf)
+	it.count += int64(n)
+	return n
+}
+
 func (it *limitNext) Err() error {
 	return it.it.Err()
 }
@@ -94,17 +115,10 @@ func (it *limitNext) Result() refs.Ref {
 	return it.it.Result()
 }
 
-// NextPath checks whether there is another path. Will call primary iterator
-// if Limit is not reached yet.
-func (it *limitNext) NextPath(ctx context.Context) bool {
-	if it.limit > 0 && it.count >= it.limit {
-		return false
-	}
-	if it.it.NextPath(ctx) {
-		it.count++
-		return true
-	}
-	return false
+// Paths lists alternative paths of the primary iterator. Paths are counted
+// towards the Limit, the same way as results are.
+func (it *limitNext) Paths(ctx context.Context) Scanner {
+	return limitPaths(ctx, it.it, it.limit, &it.count)
 }
 
 // Close closes the primary and all iterators.  It closes all subiterators
@@ -155,17 +169,10 @@ func (it *limitContains) Contains(ctx context.Context, val refs.Ref) bool {
 	return false
 }
 
-// NextPath checks whether there is another path. Will call primary iterator
-// if Limit is not reached yet.
-func (it *limitContains) NextPath(ctx context.Context) bool {
-	if it.limit > 0 && it.count >= it.limit {
-		return false
-	}
-	if it.it.NextPath(ctx) {
-		it.count++
-		return true
-	}
-	return false
+// Paths lists alternative paths of the primary iterator. Paths are counted
+// towards the Limit, the same way as results are.
+func (it *limitContains) Paths(ctx context.Context) Scanner {
+	return limitPaths(ctx, it.it, it.limit, &it.count)
 }
 
 // Close closes the primary and all iterators.  It closes all subiterators
@@ -177,3 +184,32 @@ func (it *limitContains) Close() error {
 func (it *limitContains) String() string {
 	return fmt.Sprintf("LimitContains(%d)", it.limit)
 }
+
+// limitPaths lists alternative paths of an iterator, until the Limit is reached.
+func limitPaths(ctx context.Context, it Base, limit int64, count *int64) Scanner {
+	if limit > 0 && *count >= limit {
+		return nil
+	}
+	sc := Paths(ctx, it)
+	if sc == nil {
+		return nil
+	}
+	return &pathLimit{Scanner: sc, limit: limit, count: count}
+}
+
+type pathLimit struct {
+	Scanner
+	limit int64
+	count *int64
+}
+
+func (it *pathLimit) Next(ctx context.Context) bool {
+	if it.limit > 0 && *it.count >= it.limit {
+		return false
+	}
+	if it.Scanner.Next(ctx) {
+		*it.count++
+		return true
+	}
+	return false
+}
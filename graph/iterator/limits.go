@@ -0,0 +1,357 @@
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// ResourceLimits restricts the amount of work done by a single query. Zero value of each field means no limit.
+type ResourceLimits struct {
+	// MaxScanned is the maximal number of values read or checked by leaf iterators, such as quad store scans.
+	MaxScanned int64
+	// MaxResults is the maximal number of results produced by all iterators of the tree, including intermediate ones.
+	MaxResults int64
+	// MaxFrontier is the maximal number of new values found on a single step of the Recursive iterator.
+	MaxFrontier int64
+}
+
+// IsZero reports if no limits are set.
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// LimitError is returned by iterators when the query exceeds one of the ResourceLimits.
+type LimitError struct {
+	Resource string // name of the resource, for example "scanned values"
+	Max      int64  // the limit that was exceeded
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("query exceeded the limit of %d %s", e.Max, e.Resource)
+}
+
+// Limiter enforces resource limits and cancellation for all iterator trees of a query.
+// Iterator trees are wrapped by Enforce.
+//
+// A single limiter must be shared by all iterator trees of a query, since the limits apply to the query as a whole.
+type Limiter struct {
+	lim     ResourceLimits
+	scanned int64
+	results int64
+
+	failed int32 // set when err is set; allows to check it without a lock
+	mu     sync.Mutex
+	err    error
+}
+
+// NewLimiter creates a limiter for a single query.
+func NewLimiter(l ResourceLimits) *Limiter {
+	return &Limiter{lim: l}
+}
+
+// Limits returns the limits enforced by the limiter.
+func (l *Limiter) Limits() ResourceLimits {
+	return l.lim
+}
+
+// Err returns the error that stopped the query, if any.
+func (l *Limiter) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// fail records the first error that stopped the query.
+func (l *Limiter) fail(err error) {
+	l.mu.Lock()
+	if l.err == nil {
+		l.err = err
+		atomic.StoreInt32(&l.failed, 1)
+	}
+	l.mu.Unlock()
+}
+
+// check returns an error if the query was stopped or the context was canceled.
+func (l *Limiter) check(ctx context.Context) error {
+	if atomic.LoadInt32(&l.failed) != 0 {
+		return l.Err()
+	}
+	select {
+	case <-ctx.Done():
+		l.fail(ctx.Err())
+		return ctx.Err()
+	default:
+	}
+	return nil
+}
+
+// add accounts n more units of a given resource and returns an error if the limit is exceeded.
+func (l *Limiter) add(cnt *int64, max int64, resource string, n int64) error {
+	if n == 0 || max <= 0 {
+		return nil
+	}
+	if atomic.AddInt64(cnt, n) > max {
+		err := &LimitError{Resource: resource, Max: max}
+		l.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (l *Limiter) addScanned(n int64) error {
+	return l.add(&l.scanned, l.lim.MaxScanned, "scanned values", n)
+}
+
+func (l *Limiter) addResults(n int64) error {
+	return l.add(&l.results, l.lim.MaxResults, "intermediate results", n)
+}
+
+// frontier checks the number of values found on a single step of the recursion.
+// Nil limiter never returns an error.
+func (l *Limiter) frontier(n int) error {
+	if l == nil || l.lim.MaxFrontier <= 0 || int64(n) <= l.lim.MaxFrontier {
+		return nil
+	}
+	err := &LimitError{Resource: "values in the recursion frontier", Max: l.lim.MaxFrontier}
+	l.fail(err)
+	return err
+}
+
+// Wrap makes each iterator of the tree stop with an error when the query exceeds the limits or the context is canceled.
+// The tree should be wrapped after optimization, since the optimizer cannot see through wrapped iterators.
+// Nil limiter returns the shape unchanged.
+func (l *Limiter) Wrap(s Shape) Shape {
+	if l == nil {
+		return s
+	} else if ls, ok := s.(*limitedShape); ok && ls.l == l {
+		return s // already wrapped
+	}
+	leaf := true
+	if c, ok := s.(Composite); ok {
+		s = c.MapSubIterators(func(sub Shape) Shape {
+			leaf = false
+			return l.Wrap(sub)
+		})
+	}
+	return &limitedShape{s: s, l: l, leaf: leaf}
+}
+
+type limiterKey struct{}
+
+// WithLimiter returns a context that makes Enforce apply a given limiter to iterator trees.
+func WithLimiter(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+// limiterFrom returns a limiter associated with the context, or nil if the query is not limited.
+func limiterFrom(ctx context.Context) *Limiter {
+	l, _ := ctx.Value(limiterKey{}).(*Limiter)
+	return l
+}
+
+// Enforce wraps an iterator tree to apply the limiter associated with the context. See Limiter.Wrap.
+// If there is no limiter, it returns the shape unchanged.
+//
+// Cancellation alone does not require wrapping: leaf iterators and iterators that read their sub-iterators
+// in a loop (such as Sort or Recursive) check the context themselves.
+func Enforce(ctx context.Context, s Shape) Shape {
+	return limiterFrom(ctx).Wrap(s)
+}
+
+var (
+	_ OrderedShape = (*limitedShape)(nil)
+	_ Composite    = (*limitedShape)(nil)
+)
+
+// limitedShape is an iterator that enforces resource limits.
+type limitedShape struct {
+	s    Shape
+	l    *Limiter
+	leaf bool // values are scanned by this iterator, not by its sub-iterators
+}
+
+func (it *limitedShape) Iterate() Scanner {
+	return &limitedScanner{Scanner: it.s.Iterate(), limitedBase: limitedBase{l: it.l, leaf: it.leaf}}
+}
+
+func (it *limitedShape) Lookup() Index {
+	return &limitedIndex{Index: it.s.Lookup(), limitedBase: limitedBase{l: it.l, leaf: it.leaf}}
+}
+
+func (it *limitedShape) String() string {
+	return it.s.String()
+}
+
+func (it *limitedShape) Stats(ctx context.Context) (Costs, error) {
+	return it.s.Stats(ctx)
+}
+
+// Optimize does nothing, since wrapped trees are already optimized.
+func (it *limitedShape) Optimize(ctx context.Context) (Shape, bool) {
+	return it, false
+}
+
+func (it *limitedShape) SubIterators() []Shape {
+	return it.s.SubIterators()
+}
+
+// MapSubIterators implements Composite. It allows to instrument wrapped trees (see Profile).
+func (it *limitedShape) MapSubIterators(m Morphism) Shape {
+	c, ok := it.s.(Composite)
+	if !ok {
+		return it
+	}
+	nit := *it
+	nit.s = c.MapSubIterators(m)
+	return &nit
+}
+
+func (it *limitedShape) Order() Order {
+	return OrderOf(it.s)
+}
+
+// limitedBase implements accounting shared by scanners and indexes.
+type limitedBase struct {
+	l    *Limiter
+	leaf bool
+	err  error
+}
+
+// account checks the limits after a call that returned n results.
+// It returns false if the iterator must stop.
+func (it *limitedBase) account(ctx context.Context, n int, scanned bool) bool {
+	if err := it.l.check(ctx); err != nil {
+		it.err = err
+		return false
+	}
+	if it.leaf && scanned {
+		if err := it.l.addScanned(int64(n)); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	if err := it.l.addResults(int64(n)); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// stopped checks if the iterator must stop before making a call.
+func (it *limitedBase) stopped(ctx context.Context) bool {
+	if it.err != nil {
+		return true
+	}
+	if err := it.l.check(ctx); err != nil {
+		it.err = err
+		return true
+	}
+	return false
+}
+
+// paths lists alternative paths of a wrapped iterator. Each path is accounted as a result.
+func (it *limitedBase) paths(ctx context.Context, sub Base) Scanner {
+	if it.stopped(ctx) {
+		return nil
+	}
+	sc := Paths(ctx, sub)
+	if sc == nil {
+		return nil
+	}
+	return &limitedPaths{Scanner: sc, base: it}
+}
+
+// limitedPaths enforces resource limits on alternative paths of an iterator.
+// Errors are recorded in the state of the iterator, thus they are reported by both scanners.
+type limitedPaths struct {
+	Scanner
+	base *limitedBase
+}
+
+func (it *limitedPaths) Next(ctx context.Context) bool {
+	if it.base.stopped(ctx) || !it.Scanner.Next(ctx) {
+		return false
+	}
+	return it.base.account(ctx, 1, false)
+}
+
+func (it *limitedPaths) Err() error {
+	return it.base.errOr(it.Scanner.Err())
+}
+
+func (it *limitedBase) errOr(err error) error {
+	if it.err != nil {
+		return it.err
+	} else if err != nil {
+		return err
+	}
+	// sub-iterators may drop errors of their own sub-iterators
+	return it.l.Err()
+}
+
+type limitedScanner struct {
+	Scanner
+	limitedBase
+}
+
+func (it *limitedScanner) Next(ctx context.Context) bool {
+	if it.stopped(ctx) || !it.Scanner.Next(ctx) {
+		return false
+	}
+	return it.account(ctx, 1, true)
+}
+
+// NextBatch implements BatchScanner.
+func (it *limitedScanner) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	if it.stopped(ctx) {
+		return 0
+	}
+	n := NextBatch(ctx, it.Scanner, buf)
+	if n == 0 || !it.account(ctx, n, true) {
+		return 0
+	}
+	return n
+}
+
+func (it *limitedScanner) Paths(ctx context.Context) Scanner {
+	return it.paths(ctx, it.Scanner)
+}
+
+func (it *limitedScanner) Err() error {
+	return it.errOr(it.Scanner.Err())
+}
+
+type limitedIndex struct {
+	Index
+	limitedBase
+}
+
+func (it *limitedIndex) Contains(ctx context.Context, v refs.Ref) bool {
+	if it.stopped(ctx) {
+		return false
+	}
+	ok := it.Index.Contains(ctx, v)
+	// each check of a leaf iterator is a scan, even if the value is not found
+	if it.leaf {
+		if err := it.l.addScanned(1); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	if !ok {
+		return false
+	}
+	return it.account(ctx, 1, false)
+}
+
+func (it *limitedIndex) Paths(ctx context.Context) Scanner {
+	return it.paths(ctx, it.Index)
+}
+
+func (it *limitedIndex) Err() error {
+	return it.errOr(it.Index.Err())
+}
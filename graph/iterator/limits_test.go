@@ -0,0 +1,129 @@
+package iterator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+	"github.com/cayleygraph/quad"
+)
+
+func limitedValues(ctx context.Context, l ResourceLimits, s Shape) (int, error) {
+	n := 0
+	err := Iterate(WithLimiter(ctx, NewLimiter(l)), s).UnOptimized().Each(func(refs.Ref) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func TestLimits(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		// 10 values are scanned by the primary iterator and 10 more are checked by the second one
+		return NewAnd(fixedRange(0, 10), fixedRange(5, 20))
+	}
+	cases := []struct {
+		name   string
+		limits ResourceLimits
+		err    string
+	}{
+		{name: "no limits"},
+		{name: "scanned", limits: ResourceLimits{MaxScanned: 20}},
+		{name: "scanned exceeded", limits: ResourceLimits{MaxScanned: 19}, err: "scanned values"},
+		// 10 from the primary iterator, 5 from the second one and 5 from And itself
+		{name: "results", limits: ResourceLimits{MaxResults: 20}},
+		{name: "results exceeded", limits: ResourceLimits{MaxResults: 15}, err: "intermediate results"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n, err := limitedValues(ctx, c.limits, shape())
+			if c.err == "" {
+				require.NoError(t, err)
+				require.Equal(t, 5, n)
+				return
+			}
+			var lerr *LimitError
+			require.True(t, errors.As(err, &lerr), "unexpected error: %v", err)
+			require.Equal(t, c.err, lerr.Resource)
+		})
+	}
+}
+
+func TestLimitsShared(t *testing.T) {
+	ctx := WithLimiter(context.TODO(), NewLimiter(ResourceLimits{MaxScanned: 15}))
+	// limits apply to all trees of the query
+	_, err := Iterate(ctx, fixedRange(0, 10)).All()
+	require.NoError(t, err)
+	_, err = Iterate(ctx, fixedRange(0, 10)).All()
+	require.Error(t, err)
+}
+
+func TestLimitsFrontier(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		start := NewFixed(
+			refs.PreFetched(quad.Raw("alice")),
+			refs.PreFetched(quad.Raw("fred")),
+			refs.PreFetched(quad.Raw("greg")),
+		)
+		return NewRecursive(start, singleHop(recTestQs, "parent"), 0)
+	}
+	n, err := limitedValues(ctx, ResourceLimits{MaxFrontier: 3}, shape())
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	_, err = limitedValues(ctx, ResourceLimits{MaxFrontier: 2}, shape())
+	var lerr *LimitError
+	require.True(t, errors.As(err, &lerr), "unexpected error: %v", err)
+	require.Equal(t, int64(2), lerr.Max)
+}
+
+func TestEnforceCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	err := Iterate(ctx, NewSort(valueNamer{}, shuffledValues(100, 1))).Each(func(refs.Ref) error {
+		if n++; n == 10 {
+			cancel()
+		}
+		return nil
+	})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 10, n)
+
+	// the tree is only wrapped if there is a limiter
+	s := fixedRange(0, 10)
+	require.Equal(t, Shape(s), Enforce(ctx, s))
+
+	// iterators that read all values of their sub-iterators check the context themselves
+	start := NewFixed(refs.PreFetched(quad.Raw("alice")))
+	for _, s := range []Shape{
+		NewSort(valueNamer{}, shuffledValues(100, 1)),
+		NewMaterialize(shuffledValues(100, 1)),
+		NewRecursive(start, singleHop(recTestQs, "parent"), 0),
+		NewSample(shuffledValues(100, 1), 10, 1),
+	} {
+		it := s.Iterate()
+		require.False(t, it.Next(ctx), "%v", s)
+		require.Equal(t, context.Canceled, it.Err(), "%v", s)
+		it.Close()
+	}
+}
+
+func TestEnforceProfile(t *testing.T) {
+	p := NewProfile()
+	ctx := WithProfile(context.TODO(), p)
+	n, err := limitedValues(ctx, ResourceLimits{MaxScanned: 100}, NewAnd(fixedRange(0, 10), fixedRange(5, 20)))
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+
+	trees := p.Trees()
+	require.Len(t, trees, 1)
+	require.Equal(t, "And", trees[0].Name)
+	require.Len(t, trees[0].Sub, 2)
+	require.Equal(t, int64(10), trees[0].Sub[0].Results)
+}
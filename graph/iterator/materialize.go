@@ -30,6 +30,11 @@ type result struct {
 	tags map[string]refs.Ref
 }
 
+// Materialize caches results of the subiterator in memory.
+//
+// The cache is bounded: it keeps at most MaterializeLimit results and only while the memory budget allows it.
+// Otherwise, the cache is dropped and the subiterator is iterated directly from the start, thus the subiterator
+// may be partially iterated twice. Unlike Sort and Unique, Materialize never spills results to disk.
 type Materialize struct {
 	sub        Shape
 	expectSize int64
@@ -62,6 +67,13 @@ func (it *Materialize) SubIterators() []Shape {
 	return []Shape{it.sub}
 }
 
+// MapSubIterators implements Composite.
+func (it *Materialize) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.sub = m(it.sub)
+	return &nit
+}
+
 func (it *Materialize) Optimize(ctx context.Context) (Shape, bool) {
 	newSub, changed := it.sub.Optimize(ctx)
 	if changed {
@@ -98,10 +110,12 @@ type materializeNext struct {
 	containsMap map[interface{}]int
 	values      [][]result
 	index       int
-	subindex    int
 	hasRun      bool
 	aborted     bool
 	err         error
+
+	budget *MemoryBudget
+	used   int64 // memory accounted in the budget
 }
 
 func newMaterializeNext(sub Shape) *materializeNext {
@@ -116,6 +130,8 @@ func newMaterializeNext(sub Shape) *materializeNext {
 func (it *materializeNext) Close() error {
 	it.containsMap = nil
 	it.values = nil
+	it.budget.release(it.used)
+	it.used = 0
 	it.hasRun = false
 	return it.next.Close()
 }
@@ -131,7 +147,7 @@ func (it *materializeNext) TagResults(dst map[string]refs.Ref) {
 	if it.Result() == nil {
 		return
 	}
-	for tag, value := range it.values[it.index][it.subindex].tags {
+	for tag, value := range it.values[it.index][0].tags {
 		dst[tag] = value
 	}
 }
@@ -153,7 +169,7 @@ func (it *materializeNext) Result() refs.Ref {
 	if it.index >= len(it.values) {
 		return nil
 	}
-	return it.values[it.index][it.subindex].id
+	return it.values[it.index][0].id
 }
 
 func (it *materializeNext) Next(ctx context.Context) bool {
@@ -170,7 +186,6 @@ func (it *materializeNext) Next(ctx context.Context) bool {
 	}
 
 	it.index++
-	it.subindex = 0
 	if it.index >= len(it.values) {
 		return false
 	}
@@ -181,30 +196,34 @@ func (it *materializeNext) Err() error {
 	return it.err
 }
 
-func (it *materializeNext) NextPath(ctx context.Context) bool {
-	if !it.hasRun {
-		it.materializeSet(ctx)
-	}
-	if it.err != nil {
-		return false
+// Paths lists paths of the current value, except the first one. Values are materialized with all their paths,
+// including those returned by the subiterator as separate results.
+func (it *materializeNext) Paths(ctx context.Context) Scanner {
+	if it.err != nil || !it.hasRun {
+		return nil
 	}
 	if it.aborted {
-		return it.next.NextPath(ctx)
+		return Paths(ctx, it.next)
 	}
-
-	it.subindex++
-	if it.subindex >= len(it.values[it.index]) {
-		// Don't go off the end of the world
-		it.subindex--
-		return false
+	if it.index < 0 || it.index >= len(it.values) {
+		return nil
 	}
-	return true
+	return newPathRows(it.values[it.index][1:])
+}
+
+// grow accounts n bytes of materialized results in the memory budget.
+func (it *materializeNext) grow(n int64) bool {
+	it.used += n
+	return it.budget.grow(n)
 }
 
 func (it *materializeNext) materializeSet(ctx context.Context) {
+	it.budget = memoryBudgetFrom(ctx)
 	i := 0
-	mn := 0
 	for it.next.Next(ctx) {
+		if ctx.Err() != nil {
+			break
+		}
 		i++
 		if i > MaterializeLimit {
 			it.aborted = true
@@ -215,35 +234,50 @@ func (it *materializeNext) materializeSet(ctx context.Context) {
 		if _, ok := it.containsMap[val]; !ok {
 			it.containsMap[val] = len(it.values)
 			it.values = append(it.values, nil)
+			if !it.grow(2 * entryCost) {
+				it.aborted = true
+				break
+			}
 		}
 		index := it.containsMap[val]
-		tags := make(map[string]refs.Ref, mn)
-		it.next.TagResults(tags)
-		if n := len(tags); n > mn {
-			mn = n
+		paths, err := pathsOf(ctx, it.next)
+		if err != nil {
+			it.err = err
+			break
 		}
-		it.values[index] = append(it.values[index], result{id: id, tags: tags})
-		for it.next.NextPath(ctx) {
-			i++
-			if i > MaterializeLimit {
+		for j, p := range paths {
+			if j > 0 {
+				i++
+				if i > MaterializeLimit {
+					it.aborted = true
+					break
+				}
+			}
+			it.values[index] = append(it.values[index], p)
+			if !it.grow(refCost + tagsCost(p.tags)) {
 				it.aborted = true
 				break
 			}
-			tags := make(map[string]refs.Ref, mn)
-			it.next.TagResults(tags)
-			if n := len(tags); n > mn {
-				mn = n
-			}
-			it.values[index] = append(it.values[index], result{id: id, tags: tags})
+		}
+		if it.aborted {
+			break
 		}
 	}
-	it.err = it.next.Err()
+	if it.err == nil {
+		it.err = it.next.Err()
+	}
+	if it.err == nil {
+		it.err = ctx.Err()
+	}
 	if it.err == nil && it.aborted {
+		// the cache is only an optimization, thus it is dropped instead of being spilled to disk
 		if clog.V(2) {
 			clog.Infof("Aborting subiterator")
 		}
 		it.values = nil
 		it.containsMap = nil
+		it.budget.release(it.used)
+		it.used = 0
 		_ = it.next.Close()
 		it.next = it.sub.Iterate()
 	}
@@ -319,21 +353,17 @@ func (it *materializeContains) Contains(ctx context.Context, v refs.Ref) bool {
 	key := refs.ToKey(v)
 	if i, ok := it.next.containsMap[key]; ok {
 		it.next.index = i
-		it.next.subindex = 0
 		return true
 	}
 	return false
 }
 
-func (it *materializeContains) NextPath(ctx context.Context) bool {
-	if !it.next.hasRun {
-		it.run(ctx)
-	}
+func (it *materializeContains) Paths(ctx context.Context) Scanner {
 	if it.next.Err() != nil {
-		return false
+		return nil
 	}
 	if it.sub != nil {
-		return it.sub.NextPath(ctx)
+		return Paths(ctx, it.sub)
 	}
-	return it.next.NextPath(ctx)
+	return it.next.Paths(ctx)
 }
@@ -35,6 +35,11 @@ func (it *Not) SubIterators() []Shape {
 	return []Shape{it.primary, it.allIt}
 }
 
+// MapSubIterators implements Composite.
+func (it *Not) MapSubIterators(m Morphism) Shape {
+	return NewNot(m(it.primary), m(it.allIt))
+}
+
 func (it *Not) Optimize(ctx context.Context) (Shape, bool) {
 	// TODO - consider wrapping the primary with a MaterializeIt
 	optimizedPrimaryIt, optimized := it.primary.Optimize(ctx)
@@ -113,12 +118,6 @@ func (it *notNext) Result() refs.Ref {
 	return it.result
 }
 
-// NextPath checks whether there is another path. Not applicable, hence it will
-// return false.
-func (it *notNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // Close closes the primary and all iterators.  It closes all subiterators
 // it can, but returns the first error it encounters.
 func (it *notNext) Close() error {
@@ -177,12 +176,6 @@ func (it *notContains) Contains(ctx context.Context, val refs.Ref) bool {
 	return true
 }
 
-// NextPath checks whether there is another path. Not applicable, hence it will
-// return false.
-func (it *notContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // Close closes the primary and all iterators.  It closes all subiterators
 // it can, but returns the first error it encounters.
 func (it *notContains) Close() error {
@@ -23,6 +23,7 @@ package iterator
 
 import (
 	"context"
+	"sync"
 
 	"github.com/cayleygraph/cayley/graph/refs"
 )
@@ -79,6 +80,16 @@ func (it *Or) SubIterators() []Shape {
 	return it.sub
 }
 
+// MapSubIterators implements Composite.
+func (it *Or) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.sub = make([]Shape, 0, len(it.sub))
+	for _, sub := range it.sub {
+		nit.sub = append(nit.sub, m(sub))
+	}
+	return &nit
+}
+
 func (it *Or) String() string {
 	return "Or"
 }
@@ -141,6 +152,27 @@ type orNext struct {
 	curInd       int
 	result       refs.Ref
 	err          error
+
+	// parallel execution
+	pre   []chan orBatch // results of branches prefetched by workers; nil for branches scanned directly
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	batch orBatch // current batch of prefetched results
+	pos   int     // current result in the batch
+}
+
+const (
+	// orPrefetchBatch is a number of results sent by a prefetching worker at once.
+	orPrefetchBatch = 64
+	// orPrefetchDepth is a number of batches a prefetching worker may send ahead.
+	orPrefetchDepth = 16
+)
+
+// orBatch is a batch of results prefetched from a branch of the Or iterator.
+type orBatch struct {
+	refs  []refs.Ref
+	paths [][]result // all paths of each result; nil if tags were not requested
+	err   error
 }
 
 func newOrNext(sub []Scanner, shortCircuit bool) *orNext {
@@ -154,6 +186,14 @@ func newOrNext(sub []Scanner, shortCircuit bool) *orNext {
 // Overrides BaseIterator TagResults, as it needs to add it's own results and
 // recurse down it's subiterators.
 func (it *orNext) TagResults(dst map[string]refs.Ref) {
+	if it.prefetched() {
+		if it.batch.paths != nil && it.pos >= 0 && it.pos < len(it.batch.refs) {
+			for k, v := range it.batch.paths[it.pos][0].tags {
+				dst[k] = v
+			}
+		}
+		return
+	}
 	it.sub[it.curInd].TagResults(dst)
 }
 
@@ -168,6 +208,12 @@ func (it *orNext) Next(ctx context.Context) bool {
 	if it.curInd >= len(it.sub) {
 		return false
 	}
+	if it.curInd == -1 && it.pre == nil {
+		it.startWorkers(ctx, true)
+	}
+	if it.pre != nil {
+		return it.nextParallel(ctx)
+	}
 	var first bool
 	for {
 		if it.curInd == -1 {
@@ -198,6 +244,184 @@ func (it *orNext) Next(ctx context.Context) bool {
 	return false
 }
 
+// NextBatch is similar to Next, but returns a batch of results from the current subiterator.
+func (it *orNext) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	if it.curInd >= len(it.sub) || it.err != nil {
+		return 0
+	}
+	if it.curInd == -1 && it.pre == nil {
+		it.startWorkers(ctx, false)
+	}
+	if it.pre != nil {
+		return it.nextBatchParallel(ctx, buf)
+	}
+	var first bool
+	for {
+		if it.curInd == -1 {
+			it.curInd = 0
+			first = true
+		}
+		curIt := it.sub[it.curInd]
+
+		if n := NextBatch(ctx, curIt, buf); n > 0 {
+			return n
+		}
+
+		it.err = curIt.Err()
+		if it.err != nil {
+			return 0
+		}
+
+		if it.shortCircuit && !first {
+			break
+		}
+		it.curInd++
+		if it.curInd >= len(it.sub) {
+			break
+		}
+	}
+	return 0
+}
+
+// startWorkers starts prefetching branches in the background, if parallel execution is enabled.
+// The first branch is always scanned directly, as well as branches for which no free workers were available.
+// If paths are requested, workers capture tags of all paths of each result.
+func (it *orNext) startWorkers(ctx context.Context, paths bool) {
+	w := workersFrom(ctx)
+	if w == nil || it.shortCircuit || len(it.sub) < 2 {
+		return
+	}
+	var pre []chan orBatch
+	for i := 1; i < len(it.sub) && w.tryAcquire(); i++ {
+		if pre == nil {
+			pre = make([]chan orBatch, len(it.sub))
+			it.stop = make(chan struct{})
+		}
+		ch := make(chan orBatch, orPrefetchDepth)
+		pre[i] = ch
+		it.wg.Add(1)
+		go it.prefetch(ctx, w, it.sub[i], ch, paths)
+	}
+	it.pre, it.pos = pre, -1
+}
+
+// prefetch scans a branch and sends results to the channel. It runs in a separate goroutine.
+func (it *orNext) prefetch(ctx context.Context, w *Workers, sub Scanner, ch chan<- orBatch, paths bool) {
+	defer it.wg.Done()
+	defer w.release()
+	defer close(ch)
+	send := func(b orBatch) bool {
+		select {
+		case ch <- b:
+			return true
+		case <-it.stop:
+		case <-ctx.Done():
+		}
+		return false
+	}
+	for done := false; !done; {
+		var b orBatch
+		if paths {
+			for len(b.refs) < orPrefetchBatch {
+				if !sub.Next(ctx) {
+					done = true
+					break
+				}
+				paths, err := pathsOf(ctx, sub)
+				if err != nil {
+					send(orBatch{err: err})
+					return
+				}
+				b.refs = append(b.refs, sub.Result())
+				b.paths = append(b.paths, paths)
+			}
+		} else {
+			buf := make([]refs.Ref, orPrefetchBatch)
+			n := NextBatch(ctx, sub, buf)
+			b.refs, done = buf[:n], n == 0
+		}
+		if len(b.refs) != 0 && !send(b) {
+			return
+		}
+	}
+	if err := sub.Err(); err != nil {
+		send(orBatch{err: err})
+	}
+}
+
+// prefetched checks if the current branch is scanned by a worker.
+func (it *orNext) prefetched() bool {
+	return it.pre != nil && it.curInd >= 0 && it.curInd < len(it.pre) && it.pre[it.curInd] != nil
+}
+
+// fill makes sure there are unread results in the current batch, receiving the next batch from a worker if needed.
+// It returns false if the worker has finished or failed.
+func (it *orNext) fill(ch <-chan orBatch) bool {
+	for it.pos+1 >= len(it.batch.refs) {
+		b, ok := <-ch
+		if !ok {
+			it.batch, it.pos = orBatch{}, -1
+			return false
+		} else if b.err != nil {
+			it.err = b.err
+			return false
+		}
+		it.batch, it.pos = b, -1
+	}
+	return true
+}
+
+// nextParallel is similar to Next, but receives results of prefetched branches from the workers.
+func (it *orNext) nextParallel(ctx context.Context) bool {
+	if it.curInd == -1 {
+		it.curInd = 0
+	}
+	for ; it.curInd < len(it.sub); it.curInd++ {
+		if ch := it.pre[it.curInd]; ch != nil {
+			if it.fill(ch) {
+				it.pos++
+				it.result = it.batch.refs[it.pos]
+				return true
+			}
+		} else if cur := it.sub[it.curInd]; cur.Next(ctx) {
+			it.result = cur.Result()
+			return true
+		} else {
+			it.err = cur.Err()
+		}
+		if it.err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// nextBatchParallel is similar to NextBatch, but receives results of prefetched branches from the workers.
+func (it *orNext) nextBatchParallel(ctx context.Context, buf []refs.Ref) int {
+	if it.curInd == -1 {
+		it.curInd = 0
+	}
+	for ; it.curInd < len(it.sub); it.curInd++ {
+		if ch := it.pre[it.curInd]; ch != nil {
+			if it.fill(ch) {
+				n := copy(buf, it.batch.refs[it.pos+1:])
+				it.pos += n
+				return n
+			}
+		} else {
+			cur := it.sub[it.curInd]
+			if n := NextBatch(ctx, cur, buf); n > 0 {
+				return n
+			}
+			it.err = cur.Err()
+		}
+		if it.err != nil {
+			return 0
+		}
+	}
+	return 0
+}
+
 func (it *orNext) Err() error {
 	return it.err
 }
@@ -206,20 +430,20 @@ func (it *orNext) Result() refs.Ref {
 	return it.result
 }
 
-// An Or has no NextPath of its own -- that is, there are no other values
+// An Or has no paths of its own -- that is, there are no other values
 // which satisfy our previous result that are not the result itself. Our
-// subiterators might, however, so just pass the call recursively. In the case of
-// shortcircuiting, only allow new results from the currently checked iterator
-func (it *orNext) NextPath(ctx context.Context) bool {
-	if it.curInd != -1 {
-		currIt := it.sub[it.curInd]
-		ok := currIt.NextPath(ctx)
-		if !ok {
-			it.err = currIt.Err()
+// subiterators might, however, so paths of the current subiterator are listed.
+func (it *orNext) Paths(ctx context.Context) Scanner {
+	if it.prefetched() {
+		if it.batch.paths == nil || it.pos < 0 || it.pos >= len(it.batch.refs) {
+			return nil
 		}
-		return ok
+		return newPathRows(it.batch.paths[it.pos][1:])
 	}
-	return false
+	if it.curInd < 0 || it.curInd >= len(it.sub) {
+		return nil
+	}
+	return Paths(ctx, it.sub[it.curInd])
 }
 
 // Close this iterator, and, by extension, close the subiterators.
@@ -227,6 +451,12 @@ func (it *orNext) NextPath(ctx context.Context) bool {
 // follow this contract, the Or follows the contract.  It closes all
 // subiterators it can, but returns the first error it encounters.
 func (it *orNext) Close() error {
+	if it.stop != nil {
+		// workers must finish before closing the branches they scan
+		close(it.stop)
+		it.wg.Wait()
+		it.stop = nil
+	}
 	var err error
 	for _, sub := range it.sub {
 		_err := sub.Close()
@@ -302,21 +532,15 @@ func (it *orContains) Contains(ctx context.Context, val refs.Ref) bool {
 	return true
 }
 
-// An Or has no NextPath of its own -- that is, there are no other values
+// An Or has no paths of its own -- that is, there are no other values
 // which satisfy our previous result that are not the result itself. Our
-// subiterators might, however, so just pass the call recursively. In the case of
-// shortcircuiting, only allow new results from the currently checked iterator
-func (it *orContains) NextPath(ctx context.Context) bool {
-	if it.curInd != -1 {
-		currIt := it.sub[it.curInd]
-		ok := currIt.NextPath(ctx)
-		if !ok {
-			it.err = currIt.Err()
-		}
-		return ok
+// subiterators might, however, so paths of the matching subiterator are listed.
+func (it *orContains) Paths(ctx context.Context) Scanner {
+	if it.curInd < 0 {
+		return nil
 	}
 	// TODO(dennwc): this should probably list matches from other sub-iterators
-	return false
+	return Paths(ctx, it.sub[it.curInd])
 }
 
 // Close this iterator, and, by extension, close the subiterators.
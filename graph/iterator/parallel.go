@@ -0,0 +1,95 @@
+package iterator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Workers is a bounded pool of workers shared by all iterators of a query.
+//
+// Iterators only use a worker if it is free at the moment, otherwise the work is done
+// in the calling goroutine. Thus, nested iterators may share the same pool without deadlocks.
+type Workers struct {
+	slots chan struct{}
+}
+
+// NewWorkers creates a pool that allows up to n concurrent workers for a query, including the calling goroutine.
+func NewWorkers(n int) *Workers {
+	if n < 1 {
+		n = 1
+	}
+	return &Workers{slots: make(chan struct{}, n-1)}
+}
+
+type workersKey struct{}
+
+// WithWorkers returns a context that enables parallel execution of iterators with a given pool.
+// Nil pool disables parallel execution.
+//
+// Or scans its branches concurrently and And checks its sub-iterators and candidate batches concurrently.
+// Results, tags and alternative paths are the same as for sequential execution, thus the mode is only
+// beneficial for quad stores with noticeable I/O latency, since it adds synchronization overhead.
+//
+// Scanners in parallel mode must be closed to stop background workers.
+func WithWorkers(ctx context.Context, w *Workers) context.Context {
+	return context.WithValue(ctx, workersKey{}, w)
+}
+
+// WithParallel is a shorthand for WithWorkers(ctx, NewWorkers(n)). It returns ctx unchanged if n <= 1.
+func WithParallel(ctx context.Context, n int) context.Context {
+	if n <= 1 {
+		return ctx
+	}
+	return WithWorkers(ctx, NewWorkers(n))
+}
+
+// workersFrom returns a pool associated with the context, or nil if parallel execution is disabled.
+func workersFrom(ctx context.Context) *Workers {
+	w, _ := ctx.Value(workersKey{}).(*Workers)
+	if w == nil || cap(w.slots) == 0 {
+		return nil
+	}
+	return w
+}
+
+// tryAcquire reserves a worker. It never blocks.
+func (w *Workers) tryAcquire() bool {
+	select {
+	case w.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *Workers) release() {
+	<-w.slots
+}
+
+// spawn calls fn with distinct ids from 0 to k-1 concurrently and waits for all of them to finish.
+// The first call runs in the calling goroutine, and k is limited by max and the number of free workers.
+func (w *Workers) spawn(max int, fn func(id int)) {
+	var wg sync.WaitGroup
+	for id := 1; w != nil && id < max && w.tryAcquire(); id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			defer w.release()
+			fn(id)
+		}(id)
+	}
+	fn(0)
+	wg.Wait()
+}
+
+// parallelFor calls fn for each i from 0 to n-1, using free workers for some of the calls.
+// The fn is called with an id of the worker, same as in spawn.
+func (w *Workers) parallelFor(n int, fn func(id, i int)) {
+	var next int64 = -1
+	w.spawn(n, func(id int) {
+		for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+			fn(id, i)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package iterator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// activity tracks the number of concurrent calls.
+type activity struct {
+	delay  time.Duration
+	active int64
+	max    int64
+}
+
+func (a *activity) enter() {
+	if a == nil {
+		return
+	}
+	n := atomic.AddInt64(&a.active, 1)
+	for {
+		m := atomic.LoadInt64(&a.max)
+		if n <= m || atomic.CompareAndSwapInt64(&a.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(a.delay)
+	atomic.AddInt64(&a.active, -1)
+}
+
+// pathsShape returns each value of a fixed iterator with a given number of paths.
+// Each path sets the tag to its index.
+type pathsShape struct {
+	*Fixed
+	tag   string
+	paths int
+	act   *activity
+}
+
+func newPathsShape(f *Fixed, tag string, paths int) *pathsShape {
+	return &pathsShape{Fixed: f, tag: tag, paths: paths}
+}
+
+func (s *pathsShape) Iterate() Scanner {
+	return &pathsScanner{Scanner: s.Fixed.Iterate(), s: s}
+}
+
+func (s *pathsShape) Lookup() Index {
+	return &pathsIndex{Index: s.Fixed.Lookup(), s: s}
+}
+
+type pathsScanner struct {
+	Scanner
+	s *pathsShape
+}
+
+func (it *pathsScanner) Next(ctx context.Context) bool {
+	it.s.act.enter()
+	return it.Scanner.Next(ctx)
+}
+
+func (it *pathsScanner) Paths(ctx context.Context) Scanner {
+	return newPathRows(it.Result(), it.s)
+}
+
+func (it *pathsScanner) TagResults(dst map[string]refs.Ref) {
+	dst[it.s.tag] = Int64Node(0)
+}
+
+type pathsIndex struct {
+	Index
+	s *pathsShape
+}
+
+func (it *pathsIndex) Contains(ctx context.Context, v refs.Ref) bool {
+	it.s.act.enter()
+	return it.Index.Contains(ctx, v)
+}
+
+func (it *pathsIndex) Paths(ctx context.Context) Scanner {
+	return newPathRows(it.Result(), it.s)
+}
+
+func (it *pathsIndex) TagResults(dst map[string]refs.Ref) {
+	dst[it.s.tag] = Int64Node(0)
+}
+
+// pathRows lists alternative paths of a value of pathsShape, starting from the second one.
+type pathRows struct {
+	res  refs.Ref
+	s    *pathsShape
+	path int
+}
+
+func newPathRows(res refs.Ref, s *pathsShape) Scanner {
+	if s.paths < 2 {
+		return nil
+	}
+	return &pathRows{res: res, s: s}
+}
+
+func (it *pathRows) Next(ctx context.Context) bool {
+	if it.path+1 >= it.s.paths {
+		return false
+	}
+	it.path++
+	return true
+}
+
+func (it *pathRows) Result() refs.Ref {
+	return it.res
+}
+
+func (it *pathRows) TagResults(dst map[string]refs.Ref) {
+	dst[it.s.tag] = Int64Node(it.path)
+}
+
+func (it *pathRows) Err() error     { return nil }
+func (it *pathRows) Close() error   { return nil }
+func (it *pathRows) String() string { return "PathRows" }
+
+func iteratedTags(t testing.TB, ctx context.Context, s Shape) []string {
+	var out []string
+	err := Iterate(ctx, s).UnOptimized().TagEach(func(m map[string]refs.Ref) error {
+		out = append(out, fmt.Sprint(m))
+		return nil
+	})
+	require.NoError(t, err)
+	return out
+}
+
+var parallelTests = []struct {
+	name  string
+	shape func() Shape
+}{
+	{"or", func() Shape {
+		return NewOr(
+			Tag(fixedRange(0, 5), "a"),
+			newPathsShape(fixedRange(3, 300), "b", 3),
+			Tag(fixedRange(0, 200), "c"),
+			fixedRange(7, 9),
+		)
+	}},
+	{"and", func() Shape {
+		return NewAnd(
+			Tag(fixedRange(0, 100), "a"),
+			newPathsShape(fixedRange(5, 50), "b", 2),
+			Tag(fixedRange(10, 80), "c"),
+			newPathsShape(fixedRange(0, 40), "d", 3),
+		)
+	}},
+	{"nested", func() Shape {
+		return NewOr(
+			NewAnd(fixedRange(0, 300), newPathsShape(fixedRange(100, 400), "a", 2), Tag(fixedRange(0, 150), "b")),
+			NewAnd(newPathsShape(fixedRange(0, 500), "c", 2), NewOr(fixedRange(0, 10), Tag(fixedRange(20, 30), "d"))),
+		)
+	}},
+}
+
+func TestParallel(t *testing.T) {
+	ctx := context.TODO()
+	for _, c := range parallelTests {
+		t.Run(c.name, func(t *testing.T) {
+			expect := iteratedTags(t, ctx, c.shape())
+			require.NotEmpty(t, expect)
+			for _, n := range []int{2, 4, 16} {
+				got := iteratedTags(t, WithParallel(ctx, n), c.shape())
+				require.Equal(t, expect, got, "workers: %d", n)
+			}
+		})
+	}
+}
+
+func TestParallelBatch(t *testing.T) {
+	ctx := WithParallel(context.TODO(), 4)
+	s := NewAnd(fixedRange(0, 1000), fixedRange(500, 2000), NewOr(fixedRange(0, 700), fixedRange(900, 1000)))
+
+	out, err := Iterate(ctx, s).UnOptimized().Paths(false).All()
+	require.NoError(t, err)
+	require.Len(t, out, 300)
+	require.Equal(t, Int64Node(500), out[0])
+	require.Equal(t, Int64Node(999), out[299])
+
+	s2 := NewOr(fixedRange(0, 500), fixedRange(0, 100), fixedRange(50, 1000))
+	expect := iterated(s2)
+	require.Len(t, expect, 1550)
+	out, err = Iterate(ctx, s2).UnOptimized().Paths(false).All()
+	require.NoError(t, err)
+	require.Len(t, out, len(expect))
+	for i, v := range out {
+		require.Equal(t, Int64Node(expect[i]), v)
+	}
+}
+
+func TestParallelConcurrency(t *testing.T) {
+	act := &activity{delay: time.Millisecond}
+	branch := func(from, to int) Shape {
+		s := newPathsShape(fixedRange(from, to), "a", 1)
+		s.act = act
+		return s
+	}
+	s := NewOr(branch(0, 10), branch(10, 20), branch(20, 30), branch(30, 40))
+
+	out, err := Iterate(WithParallel(context.TODO(), 4), s).UnOptimized().All()
+	require.NoError(t, err)
+	require.Len(t, out, 40)
+	require.True(t, atomic.LoadInt64(&act.max) > 1, "branches were not scanned concurrently")
+	require.True(t, atomic.LoadInt64(&act.max) <= 4, "too many workers: %d", act.max)
+
+	act.max = 0
+	out, err = Iterate(context.TODO(), s).UnOptimized().All()
+	require.NoError(t, err)
+	require.Len(t, out, 40)
+	require.Equal(t, int64(1), act.max)
+}
+
+func TestParallelErr(t *testing.T) {
+	ctx := WithParallel(context.TODO(), 4)
+	retErr := errors.New("unique")
+	s := NewOr(fixedRange(0, 5), fixedRange(5, 10), newTestIterator(false, retErr))
+
+	it := s.Iterate()
+	var n int
+	for it.Next(ctx) {
+		n++
+	}
+	require.Equal(t, 10, n)
+	require.Equal(t, retErr, it.Err())
+	require.NoError(t, it.Close())
+
+	s2 := NewAnd(fixedRange(0, 100), fixedRange(0, 100), newTestIterator(false, retErr))
+	_, err := Iterate(ctx, s2).UnOptimized().Paths(false).All()
+	require.Equal(t, retErr, err)
+}
@@ -0,0 +1,276 @@
+package iterator
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// PathIterator is an optional interface for Scanner and Index iterators that may have more than one
+// set of tag bindings for the current result.
+//
+// Scanners should not implement it, unless they combine the paths of their sub-iterators. Instead, alternative
+// bindings should be returned as separate results of Next, even if the value is the same. Indexes implement it
+// if a value may match with different bindings (for example, a single node may be linked by multiple quads).
+//
+// Callers that need all bindings can iterate results with Bindings. Callers that only need values
+// (see Chain.Paths) never see alternative bindings.
+type PathIterator interface {
+	Base
+
+	// Paths returns a scanner that lists alternative sets of tag bindings for the current result.
+	// Each set is returned as a separate result with the same value. The set returned by TagResults
+	// of the iterator itself is not included. Paths may return nil if there are no alternative bindings.
+	//
+	// The scanner must be closed before the iterator is advanced.
+	Paths(ctx context.Context) Scanner
+}
+
+// Paths returns a scanner that lists alternative sets of tag bindings for the current result of a given iterator.
+// It returns nil if the iterator does not implement PathIterator. See PathIterator for details.
+func Paths(ctx context.Context, it Base) Scanner {
+	if p, ok := it.(PathIterator); ok {
+		return p.Paths(ctx)
+	}
+	return nil
+}
+
+// SubPaths returns alternative bindings of a sub-iterator as bindings of a parent iterator with a given result.
+// If tags is not nil, it is called to add tags of the parent iterator to each set of bindings.
+// It returns nil if the sub-iterator has no alternative bindings.
+func SubPaths(ctx context.Context, sub Base, res refs.Ref, tags func(dst map[string]refs.Ref)) Scanner {
+	sc := Paths(ctx, sub)
+	if sc == nil {
+		return nil
+	}
+	return &subPaths{Scanner: sc, res: res, tags: tags}
+}
+
+type subPaths struct {
+	Scanner
+	res  refs.Ref
+	tags func(dst map[string]refs.Ref)
+}
+
+func (it *subPaths) Result() refs.Ref {
+	return it.res
+}
+
+func (it *subPaths) TagResults(dst map[string]refs.Ref) {
+	it.Scanner.TagResults(dst)
+	if it.tags != nil {
+		it.tags(dst)
+	}
+}
+
+// pathsOf returns the current result of an iterator with its tags, followed by all alternative paths.
+func pathsOf(ctx context.Context, it Base) ([]result, error) {
+	cur := func(it Base) result {
+		tags := make(map[string]refs.Ref)
+		it.TagResults(tags)
+		return result{id: it.Result(), tags: tags}
+	}
+	out := []result{cur(it)}
+	sc := Paths(ctx, it)
+	if sc == nil {
+		return out, nil
+	}
+	for sc.Next(ctx) {
+		out = append(out, cur(sc))
+	}
+	err := sc.Err()
+	if err2 := sc.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return out, err
+}
+
+// joinPaths returns a scanner that lists a value with all combinations of paths of given iterators,
+// except the combination of their current paths. Tags of later iterators override tags of earlier ones,
+// the same way as TagResults of composite iterators do. It returns nil if there are no alternative paths.
+func joinPaths(ctx context.Context, res refs.Ref, subs ...Base) Scanner {
+	sets := make([][]result, 0, len(subs))
+	for _, sub := range subs {
+		paths, err := pathsOf(ctx, sub)
+		if err != nil {
+			return &pathRows{err: err}
+		}
+		sets = append(sets, paths)
+	}
+	return joinSets(res, sets...)
+}
+
+// joinSets is similar to joinPaths, but combines sets of paths that were already collected.
+// The first path of each set must be the current one.
+func joinSets(res refs.Ref, sets ...[]result) Scanner {
+	n := 1
+	for _, set := range sets {
+		n *= len(set)
+	}
+	if n <= 1 {
+		return nil
+	}
+	rows := make([]result, 0, n-1)
+	ind := make([]int, len(sets))
+	for {
+		// advance indexes of the sets as digits of a number, the last set changing first
+		i := len(ind) - 1
+		for ; i >= 0; i-- {
+			if ind[i]++; ind[i] < len(sets[i]) {
+				break
+			}
+			ind[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+		tags := make(map[string]refs.Ref)
+		for j, set := range sets {
+			for k, v := range set[ind[j]].tags {
+				tags[k] = v
+			}
+		}
+		rows = append(rows, result{id: res, tags: tags})
+	}
+	return &pathRows{rows: rows}
+}
+
+// newPathRows returns a scanner that lists given results. It returns nil if the list is empty.
+func newPathRows(rows []result) Scanner {
+	if len(rows) == 0 {
+		return nil
+	}
+	return &pathRows{rows: rows}
+}
+
+var _ Scanner = (*pathRows)(nil)
+
+// pathRows is a scanner that lists precomputed paths.
+type pathRows struct {
+	rows []result
+	cur  int
+	err  error
+}
+
+func (it *pathRows) Next(ctx context.Context) bool {
+	if it.err != nil || it.cur >= len(it.rows) {
+		return false
+	}
+	it.cur++
+	return true
+}
+
+func (it *pathRows) Result() refs.Ref {
+	if it.cur == 0 {
+		return nil
+	}
+	return it.rows[it.cur-1].id
+}
+
+func (it *pathRows) TagResults(dst map[string]refs.Ref) {
+	if it.cur == 0 {
+		return
+	}
+	for k, v := range it.rows[it.cur-1].tags {
+		dst[k] = v
+	}
+}
+
+func (it *pathRows) Err() error {
+	return it.err
+}
+
+func (it *pathRows) Close() error {
+	it.rows = nil
+	return nil
+}
+
+func (it *pathRows) String() string {
+	return "Paths"
+}
+
+// Bindings returns a scanner that lists each set of tag bindings of a given scanner as a separate result.
+// It allows to get all paths of an iterator tree by calling Next only:
+//
+//	it := Bindings(s.Iterate())
+//	for it.Next(ctx) {
+//		it.TagResults(tags)
+//	}
+//
+// The same value is returned multiple times if it has multiple paths.
+func Bindings(it Scanner) Scanner {
+	if _, ok := it.(PathIterator); !ok {
+		return it
+	}
+	return &bindings{Scanner: it}
+}
+
+// bindings is a binding-set iterator that flattens alternative paths of a scanner into a sequence of results.
+type bindings struct {
+	Scanner
+	started bool    // paths of the current result were not requested yet
+	paths   Scanner // alternative paths of the current result
+	err     error
+}
+
+// closePaths closes the scanner of alternative paths, if any.
+func (it *bindings) closePaths() {
+	if it.paths == nil {
+		return
+	}
+	err := it.paths.Err()
+	if err2 := it.paths.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	if err != nil && it.err == nil {
+		it.err = err
+	}
+	it.paths = nil
+}
+
+func (it *bindings) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.started {
+		it.started = false
+		it.paths = Paths(ctx, it.Scanner)
+	}
+	if it.paths != nil {
+		if it.paths.Next(ctx) {
+			return true
+		}
+		if it.closePaths(); it.err != nil {
+			return false
+		}
+	}
+	it.started = it.Scanner.Next(ctx)
+	return it.started
+}
+
+func (it *bindings) Result() refs.Ref {
+	if it.paths != nil {
+		return it.paths.Result()
+	}
+	return it.Scanner.Result()
+}
+
+func (it *bindings) TagResults(dst map[string]refs.Ref) {
+	if it.paths != nil {
+		it.paths.TagResults(dst)
+		return
+	}
+	it.Scanner.TagResults(dst)
+}
+
+func (it *bindings) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.Scanner.Err()
+}
+
+func (it *bindings) Close() error {
+	it.closePaths()
+	return it.Scanner.Close()
+}
@@ -0,0 +1,60 @@
+package iterator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+func TestBindings(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		return NewAnd(
+			newPathsShape(fixedRange(0, 3), "a", 2),
+			newPathsShape(fixedRange(1, 5), "b", 3),
+		)
+	}
+	row := func(it Base) string {
+		tags := make(map[string]refs.Ref)
+		it.TagResults(tags)
+		return fmt.Sprint(it.Result(), tags)
+	}
+
+	// all combinations of paths of sub-iterators are listed, the current one first
+	var expect []string
+	for v := 1; v <= 2; v++ {
+		for a := 0; a < 2; a++ {
+			for b := 0; b < 3; b++ {
+				expect = append(expect, fmt.Sprint(Int64Node(v), map[string]refs.Ref{
+					"a": Int64Node(a), "b": Int64Node(b),
+				}))
+			}
+		}
+	}
+
+	var got []string
+	it := Bindings(shape().Iterate())
+	for it.Next(ctx) {
+		got = append(got, row(it))
+	}
+	require.NoError(t, it.Err())
+	require.NoError(t, it.Close())
+	require.Equal(t, expect, got)
+
+	// alternative paths are not listed when only values are requested
+	vals, err := Iterate(ctx, shape()).UnOptimized().Paths(false).All()
+	require.NoError(t, err)
+	require.Equal(t, []refs.Ref{Int64Node(1), Int64Node(2)}, vals)
+}
+
+func TestBindingsNoPaths(t *testing.T) {
+	sc := fixedRange(0, 3).Iterate()
+	require.Equal(t, sc, Bindings(sc))
+	_, ok := sc.(PathIterator)
+	require.False(t, ok)
+}
@@ -0,0 +1,251 @@
+package iterator
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// Composite is an optional interface for shapes with sub-iterators that allows to replace them.
+// It is used to instrument iterator trees after optimization (see Profile).
+type Composite interface {
+	Shape
+	// MapSubIterators returns a copy of the shape with each sub-iterator replaced by the result of m.
+	MapSubIterators(m Morphism) Shape
+}
+
+var (
+	_ Composite = (*And)(nil)
+	_ Composite = (*Or)(nil)
+	_ Composite = (*Not)(nil)
+	_ Composite = (*Save)(nil)
+	_ Composite = (*Limit)(nil)
+	_ Composite = (*Skip)(nil)
+	_ Composite = (*Unique)(nil)
+	_ Composite = (*Sort)(nil)
+	_ Composite = (*Sample)(nil)
+	_ Composite = (*SaveCount)(nil)
+	_ Composite = (*Count)(nil)
+	_ Composite = (*Materialize)(nil)
+	_ Composite = (*Recursive)(nil)
+	_ Composite = (*ValueFilter)(nil)
+	_ Composite = (*HashJoin)(nil)
+	_ Composite = (*MergeJoin)(nil)
+)
+
+// Profile collects runtime statistics of iterators: the number of calls of each method, the number
+// of results and the time spent in each iterator. Iterator trees are instrumented by Wrap.
+//
+// A single profile can be shared by all iterator trees of a query.
+// It can be read after the query is completed.
+type Profile struct {
+	mu    sync.Mutex
+	trees []*ProfileNode
+}
+
+// NewProfile creates an empty profile.
+func NewProfile() *Profile {
+	return &Profile{}
+}
+
+// ProfileNode is a runtime statistic of a single iterator in the tree.
+//
+// Calls of the same iterator from different scanners and indexes are accumulated, for example,
+// when a query iterates the tree multiple times or evaluates it in parallel.
+type ProfileNode struct {
+	Name     string `json:"name"`
+	Next     int64  `json:"next"`              // calls of Next
+	Batches  int64  `json:"batches,omitempty"` // calls of NextBatch
+	Paths    int64  `json:"paths"`             // calls of Paths and Next of the returned scanners
+	Contains int64  `json:"contains"`          // calls of Contains
+	Results  int64  `json:"results"`           // values or paths returned by all calls
+	// Time is the wall time spent in all calls, including calls of sub-iterators.
+	Time time.Duration `json:"time"`
+	// Sub are statistics of sub-iterators. Sub-iterators of shapes that do not implement Composite
+	// are not instrumented, thus they are not listed.
+	Sub []*ProfileNode `json:"sub,omitempty"`
+}
+
+// Trees returns statistics of all instrumented iterator trees, in the order they were wrapped.
+func (p *Profile) Trees() []*ProfileNode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*ProfileNode{}, p.trees...)
+}
+
+// MarshalJSON implements json.Marshaler. The profile is encoded as a list of trees.
+func (p *Profile) MarshalJSON() ([]byte, error) {
+	trees := p.Trees()
+	if trees == nil {
+		trees = []*ProfileNode{}
+	}
+	return json.Marshal(trees)
+}
+
+// Wrap instruments an iterator tree to collect its statistics in the profile.
+// The tree should be wrapped after optimization, since the optimizer cannot see through instrumented iterators.
+// Nil profile returns the shape unchanged.
+func (p *Profile) Wrap(s Shape) Shape {
+	if p == nil {
+		return s
+	} else if ps, ok := s.(*profileShape); ok && ps.p == p {
+		return s // already instrumented
+	}
+	ps := p.wrap(s)
+	p.mu.Lock()
+	p.trees = append(p.trees, ps.node)
+	p.mu.Unlock()
+	return ps
+}
+
+func (p *Profile) wrap(s Shape) *profileShape {
+	node := &ProfileNode{Name: s.String()}
+	if c, ok := s.(Composite); ok {
+		s = c.MapSubIterators(func(sub Shape) Shape {
+			ps := p.wrap(sub)
+			node.Sub = append(node.Sub, ps.node)
+			return ps
+		})
+	}
+	return &profileShape{s: s, p: p, node: node}
+}
+
+type profileKey struct{}
+
+// WithProfile returns a context that makes Instrument collect statistics of iterators in a given profile.
+func WithProfile(ctx context.Context, p *Profile) context.Context {
+	return context.WithValue(ctx, profileKey{}, p)
+}
+
+// ProfileFrom returns a profile associated with the context, or nil if profiling is disabled.
+func ProfileFrom(ctx context.Context) *Profile {
+	p, _ := ctx.Value(profileKey{}).(*Profile)
+	return p
+}
+
+// Instrument wraps an iterator tree to collect its statistics in the profile associated with the context.
+// It returns the shape unchanged if profiling is disabled. See Profile.Wrap.
+func Instrument(ctx context.Context, s Shape) Shape {
+	return ProfileFrom(ctx).Wrap(s)
+}
+
+// record accounts a single call that took a given time.
+func (n *ProfileNode) record(calls *int64, start time.Time, results int) {
+	atomic.AddInt64((*int64)(&n.Time), int64(time.Since(start)))
+	atomic.AddInt64(calls, 1)
+	if results != 0 {
+		atomic.AddInt64(&n.Results, int64(results))
+	}
+}
+
+func boolResult(ok bool) int {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+var _ OrderedShape = (*profileShape)(nil)
+
+// profileShape is an instrumented iterator.
+type profileShape struct {
+	s    Shape
+	p    *Profile
+	node *ProfileNode
+}
+
+func (it *profileShape) Iterate() Scanner {
+	return &profileScanner{Scanner: it.s.Iterate(), node: it.node}
+}
+
+func (it *profileShape) Lookup() Index {
+	return &profileIndex{Index: it.s.Lookup(), node: it.node}
+}
+
+func (it *profileShape) String() string {
+	return it.s.String()
+}
+
+func (it *profileShape) Stats(ctx context.Context) (Costs, error) {
+	return it.s.Stats(ctx)
+}
+
+// Optimize does nothing, since instrumented trees are already optimized.
+func (it *profileShape) Optimize(ctx context.Context) (Shape, bool) {
+	return it, false
+}
+
+func (it *profileShape) SubIterators() []Shape {
+	return it.s.SubIterators()
+}
+
+func (it *profileShape) Order() Order {
+	return OrderOf(it.s)
+}
+
+type profileScanner struct {
+	Scanner
+	node *ProfileNode
+}
+
+func (it *profileScanner) Next(ctx context.Context) bool {
+	start := time.Now()
+	ok := it.Scanner.Next(ctx)
+	it.node.record(&it.node.Next, start, boolResult(ok))
+	return ok
+}
+
+// NextBatch implements BatchScanner.
+func (it *profileScanner) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	start := time.Now()
+	n := NextBatch(ctx, it.Scanner, buf)
+	it.node.record(&it.node.Batches, start, n)
+	return n
+}
+
+func (it *profileScanner) Paths(ctx context.Context) Scanner {
+	return it.node.paths(ctx, it.Scanner)
+}
+
+type profileIndex struct {
+	Index
+	node *ProfileNode
+}
+
+func (it *profileIndex) Contains(ctx context.Context, v refs.Ref) bool {
+	start := time.Now()
+	ok := it.Index.Contains(ctx, v)
+	it.node.record(&it.node.Contains, start, boolResult(ok))
+	return ok
+}
+
+func (it *profileIndex) Paths(ctx context.Context) Scanner {
+	return it.node.paths(ctx, it.Index)
+}
+
+// paths lists alternative paths of an iterator, recording each path in the profile node.
+func (n *ProfileNode) paths(ctx context.Context, it Base) Scanner {
+	start := time.Now()
+	sc := Paths(ctx, it)
+	n.record(&n.Paths, start, 0)
+	if sc == nil {
+		return nil
+	}
+	return &profilePaths{Scanner: sc, node: n}
+}
+
+type profilePaths struct {
+	Scanner
+	node *ProfileNode
+}
+
+func (it *profilePaths) Next(ctx context.Context) bool {
+	start := time.Now()
+	ok := it.Scanner.Next(ctx)
+	it.node.record(&it.node.Paths, start, boolResult(ok))
+	return ok
+}
@@ -0,0 +1,63 @@
+package iterator_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+)
+
+func TestProfile(t *testing.T) {
+	p := NewProfile()
+	ctx := WithProfile(context.TODO(), p)
+
+	s := NewAnd(
+		Tag(fixedRange(0, 10), "a"),
+		NewOr(fixedRange(5, 7), fixedRange(8, 20)),
+	)
+	expect := iteratedTags(t, context.TODO(), s)
+	got := iteratedTags(t, ctx, s)
+	require.Equal(t, expect, got)
+
+	trees := p.Trees()
+	require.Len(t, trees, 1)
+	and := trees[0]
+	require.Equal(t, "And", and.Name)
+	require.Equal(t, int64(len(expect)+1), and.Next)
+	require.Equal(t, int64(len(expect)), and.Results)
+	require.Len(t, and.Sub, 2)
+
+	primary := and.Sub[0]
+	require.Equal(t, int64(11), primary.Next)
+	require.Equal(t, int64(10), primary.Results)
+	require.Zero(t, primary.Contains)
+
+	or := and.Sub[1]
+	require.Equal(t, "Or", or.Name)
+	require.Equal(t, int64(10), or.Contains)
+	require.Zero(t, or.Next)
+	require.Len(t, or.Sub, 2)
+	// the second branch is only checked for values not found in the first one
+	require.Equal(t, int64(10), or.Sub[0].Contains)
+	require.Equal(t, int64(2), or.Sub[0].Results)
+	require.Equal(t, int64(8), or.Sub[1].Contains)
+	require.Equal(t, int64(2), or.Sub[1].Results)
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	var out []map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Len(t, out, 1)
+	require.Equal(t, "And", out[0]["name"])
+}
+
+func TestProfileDisabled(t *testing.T) {
+	s := fixedRange(0, 10)
+	require.Equal(t, Shape(s), Instrument(context.TODO(), s))
+
+	var p *Profile
+	require.Equal(t, Shape(s), p.Wrap(s))
+}
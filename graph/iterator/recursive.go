@@ -53,6 +53,13 @@ func (it *Recursive) SubIterators() []Shape {
 	return []Shape{it.subIt}
 }
 
+// MapSubIterators implements Composite.
+func (it *Recursive) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.subIt = m(it.subIt)
+	return &nit
+}
+
 func (it *Recursive) Optimize(ctx context.Context) (Shape, bool) {
 	newIt, optimized := it.subIt.Optimize(ctx)
 	if optimized {
@@ -97,7 +104,6 @@ type recursiveNext struct {
 	depth         int
 	maxDepth      int
 	pathMap       map[interface{}][]map[string]refs.Ref
-	pathIndex     int
 	containsValue refs.Ref
 	depthTags     []string
 	depthCache    []refs.Ref
@@ -119,14 +125,19 @@ func newRecursiveNext(it Scanner, morphism Morphism, maxDepth int, depthTags []s
 }
 
 func (it *recursiveNext) TagResults(dst map[string]refs.Ref) {
+	it.tagPath(dst, 0)
+}
+
+// tagPath fills tags of a given path of the current result.
+func (it *recursiveNext) tagPath(dst map[string]refs.Ref, path int) {
 	for _, tag := range it.depthTags {
 		dst[tag] = refs.PreFetched(quad.Int(it.result.depth))
 	}
 
 	if it.containsValue != nil {
 		paths := it.pathMap[refs.ToKey(it.containsValue)]
-		if len(paths) != 0 {
-			for k, v := range paths[it.pathIndex] {
+		if path < len(paths) {
+			for k, v := range paths[path] {
 				dst[k] = v
 			}
 		}
@@ -138,26 +149,43 @@ func (it *recursiveNext) TagResults(dst map[string]refs.Ref) {
 }
 
 func (it *recursiveNext) Next(ctx context.Context) bool {
-	it.pathIndex = 0
+	if it.err != nil {
+		return false
+	}
+	lim := limiterFrom(ctx)
 	if it.depth == 0 {
 		for it.subIt.Next(ctx) {
+			if it.err = ctx.Err(); it.err != nil {
+				return false
+			}
 			res := it.subIt.Result()
 			it.depthCache = append(it.depthCache, it.subIt.Result())
-			tags := make(map[string]refs.Ref)
-			it.subIt.TagResults(tags)
+			if it.err = lim.frontier(len(it.depthCache)); it.err != nil {
+				return false
+			}
+			paths, err := pathsOf(ctx, it.subIt)
+			if err != nil {
+				it.err = err
+				return false
+			}
 			key := refs.ToKey(res)
-			it.pathMap[key] = append(it.pathMap[key], tags)
-			for it.subIt.NextPath(ctx) {
-				tags := make(map[string]refs.Ref)
-				it.subIt.TagResults(tags)
-				it.pathMap[key] = append(it.pathMap[key], tags)
+			for _, p := range paths {
+				it.pathMap[key] = append(it.pathMap[key], p.tags)
 			}
 		}
+		if it.err = it.subIt.Err(); it.err != nil {
+			return false
+		}
 	}
 
 	for {
+		if it.err = ctx.Err(); it.err != nil {
+			return false
+		}
 		if !it.nextIt.Next(ctx) {
-			if it.maxDepth > 0 && it.depth >= it.maxDepth {
+			if it.err = it.nextIt.Err(); it.err != nil {
+				return false
+			} else if it.maxDepth > 0 && it.depth >= it.maxDepth {
 				return false
 			} else if len(it.depthCache) == 0 {
 				return false
@@ -168,7 +196,8 @@ func (it *recursiveNext) Next(ctx context.Context) bool {
 			if it.nextIt != nil {
 				it.nextIt.Close()
 			}
-			it.nextIt = it.morphism(Tag(it.baseIt, recursiveBaseTag)).Iterate()
+			// the tree of each step is built during the iteration, thus it must be wrapped separately
+			it.nextIt = Enforce(ctx, it.morphism(Tag(it.baseIt, recursiveBaseTag))).Iterate()
 			continue
 		}
 		val := it.nextIt.Result()
@@ -187,6 +216,9 @@ func (it *recursiveNext) Next(ctx context.Context) bool {
 			it.result.val = val
 			it.containsValue = it.getBaseValue(val)
 			it.depthCache = append(it.depthCache, val)
+			if it.err = lim.frontier(len(it.depthCache)); it.err != nil {
+				return false
+			}
 			return true
 		}
 	}
@@ -215,12 +247,22 @@ func (it *recursiveNext) getBaseValue(val refs.Ref) refs.Ref {
 	return at.val
 }
 
-func (it *recursiveNext) NextPath(ctx context.Context) bool {
-	if it.pathIndex+1 >= len(it.pathMap[refs.ToKey(it.containsValue)]) {
-		return false
+// Paths lists the current result with tags of other paths to its base value.
+func (it *recursiveNext) Paths(ctx context.Context) Scanner {
+	if it.containsValue == nil {
+		return nil
+	}
+	n := len(it.pathMap[refs.ToKey(it.containsValue)])
+	if n < 2 {
+		return nil
 	}
-	it.pathIndex++
-	return true
+	rows := make([]result, 0, n-1)
+	for i := 1; i < n; i++ {
+		tags := make(map[string]refs.Ref)
+		it.tagPath(tags, i)
+		rows = append(rows, result{id: it.result.val, tags: tags})
+	}
+	return newPathRows(rows)
 }
 
 func (it *recursiveNext) Close() error {
@@ -268,7 +310,6 @@ func (it *recursiveContains) Result() refs.Ref {
 }
 
 func (it *recursiveContains) Contains(ctx context.Context, val refs.Ref) bool {
-	it.next.pathIndex = 0
 	key := refs.ToKey(val)
 	if at, ok := it.next.seen[key]; ok {
 		it.next.containsValue = it.next.getBaseValue(val)
@@ -285,8 +326,13 @@ func (it *recursiveContains) Contains(ctx context.Context, val refs.Ref) bool {
 	return false
 }
 
-func (it *recursiveContains) NextPath(ctx context.Context) bool {
-	return it.next.NextPath(ctx)
+func (it *recursiveContains) Paths(ctx context.Context) Scanner {
+	tags := it.tags
+	return SubPaths(ctx, it.next, it.next.Result(), func(dst map[string]refs.Ref) {
+		for k, v := range tags {
+			dst[k] = v
+		}
+	})
 }
 
 func (it *recursiveContains) Close() error {
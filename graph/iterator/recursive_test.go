@@ -89,7 +89,7 @@ func TestRecursiveContains(t *testing.T) {
 	}
 }
 
-func TestRecursiveNextPath(t *testing.T) {
+func TestRecursivePaths(t *testing.T) {
 	ctx := context.TODO()
 	qs := recTestQs
 	start := qs.NodesAllIterator()
@@ -100,7 +100,7 @@ func TestRecursiveNextPath(t *testing.T) {
 	fixed := NewFixed()
 	fixed.Add(refs.PreFetched(quad.Raw("alice")))
 	and.AddSubIterator(fixed)
-	r := NewRecursive(and, singleHop(qs, "parent"), 0).Iterate()
+	r := Bindings(NewRecursive(and, singleHop(qs, "parent"), 0).Iterate())
 
 	expected := []string{"fred", "fred", "fred", "fred", "greg", "greg", "greg", "greg"}
 	var got []string
@@ -110,14 +110,8 @@ func TestRecursiveNextPath(t *testing.T) {
 		vn, err := qs.NameOf(res["person"])
 		require.NoError(t, err)
 		got = append(got, quad.ToString(vn))
-		for r.NextPath(ctx) {
-			res := make(map[string]refs.Ref)
-			r.TagResults(res)
-			vn, err := qs.NameOf(res["person"])
-			require.NoError(t, err)
-			got = append(got, quad.ToString(vn))
-		}
 	}
+	require.NoError(t, r.Err())
 	sort.Strings(expected)
 	sort.Strings(got)
 	require.Equal(t, expected, got)
@@ -146,10 +146,6 @@ func (it *resolverNext) Result() refs.Ref {
 	return it.result
 }
 
-func (it *resolverNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // A Resolver iterator consists of it's order, an index (where it is in the,
 // process of iterating) and a store to resolve values from.
 type resolverContains struct {
@@ -221,7 +217,3 @@ func (it *resolverContains) Err() error {
 func (it *resolverContains) Result() refs.Ref {
 	return it.result
 }
-
-func (it *resolverContains) NextPath(ctx context.Context) bool {
-	return false
-}
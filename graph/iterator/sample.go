@@ -0,0 +1,267 @@
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// Sample iterator returns a uniform random sample of values from its subiterator.
+//
+// Values are selected with reservoir sampling in a single pass over the subiterator, thus only
+// the sampled values are kept in memory. Sampled values are returned in the order of the subiterator,
+// together with all their paths.
+//
+// The same seed produces the same sample, as long as the subiterator returns values in the same order.
+type Sample struct {
+	it   Shape
+	n    int64
+	seed int64
+}
+
+// NewSample creates a new Sample iterator that selects up to n values.
+// Zero seed selects a random seed for each iteration.
+func NewSample(it Shape, n int64, seed int64) *Sample {
+	return &Sample{it: it, n: n, seed: seed}
+}
+
+func (it *Sample) Iterate() Scanner {
+	if it.n <= 0 {
+		return NewNull().Iterate()
+	}
+	return newSampleNext(it.it.Iterate(), it.n, it.seed)
+}
+
+func (it *Sample) Lookup() Index {
+	if it.n <= 0 {
+		return NewNull().Lookup()
+	}
+	return &sampleContains{next: newSampleNext(it.it.Iterate(), it.n, it.seed)}
+}
+
+// SubIterators returns a slice of the sub iterators.
+func (it *Sample) SubIterators() []Shape {
+	return []Shape{it.it}
+}
+
+// MapSubIterators implements Composite.
+func (it *Sample) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.it = m(it.it)
+	return &nit
+}
+
+func (it *Sample) Optimize(ctx context.Context) (Shape, bool) {
+	nit, optimized := it.it.Optimize(ctx)
+	if it.n <= 0 || IsNull(nit) {
+		return NewNull(), true
+	}
+	it.it = nit
+	return it, optimized
+}
+
+func (it *Sample) Stats(ctx context.Context) (Costs, error) {
+	st, err := it.it.Stats(ctx)
+	if it.n <= 0 {
+		return Costs{Size: refs.Size{Value: 0, Exact: true}}, err
+	}
+	// all values of the subiterator are read to return the sample
+	if st.Size.Value > it.n {
+		st.NextCost = st.NextCost * st.Size.Value / it.n
+		st.Size.Value = it.n
+	}
+	return Costs{
+		NextCost:     st.NextCost,
+		ContainsCost: st.NextCost,
+		Size:         st.Size,
+	}, err
+}
+
+func (it *Sample) String() string {
+	return fmt.Sprintf("Sample(%d)", it.n)
+}
+
+// sampleValue is a sampled value with all its paths.
+type sampleValue struct {
+	paths []result
+	seq   int64 // position of the value in the subiterator
+}
+
+type sampleNext struct {
+	it   Scanner
+	n    int64
+	seed int64
+
+	loaded bool
+	values []sampleValue
+	index  int
+	err    error
+
+	budget *MemoryBudget
+	used   int64
+}
+
+func newSampleNext(it Scanner, n int64, seed int64) *sampleNext {
+	return &sampleNext{it: it, n: n, seed: seed, index: -1}
+}
+
+// load reads all values from the subiterator and selects the sample.
+func (it *sampleNext) load(ctx context.Context) {
+	it.loaded = true
+	it.budget = memoryBudgetFrom(ctx)
+	seed := it.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	for seq := int64(0); it.it.Next(ctx); seq++ {
+		if it.err = ctx.Err(); it.err != nil {
+			return
+		}
+		i := seq
+		if seq >= it.n {
+			// replace a random value in the reservoir with probability n/(seq+1)
+			i = rnd.Int63n(seq + 1)
+			if i >= it.n {
+				continue
+			}
+		}
+		paths, err := pathsOf(ctx, it.it)
+		if err != nil {
+			it.err = err
+			return
+		}
+		v := sampleValue{paths: paths, seq: seq}
+		sz := v.cost()
+		if seq < it.n {
+			it.values = append(it.values, v)
+		} else {
+			sz -= it.values[i].cost()
+			it.values[i] = v
+		}
+		it.used += sz
+		if sz > 0 {
+			it.budget.grow(sz)
+		} else {
+			it.budget.release(-sz)
+		}
+	}
+	if it.err = it.it.Err(); it.err != nil {
+		return
+	}
+	sort.Slice(it.values, func(i, j int) bool {
+		return it.values[i].seq < it.values[j].seq
+	})
+}
+
+// cost returns an approximate memory usage of the value.
+func (v sampleValue) cost() int64 {
+	n := int64(entryCost)
+	for _, p := range v.paths {
+		n += entryCost + refCost + tagsCost(p.tags)
+	}
+	return n
+}
+
+func (it *sampleNext) Next(ctx context.Context) bool {
+	if !it.loaded {
+		it.load(ctx)
+	}
+	if it.err != nil || it.index+1 >= len(it.values) {
+		return false
+	}
+	it.index++
+	return true
+}
+
+func (it *sampleNext) Paths(ctx context.Context) Scanner {
+	if it.index < 0 || it.index >= len(it.values) {
+		return nil
+	}
+	return newPathRows(it.values[it.index].paths[1:])
+}
+
+func (it *sampleNext) Result() refs.Ref {
+	if it.index < 0 || it.index >= len(it.values) {
+		return nil
+	}
+	return it.values[it.index].paths[0].id
+}
+
+func (it *sampleNext) TagResults(dst map[string]refs.Ref) {
+	if it.index < 0 || it.index >= len(it.values) {
+		return
+	}
+	for k, v := range it.values[it.index].paths[0].tags {
+		dst[k] = v
+	}
+}
+
+func (it *sampleNext) Err() error {
+	return it.err
+}
+
+func (it *sampleNext) Close() error {
+	it.values = nil
+	it.budget.release(it.used)
+	it.used = 0
+	return it.it.Close()
+}
+
+func (it *sampleNext) String() string {
+	return fmt.Sprintf("SampleNext(%d)", it.n)
+}
+
+// sampleContains checks values against the sample. The sample is selected on the first call.
+type sampleContains struct {
+	next  *sampleNext
+	index map[interface{}]int
+}
+
+func (it *sampleContains) Contains(ctx context.Context, v refs.Ref) bool {
+	if it.index == nil {
+		it.next.load(ctx)
+		it.index = make(map[interface{}]int, len(it.next.values))
+		for i, sv := range it.next.values {
+			it.index[refs.ToKey(sv.paths[0].id)] = i
+		}
+	}
+	if it.next.err != nil {
+		return false
+	}
+	i, ok := it.index[refs.ToKey(v)]
+	if !ok {
+		it.next.index = -1
+		return false
+	}
+	it.next.index = i
+	return true
+}
+
+func (it *sampleContains) Paths(ctx context.Context) Scanner {
+	return it.next.Paths(ctx)
+}
+
+func (it *sampleContains) Result() refs.Ref {
+	return it.next.Result()
+}
+
+func (it *sampleContains) TagResults(dst map[string]refs.Ref) {
+	it.next.TagResults(dst)
+}
+
+func (it *sampleContains) Err() error {
+	return it.next.Err()
+}
+
+func (it *sampleContains) Close() error {
+	return it.next.Close()
+}
+
+func (it *sampleContains) String() string {
+	return fmt.Sprintf("SampleContains(%d)", it.next.n)
+}
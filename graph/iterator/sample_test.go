@@ -0,0 +1,106 @@
+package iterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+func sampledValues(t testing.TB, ctx context.Context, s Shape) []int64 {
+	var out []int64
+	err := Iterate(ctx, s).UnOptimized().Each(func(r refs.Ref) error {
+		out = append(out, int64(r.(Int64Node)))
+		return nil
+	})
+	require.NoError(t, err)
+	return out
+}
+
+func TestSample(t *testing.T) {
+	ctx := context.TODO()
+	b := newTestBudget(t, 1<<20)
+	bctx := WithMemoryBudget(ctx, b)
+
+	got := sampledValues(t, bctx, NewSample(fixedRange(0, 1000), 10, 1))
+	require.Len(t, got, 10)
+	for i := 1; i < len(got); i++ {
+		// values are returned in the order of the subiterator
+		require.True(t, got[i-1] < got[i], "%v", got)
+	}
+	requireNoSpills(t, b)
+
+	require.Equal(t, got, sampledValues(t, ctx, NewSample(fixedRange(0, 1000), 10, 1)))
+	require.NotEqual(t, got, sampledValues(t, ctx, NewSample(fixedRange(0, 1000), 10, 2)))
+
+	// the sample is the whole input if it is small enough
+	require.Equal(t, []int64{0, 1, 2}, sampledValues(t, ctx, NewSample(fixedRange(0, 3), 10, 0)))
+}
+
+func TestSampleUniform(t *testing.T) {
+	ctx := context.TODO()
+	const (
+		n      = 10
+		k      = 3
+		trials = 3000
+	)
+	counts := make([]int, n)
+	for seed := int64(1); seed <= trials; seed++ {
+		for _, v := range sampledValues(t, ctx, NewSample(fixedRange(0, n), k, seed)) {
+			counts[v]++
+		}
+	}
+	expect := trials * k / n
+	for v, c := range counts {
+		require.InDelta(t, expect, c, float64(expect)/5, "value: %d", v)
+	}
+}
+
+func TestSampleEmpty(t *testing.T) {
+	ctx := context.TODO()
+	for _, n := range []int64{0, -1} {
+		s := NewSample(fixedRange(0, 100), n, 1)
+		st, err := s.Stats(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), st.Size.Value)
+		require.Empty(t, sampledValues(t, ctx, s))
+
+		ix := s.Lookup()
+		require.False(t, ix.Contains(ctx, Int64Node(1)))
+		require.NoError(t, ix.Close())
+	}
+}
+
+func TestSamplePaths(t *testing.T) {
+	ctx := context.TODO()
+	s := NewSample(newPathsShape(fixedRange(0, 100), "a", 3), 5, 1)
+	got := iteratedTags(t, ctx, s)
+	require.Len(t, got, 15)
+}
+
+func TestSampleContains(t *testing.T) {
+	ctx := context.TODO()
+	s := NewSample(fixedRange(0, 100), 7, 3)
+	expect := sampledValues(t, ctx, s)
+
+	var got []int64
+	ix := s.Lookup()
+	for i := 0; i < 100; i++ {
+		if ix.Contains(ctx, Int64Node(i)) {
+			got = append(got, int64(ix.Result().(Int64Node)))
+		}
+	}
+	require.NoError(t, ix.Err())
+	require.NoError(t, ix.Close())
+	require.Equal(t, expect, got)
+}
+
+func TestTopK(t *testing.T) {
+	ctx := context.TODO()
+	all := sortedIDs(t, ctx, NewSort(valueNamer{}, people(), ParseSortKeys("-age")...))
+	s, _ := NewTopK(valueNamer{}, people(), 2, ParseSortKeys("-age")...).Optimize(ctx)
+	require.Equal(t, all[:2], sortedIDs(t, ctx, s))
+}
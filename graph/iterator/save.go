@@ -47,6 +47,11 @@ func (it *Save) Lookup() Index {
 	return newSaveContains(it.it.Lookup(), it.tags, it.fixedTags)
 }
 
+// Order returns the order of the underlying iterator; tags do not affect it.
+func (it *Save) Order() Order {
+	return OrderOf(it.it)
+}
+
 func (it *Save) String() string {
 	return fmt.Sprintf("Save(%v, %v)", it.tags, it.fixedTags)
 }
@@ -113,6 +118,13 @@ func (it *Save) SubIterators() []Shape {
 	return []Shape{it.it}
 }
 
+// MapSubIterators implements Composite.
+func (it *Save) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.it = m(it.it)
+	return &nit
+}
+
 func newSaveNext(it Scanner, tags []string, fixed map[string]refs.Ref) *saveNext {
 	return &saveNext{it: it, tags: tags, fixedTags: fixed}
 }
@@ -148,8 +160,8 @@ func (it *saveNext) Next(ctx context.Context) bool {
 	return it.it.Next(ctx)
 }
 
-func (it *saveNext) NextPath(ctx context.Context) bool {
-	return it.it.NextPath(ctx)
+func (it *saveNext) Paths(ctx context.Context) Scanner {
+	return savePaths(ctx, it.it, it.tags, it.fixedTags)
 }
 
 func (it *saveNext) Err() error {
@@ -191,8 +203,8 @@ func (it *saveContains) Result() refs.Ref {
 	return it.it.Result()
 }
 
-func (it *saveContains) NextPath(ctx context.Context) bool {
-	return it.it.NextPath(ctx)
+func (it *saveContains) Paths(ctx context.Context) Scanner {
+	return savePaths(ctx, it.it, it.tags, it.fixed)
 }
 
 func (it *saveContains) Contains(ctx context.Context, v refs.Ref) bool {
@@ -206,3 +218,16 @@ func (it *saveContains) Err() error {
 func (it *saveContains) Close() error {
 	return it.it.Close()
 }
+
+// savePaths lists alternative paths of a subiterator, adding the saved tags to each of them.
+func savePaths(ctx context.Context, sub Base, tags []string, fixed map[string]refs.Ref) Scanner {
+	v := sub.Result()
+	return SubPaths(ctx, sub, v, func(dst map[string]refs.Ref) {
+		for _, tag := range tags {
+			dst[tag] = v
+		}
+		for tag, value := range fixed {
+			dst[tag] = value
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package iterator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+	"github.com/cayleygraph/quad"
+)
+
+// SaveCount iterator returns all values of its subiterator and tags each of them with the number of values
+// returned by a morphism applied to this value. For example, it can tag nodes with their degree.
+type SaveCount struct {
+	it       Shape
+	morphism Morphism
+	tag      string
+}
+
+// NewSaveCount creates a new SaveCount iterator. Morphism is applied to a Fixed iterator with a single value.
+func NewSaveCount(it Shape, morphism Morphism, tag string) *SaveCount {
+	return &SaveCount{it: it, morphism: morphism, tag: tag}
+}
+
+func (it *SaveCount) Iterate() Scanner {
+	return &saveCountNext{it: it.it.Iterate(), morphism: it.morphism, tag: it.tag}
+}
+
+func (it *SaveCount) Lookup() Index {
+	return &saveCountContains{it: it.it.Lookup(), morphism: it.morphism, tag: it.tag}
+}
+
+// SubIterators returns a slice of the sub iterators.
+func (it *SaveCount) SubIterators() []Shape {
+	return []Shape{it.it}
+}
+
+// MapSubIterators implements Composite.
+func (it *SaveCount) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.it = m(it.it)
+	return &nit
+}
+
+func (it *SaveCount) Optimize(ctx context.Context) (Shape, bool) {
+	nit, optimized := it.it.Optimize(ctx)
+	if IsNull(nit) {
+		return NewNull(), true
+	}
+	it.it = nit
+	return it, optimized
+}
+
+func (it *SaveCount) Stats(ctx context.Context) (Costs, error) {
+	st, err := it.it.Stats(ctx)
+	// each value is counted with a separate iterator
+	base := NewFixed()
+	base.Add(Int64Node(20))
+	step, err2 := it.morphism(base).Stats(ctx)
+	if err == nil {
+		err = err2
+	}
+	cost := step.NextCost * step.Size.Value
+	return Costs{
+		NextCost:     st.NextCost + cost,
+		ContainsCost: st.ContainsCost + cost,
+		Size:         st.Size,
+	}, err
+}
+
+func (it *SaveCount) String() string {
+	return fmt.Sprintf("SaveCount(%q)", it.tag)
+}
+
+// countFrom returns the number of values returned by a morphism for a given value.
+func countFrom(ctx context.Context, morphism Morphism, v refs.Ref) (refs.Ref, error) {
+	n, err := Iterate(ctx, morphism(NewFixed(v))).Paths(false).Count()
+	if err != nil {
+		return nil, err
+	}
+	return refs.PreFetched(quad.Int(n)), nil
+}
+
+type saveCountNext struct {
+	it       Scanner
+	morphism Morphism
+	tag      string
+	count    refs.Ref
+	err      error
+}
+
+func (it *saveCountNext) Next(ctx context.Context) bool {
+	it.count = nil
+	if it.err != nil || !it.it.Next(ctx) {
+		return false
+	}
+	it.count, it.err = countFrom(ctx, it.morphism, it.it.Result())
+	return it.err == nil
+}
+
+func (it *saveCountNext) Paths(ctx context.Context) Scanner {
+	return saveCountPaths(ctx, it.it, it.tag, it.count)
+}
+
+func (it *saveCountNext) Result() refs.Ref {
+	return it.it.Result()
+}
+
+func (it *saveCountNext) TagResults(dst map[string]refs.Ref) {
+	it.it.TagResults(dst)
+	if it.count != nil {
+		dst[it.tag] = it.count
+	}
+}
+
+func (it *saveCountNext) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.it.Err()
+}
+
+func (it *saveCountNext) Close() error {
+	return it.it.Close()
+}
+
+func (it *saveCountNext) String() string {
+	return fmt.Sprintf("SaveCountNext(%q)", it.tag)
+}
+
+type saveCountContains struct {
+	it       Index
+	morphism Morphism
+	tag      string
+	count    refs.Ref
+	err      error
+}
+
+func (it *saveCountContains) Contains(ctx context.Context, v refs.Ref) bool {
+	it.count = nil
+	if it.err != nil || !it.it.Contains(ctx, v) {
+		return false
+	}
+	it.count, it.err = countFrom(ctx, it.morphism, it.it.Result())
+	return it.err == nil
+}
+
+func (it *saveCountContains) Paths(ctx context.Context) Scanner {
+	return saveCountPaths(ctx, it.it, it.tag, it.count)
+}
+
+func (it *saveCountContains) Result() refs.Ref {
+	return it.it.Result()
+}
+
+func (it *saveCountContains) TagResults(dst map[string]refs.Ref) {
+	it.it.TagResults(dst)
+	if it.count != nil {
+		dst[it.tag] = it.count
+	}
+}
+
+func (it *saveCountContains) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.it.Err()
+}
+
+func (it *saveCountContains) Close() error {
+	return it.it.Close()
+}
+
+func (it *saveCountContains) String() string {
+	return fmt.Sprintf("SaveCountContains(%q)", it.tag)
+}
+
+// saveCountPaths lists alternative paths of a subiterator, adding the count of the current value to each of them.
+func saveCountPaths(ctx context.Context, sub Base, tag string, count refs.Ref) Scanner {
+	if count == nil {
+		return Paths(ctx, sub)
+	}
+	return SubPaths(ctx, sub, sub.Result(), func(dst map[string]refs.Ref) {
+		dst[tag] = count
+	})
+}
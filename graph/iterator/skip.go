@@ -33,6 +33,13 @@ func (it *Skip) SubIterators() []Shape {
 	return []Shape{it.primaryIt}
 }
 
+// MapSubIterators implements Composite.
+func (it *Skip) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.primaryIt = m(it.primaryIt)
+	return &nit
+}
+
 func (it *Skip) Optimize(ctx context.Context) (Shape, bool) {
 	optimizedPrimaryIt, optimized := it.primaryIt.Optimize(ctx)
 	if it.skip == 0 { // nothing to skip
@@ -97,15 +104,10 @@ func (it *skipNext) Result() refs.Ref {
 	return it.primaryIt.Result()
 }
 
-// NextPath checks whether there is another path. It will skip first paths
-// according to iterator parameter.
-func (it *skipNext) NextPath(ctx context.Context) bool {
-	for ; it.skipped < it.skip; it.skipped++ {
-		if !it.primaryIt.NextPath(ctx) {
-			return false
-		}
-	}
-	return it.primaryIt.NextPath(ctx)
+// Paths lists alternative paths of the primary iterator. Paths are only skipped
+// together with values, thus there is nothing left to skip at this point.
+func (it *skipNext) Paths(ctx context.Context) Scanner {
+	return Paths(ctx, it.primaryIt)
 }
 
 // Close closes the primary and all iterators.  It closes all subiterators
@@ -123,6 +125,8 @@ type skipContains struct {
 	skip      int64
 	skipped   int64
 	primaryIt Index
+	paths     []result // paths of the current value that were left after skipping
+	err       error
 }
 
 func newSkipContains(primaryIt Index, skip int64) *skipContains {
@@ -133,10 +137,19 @@ func newSkipContains(primaryIt Index, skip int64) *skipContains {
 }
 
 func (it *skipContains) TagResults(dst map[string]refs.Ref) {
+	if it.paths != nil {
+		for k, v := range it.paths[0].tags {
+			dst[k] = v
+		}
+		return
+	}
 	it.primaryIt.TagResults(dst)
 }
 
 func (it *skipContains) Err() error {
+	if it.err != nil {
+		return it.err
+	}
 	return it.primaryIt.Err()
 }
 
@@ -144,52 +157,41 @@ func (it *skipContains) Result() refs.Ref {
 	return it.primaryIt.Result()
 }
 
+// Contains checks the value against the primary iterator. Paths of matching values are
+// counted towards the number of skipped results: the value is only returned if some of
+// its paths were left after skipping.
 func (it *skipContains) Contains(ctx context.Context, val refs.Ref) bool {
-	inNextPath := false
-	for it.skipped <= it.skip {
-		// skipping main iterator results
-		inNextPath = false
-		if !it.primaryIt.Contains(ctx, val) {
-			return false
-		}
-		it.skipped++
-
-		// TODO(dennwc): we don't really know if we should call NextPath or not,
-		//               and there is no good way to know
-		if it.skipped <= it.skip {
-			// skipping NextPath results
-			inNextPath = true
-			if !it.primaryIt.NextPath(ctx) {
-				// main path exists, but we skipped it
-				// and we skipped all alternative paths now
-				// so we definitely "don't have" this value
-				return false
-			}
-			it.skipped++
-
-			for it.skipped <= it.skip {
-				if !it.primaryIt.NextPath(ctx) {
-					return false
-				}
-				it.skipped++
-			}
-		}
+	it.paths = nil
+	if it.skipped >= it.skip {
+		return it.primaryIt.Contains(ctx, val)
 	}
-	if inNextPath && it.primaryIt.NextPath(ctx) {
-		return true
+	if !it.primaryIt.Contains(ctx, val) {
+		return false
+	}
+	paths, err := pathsOf(ctx, it.primaryIt)
+	if err != nil {
+		it.err = err
+		return false
 	}
-	return it.primaryIt.Contains(ctx, val)
+	left := it.skip - it.skipped
+	if int64(len(paths)) <= left {
+		// main path exists, but we skipped it,
+		// and we skipped all alternative paths as well,
+		// so we definitely "don't have" this value
+		it.skipped += int64(len(paths))
+		return false
+	}
+	it.skipped = it.skip
+	it.paths = paths[left:]
+	return true
 }
 
-// NextPath checks whether there is another path. It will skip first paths
-// according to iterator parameter.
-func (it *skipContains) NextPath(ctx context.Context) bool {
-	for ; it.skipped < it.skip; it.skipped++ {
-		if !it.primaryIt.NextPath(ctx) {
-			return false
-		}
+// Paths lists paths of the current value that were not skipped.
+func (it *skipContains) Paths(ctx context.Context) Scanner {
+	if it.paths != nil {
+		return newPathRows(it.paths[1:])
 	}
-	return it.primaryIt.NextPath(ctx)
+	return Paths(ctx, it.primaryIt)
 }
 
 // Close closes the primary and all iterators.  It closes all subiterators
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
 )
 
 func TestSkipIteratorBasics(t *testing.T) {
@@ -49,5 +50,17 @@ func TestSkipIteratorBasics(t *testing.T) {
 		require.True(t, uc.Contains(ctx, Int64Node(v)))
 	}
 
-	// TODO(dennwc): check with NextPath
+	// paths of values are skipped as well
+	uc = NewSkip(newPathsShape(fixedRange(1, 3), "a", 3), 4).Lookup()
+	require.False(t, uc.Contains(ctx, Int64Node(1)))
+	require.True(t, uc.Contains(ctx, Int64Node(2)))
+	tags := make(map[string]refs.Ref)
+	uc.TagResults(tags)
+	require.Equal(t, Int64Node(1), tags["a"])
+	p := Paths(ctx, uc)
+	require.True(t, p.Next(ctx))
+	p.TagResults(tags)
+	require.Equal(t, Int64Node(2), tags["a"])
+	require.False(t, p.Next(ctx))
+	require.NoError(t, p.Close())
 }
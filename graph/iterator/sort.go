@@ -1,27 +1,98 @@
 package iterator
 
 import (
+	"bufio"
+	"container/heap"
 	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
 	"sort"
+	"strings"
+	"time"
 
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph/refs"
 )
 
+// SortKey is a key to order results of the Sort iterator by.
+type SortKey struct {
+	// Tag is a name of the tag to sort by. Empty tag sorts by the result value itself.
+	Tag string
+	// Desc reverses the order of this key.
+	Desc bool
+}
+
+func (k SortKey) String() string {
+	name := k.Tag
+	if name == "" {
+		name = "value"
+	}
+	if k.Desc {
+		return name + " desc"
+	}
+	return name
+}
+
+// ParseSortKeys converts a list of tag names to sort keys. Tags prefixed with "-" are sorted in descending order.
+// Empty tag name (or "-") refers to the value itself.
+func ParseSortKeys(tags ...string) []SortKey {
+	keys := make([]SortKey, 0, len(tags))
+	for _, tag := range tags {
+		var k SortKey
+		if strings.HasPrefix(tag, "-") {
+			k.Desc, tag = true, tag[1:]
+		}
+		k.Tag = tag
+		keys = append(keys, k)
+	}
+	return keys
+}
+
 // Sort iterator orders values from it's subiterator.
+//
+// Values are ordered by the list of keys. If no keys are given, values are ordered by themselves.
+// Numbers are compared numerically, times are compared chronologically, false is ordered before true,
+// and strings, IRIs and blank nodes are compared by their text. Values of different types are ordered
+// by type: numbers, times, booleans, IRIs, blank nodes, strings (including typed and language-tagged strings)
+// and then all other values (compared by their string representation).
+// Results without a value for a key are always ordered last.
+//
+// If the order depends on tags, each path of a value is ordered separately, thus the same value
+// may be returned multiple times (see Bindings). Otherwise, paths are returned together with their value.
+//
+// Sort may be used inside And: the optimizer moves it above the And.
 type Sort struct {
 	namer refs.Namer
 	subIt Shape
+	keys  []SortKey
+	limit int64 // only the first values are needed; set by Limit
+}
+
+// NewSort creates a new Sort iterator that orders values by given keys.
+func NewSort(namer refs.Namer, subIt Shape, keys ...SortKey) *Sort {
+	return &Sort{namer: namer, subIt: subIt, keys: keys}
 }
 
-// NewSort creates a new Sort iterator.
-// TODO(dennwc): This iterator must not be used inside And: it may be moved to a Contains branch and won't do anything.
-//               We should make And/Intersect account for this.
-func NewSort(namer refs.Namer, subIt Shape) *Sort {
-	return &Sort{namer, subIt}
+// NewTopK creates an iterator that returns the first k values ordered by given keys.
+//
+// Unlike Sort followed by Limit, it keeps only k values in a bounded heap and never spills them to disk.
+func NewTopK(namer refs.Namer, subIt Shape, k int64, keys ...SortKey) *Limit {
+	return NewLimit(NewSort(namer, subIt, keys...).withLimit(k), k)
+}
+
+// Keys returns the keys used to order values.
+func (it *Sort) Keys() []SortKey {
+	return it.keys
 }
 
 func (it *Sort) Iterate() Scanner {
-	return newSortNext(it.namer, it.subIt.Iterate())
+	s := newSortNext(it.namer, it.subIt.Iterate(), it.keys)
+	s.limit = it.limit
+	return s
 }
 
 func (it *Sort) Lookup() Index {
@@ -41,6 +112,9 @@ func (it *Sort) Optimize(ctx context.Context) (Shape, bool) {
 
 func (it *Sort) Stats(ctx context.Context) (Costs, error) {
 	subStats, err := it.subIt.Stats(ctx)
+	if it.limit > 0 && subStats.Size.Value > it.limit {
+		subStats.Size.Value = it.limit
+	}
 	return Costs{
 		// TODO(dennwc): better cost calculation; we probably need an InitCost defined in Costs
 		NextCost:     subStats.NextCost * 2,
@@ -53,7 +127,50 @@ func (it *Sort) Stats(ctx context.Context) (Costs, error) {
 }
 
 func (it *Sort) String() string {
-	return "Sort"
+	if len(it.keys) == 0 {
+		return "Sort"
+	}
+	keys := make([]string, len(it.keys))
+	for i, k := range it.keys {
+		keys[i] = k.String()
+	}
+	return "Sort(" + strings.Join(keys, ", ") + ")"
+}
+
+// byTags reports if the order depends on tag values.
+func byTags(keys []SortKey) bool {
+	for _, k := range keys {
+		if k.Tag != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// withLimit returns a copy of the iterator that only keeps the first n values in memory.
+func (it *Sort) withLimit(n int64) *Sort {
+	if n <= 0 || (it.limit > 0 && it.limit <= n) {
+		return it
+	}
+	nit := *it
+	nit.limit = n
+	return &nit
+}
+
+// limitSort propagates the limit to the Sort iterator below Limit and Skip,
+// allowing it to keep only the top values instead of sorting all of them.
+func limitSort(it Shape, n int64) Shape {
+	switch s := it.(type) {
+	case *Sort:
+		return s.withLimit(n)
+	case *Skip:
+		if sub, ok := s.primaryIt.(*Sort); ok && s.skip > 0 {
+			ns := *s
+			ns.primaryIt = sub.withLimit(n + s.skip)
+			return &ns
+		}
+	}
+	return it
 }
 
 // SubIterators returns a slice of the sub iterators.
@@ -61,10 +178,18 @@ func (it *Sort) SubIterators() []Shape {
 	return []Shape{it.subIt}
 }
 
+// MapSubIterators implements Composite.
+func (it *Sort) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.subIt = m(it.subIt)
+	return &nit
+}
+
 type sortValue struct {
 	result
-	str   string
+	str   string // encoded sort key (see appendSortKey)
 	paths []result
+	seq   int // order of the value in the subiterator; only used for top values
 }
 type sortByString []sortValue
 
@@ -75,20 +200,33 @@ func (v sortByString) Less(i, j int) bool {
 func (v sortByString) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
 
 type sortNext struct {
-	namer     refs.Namer
-	subIt     Scanner
-	ordered   sortByString
-	result    result
-	err       error
-	index     int
-	pathIndex int
+	namer   refs.Namer
+	subIt   Scanner
+	keys    []SortKey
+	limit   int64
+	loaded  bool
+	ordered sortByString
+	runs    *spillRuns // sorted runs spilled to disk; nil if all values fit into memory
+	merged  *runMerger
+	codec   refs.Codec
+	noSpill bool
+	budget  *MemoryBudget
+	used    int64
+	cur     sortValue
+	result  result
+	err     error
+	index   int
 }
 
-func newSortNext(namer refs.Namer, subIt Scanner) *sortNext {
+func newSortNext(namer refs.Namer, subIt Scanner, keys []SortKey) *sortNext {
+	if byTags(keys) {
+		// each path is ordered separately
+		subIt = Bindings(subIt)
+	}
 	return &sortNext{
-		namer:     namer,
-		subIt:     subIt,
-		pathIndex: -1,
+		namer: namer,
+		subIt: subIt,
+		keys:  keys,
 	}
 }
 
@@ -110,71 +248,461 @@ func (it *sortNext) Next(ctx context.Context) bool {
 	if it.err != nil {
 		return false
 	}
-	if it.ordered == nil {
-		v, err := getSortedValues(ctx, it.namer, it.subIt)
-		it.ordered = v
-		it.err = err
+	if !it.loaded {
+		it.err = it.load(ctx)
 		if it.err != nil {
 			return false
 		}
 	}
-	if it.index >= len(it.ordered) {
-		return false
+	if it.merged != nil {
+		rec, err := it.merged.next()
+		if err == io.EOF {
+			return false
+		} else if err == nil {
+			it.cur, err = it.decodeValue(rec)
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+	} else {
+		if it.index >= len(it.ordered) {
+			return false
+		}
+		it.cur = it.ordered[it.index]
+		it.index++
 	}
-	it.pathIndex = -1
-	it.result = it.ordered[it.index].result
-	it.index++
+	it.result = it.cur.result
 	return true
 }
 
-func (it *sortNext) NextPath(ctx context.Context) bool {
-	if it.index >= len(it.ordered) {
-		return false
-	}
-	r := it.ordered[it.index]
-	if it.pathIndex+1 >= len(r.paths) {
-		return false
-	}
-	it.pathIndex++
-	it.result = r.paths[it.pathIndex]
-	return true
+func (it *sortNext) Paths(ctx context.Context) Scanner {
+	return newPathRows(it.cur.paths)
 }
 
 func (it *sortNext) Close() error {
 	it.ordered = nil
-	return it.subIt.Close()
+	it.budget.release(it.used)
+	it.used = 0
+	var err error
+	if it.merged != nil {
+		err = it.merged.Close()
+		it.merged = nil
+	}
+	if it.runs != nil {
+		if err2 := it.runs.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+		it.runs = nil
+	}
+	if err2 := it.subIt.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
 }
 
 func (it *sortNext) String() string {
 	return "SortNext"
 }
 
-func getSortedValues(ctx context.Context, namer refs.Namer, it Scanner) (sortByString, error) {
-	var v sortByString
-	for it.Next(ctx) {
-		id := it.Result()
-		// TODO(dennwc): batch and use refs.ValuesOf
-		name, err := namer.NameOf(id)
+// load reads all values from the subiterator and sorts them.
+// If the memory budget is exhausted, sorted runs of values are written to temporary files and merged later.
+func (it *sortNext) load(ctx context.Context) error {
+	it.loaded = true
+	it.budget = memoryBudgetFrom(ctx)
+	if it.limit > 0 {
+		return it.loadTop(ctx)
+	}
+	for it.subIt.Next(ctx) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		v, err := it.getSortValue(ctx)
+		if err != nil {
+			return err
+		}
+		it.ordered = append(it.ordered, v)
+		sz := v.cost()
+		it.used += sz
+		if !it.budget.grow(sz) && !it.noSpill && it.used >= it.budget.spillMin() {
+			if err := it.spill(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := it.subIt.Err(); err != nil {
+		return err
+	}
+	if it.runs != nil && len(it.ordered) != 0 {
+		if err := it.spill(); err != nil {
+			return err
+		}
+	}
+	if it.runs == nil {
+		sort.Stable(it.ordered)
+		return nil
+	}
+	var err error
+	it.merged, err = it.runs.merge()
+	return err
+}
+
+// loadTop reads all values from the subiterator, but only keeps the first values according to the limit.
+// The number of values is bounded, thus they are never spilled to disk.
+func (it *sortNext) loadTop(ctx context.Context) error {
+	h := &sortHeap{}
+	for seq := 0; it.subIt.Next(ctx); seq++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		v, err := it.getSortValue(ctx)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		v.seq = seq
+		if int64(h.Len()) < it.limit {
+			heap.Push(h, v)
+			sz := v.cost()
+			it.used += sz
+			it.budget.grow(sz)
+		} else if h.less(v, h.vals[0]) {
+			sz := v.cost() - h.vals[0].cost()
+			it.used += sz
+			if sz > 0 {
+				it.budget.grow(sz)
+			} else {
+				it.budget.release(-sz)
+			}
+			h.vals[0] = v
+			heap.Fix(h, 0)
+		}
+	}
+	if err := it.subIt.Err(); err != nil {
+		return err
+	}
+	it.ordered = make(sortByString, h.Len())
+	for i := len(it.ordered) - 1; i >= 0; i-- {
+		it.ordered[i] = heap.Pop(h).(sortValue)
+	}
+	return nil
+}
+
+// sortHeap is a max-heap of sorted values. Values that are equal are ordered by their sequence number.
+type sortHeap struct {
+	vals []sortValue
+}
+
+func (h *sortHeap) less(a, b sortValue) bool {
+	if a.str != b.str {
+		return a.str < b.str
+	}
+	return a.seq < b.seq
+}
+
+func (h *sortHeap) Len() int           { return len(h.vals) }
+func (h *sortHeap) Less(i, j int) bool { return h.less(h.vals[j], h.vals[i]) }
+func (h *sortHeap) Swap(i, j int)      { h.vals[i], h.vals[j] = h.vals[j], h.vals[i] }
+func (h *sortHeap) Push(x interface{}) { h.vals = append(h.vals, x.(sortValue)) }
+func (h *sortHeap) Pop() interface{} {
+	v := h.vals[len(h.vals)-1]
+	h.vals = h.vals[:len(h.vals)-1]
+	return v
+}
+
+// spill writes values collected in memory to a new sorted run.
+func (it *sortNext) spill() error {
+	if it.codec == nil {
+		it.codec = codecFor(it.namer)
+	}
+	if it.runs == nil {
+		it.runs = &spillRuns{dir: it.budget.dir(), less: lessRecordStrings}
+	}
+	sort.Stable(it.ordered)
+	var buf []byte
+	err := it.runs.add(func(w *bufio.Writer) error {
+		for _, v := range it.ordered {
+			var err error
+			buf, err = it.appendValue(buf[:0], v)
+			if err != nil {
+				return err
+			}
+			if err = writeRecord(w, buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errNotEncodable) {
+		// values are still in memory, so we can continue without spilling
+		clog.Warningf("cannot spill sorted values to disk, memory budget will be exceeded: %v", err)
+		it.noSpill = true
+		return it.unspill()
+	} else if err != nil {
+		return err
+	}
+	it.ordered = nil
+	it.budget.release(it.used)
+	it.used = 0
+	return nil
+}
+
+// unspill reads values from all runs back into memory and removes the runs.
+func (it *sortNext) unspill() error {
+	defer func() {
+		_ = it.runs.Close()
+		it.runs = nil
+	}()
+	if len(it.runs.files) == 0 {
+		return nil
+	}
+	m, err := newRunMerger(it.runs.files, it.runs.less)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	// values in runs were read before the ones in memory; keep this order to make sorting stable
+	var vals sortByString
+	for {
+		rec, err := m.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		v, err := it.decodeValue(rec)
+		if err != nil {
+			return err
 		}
-		str := name.String()
-		tags := make(map[string]refs.Ref)
-		it.TagResults(tags)
-		val := sortValue{
-			result: result{id, tags},
-			str:    str,
+		vals = append(vals, v)
+		sz := v.cost()
+		it.used += sz
+		it.budget.grow(sz)
+	}
+	it.ordered = append(vals, it.ordered...)
+	return nil
+}
+
+func (it *sortNext) appendValue(buf []byte, v sortValue) ([]byte, error) {
+	buf = appendString(buf, v.str)
+	buf = binary.AppendUvarint(buf, uint64(len(v.paths)))
+	var err error
+	for i := -1; i < len(v.paths); i++ {
+		r := v.result
+		if i >= 0 {
+			r = v.paths[i]
+		}
+		buf, err = appendRef(buf, it.codec, r.id)
+		if err != nil {
+			return nil, err
 		}
-		for it.NextPath(ctx) {
-			tags = make(map[string]refs.Ref)
-			it.TagResults(tags)
-			val.paths = append(val.paths, result{id, tags})
+		buf = binary.AppendUvarint(buf, uint64(len(r.tags)))
+		for k, t := range r.tags {
+			buf = appendString(buf, k)
+			buf, err = appendRef(buf, it.codec, t)
+			if err != nil {
+				return nil, err
+			}
 		}
-		v = append(v, val)
 	}
-	if err := it.Err(); err != nil {
+	return buf, nil
+}
+
+func (it *sortNext) decodeValue(p []byte) (sortValue, error) {
+	var (
+		v   sortValue
+		err error
+	)
+	v.str, p, err = decodeString(p)
+	if err != nil {
 		return v, err
 	}
-	sort.Sort(v)
+	n, sz := binary.Uvarint(p)
+	if sz <= 0 {
+		return v, io.ErrUnexpectedEOF
+	}
+	p = p[sz:]
+	if n != 0 {
+		v.paths = make([]result, n)
+	}
+	for i := -1; i < int(n); i++ {
+		var r result
+		r.id, p, err = decodeRef(p, it.codec)
+		if err != nil {
+			return v, err
+		}
+		tags, sz := binary.Uvarint(p)
+		if sz <= 0 {
+			return v, io.ErrUnexpectedEOF
+		}
+		p = p[sz:]
+		r.tags = make(map[string]refs.Ref, tags)
+		for j := 0; j < int(tags); j++ {
+			var (
+				k string
+				t refs.Ref
+			)
+			if k, p, err = decodeString(p); err != nil {
+				return v, err
+			}
+			if t, p, err = decodeRef(p, it.codec); err != nil {
+				return v, err
+			}
+			r.tags[k] = t
+		}
+		if i < 0 {
+			v.result = r
+		} else {
+			v.paths[i] = r
+		}
+	}
 	return v, nil
 }
+
+// cost returns an approximate memory usage of the value.
+func (v sortValue) cost() int64 {
+	n := int64(entryCost+len(v.str)) + refCost + tagsCost(v.result.tags)
+	for _, p := range v.paths {
+		n += entryCost + refCost + tagsCost(p.tags)
+	}
+	return n
+}
+
+// getSortValue reads the current value of the iterator with all its paths.
+// If the order depends on tags, paths are not collected, since they are returned as separate values.
+func (it *sortNext) getSortValue(ctx context.Context) (sortValue, error) {
+	sc := it.subIt
+	id := sc.Result()
+	tags := make(map[string]refs.Ref)
+	sc.TagResults(tags)
+	str, err := it.sortKey(id, tags)
+	if err != nil {
+		return sortValue{}, err
+	}
+	val := sortValue{
+		result: result{id, tags},
+		str:    str,
+	}
+	if byTags(it.keys) {
+		return val, nil
+	}
+	paths := Paths(ctx, sc)
+	if paths == nil {
+		return val, nil
+	}
+	for paths.Next(ctx) {
+		tags = make(map[string]refs.Ref)
+		paths.TagResults(tags)
+		val.paths = append(val.paths, result{id, tags})
+	}
+	err = paths.Err()
+	if err2 := paths.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return val, err
+}
+
+// sortKey returns an encoded key of the result. Encoded keys can be compared as strings.
+func (it *sortNext) sortKey(id refs.Ref, tags map[string]refs.Ref) (string, error) {
+	keys := it.keys
+	if len(keys) == 0 {
+		keys = []SortKey{{}}
+	}
+	var buf []byte
+	for _, k := range keys {
+		ref := id
+		if k.Tag != "" {
+			ref = tags[k.Tag]
+		}
+		var v quad.Value
+		if ref != nil {
+			// TODO(dennwc): batch and use refs.ValuesOf
+			var err error
+			v, err = it.namer.NameOf(ref)
+			if err != nil {
+				return "", err
+			}
+		}
+		buf = appendSortKey(buf, v, k.Desc)
+	}
+	return string(buf), nil
+}
+
+// Ranks of value types in the sort order.
+const (
+	sortRankNumber  = 0x01
+	sortRankTime    = 0x02
+	sortRankBool    = 0x03
+	sortRankIRI     = 0x04
+	sortRankBNode   = 0x05
+	sortRankString  = 0x06
+	sortRankOther   = 0x07
+	sortRankMissing = 0xff
+)
+
+// appendSortKey appends an order-preserving binary representation of the value:
+// encoded values compare the same way as values (see Sort), and they can be concatenated for multiple keys.
+// Descending keys are encoded with inverted bits. Missing values are ordered last in both directions.
+// Integers are compared as floats, thus very large integers may compare equal.
+func appendSortKey(buf []byte, v quad.Value, desc bool) []byte {
+	start := len(buf)
+	switch v := v.(type) {
+	case nil:
+		return append(buf, sortRankMissing)
+	case quad.Int:
+		buf = append(buf, sortRankNumber)
+		buf = binary.BigEndian.AppendUint64(buf, sortFloatBits(float64(v)))
+	case quad.Float:
+		buf = append(buf, sortRankNumber)
+		buf = binary.BigEndian.AppendUint64(buf, sortFloatBits(float64(v)))
+	case quad.Time:
+		buf = append(buf, sortRankTime)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(time.Time(v).UnixNano())^(1<<63))
+	case quad.Bool:
+		buf = append(buf, sortRankBool)
+		if v {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+	case quad.String:
+		buf = appendSortString(append(buf, sortRankString), string(v))
+	case quad.TypedString:
+		buf = appendSortString(append(buf, sortRankString), string(v.Value))
+	case quad.LangString:
+		buf = appendSortString(append(buf, sortRankString), string(v.Value))
+	case quad.IRI:
+		buf = appendSortString(append(buf, sortRankIRI), string(v))
+	case quad.BNode:
+		buf = appendSortString(append(buf, sortRankBNode), string(v))
+	default:
+		buf = appendSortString(append(buf, sortRankOther), v.String())
+	}
+	if desc {
+		for i := start; i < len(buf); i++ {
+			buf[i] = ^buf[i]
+		}
+	}
+	return buf
+}
+
+// appendSortString appends a string that is ordered before all its continuations.
+func appendSortString(buf []byte, s string) []byte {
+	// zero bytes are escaped, so the terminator is less than any continuation of the string
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 {
+			buf = append(buf, 0, 0xff)
+		} else {
+			buf = append(buf, s[i])
+		}
+	}
+	return append(buf, 0, 0x01)
+}
+
+// sortFloatBits converts a float to an unsigned number with the same order.
+func sortFloatBits(f float64) uint64 {
+	u := math.Float64bits(f)
+	if u&(1<<63) != 0 {
+		return ^u
+	}
+	return u | (1 << 63)
+}
@@ -0,0 +1,184 @@
+package iterator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/quad"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// rowsShape returns pre-fetched values with given tags.
+type rowsShape struct {
+	*Fixed
+	tags map[string]map[string]quad.Value
+}
+
+func newRowsShape(rows map[string]map[string]quad.Value, order ...string) *rowsShape {
+	f := NewFixed()
+	for _, id := range order {
+		f.Add(refs.PreFetched(quad.IRI(id)))
+	}
+	return &rowsShape{Fixed: f, tags: rows}
+}
+
+func (s *rowsShape) Iterate() Scanner {
+	return &rowsScanner{Scanner: s.Fixed.Iterate(), s: s}
+}
+
+func (s *rowsShape) Lookup() Index {
+	return &rowsIndex{Index: s.Fixed.Lookup(), s: s}
+}
+
+func (s *rowsShape) Optimize(ctx context.Context) (Shape, bool) {
+	return s, false
+}
+
+func (s *rowsShape) tagResults(id refs.Ref, dst map[string]refs.Ref) {
+	name := string(id.(refs.PreFetchedValue).NameOf().(quad.IRI))
+	for k, v := range s.tags[name] {
+		dst[k] = refs.PreFetched(v)
+	}
+}
+
+type rowsScanner struct {
+	Scanner
+	s *rowsShape
+}
+
+func (it *rowsScanner) TagResults(dst map[string]refs.Ref) {
+	it.s.tagResults(it.Result(), dst)
+}
+
+type rowsIndex struct {
+	Index
+	s *rowsShape
+}
+
+func (it *rowsIndex) TagResults(dst map[string]refs.Ref) {
+	it.s.tagResults(it.Result(), dst)
+}
+
+func people() *rowsShape {
+	return newRowsShape(map[string]map[string]quad.Value{
+		"alice": {"age": quad.Int(30), "name": quad.String("Alice")},
+		"bob":   {"age": quad.Int(9), "name": quad.String("Bob")},
+		"carol": {"age": quad.Float(30), "name": quad.String("Carol")},
+		"dan":   {"name": quad.String("Dan")},
+		"eve":   {"age": quad.Int(100), "name": quad.String("Eve")},
+	}, "carol", "dan", "alice", "eve", "bob")
+}
+
+func sortedIDs(t testing.TB, ctx context.Context, s Shape) []string {
+	var out []string
+	err := Iterate(ctx, s).UnOptimized().EachValue(valueNamer{}, func(v quad.Value) error {
+		out = append(out, string(v.(quad.IRI)))
+		return nil
+	})
+	require.NoError(t, err)
+	return out
+}
+
+func TestSortKeys(t *testing.T) {
+	ctx := context.TODO()
+	cases := []struct {
+		keys   []string
+		expect []string
+	}{
+		{nil, []string{"alice", "bob", "carol", "dan", "eve"}},
+		{[]string{"-"}, []string{"eve", "dan", "carol", "bob", "alice"}},
+		// numbers are compared numerically; missing values are last
+		{[]string{"age"}, []string{"bob", "carol", "alice", "eve", "dan"}},
+		{[]string{"-age"}, []string{"eve", "carol", "alice", "bob", "dan"}},
+		{[]string{"-age", "name"}, []string{"eve", "alice", "carol", "bob", "dan"}},
+		{[]string{"age", "-name"}, []string{"bob", "carol", "alice", "eve", "dan"}},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprint(c.keys), func(t *testing.T) {
+			got := sortedIDs(t, ctx, NewSort(valueNamer{}, people(), ParseSortKeys(c.keys...)...))
+			require.Equal(t, c.expect, got)
+		})
+	}
+}
+
+func TestSortTypes(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	vals := []quad.Value{
+		quad.String("b"),
+		quad.Time(now),
+		quad.Int(10),
+		quad.String("ab"),
+		quad.Float(-2.5),
+		quad.Time(now.Add(-time.Hour)),
+		quad.String("a"),
+		quad.Int(2),
+		quad.IRI("a1"),
+		quad.Bool(true),
+		quad.BNode("a"),
+		quad.IRI("a"),
+		quad.Bool(false),
+	}
+	var its []refs.Ref
+	for _, v := range vals {
+		its = append(its, refs.PreFetched(v))
+	}
+	var got []quad.Value
+	err := Iterate(ctx, NewSort(valueNamer{}, NewFixed(its...))).EachValue(valueNamer{}, func(v quad.Value) error {
+		got = append(got, v)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{
+		quad.Float(-2.5),
+		quad.Int(2),
+		quad.Int(10),
+		quad.Time(now.Add(-time.Hour)),
+		quad.Time(now),
+		quad.Bool(false),
+		quad.Bool(true),
+		quad.IRI("a"),
+		quad.IRI("a1"),
+		quad.BNode("a"),
+		quad.String("a"),
+		quad.String("ab"),
+		quad.String("b"),
+	}, got)
+}
+
+func TestSortTop(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		return NewSort(valueNamer{}, NewOr(
+			Tag(shuffledValues(500, 1), "a"),
+			Tag(shuffledValues(200, 2), "b"),
+		))
+	}
+	all := iteratedTags(t, ctx, shape())
+
+	for _, n := range []int64{1, 10, 250} {
+		s, _ := NewLimit(shape(), n).Optimize(ctx)
+		got := iteratedTags(t, ctx, s)
+		require.Equal(t, all[:n], got, "limit: %d", n)
+
+		s, _ = NewLimit(NewSkip(shape(), 5), n).Optimize(ctx)
+		got = iteratedTags(t, ctx, s)
+		require.Equal(t, all[5:5+n], got, "skip and limit: %d", n)
+	}
+}
+
+func TestSortInAnd(t *testing.T) {
+	ctx := context.TODO()
+	s, _ := NewAnd(
+		NewFixed(refs.PreFetched(quad.IRI("alice")), refs.PreFetched(quad.IRI("eve")), refs.PreFetched(quad.IRI("dan"))),
+		NewSort(valueNamer{}, people(), ParseSortKeys("-age")...),
+	).Optimize(ctx)
+	require.Equal(t, "Sort(age desc)", s.String())
+	require.Equal(t, []string{"eve", "alice", "dan"}, sortedIDs(t, ctx, s))
+}
@@ -0,0 +1,580 @@
+package iterator
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/cayleygraph/quad/pquads"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// MemoryBudget limits the memory used by iterators that hold intermediate results: Sort, Unique and Materialize.
+// A single budget is shared by all iterators of a query.
+//
+// When the budget is exhausted, Sort writes sorted runs to temporary files and merges them later,
+// Unique moves the set of seen values to a temporary on-disk database, and Materialize falls back
+// to iterating the subiterator directly. Sample and Sort below Limit keep a bounded number of values,
+// thus they only account the memory they use.
+//
+// Sort and Unique only spill when they use a noticeable part of the budget themselves, thus they may exceed
+// the budget by a fraction of the limit each, if the memory is used by other iterators.
+type MemoryBudget struct {
+	limit int64
+	used  int64
+
+	// Dir is a directory for temporary files. If empty, the default directory for temporary files is used.
+	Dir string
+}
+
+// NewMemoryBudget creates a memory budget with a given limit in bytes.
+// The memory usage is estimated, thus the limit is not exact.
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit}
+}
+
+type memoryBudgetKey struct{}
+
+// WithMemoryBudget returns a context that limits the memory used by iterators. Nil budget means no limit.
+func WithMemoryBudget(ctx context.Context, b *MemoryBudget) context.Context {
+	return context.WithValue(ctx, memoryBudgetKey{}, b)
+}
+
+// memoryBudgetFrom returns a budget associated with the context, or nil if the memory is not limited.
+func memoryBudgetFrom(ctx context.Context) *MemoryBudget {
+	b, _ := ctx.Value(memoryBudgetKey{}).(*MemoryBudget)
+	return b
+}
+
+// Limit returns the limit of the budget in bytes.
+func (b *MemoryBudget) Limit() int64 {
+	return b.limit
+}
+
+// Used returns an estimated memory usage of iterators in bytes.
+func (b *MemoryBudget) Used() int64 {
+	return atomic.LoadInt64(&b.used)
+}
+
+// grow accounts n more bytes and reports if the usage is still within the limit.
+func (b *MemoryBudget) grow(n int64) bool {
+	if b == nil {
+		return true
+	}
+	return atomic.AddInt64(&b.used, n) <= b.limit
+}
+
+// release returns n bytes to the budget.
+func (b *MemoryBudget) release(n int64) {
+	if b == nil || n == 0 {
+		return
+	}
+	atomic.AddInt64(&b.used, -n)
+}
+
+// spillMin returns the memory that an iterator may use before spilling to disk, even if the budget is exhausted.
+// It prevents iterators from spilling each new value separately when the budget is used by other iterators.
+func (b *MemoryBudget) spillMin() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit / 16
+}
+
+func (b *MemoryBudget) dir() string {
+	if b == nil {
+		return ""
+	}
+	return b.Dir
+}
+
+// Approximate memory costs of intermediate results, used to account for the memory budget.
+const (
+	refCost   = 32 // interface value with a small dynamic value
+	entryCost = 48 // map or slice entry
+	mapCost   = 48 // map header
+)
+
+func tagsCost(tags map[string]refs.Ref) int64 {
+	n := int64(mapCost)
+	for k := range tags {
+		n += int64(len(k)) + refCost + entryCost
+	}
+	return n
+}
+
+// errNotEncodable is returned if a reference cannot be stored outside of memory.
+var errNotEncodable = errors.New("reference cannot be encoded")
+
+// codecFor returns a codec for references of the quad store.
+// If the quad store does not implement refs.Codec, node references are encoded as values.
+func codecFor(namer refs.Namer) refs.Codec {
+	if c, ok := namer.(refs.Codec); ok {
+		return c
+	}
+	return valueCodec{namer: namer}
+}
+
+// valueCodec encodes node references as values and resolves them back when decoding.
+type valueCodec struct {
+	namer refs.Namer
+}
+
+func (c valueCodec) AppendRef(buf []byte, r refs.Ref) ([]byte, error) {
+	v, err := c.namer.NameOf(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotEncodable, err)
+	} else if v == nil {
+		return nil, errNotEncodable
+	}
+	p, err := pquads.MarshalValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, p...), nil
+}
+
+func (c valueCodec) DecodeRef(p []byte) (refs.Ref, error) {
+	v, err := pquads.UnmarshalValue(p)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.namer.ValueOf(v)
+	if err != nil {
+		return nil, err
+	} else if r == nil {
+		return nil, fmt.Errorf("value no longer exists: %v", v)
+	}
+	return r, nil
+}
+
+// appendRef encodes a reference with a length prefix. Nil references are allowed.
+func appendRef(buf []byte, c refs.Codec, r refs.Ref) ([]byte, error) {
+	if r == nil {
+		return append(buf, 0), nil
+	}
+	p, err := c.AppendRef(nil, r)
+	if err != nil {
+		return nil, err
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(p))+1)
+	return append(buf, p...), nil
+}
+
+// decodeRef decodes a reference encoded by appendRef and returns the rest of the buffer.
+func decodeRef(p []byte, c refs.Codec) (refs.Ref, []byte, error) {
+	n, sz := binary.Uvarint(p)
+	if sz <= 0 || uint64(len(p)-sz)+1 < n {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	p = p[sz:]
+	if n == 0 {
+		return nil, p, nil
+	}
+	r, err := c.DecodeRef(p[:n-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, p[n-1:], nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func decodeString(p []byte) (string, []byte, error) {
+	n, sz := binary.Uvarint(p)
+	if sz <= 0 || uint64(len(p)-sz) < n {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	p = p[sz:]
+	return string(p[:n]), p[n:], nil
+}
+
+// appendKey appends a binary representation of a key returned by refs.ToKey.
+// Representations of different keys are always different. It returns false if the key type is not supported.
+func appendKey(buf []byte, key interface{}) ([]byte, bool) {
+	return appendKeyValue(buf, reflect.ValueOf(key))
+}
+
+func appendKeyValue(buf []byte, v reflect.Value) ([]byte, bool) {
+	if !v.IsValid() {
+		return append(buf, 0), true
+	}
+	t := v.Type()
+	buf = appendString(buf, t.PkgPath()+"."+t.String())
+	return appendKeyData(buf, v)
+}
+
+func appendKeyData(buf []byte, v reflect.Value) ([]byte, bool) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), true
+		}
+		return append(buf, 0), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(buf, v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return binary.AppendUvarint(buf, math.Float64bits(v.Float())), true
+	case reflect.String:
+		return appendString(buf, v.String()), true
+	case reflect.Array:
+		ok := true
+		for i := 0; ok && i < v.Len(); i++ {
+			buf, ok = appendKeyData(buf, v.Index(i))
+		}
+		return buf, ok
+	case reflect.Struct:
+		ok := true
+		for i := 0; ok && i < v.NumField(); i++ {
+			buf, ok = appendKeyData(buf, v.Field(i))
+		}
+		return buf, ok
+	case reflect.Interface:
+		return appendKeyValue(buf, v.Elem())
+	}
+	return buf, false
+}
+
+// maxMergeRuns is the maximal number of runs merged at once.
+const maxMergeRuns = 64
+
+// spillRuns is a set of temporary files, each containing a sorted sequence of records.
+type spillRuns struct {
+	dir   string
+	less  func(a, b []byte) bool
+	files []string
+}
+
+func writeRecord(w *bufio.Writer, rec []byte) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(rec)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(rec)
+	return err
+}
+
+// add creates a new run. The function must write a sorted sequence of records with writeRecord.
+func (r *spillRuns) add(fnc func(w *bufio.Writer) error) error {
+	path, err := r.write(fnc)
+	if err != nil {
+		return err
+	}
+	r.files = append(r.files, path)
+	return nil
+}
+
+func (r *spillRuns) write(fnc func(w *bufio.Writer) error) (string, error) {
+	f, err := os.CreateTemp(r.dir, "cayley-sort-*")
+	if err != nil {
+		return "", err
+	}
+	path := f.Name()
+	w := bufio.NewWriter(f)
+	err = fnc(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// merge returns a merged sequence of all runs. It may merge runs in multiple passes to limit the number of open files.
+func (r *spillRuns) merge() (*runMerger, error) {
+	for len(r.files) > maxMergeRuns {
+		var files []string
+		for i := 0; i < len(r.files); i += maxMergeRuns {
+			end := i + maxMergeRuns
+			if end > len(r.files) {
+				end = len(r.files)
+			}
+			m, err := newRunMerger(r.files[i:end], r.less)
+			if err != nil {
+				return nil, err
+			}
+			path, err := r.write(func(w *bufio.Writer) error {
+				for {
+					rec, err := m.next()
+					if err == io.EOF {
+						return nil
+					} else if err != nil {
+						return err
+					}
+					if err = writeRecord(w, rec); err != nil {
+						return err
+					}
+				}
+			})
+			m.Close()
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range r.files[i:end] {
+				_ = os.Remove(name)
+			}
+			files = append(files, path)
+		}
+		r.files = files
+	}
+	return newRunMerger(r.files, r.less)
+}
+
+// Close removes all runs.
+func (r *spillRuns) Close() error {
+	var err error
+	for _, name := range r.files {
+		if err2 := os.Remove(name); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	r.files = nil
+	return err
+}
+
+type runReader struct {
+	idx int // index of the run; used to keep the merge stable
+	f   *os.File
+	r   *bufio.Reader
+	cur []byte
+}
+
+func (r *runReader) next() error {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	if uint64(cap(r.cur)) < n {
+		r.cur = make([]byte, n)
+	}
+	r.cur = r.cur[:n]
+	_, err = io.ReadFull(r.r, r.cur)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// runMerger merges sorted runs using a heap. Equal records are returned in the order of runs.
+type runMerger struct {
+	less func(a, b []byte) bool
+	runs []*runReader
+	last *runReader // the run that returned the last record; it's not in the heap
+	all  []*runReader
+}
+
+func newRunMerger(files []string, less func(a, b []byte) bool) (*runMerger, error) {
+	m := &runMerger{less: less}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		r := &runReader{idx: len(m.all), f: f, r: bufio.NewReader(f)}
+		m.all = append(m.all, r)
+		if err = r.next(); err == io.EOF {
+			continue
+		} else if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.runs = append(m.runs, r)
+	}
+	heap.Init(m)
+	return m, nil
+}
+
+func (m *runMerger) Len() int { return len(m.runs) }
+func (m *runMerger) Less(i, j int) bool {
+	a, b := m.runs[i], m.runs[j]
+	if m.less(a.cur, b.cur) {
+		return true
+	} else if m.less(b.cur, a.cur) {
+		return false
+	}
+	return a.idx < b.idx
+}
+func (m *runMerger) Swap(i, j int)      { m.runs[i], m.runs[j] = m.runs[j], m.runs[i] }
+func (m *runMerger) Push(x interface{}) { m.runs = append(m.runs, x.(*runReader)) }
+func (m *runMerger) Pop() interface{} {
+	r := m.runs[len(m.runs)-1]
+	m.runs = m.runs[:len(m.runs)-1]
+	return r
+}
+
+// next returns the next record. The record is only valid until the next call.
+// It returns io.EOF if there are no more records.
+func (m *runMerger) next() ([]byte, error) {
+	if r := m.last; r != nil {
+		m.last = nil
+		if err := r.next(); err == nil {
+			heap.Push(m, r)
+		} else if err != io.EOF {
+			return nil, err
+		}
+	}
+	if len(m.runs) == 0 {
+		return nil, io.EOF
+	}
+	m.last = heap.Pop(m).(*runReader)
+	return m.last.cur, nil
+}
+
+func (m *runMerger) Close() error {
+	var err error
+	for _, r := range m.all {
+		if err2 := r.f.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	m.all, m.runs, m.last = nil, nil, nil
+	return err
+}
+
+// recordString returns a string at the beginning of the record, encoded with appendString.
+func recordString(p []byte) []byte {
+	n, sz := binary.Uvarint(p)
+	return p[sz : sz+int(n)]
+}
+
+// lessRecordStrings compares records by the string at the beginning of each record.
+func lessRecordStrings(a, b []byte) bool {
+	return bytes.Compare(recordString(a), recordString(b)) < 0
+}
+
+var uniqueBucket = []byte("seen")
+
+// keySet is a set of reference keys. When the memory budget is exhausted, keys are moved
+// to a temporary on-disk database.
+type keySet struct {
+	budget  *MemoryBudget
+	mem     map[interface{}]struct{}
+	used    int64
+	noSpill bool // keys in memory cannot be moved to disk
+
+	path string
+	db   *bolt.DB
+	tx   *bolt.Tx // read transaction for lookups
+	buf  []byte
+}
+
+func newKeySet(budget *MemoryBudget) *keySet {
+	return &keySet{budget: budget, mem: make(map[interface{}]struct{})}
+}
+
+// add inserts a key to the set and reports if the key was not in the set before.
+func (s *keySet) add(key interface{}) (bool, error) {
+	if _, ok := s.mem[key]; ok {
+		return false, nil
+	}
+	if s.tx != nil {
+		var ok bool
+		s.buf, ok = appendKey(s.buf[:0], key)
+		if ok && s.tx.Bucket(uniqueBucket).Get(s.buf) != nil {
+			return false, nil
+		}
+	}
+	s.mem[key] = struct{}{}
+	const cost = refCost + entryCost
+	s.used += cost
+	if s.budget.grow(cost) || s.noSpill || s.used < s.budget.spillMin() {
+		return true, nil
+	}
+	return true, s.spill()
+}
+
+// spill moves all keys that can be encoded from memory to disk.
+func (s *keySet) spill() error {
+	if s.db == nil {
+		f, err := os.CreateTemp(s.budget.dir(), "cayley-unique-*")
+		if err != nil {
+			return err
+		}
+		s.path = f.Name()
+		_ = f.Close()
+		s.db, err = bolt.Open(s.path, 0600, &bolt.Options{NoSync: true, NoFreelistSync: true})
+		if err != nil {
+			_ = os.Remove(s.path)
+			return err
+		}
+	} else if s.tx != nil {
+		// read transaction must be closed before writing, or the database may deadlock on remap
+		_ = s.tx.Rollback()
+		s.tx = nil
+	}
+	var moved int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(uniqueBucket)
+		if err != nil {
+			return err
+		}
+		for key := range s.mem {
+			p, ok := appendKey(nil, key)
+			if !ok || len(p) > bolt.MaxKeySize {
+				continue
+			}
+			if err = b.Put(p, []byte{1}); err != nil {
+				return err
+			}
+			delete(s.mem, key)
+			moved++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		clog.Warningf("cannot move seen values to disk, memory budget will be exceeded")
+		s.noSpill = true
+	}
+	moved *= refCost + entryCost
+	s.used -= moved
+	s.budget.release(moved)
+	s.tx, err = s.db.Begin(false)
+	return err
+}
+
+// Close releases the memory and removes the temporary database.
+func (s *keySet) Close() error {
+	s.mem = nil
+	s.budget.release(s.used)
+	s.used = 0
+	if s.db == nil {
+		return nil
+	}
+	if s.tx != nil {
+		_ = s.tx.Rollback()
+		s.tx = nil
+	}
+	err := s.db.Close()
+	if err2 := os.Remove(s.path); err2 != nil && err == nil {
+		err = err2
+	}
+	s.db = nil
+	return err
+}
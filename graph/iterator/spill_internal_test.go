@@ -0,0 +1,36 @@
+package iterator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+	"github.com/cayleygraph/quad"
+)
+
+func TestKeySetExhaustedBudget(t *testing.T) {
+	b := NewMemoryBudget(1 << 12)
+	b.Dir = t.TempDir()
+	// the budget is used by another iterator
+	require.False(t, b.grow(b.Limit()+1))
+	defer b.release(b.Limit() + 1)
+
+	s := newKeySet(b)
+	defer s.Close()
+	ok, err := s.add(refs.ToKey(refs.PreFetched(quad.Int(0))))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Nil(t, s.db, "a single key should not be spilled")
+
+	for i := 1; i < 1000; i++ {
+		ok, err = s.add(refs.ToKey(refs.PreFetched(quad.Int(i))))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.True(t, s.used <= b.spillMin(), "keys must be spilled in batches")
+	}
+	require.NotNil(t, s.db)
+	ok, err = s.add(refs.ToKey(refs.PreFetched(quad.Int(1))))
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,138 @@
+package iterator_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+	"github.com/cayleygraph/quad"
+)
+
+// valueNamer resolves pre-fetched values. It doesn't implement refs.Codec, so values are spilled by name.
+type valueNamer struct{}
+
+func (valueNamer) ValueOf(v quad.Value) (refs.Ref, error) {
+	return refs.PreFetched(v), nil
+}
+
+func (valueNamer) NameOf(r refs.Ref) (quad.Value, error) {
+	if v, ok := r.(refs.PreFetchedValue); ok {
+		return v.NameOf(), nil
+	}
+	return nil, fmt.Errorf("unexpected ref: %T", r)
+}
+
+// shuffledValues returns n string values in random order, each repeated dup times.
+func shuffledValues(n, dup int) *Fixed {
+	var vals []refs.Ref
+	for i := 0; i < n; i++ {
+		for j := 0; j < dup; j++ {
+			vals = append(vals, refs.PreFetched(quad.String(fmt.Sprintf("v%05d", i))))
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	rnd.Shuffle(len(vals), func(i, j int) { vals[i], vals[j] = vals[j], vals[i] })
+	return NewFixed(vals...)
+}
+
+func newTestBudget(t *testing.T, limit int64) *MemoryBudget {
+	b := NewMemoryBudget(limit)
+	b.Dir = t.TempDir()
+	return b
+}
+
+func requireNoSpills(t *testing.T, b *MemoryBudget) {
+	require.Equal(t, int64(0), b.Used())
+	files, err := os.ReadDir(b.Dir)
+	require.NoError(t, err)
+	require.Empty(t, files, "temporary files were not removed")
+}
+
+func TestSortSpill(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		return NewSort(valueNamer{}, NewOr(
+			Tag(shuffledValues(500, 1), "a"),
+			Tag(shuffledValues(200, 2), "b"),
+		))
+	}
+	expect := iteratedTags(t, ctx, shape())
+	require.Len(t, expect, 900)
+
+	for _, limit := range []int64{1, 1 << 10, 1 << 14} {
+		b := newTestBudget(t, limit)
+		got := iteratedTags(t, WithMemoryBudget(ctx, b), shape())
+		require.Equal(t, expect, got, "limit: %d", limit)
+		requireNoSpills(t, b)
+	}
+}
+
+func TestSortSpillPaths(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		// tags of paths are Int64Node values that are not known to the namer,
+		// thus the iterator must read spilled values back into memory when reaching the second branch
+		return NewSort(valueNamer{}, NewOr(
+			Tag(shuffledValues(300, 1), "a"),
+			newPathsShape(shuffledValues(100, 1), "b", 3),
+		))
+	}
+	expect := iteratedTags(t, ctx, shape())
+	require.Len(t, expect, 600)
+
+	b := newTestBudget(t, 1<<10)
+	got := iteratedTags(t, WithMemoryBudget(ctx, b), shape())
+	require.Equal(t, expect, got)
+	requireNoSpills(t, b)
+}
+
+func TestUniqueSpill(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		return NewUnique(Tag(shuffledValues(1000, 3), "a"))
+	}
+	expect := iteratedTags(t, ctx, shape())
+	require.Len(t, expect, 1000)
+
+	for _, limit := range []int64{1, 1 << 10, 1 << 14} {
+		b := newTestBudget(t, limit)
+		got := iteratedTags(t, WithMemoryBudget(ctx, b), shape())
+		require.Equal(t, expect, got, "limit: %d", limit)
+		requireNoSpills(t, b)
+
+		out, err := Iterate(WithMemoryBudget(ctx, b), shape()).UnOptimized().Paths(false).All()
+		require.NoError(t, err)
+		require.Len(t, out, 1000)
+		requireNoSpills(t, b)
+	}
+}
+
+func TestMaterializeBudget(t *testing.T) {
+	ctx := context.TODO()
+	shape := func() Shape {
+		return NewMaterialize(Tag(fixedRange(0, 100), "a"))
+	}
+	expect := iteratedTags(t, ctx, shape())
+	require.Len(t, expect, 100)
+
+	b := NewMemoryBudget(1 << 10)
+	it := shape().Iterate()
+	var n int
+	for it.Next(WithMemoryBudget(ctx, b)) {
+		n++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, 100, n)
+	require.NoError(t, it.Close())
+	require.Equal(t, int64(0), b.Used())
+
+	got := iteratedTags(t, WithMemoryBudget(ctx, b), shape())
+	require.Equal(t, expect, got)
+	require.Equal(t, int64(0), b.Used())
+}
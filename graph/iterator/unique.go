@@ -7,6 +7,8 @@ import (
 )
 
 // Unique iterator removes duplicate values from it's subiterator.
+// Only the first path of each value is returned: if it were to return multiple paths,
+// the result would no longer be unique.
 type Unique struct {
 	subIt Shape
 }
@@ -31,6 +33,13 @@ func (it *Unique) SubIterators() []Shape {
 	return []Shape{it.subIt}
 }
 
+// MapSubIterators implements Composite.
+func (it *Unique) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.subIt = m(it.subIt)
+	return &nit
+}
+
 func (it *Unique) Optimize(ctx context.Context) (Shape, bool) {
 	newIt, optimized := it.subIt.Optimize(ctx)
 	if optimized {
@@ -62,16 +71,27 @@ type uniqueNext struct {
 	subIt  Scanner
 	result refs.Ref
 	err    error
-	seen   map[interface{}]bool
+	seen   *keySet
 }
 
 func newUniqueNext(subIt Scanner) *uniqueNext {
 	return &uniqueNext{
 		subIt: subIt,
-		seen:  make(map[interface{}]bool),
 	}
 }
 
+// add marks the value as seen and reports if it was not seen before.
+func (it *uniqueNext) add(ctx context.Context, v refs.Ref) bool {
+	if it.seen == nil {
+		it.seen = newKeySet(memoryBudgetFrom(ctx))
+	}
+	ok, err := it.seen.add(refs.ToKey(v))
+	if err != nil && it.err == nil {
+		it.err = err
+	}
+	return ok
+}
+
 func (it *uniqueNext) TagResults(dst map[string]refs.Ref) {
 	if it.subIt != nil {
 		it.subIt.TagResults(dst)
@@ -81,19 +101,48 @@ func (it *uniqueNext) TagResults(dst map[string]refs.Ref) {
 // Next advances the subiterator, continuing until it returns a value which it
 // has not previously seen.
 func (it *uniqueNext) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
 	for it.subIt.Next(ctx) {
 		curr := it.subIt.Result()
-		key := refs.ToKey(curr)
-		if ok := it.seen[key]; !ok {
+		if it.add(ctx, curr) {
 			it.result = curr
-			it.seen[key] = true
-			return true
+			return it.err == nil
+		}
+		if it.err != nil {
+			return false
 		}
 	}
 	it.err = it.subIt.Err()
 	return false
 }
 
+// NextBatch is similar to Next, but removes duplicates from a batch of results of the subiterator.
+func (it *uniqueNext) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	for it.err == nil {
+		m := NextBatch(ctx, it.subIt, buf)
+		if m == 0 {
+			it.err = it.subIt.Err()
+			return 0
+		}
+		n := 0
+		for _, curr := range buf[:m] {
+			if it.add(ctx, curr) {
+				buf[n] = curr
+				n++
+			}
+		}
+		if it.err != nil {
+			return 0
+		}
+		if n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
 func (it *uniqueNext) Err() error {
 	return it.err
 }
@@ -102,17 +151,17 @@ func (it *uniqueNext) Result() refs.Ref {
 	return it.result
 }
 
-// NextPath for unique always returns false. If we were to return multiple
-// paths, we'd no longer be a unique result, so we have to choose only the first
-// path that got us here. Unique is serious on this point.
-func (it *uniqueNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // Close closes the primary iterators.
 func (it *uniqueNext) Close() error {
-	it.seen = nil
-	return it.subIt.Close()
+	var err error
+	if it.seen != nil {
+		err = it.seen.Close()
+		it.seen = nil
+	}
+	if err2 := it.subIt.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
 }
 
 func (it *uniqueNext) String() string {
@@ -150,13 +199,6 @@ func (it *uniqueContains) Contains(ctx context.Context, val refs.Ref) bool {
 	return it.subIt.Contains(ctx, val)
 }
 
-// NextPath for unique always returns false. If we were to return multiple
-// paths, we'd no longer be a unique result, so we have to choose only the first
-// path that got us here. Unique is serious on this point.
-func (it *uniqueContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 // Close closes the primary iterators.
 func (it *uniqueContains) Close() error {
 	return it.subIt.Close()
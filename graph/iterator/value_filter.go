@@ -49,6 +49,13 @@ func (it *ValueFilter) SubIterators() []Shape {
 	return []Shape{it.sub}
 }
 
+// MapSubIterators implements Composite.
+func (it *ValueFilter) MapSubIterators(m Morphism) Shape {
+	nit := *it
+	nit.sub = m(it.sub)
+	return &nit
+}
+
 func (it *ValueFilter) String() string {
 	return "ValueFilter"
 }
@@ -126,8 +133,8 @@ func (it *valueFilterNext) Result() refs.Ref {
 	return it.result
 }
 
-func (it *valueFilterNext) NextPath(ctx context.Context) bool {
-	return it.sub.NextPath(ctx)
+func (it *valueFilterNext) Paths(ctx context.Context) Scanner {
+	return Paths(ctx, it.sub)
 }
 
 // If we failed the check, then the subiterator should not contribute to the result
@@ -181,8 +188,8 @@ func (it *valueFilterContains) Result() refs.Ref {
 	return it.result
 }
 
-func (it *valueFilterContains) NextPath(ctx context.Context) bool {
-	return it.sub.NextPath(ctx)
+func (it *valueFilterContains) Paths(ctx context.Context) Scanner {
+	return Paths(ctx, it.sub)
 }
 
 func (it *valueFilterContains) Contains(ctx context.Context, val refs.Ref) bool {
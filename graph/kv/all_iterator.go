@@ -16,6 +16,7 @@ package kv
 
 import (
 	"context"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -86,6 +87,7 @@ type allIteratorNext struct {
 	buf     []*proto.Primitive
 	prim    *proto.Primitive
 	horizon int64
+	now     int64 // quads expired at this time are skipped
 	qs      *QuadStore
 	err     error
 	cons    *constraint
@@ -99,6 +101,7 @@ func (qs *QuadStore) newAllIteratorNext(nodes bool, cons *constraint) *allIterat
 		qs:      qs,
 		nodes:   nodes,
 		horizon: qs.horizon(context.TODO()),
+		now:     time.Now().UnixNano(),
 		cons:    cons,
 	}
 }
@@ -128,28 +131,45 @@ func (it *allIteratorNext) Result() graph.Ref {
 
 const nextBatch = 100
 
+// fetch reads the next n primitives from the log with a single multi-key read.
+func (it *allIteratorNext) fetch(ctx context.Context, n int) bool {
+	if it.id+1 > uint64(it.horizon) {
+		return false
+	} else if it.err = ctx.Err(); it.err != nil {
+		return false
+	}
+	ids := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		it.id++
+		if it.id > uint64(it.horizon) {
+			break
+		}
+		ids = append(ids, it.id)
+	}
+	if len(ids) == 0 {
+		return false
+	}
+	it.buf, it.err = it.qs.getPrimitives(ctx, ids)
+	return it.err == nil && len(it.buf) != 0
+}
+
+// accept checks if a primitive should be returned by the iterator.
+func (it *allIteratorNext) accept(p *proto.Primitive) bool {
+	if it.nodes {
+		return p.IsNode()
+	} else if p.IsNode() || p.IsExpired(it.now) {
+		return false
+	}
+	return it.cons == nil || Int64Value(p.GetDirection(it.cons.dir)) == it.cons.val
+}
+
 func (it *allIteratorNext) Next(ctx context.Context) bool {
 	if it.err != nil {
 		return false
 	}
 	for {
 		if len(it.buf) == 0 {
-			if it.id+1 > uint64(it.horizon) {
-				return false
-			}
-			ids := make([]uint64, 0, nextBatch)
-			for i := 0; i < nextBatch; i++ {
-				it.id++
-				if it.id > uint64(it.horizon) {
-					break
-				}
-				ids = append(ids, it.id)
-			}
-			if len(ids) == 0 {
-				return false
-			}
-			it.buf, it.err = it.qs.getPrimitives(ctx, ids)
-			if it.err != nil || len(it.buf) == 0 {
+			if !it.fetch(ctx, nextBatch) {
 				return false
 			}
 		} else {
@@ -162,23 +182,51 @@ func (it *allIteratorNext) Next(ctx context.Context) bool {
 				continue
 			}
 			it.id = it.prim.ID
-			if p.IsNode() && it.nodes {
+			if it.accept(p) {
 				return true
 			}
-			if !p.IsNode() && !it.nodes {
-				if it.cons == nil {
-					return true
-				}
-				if Int64Value(p.GetDirection(it.cons.dir)) == it.cons.val {
-					return true
-				}
-			}
 		}
 	}
 }
 
-func (it *allIteratorNext) NextPath(ctx context.Context) bool {
-	return false
+var _ iterator.BatchScanner = (*allIteratorNext)(nil)
+
+// NextBatch implements iterator.BatchScanner. Primitives for the whole batch are read from the log at once.
+func (it *allIteratorNext) NextBatch(ctx context.Context, buf []graph.Ref) int {
+	if it.err != nil || len(buf) == 0 {
+		return 0
+	}
+	if len(it.buf) != 0 {
+		// skip the current result
+		it.buf = it.buf[1:]
+	}
+	n := 0
+	for {
+		for ; len(it.buf) > 0; it.buf = it.buf[1:] {
+			p := it.buf[0]
+			it.prim = p
+			if p == nil || p.Deleted {
+				continue
+			}
+			it.id = it.prim.ID
+			if !it.accept(p) {
+				continue
+			}
+			if it.nodes {
+				buf[n] = Int64Value(p.ID)
+			} else {
+				buf[n] = p
+			}
+			n++
+			if n == len(buf) {
+				// keep the last result as the current one
+				return n
+			}
+		}
+		if !it.fetch(ctx, len(buf)) {
+			return n
+		}
+	}
 }
 
 func (it *allIteratorNext) String() string {
@@ -192,6 +240,7 @@ type allIteratorContains struct {
 	id      uint64
 	prim    *proto.Primitive
 	horizon int64
+	now     int64 // quads expired at this time are skipped
 	qs      *QuadStore
 	err     error
 	cons    *constraint
@@ -205,6 +254,7 @@ func (qs *QuadStore) newAllIteratorContains(nodes bool, cons *constraint) *allIt
 		qs:      qs,
 		nodes:   nodes,
 		horizon: qs.horizon(context.TODO()),
+		now:     time.Now().UnixNano(),
 		cons:    cons,
 	}
 }
@@ -232,10 +282,6 @@ func (it *allIteratorContains) Result() graph.Ref {
 	return it.prim
 }
 
-func (it *allIteratorContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *allIteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 	// TODO(dennwc): This method doesn't check if the primitive still exists in the store.
 	//               It's okay if we assume we provide the snapshot of data, though.
@@ -250,7 +296,7 @@ func (it *allIteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 		return it.id <= uint64(it.horizon)
 	}
 	p, ok := v.(*proto.Primitive)
-	if !ok {
+	if !ok || p.IsExpired(it.now) {
 		return false
 	}
 	it.prim = p
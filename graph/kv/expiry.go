@@ -0,0 +1,127 @@
+package kv
+
+import (
+	"context"
+	"time"
+
+	"github.com/cayleygraph/quad"
+	"github.com/hidal-go/hidalgo/kv"
+	"github.com/hidal-go/hidalgo/kv/options"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+)
+
+// expiryIndex is a bucket that lists expiring quads ordered by expiry time.
+var expiryIndex = kv.Key{[]byte("expiry")}
+
+var _ graph.Expirer = (*QuadStore)(nil)
+
+// expiryKey returns a key for an expiring quad in expiryIndex.
+func expiryKey(expires int64, id uint64) kv.Key {
+	k := make([]byte, 16)
+	quadKeyEnc.PutUint64(k, uint64(expires))
+	quadKeyEnc.PutUint64(k[8:], id)
+	return expiryIndex.AppendBytes(k)
+}
+
+// parseExpiryKey returns an expiry time and a quad id from a key in expiryIndex.
+func parseExpiryKey(k kv.Key) (int64, uint64, bool) {
+	if len(k) != 2 || len(k[1]) != 16 {
+		return 0, 0, false
+	}
+	return int64(quadKeyEnc.Uint64(k[1])), quadKeyEnc.Uint64(k[1][8:]), true
+}
+
+// hasExpiring checks if there are any expiring quads in the store.
+func (qs *QuadStore) hasExpiring(ctx context.Context, tx kv.Tx) (bool, error) {
+	it := tx.Scan(ctx, options.WithPrefixKV(expiryIndex))
+	defer it.Close()
+	ok := it.Next(ctx)
+	return ok, it.Err()
+}
+
+// setExpiry changes an expiry time of an existing quad.
+func (qs *QuadStore) setExpiry(ctx context.Context, tx kv.Tx, p *proto.Primitive, expires int64) error {
+	if p.Expires == expires {
+		return nil
+	}
+	if p.Expires != 0 {
+		if err := tx.Del(ctx, expiryKey(p.Expires, p.ID)); err != nil {
+			return err
+		}
+	}
+	p.Expires = expires
+	if err := qs.indexExpiry(ctx, tx, p); err != nil {
+		return err
+	}
+	return qs.addToLog(ctx, tx, p)
+}
+
+// indexExpiry adds an expiring quad to expiryIndex.
+func (qs *QuadStore) indexExpiry(ctx context.Context, tx kv.Tx, p *proto.Primitive) error {
+	if p.Expires == 0 {
+		return nil
+	}
+	return tx.Put(ctx, expiryKey(p.Expires, p.ID), uint64toBytes(p.ID))
+}
+
+// ExpiredQuads implements graph.Expirer.
+func (qs *QuadStore) ExpiredQuads(ctx context.Context, now time.Time, n int) ([]quad.Quad, error) {
+	t := now.UnixNano()
+	var out []quad.Quad
+	err := kv.View(ctx, qs.db, func(tx kv.Tx) error {
+		var ids []uint64
+		it := tx.Scan(ctx, options.WithPrefixKV(expiryIndex))
+		for len(ids) < n && it.Next(ctx) {
+			exp, id, ok := parseExpiryKey(it.Key())
+			if !ok {
+				continue
+			} else if exp > t {
+				break
+			}
+			ids = append(ids, id)
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return err
+		}
+		prims, err := qs.getPrimitivesFromLog(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range prims {
+			if p == nil || p.Deleted {
+				continue
+			}
+			q, err := qs.primitiveToQuad(ctx, tx, p)
+			if err != nil {
+				return err
+			}
+			out = append(out, q)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// NextExpiry implements graph.Expirer.
+func (qs *QuadStore) NextExpiry(ctx context.Context) (time.Time, bool, error) {
+	var (
+		next  time.Time
+		found bool
+	)
+	err := kv.View(ctx, qs.db, func(tx kv.Tx) error {
+		it := tx.Scan(ctx, options.WithPrefixKV(expiryIndex))
+		defer it.Close()
+		for it.Next(ctx) {
+			if exp, _, ok := parseExpiryKey(it.Key()); ok {
+				next, found = time.Unix(0, exp), true
+				break
+			}
+		}
+		return it.Err()
+	})
+	return next, found, err
+}
@@ -0,0 +1,185 @@
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/cayleygraph/quad"
+	"github.com/cayleygraph/quad/pquads"
+	"github.com/hidal-go/hidalgo/kv"
+	"github.com/hidal-go/hidalgo/kv/options"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	cproto "github.com/cayleygraph/cayley/graph/proto"
+)
+
+const metaVersioned = "versioned"
+
+var _ graph.Historian = (*QuadStore)(nil)
+
+// setVersioned marks the database as versioned.
+func setVersioned(ctx context.Context, db kv.KV) error {
+	return kv.Update(ctx, db, func(tx kv.Tx) error {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], 1)
+		return tx.Put(ctx, metaBucket.AppendBytes([]byte(metaVersioned)), buf[:])
+	})
+}
+
+// isVersioned checks if the database records the history of changes.
+func (qs *QuadStore) isVersioned(ctx context.Context) (bool, error) {
+	v, err := qs.getMetaInt(ctx, metaVersioned)
+	if err == ErrNoBucket {
+		return false, nil
+	}
+	return v != 0, err
+}
+
+// addTombstones appends a tombstone to the log for each deleted quad.
+//
+// Tombstones get their ids from the same sequence as all other primitives, thus the id of the tombstone
+// is the horizon at which the quad was deleted. The id of the deleted quad is stored in the Replaces field.
+func (qs *QuadStore) addTombstones(ctx context.Context, tx kv.Tx, links []*cproto.Primitive) error {
+	if len(links) == 0 {
+		return nil
+	}
+	start, err := qs.genIDs(ctx, tx, len(links))
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+	for i, p := range links {
+		t := &cproto.Primitive{
+			ID:        start + uint64(i),
+			Replaces:  p.ID,
+			Timestamp: now,
+			Deleted:   true,
+		}
+		if err := qs.addToLog(ctx, tx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markNodeDead hides a node that has no quads left, but keeps its value in the log
+// so quads that referenced it can still be read from the history.
+func (qs *QuadStore) markNodeDead(ctx context.Context, tx kv.Tx, id uint64) error {
+	p, err := qs.getPrimitiveFromLog(ctx, tx, id)
+	if err == kv.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	p.Deleted = true
+	return qs.addToLog(ctx, tx, p)
+}
+
+// Horizon returns the horizon of the last transaction.
+// It can be used as a version for AsOf.
+func (qs *QuadStore) Horizon() int64 {
+	return qs.horizon(context.TODO())
+}
+
+// AsOf implements graph.Historian. It returns graph.ErrNoHistory if the database was not initialized
+// with the versioned option.
+//
+// The snapshot is built by replaying the log with tombstones up to a given version and is loaded into memory.
+func (qs *QuadStore) AsOf(ctx context.Context, v graph.Version) (graph.QuadStore, error) {
+	if !qs.versioned {
+		return nil, graph.ErrNoHistory
+	} else if v.IsZero() {
+		return qs, nil
+	}
+	var quads []quad.Quad
+	err := kv.View(ctx, qs.db, func(tx kv.Tx) error {
+		var err error
+		quads, err = qs.quadsAsOf(ctx, tx, v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return memstore.NewSnapshot(qs, v, quads...), nil
+}
+
+func includes(v graph.Version, p *cproto.Primitive) bool {
+	return v.Includes(int64(p.ID), time.Unix(0, p.Timestamp))
+}
+
+// quadsAsOf returns all quads that were valid at a given version.
+func (qs *QuadStore) quadsAsOf(ctx context.Context, tx kv.Tx, v graph.Version) ([]quad.Quad, error) {
+	var (
+		links   []*cproto.Primitive
+		deleted = make(map[uint64]struct{})
+	)
+	it := tx.Scan(ctx, options.WithPrefixKV(logIndex))
+	for it.Next(ctx) {
+		p := new(cproto.Primitive)
+		if err := proto.Unmarshal(it.Val(), p); err != nil {
+			it.Close()
+			return nil, err
+		}
+		if p.ID == 0 || !includes(v, p) {
+			continue // bucket itself, or a change made after this version
+		} else if p.Replaces != 0 {
+			deleted[p.Replaces] = struct{}{}
+		} else if !p.IsNode() {
+			links = append(links, p)
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return nil, err
+	}
+	vals := make(map[uint64]quad.Value)
+	var ids []uint64
+	for _, p := range links {
+		if _, ok := deleted[p.ID]; ok {
+			continue
+		}
+		for _, d := range quad.Directions {
+			if id := p.GetDirection(d); id != 0 {
+				if _, ok := vals[id]; !ok {
+					vals[id] = nil
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > nextBatch {
+			batch = batch[:nextBatch]
+		}
+		ids = ids[len(batch):]
+		prims, err := qs.getPrimitivesFromLog(ctx, tx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range prims {
+			if p == nil {
+				continue
+			}
+			if vals[batch[i]], err = pquads.UnmarshalValue(p.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	out := make([]quad.Quad, 0, len(links))
+	for _, p := range links {
+		if _, ok := deleted[p.ID]; ok {
+			continue
+		}
+		var q quad.Quad
+		for _, d := range quad.Directions {
+			q.Set(d, vals[p.GetDirection(d)])
+		}
+		out = append(out, q)
+	}
+	return out, nil
+}
@@ -50,6 +50,7 @@ var (
 	buckets = []kv.Key{
 		metaBucket,
 		logIndex,
+		expiryIndex,
 	}
 
 	// legacyQuadIndexes is a set of indexes used in Cayley < 0.7.6
@@ -399,7 +400,12 @@ func (qs *QuadStore) decNodes(ctx context.Context, tx kv.Tx, deltas []graphlog.N
 		if iri, ok := d.Val.(quad.IRI); ok {
 			qs.valueLRU.Del(string(iri))
 		}
-		if err := qs.delLog(ctx, tx, d.ID); err != nil {
+		if qs.versioned {
+			err = qs.markNodeDead(ctx, tx, d.ID)
+		} else {
+			err = qs.delLog(ctx, tx, d.ID)
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -505,6 +511,37 @@ func (qs *QuadStore) applyAddDeltas(ctx context.Context, tx kv.Tx, in []graph.De
 		return nil, err
 	}
 	deltas.IncNode = nil
+	// nodes of new quads are decremented instead, if more quads with them are removed in the same batch
+	if len(deltas.DecNode) != 0 && len(deltas.QuadAdd) != 0 {
+		need := make(map[refs.ValueHash]struct{})
+		for _, q := range deltas.QuadAdd {
+			for _, h := range q.Quad.Dirs() {
+				if _, ok := nodes[h]; !ok && h.Valid() {
+					need[h] = struct{}{}
+				}
+			}
+		}
+		var dec []graphlog.NodeUpdate
+		for _, n := range deltas.DecNode {
+			if _, ok := need[n.Hash]; ok {
+				dec = append(dec, n)
+			}
+		}
+		err = qs.resolveValDeltas(ctx, tx, dec, func(i int, id uint64) {
+			if id != 0 {
+				nodes[dec[i].Hash] = resolvedNode{ID: id}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// existing quads must be fetched to check their expiry time
+	expiring, err := qs.hasExpiring(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixNano()
 	// resolve and insert all new quads
 	links := make([]*cproto.Primitive, 0, len(deltas.QuadAdd))
 	qadd := make(map[[4]uint64]struct{}, len(deltas.QuadAdd))
@@ -525,13 +562,22 @@ func (qs *QuadStore) applyAddDeltas(ctx context.Context, tx kv.Tx, in []graph.De
 			continue
 		}
 		qadd[qkey] = struct{}{}
+		if len(in) != 0 && !in[q.Ind].Expires.IsZero() {
+			link.Expires = in[q.Ind].Expires.UnixNano()
+		}
 		if !mustBeNew {
-			p, err := qs.hasPrimitive(ctx, tx, &link, false)
+			p, err := qs.hasPrimitive(ctx, tx, &link, expiring || link.Expires != 0)
 			if err != nil {
 				return nil, err
 			}
 			if p != nil {
-				if ignoreOpts.IgnoreDup {
+				if link.Expires != 0 || p.IsExpired(now) {
+					// refresh an expiry time, see graph.Expirer
+					if err = qs.setExpiry(ctx, tx, p, link.Expires); err != nil {
+						return nil, err
+					}
+					continue
+				} else if ignoreOpts.IgnoreDup {
 					continue // already exists, no need to insert
 				}
 				err = graph.ErrQuadExists
@@ -553,6 +599,9 @@ func (qs *QuadStore) applyAddDeltas(ctx context.Context, tx kv.Tx, in []graph.De
 	for i := range links {
 		links[i].ID = qstart + uint64(i)
 		links[i].Timestamp = time.Now().UnixNano()
+		if err := qs.indexExpiry(ctx, tx, links[i]); err != nil {
+			return nil, err
+		}
 	}
 	if err := qs.indexLinks(ctx, tx, links); err != nil {
 		return nil, err
@@ -561,6 +610,11 @@ func (qs *QuadStore) applyAddDeltas(ctx context.Context, tx kv.Tx, in []graph.De
 }
 
 func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, in, ignoreOpts)
+}
+
+// ApplyDeltasIf implements graph.ConditionalApplier.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, in []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
 	mApplyBatch.Observe(float64(len(in)))
 	defer prometheus.NewTimer(mApplySeconds).ObserveDuration()
 
@@ -574,6 +628,15 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 	defer tx.Close()
 	tx = wrapTx(tx)
 
+	objects := func(s, p, l quad.Value) ([]quad.Value, error) {
+		return qs.objects(ctx, tx, s, p, l)
+	}
+	if err = graph.CheckPreconditions(conds, objects); err != nil {
+		return err
+	} else if in, err = graph.ExpandReplace(in, objects); err != nil {
+		return err
+	}
+
 	deltas := graphlog.SplitDeltas(in)
 	if len(deltas.QuadDel) != 0 || len(deltas.DecNode) != 0 {
 		qs.mapNodes = nil
@@ -621,6 +684,9 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 					return err
 				} else if p == nil || p.Deleted {
 					exists = false
+				} else if p.IsExpired(time.Now().UnixNano()) && !ignoreOpts.IgnoreMissing {
+					// expired quads are only removed by the sweeper
+					exists = false
 				} else {
 					link = p
 				}
@@ -670,6 +736,78 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 	return tx.Commit(ctx)
 }
 
+// objects returns objects of all valid quads with a given subject, predicate and label.
+func (qs *QuadStore) objects(ctx context.Context, tx kv.Tx, s, p, l quad.Value) ([]quad.Value, error) {
+	ids, err := qs.resolveQuadValues(ctx, tx, []quad.Value{s, p, l})
+	if err != nil {
+		return nil, err
+	} else if ids[0] == 0 || ids[1] == 0 || (l != nil && ids[2] == 0) {
+		return nil, nil
+	}
+	link := &cproto.Primitive{Subject: ids[0], Predicate: ids[1], Label: ids[2]}
+
+	var prims []*cproto.Primitive
+	if inds := qs.bestIndexes([]quad.Direction{quad.Subject, quad.Predicate}); len(inds) == 1 {
+		ind := inds[0]
+		var vals []uint64
+		for _, d := range ind.Dirs {
+			if d != quad.Subject && d != quad.Predicate {
+				break
+			}
+			vals = append(vals, link.GetDirection(d))
+		}
+		var keys []uint64
+		it := tx.Scan(ctx, options.WithPrefixKV(ind.Key(vals)))
+		for it.Next(ctx) {
+			list, err := decodeIndex(it.Val())
+			if err != nil {
+				it.Close()
+				return nil, err
+			}
+			keys = append(keys, list...)
+		}
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return nil, err
+		}
+		if prims, err = qs.getPrimitivesFromLog(ctx, tx, keys); err != nil {
+			return nil, err
+		}
+	} else {
+		// no suitable index - scan the whole log
+		it := tx.Scan(ctx, options.WithPrefixKV(logIndex))
+		for it.Next(ctx) {
+			var p cproto.Primitive
+			if err := proto.Unmarshal(it.Val(), &p); err != nil {
+				it.Close()
+				return nil, err
+			}
+			prims = append(prims, &p)
+		}
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now().UnixNano()
+	var out []quad.Value
+	for _, p := range prims {
+		if p == nil || p.Deleted || p.IsNode() || p.IsExpired(now) {
+			continue
+		} else if p.Subject != link.Subject || p.Predicate != link.Predicate || p.Label != link.Label {
+			continue
+		}
+		v, err := qs.getValFromLog(ctx, tx, p.Object)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
 func (qs *QuadStore) indexNode(ctx context.Context, tx kv.Tx, p *cproto.Primitive, val quad.Value) error {
 	var err error
 	if val == nil {
@@ -723,6 +861,11 @@ func (qs *QuadStore) markAsDead(ctx context.Context, tx kv.Tx, p *cproto.Primiti
 	p.Deleted = true
 	//TODO(barakmich): Add tombstone?
 	qs.bloomRemove(p)
+	if p.Expires != 0 {
+		if err := tx.Del(ctx, expiryKey(p.Expires, p.ID)); err != nil {
+			return err
+		}
+	}
 	return qs.addToLog(ctx, tx, p)
 }
 
@@ -736,6 +879,11 @@ func (qs *QuadStore) markLinksDead(ctx context.Context, tx kv.Tx, links []*cprot
 			return err
 		}
 	}
+	if qs.versioned {
+		if err := qs.addTombstones(ctx, tx, links); err != nil {
+			return err
+		}
+	}
 	return qs.incSize(ctx, tx, -int64(len(links)))
 }
 
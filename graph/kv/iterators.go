@@ -5,9 +5,11 @@ import (
 	"fmt"
 
 	"github.com/hidal-go/hidalgo/kv"
+	"github.com/hidal-go/hidalgo/kv/options"
 
 	"github.com/cayleygraph/quad"
 
+	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/refs"
@@ -140,3 +142,134 @@ func (s IndexScan) BuildIterator(qs graph.QuadStore) iterator.Shape {
 func (s IndexScan) Optimize(ctx context.Context, r shape.Optimizer) (shape.Shape, bool) {
 	return s, false
 }
+
+// statsRefresh is a relative change of the number of quads after which cached direction statistics are recalculated.
+const statsRefresh = 10 // %
+
+type dirStats struct {
+	graph.DirectionStats
+	size int64 // number of quads at the time of calculation
+}
+
+var _ graph.Statistician = (*QuadStore)(nil)
+
+// DirectionStats calculates statistics by scanning an index that starts with a given direction.
+// If there is no such index, the number of distinct values is unknown.
+//
+// Since it's a full scan of the index, results are cached until the number of quads changes significantly.
+// Estimated statistics are calculated in background, thus they are unknown until the first scan finishes,
+// and previous ones are returned while they are recalculated. Exact statistics are always calculated by the caller.
+func (qs *QuadStore) DirectionStats(ctx context.Context, d quad.Direction, exact bool) (graph.DirectionStats, error) {
+	if d < quad.Subject || d > quad.Label {
+		return graph.DirectionStats{}, fmt.Errorf("kv: invalid direction: %v", d)
+	}
+	size, err := qs.getMetaInt(ctx, "size")
+	if err != nil {
+		return graph.DirectionStats{}, err
+	}
+	if exact {
+		st, err := qs.scanDirStats(ctx, d, size)
+		if err != nil {
+			return graph.DirectionStats{}, err
+		}
+		qs.stats.Lock()
+		qs.stats.dirs[d-1] = &dirStats{DirectionStats: st, size: size}
+		qs.stats.Unlock()
+		return st, nil
+	}
+	qs.stats.Lock()
+	defer qs.stats.Unlock()
+	c := qs.stats.dirs[d-1]
+	if c != nil {
+		diff := c.size - size
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff*100 <= c.size*statsRefresh {
+			return c.DirectionStats, nil
+		}
+	}
+	if !qs.stats.running[d-1] && qs.stats.ctx.Err() == nil {
+		qs.stats.running[d-1] = true
+		qs.stats.wg.Add(1)
+		go qs.refreshDirStats(d, size)
+	}
+	if c != nil {
+		return c.DirectionStats, nil
+	}
+	return graph.DirectionStats{Quads: refs.Size{Value: size, Exact: false}}, nil
+}
+
+// refreshDirStats calculates statistics for a given direction in background and caches them.
+func (qs *QuadStore) refreshDirStats(d quad.Direction, size int64) {
+	defer qs.stats.wg.Done()
+	ctx := qs.stats.ctx
+	st, err := qs.scanDirStats(ctx, d, size)
+	if err != nil && ctx.Err() == nil {
+		clog.Warningf("kv: cannot calculate statistics for %v: %v", d, err)
+	}
+	qs.stats.Lock()
+	defer qs.stats.Unlock()
+	qs.stats.running[d-1] = false
+	if err == nil {
+		qs.stats.dirs[d-1] = &dirStats{DirectionStats: st, size: size}
+	}
+}
+
+// scanDirStats calculates statistics by scanning an index that starts with a given direction.
+func (qs *QuadStore) scanDirStats(ctx context.Context, d quad.Direction, size int64) (graph.DirectionStats, error) {
+	var ind *QuadIndex
+	qs.indexes.RLock()
+	for i := range qs.indexes.all {
+		if qs.indexes.all[i].Dirs[0] == d {
+			ind = &qs.indexes.all[i]
+			break
+		}
+	}
+	qs.indexes.RUnlock()
+	if ind == nil {
+		return graph.DirectionStats{Quads: refs.Size{Value: size, Exact: false}}, nil
+	}
+	st := graph.DirectionStats{
+		Quads:  refs.Size{Exact: true},
+		Values: refs.Size{Exact: true},
+	}
+	var top graph.TopValues
+	err := kv.View(ctx, qs.db, func(tx kv.Tx) error {
+		var (
+			last uint64
+			cnt  int64
+		)
+		flush := func() {
+			if cnt != 0 {
+				st.Values.Value++
+				st.Quads.Value += cnt
+				top.Add(Int64Value(last), cnt)
+			}
+		}
+		it := tx.Scan(ctx, options.WithPrefixKV(ind.Key(nil)))
+		defer it.Close()
+		for it.Next(ctx) {
+			k := it.Key()
+			if len(k) != 2 || len(k[1]) < 8 {
+				continue
+			}
+			n, err := countIndex(it.Val())
+			if err != nil {
+				return err
+			}
+			if v := quadKeyEnc.Uint64(k[1]); v != last {
+				flush()
+				last, cnt = v, 0
+			}
+			cnt += n
+		}
+		flush()
+		return it.Err()
+	})
+	if err != nil {
+		return graph.DirectionStats{}, err
+	}
+	st.Top = top
+	return st, nil
+}
@@ -26,20 +26,21 @@ func (c Config) quadStore() *graphtest.Config {
 	return &graphtest.Config{
 		NoPrimitives:         true,
 		AlwaysRunIntegration: c.AlwaysRunIntegration,
+		NativeBatches:        true,
 	}
 }
 
-func newQuadStoreFunc(gen DatabaseFunc, bloom bool) testutil.DatabaseFunc {
+func newQuadStoreFunc(gen DatabaseFunc, bloom, versioned bool) testutil.DatabaseFunc {
 	return func(t testing.TB) (graph.QuadStore, graph.Options) {
-		return newQuadStore(t, gen, bloom)
+		return newQuadStore(t, gen, bloom, versioned)
 	}
 }
 
 func NewQuadStoreFunc(gen DatabaseFunc) testutil.DatabaseFunc {
-	return newQuadStoreFunc(gen, true)
+	return newQuadStoreFunc(gen, true, false)
 }
 
-func newQuadStore(t testing.TB, gen DatabaseFunc, bloom bool) (graph.QuadStore, graph.Options) {
+func newQuadStore(t testing.TB, gen DatabaseFunc, bloom, versioned bool) (graph.QuadStore, graph.Options) {
 	db, opt, closer := gen(t)
 	if opt == nil {
 		opt = make(graph.Options)
@@ -47,6 +48,9 @@ func newQuadStore(t testing.TB, gen DatabaseFunc, bloom bool) (graph.QuadStore,
 	if !bloom {
 		opt[kv.OptNoBloom] = true
 	}
+	if versioned {
+		opt[kv.OptVersioned] = true
+	}
 	err := kv.Init(db, opt)
 	if err != nil {
 		db.Close()
@@ -66,7 +70,7 @@ func newQuadStore(t testing.TB, gen DatabaseFunc, bloom bool) (graph.QuadStore,
 }
 
 func NewQuadStore(t testing.TB, gen DatabaseFunc) (graph.QuadStore, graph.Options) {
-	return newQuadStore(t, gen, true)
+	return newQuadStore(t, gen, true, false)
 }
 
 func TestAll(t *testing.T, gen DatabaseFunc, conf *Config) {
@@ -77,10 +81,16 @@ func TestAll(t *testing.T, gen DatabaseFunc, conf *Config) {
 	t.Run("qs", func(t *testing.T) {
 		graphtest.TestAll(t, qsgen, conf.quadStore())
 	})
-	qsgenNoBloom := newQuadStoreFunc(gen, false)
+	qsgenNoBloom := newQuadStoreFunc(gen, false, false)
 	t.Run("qs-no-bloom", func(t *testing.T) {
 		graphtest.TestAll(t, qsgenNoBloom, conf.quadStore())
 	})
+	qsgenVersioned := newQuadStoreFunc(gen, true, true)
+	t.Run("qs-versioned", func(t *testing.T) {
+		c := conf.quadStore()
+		c.Versioned = true
+		graphtest.TestAll(t, qsgenVersioned, c)
+	})
 	t.Run("optimize", func(t *testing.T) {
 		testOptimize(t, gen, conf)
 	})
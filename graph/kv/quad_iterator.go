@@ -17,6 +17,7 @@ package kv
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hidal-go/hidalgo/kv"
 	"github.com/hidal-go/hidalgo/kv/options"
@@ -83,6 +84,31 @@ func (it *QuadIterator) String() string {
 
 func (it *QuadIterator) Sorted() bool { return true }
 
+var _ iterator.OrderedShape = (*QuadIterator)(nil)
+
+// Order implements iterator.OrderedShape. Quads are sorted by their IDs only if all values
+// of the index key are known, since the iterator scans a single list of IDs in this case.
+func (it *QuadIterator) Order() iterator.Order {
+	if len(it.vals) != len(it.ind.Dirs) {
+		return nil
+	}
+	return quadOrder{}
+}
+
+// quadOrder is the order of quads in the index lists, which is the order of their IDs.
+type quadOrder struct{}
+
+func (quadOrder) Compare(a, b graph.Ref) int {
+	x, y := a.(*proto.Primitive).ID, b.(*proto.Primitive).ID
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
 func (it *QuadIterator) Optimize(ctx context.Context) (iterator.Shape, bool) {
 	return it, false
 }
@@ -110,6 +136,7 @@ type quadIteratorNext struct {
 	ids  []uint64
 	buf  []*proto.Primitive
 	prim *proto.Primitive
+	now  int64 // quads expired at this time are skipped
 }
 
 func (qs *QuadStore) newQuadIteratorNext(ind QuadIndex, vals []uint64) *quadIteratorNext {
@@ -117,6 +144,7 @@ func (qs *QuadStore) newQuadIteratorNext(ind QuadIndex, vals []uint64) *quadIter
 		qs:   qs,
 		ind:  ind,
 		vals: vals,
+		now:  time.Now().UnixNano(),
 	}
 }
 
@@ -159,43 +187,58 @@ func (it *quadIteratorNext) ensureTx(ctx context.Context) bool {
 	return true
 }
 
-func (it *quadIteratorNext) Next(ctx context.Context) bool {
-	it.prim = nil
-	if it.err != nil || it.done {
+// open starts scanning the index.
+func (it *quadIteratorNext) open(ctx context.Context) bool {
+	if it.it != nil {
+		return true
+	} else if !it.ensureTx(ctx) {
+		return false
+	}
+	it.it = it.tx.Scan(ctx, options.WithPrefixKV(it.ind.Key(it.vals)))
+	if err := it.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// fetch reads up to n primitives of the next quads in the index with a single multi-key read.
+func (it *quadIteratorNext) fetch(ctx context.Context, n int) bool {
+	if it.err = ctx.Err(); it.err != nil {
 		return false
 	}
-	if it.it == nil {
-		if !it.ensureTx(ctx) {
+	for len(it.ids[it.off:]) == 0 {
+		it.off = 0
+		it.ids = nil
+		it.buf = nil
+		if !it.it.Next(ctx) {
+			it.Close()
+			it.done = true
 			return false
 		}
-		it.it = it.tx.Scan(ctx, options.WithPrefixKV(it.ind.Key(it.vals)))
-		if err := it.Err(); err != nil {
-			it.err = err
+		it.ids, it.err = decodeIndex(it.it.Val())
+		if it.err != nil {
 			return false
 		}
 	}
+	ids := it.ids[it.off:]
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	it.buf, it.err = it.qs.getPrimitivesFromLog(ctx, it.tx, ids)
+	return it.err == nil
+}
+
+func (it *quadIteratorNext) Next(ctx context.Context) bool {
+	it.prim = nil
+	if it.err != nil || it.done {
+		return false
+	} else if !it.open(ctx) {
+		return false
+	}
 	for {
 		if len(it.buf) == 0 {
-			for len(it.ids[it.off:]) == 0 {
-				it.off = 0
-				it.ids = nil
-				it.buf = nil
-				if !it.it.Next(ctx) {
-					it.Close()
-					it.done = true
-					return false
-				}
-				it.ids, it.err = decodeIndex(it.it.Val())
-				if it.err != nil {
-					return false
-				}
-			}
-			ids := it.ids[it.off:]
-			if len(ids) > nextBatch {
-				ids = ids[:nextBatch]
-			}
-			it.buf, it.err = it.qs.getPrimitivesFromLog(ctx, it.tx, ids)
-			if it.err != nil {
+			if !it.fetch(ctx, nextBatch) {
 				return false
 			}
 		} else {
@@ -203,7 +246,7 @@ func (it *quadIteratorNext) Next(ctx context.Context) bool {
 		}
 		for ; len(it.buf) > 0; it.buf, it.off = it.buf[1:], it.off+1 {
 			p := it.buf[0]
-			if p == nil || p.Deleted {
+			if p == nil || p.Deleted || p.IsExpired(it.now) {
 				continue
 			}
 			// TODO(dennwc): shouldn't this check the horizon?
@@ -213,8 +256,39 @@ func (it *quadIteratorNext) Next(ctx context.Context) bool {
 	}
 }
 
-func (it *quadIteratorNext) NextPath(ctx context.Context) bool {
-	return false
+var _ iterator.BatchScanner = (*quadIteratorNext)(nil)
+
+// NextBatch implements iterator.BatchScanner. Primitives for the whole batch are read from the log at once.
+func (it *quadIteratorNext) NextBatch(ctx context.Context, buf []graph.Ref) int {
+	it.prim = nil
+	if it.err != nil || it.done || len(buf) == 0 {
+		return 0
+	} else if !it.open(ctx) {
+		return 0
+	}
+	if len(it.buf) != 0 {
+		// skip the current result
+		it.buf, it.off = it.buf[1:], it.off+1
+	}
+	n := 0
+	for {
+		for ; len(it.buf) > 0; it.buf, it.off = it.buf[1:], it.off+1 {
+			p := it.buf[0]
+			if p == nil || p.Deleted || p.IsExpired(it.now) {
+				continue
+			}
+			it.prim = p
+			buf[n] = p
+			n++
+			if n == len(buf) {
+				// keep the last result as the current one
+				return n
+			}
+		}
+		if !it.fetch(ctx, len(buf)) {
+			return n
+		}
+	}
 }
 
 func (it *quadIteratorNext) String() string {
@@ -230,6 +304,7 @@ type quadIteratorContains struct {
 
 	err  error
 	prim *proto.Primitive
+	now  int64 // quads expired at this time are skipped
 }
 
 func (qs *QuadStore) newQuadIteratorContains(ind QuadIndex, vals []uint64) *quadIteratorContains {
@@ -237,6 +312,7 @@ func (qs *QuadStore) newQuadIteratorContains(ind QuadIndex, vals []uint64) *quad
 		qs:   qs,
 		ind:  ind,
 		vals: vals,
+		now:  time.Now().UnixNano(),
 	}
 }
 
@@ -257,15 +333,11 @@ func (it *quadIteratorContains) Result() graph.Ref {
 	return it.prim
 }
 
-func (it *quadIteratorContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *quadIteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 	it.prim = nil
 	// TODO(dennwc): shouldn't this check the horizon?
 	p, ok := v.(*proto.Primitive)
-	if !ok {
+	if !ok || p.IsExpired(it.now) {
 		return false
 	}
 	for i, v := range it.vals {
@@ -27,6 +27,7 @@ import (
 	"github.com/cayleygraph/quad/pquads"
 	"github.com/hidal-go/hidalgo/kv"
 	boom "github.com/tylertreat/BoomFilters"
+	protobuf "google.golang.org/protobuf/proto"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/proto"
@@ -104,6 +105,8 @@ type QuadStore struct {
 
 	valueLRU *lru.Cache
 
+	versioned bool // history of changes is kept in the log, see AsOf
+
 	writer    sync.Mutex
 	mapBucket map[string]map[string][]uint64
 	mapBloom  map[string]*boom.BloomFilter
@@ -115,10 +118,22 @@ type QuadStore struct {
 		buf []byte
 		*boom.DeletableBloomFilter
 	}
+
+	// stats caches statistics for each direction, see DirectionStats
+	stats struct {
+		sync.Mutex
+		dirs    [4]*dirStats
+		running [4]bool // statistics are being calculated in background
+		wg      sync.WaitGroup
+		ctx     context.Context // cancelled when the store is closed
+		cancel  func()
+	}
 }
 
 func newQuadStore(kv kv.KV) *QuadStore {
-	return &QuadStore{db: kv}
+	qs := &QuadStore{db: kv}
+	qs.stats.ctx, qs.stats.cancel = context.WithCancel(context.Background())
+	return qs
 }
 
 func Init(kv kv.KV, opt graph.Options) error {
@@ -142,6 +157,10 @@ func Init(kv kv.KV, opt graph.Options) error {
 	if err != nil {
 		return err
 	}
+	versioned, err := opt.BoolKey(OptVersioned, false)
+	if err != nil {
+		return err
+	}
 	if err := qs.createBuckets(ctx, upfront); err != nil {
 		return err
 	}
@@ -151,11 +170,16 @@ func Init(kv kv.KV, opt graph.Options) error {
 	if err := qs.writeIndexesMeta(ctx); err != nil {
 		return err
 	}
+	if versioned {
+		return setVersioned(ctx, qs.db)
+	}
 	return nil
 }
 
 const (
 	OptNoBloom = "no_bloom"
+	// OptVersioned enables recording of the history of all changes. It can only be set when the database is initialized.
+	OptVersioned = "versioned"
 )
 
 func New(kv kv.KV, opt graph.Options) (graph.QuadStore, error) {
@@ -173,6 +197,9 @@ func New(kv kv.KV, opt graph.Options) (graph.QuadStore, error) {
 		return nil, err
 	}
 	qs.indexes.all = list
+	if qs.versioned, err = qs.isVersioned(ctx); err != nil {
+		return nil, err
+	}
 	qs.valueLRU = lru.New(2000)
 	qs.exists.disabled, _ = opt.BoolKey(OptNoBloom, false)
 	if err := qs.initBloomFilter(ctx); err != nil {
@@ -263,6 +290,8 @@ func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error)
 }
 
 func (qs *QuadStore) Close() error {
+	qs.stats.cancel()
+	qs.stats.wg.Wait()
 	return qs.db.Close()
 }
 
@@ -468,3 +497,40 @@ func (qs *QuadStore) getPrimitives(ctx context.Context, vals []uint64) ([]*proto
 type Int64Value uint64
 
 func (v Int64Value) Key() interface{} { return v }
+
+var _ refs.Codec = (*QuadStore)(nil)
+
+// AppendRef implements refs.Codec.
+func (qs *QuadStore) AppendRef(buf []byte, r graph.Ref) ([]byte, error) {
+	switch r := r.(type) {
+	case Int64Value:
+		buf = append(buf, 'n')
+		return binary.AppendUvarint(buf, uint64(r)), nil
+	case *proto.Primitive:
+		buf = append(buf, 'q')
+		return protobuf.MarshalOptions{}.MarshalAppend(buf, r)
+	}
+	return nil, fmt.Errorf("kv: unsupported reference: %T", r)
+}
+
+// DecodeRef implements refs.Codec.
+func (qs *QuadStore) DecodeRef(p []byte) (graph.Ref, error) {
+	if len(p) == 0 {
+		return nil, errors.New("kv: invalid reference")
+	}
+	switch p[0] {
+	case 'n':
+		v, n := binary.Uvarint(p[1:])
+		if n <= 0 || n != len(p)-1 {
+			return nil, errors.New("kv: invalid reference")
+		}
+		return Int64Value(v), nil
+	case 'q':
+		var prim proto.Primitive
+		if err := protobuf.Unmarshal(p[1:], &prim); err != nil {
+			return nil, err
+		}
+		return &prim, nil
+	}
+	return nil, errors.New("kv: invalid reference")
+}
@@ -133,6 +133,7 @@ func TestApplyDeltas(t *testing.T) {
 		{opGet, key(bMeta, kVers), nil, hkv.ErrNotFound},
 		{opPut, key(bMeta, []byte{}), nil, nil},
 		{opPut, key(bLog, []byte{}), nil, nil},
+		{opPut, key("expiry", []byte{}), nil, nil},
 		{opPut, key("sp", []byte{}), nil, nil},
 		{opPut, key("ops", []byte{}), nil, nil},
 		{opPut, key(bMeta, kVers), vVers, nil},
@@ -146,6 +147,7 @@ func TestApplyDeltas(t *testing.T) {
 	expect(Ops{
 		{opGet, key(bMeta, kVers), vVers, nil},
 		{opGet, key(bMeta, kIndexes), []byte(`[{"dirs":"AQI=","unique":false},{"dirs":"AwIB","unique":false}]`), nil},
+		{opGet, key(bMeta, []byte("versioned")), nil, hkv.ErrNotFound},
 		{opGet, key(bMeta, []byte("size")), nil, hkv.ErrNotFound},
 	})
 
@@ -78,6 +78,15 @@ func (it *LinksTo) SubIterators() []iterator.Shape {
 	return []iterator.Shape{it.primary}
 }
 
+var _ iterator.Composite = (*LinksTo)(nil)
+
+// MapSubIterators implements iterator.Composite.
+func (it *LinksTo) MapSubIterators(m iterator.Morphism) iterator.Shape {
+	nit := *it
+	nit.primary = m(it.primary)
+	return &nit
+}
+
 // Optimize the LinksTo, by replacing it if it can be.
 func (it *LinksTo) Optimize(ctx context.Context) (iterator.Shape, bool) {
 	newPrimary, changed := it.primary.Optimize(ctx)
@@ -122,11 +131,19 @@ func (it *LinksTo) getSize(ctx context.Context) refs.Size {
 		it.size.Value, it.size.Exact = sz, exact
 		return it.size
 	}
+	st, _ := it.primary.Stats(ctx)
+	if ds, ok := StatsOf(ctx, it.qs, it.dir); ok {
+		value := st.Size.Value * ds.Fanout()
+		if value > ds.Quads.Value {
+			value = ds.Quads.Value
+		}
+		it.size.Value, it.size.Exact = value, false
+		return it.size
+	}
 	stats, _ := it.qs.Stats(ctx, false)
 	maxSize := stats.Quads.Value/2 + 1
 	// TODO(barakmich): It should really come from the quadstore itself
 	const fanoutFactor = 20
-	st, _ := it.primary.Stats(ctx)
 	value := st.Size.Value * fanoutFactor
 	if value > maxSize {
 		value = maxSize
@@ -199,6 +216,31 @@ func (it *linksToNext) Next(ctx context.Context) bool {
 	}
 }
 
+// NextBatch is similar to Next, but fills the batch with quads of one or more nodes of the subiterator.
+func (it *linksToNext) NextBatch(ctx context.Context, buf []refs.Ref) int {
+	if it.err != nil {
+		return 0
+	}
+	n := 0
+	for n < len(buf) {
+		if m := iterator.NextBatch(ctx, it.nextIt, buf[n:]); m > 0 {
+			n += m
+			continue
+		}
+		it.err = it.nextIt.Err()
+		if it.err != nil {
+			break
+		}
+		if !it.primary.Next(ctx) {
+			it.err = it.primary.Err()
+			break
+		}
+		it.nextIt.Close()
+		it.nextIt = it.qs.QuadIterator(it.dir, it.primary.Result()).Iterate()
+	}
+	return n
+}
+
 func (it *linksToNext) Err() error {
 	return it.err
 }
@@ -221,12 +263,8 @@ func (it *linksToNext) Close() error {
 }
 
 // We won't ever have a new result, but our subiterators might.
-func (it *linksToNext) NextPath(ctx context.Context) bool {
-	ok := it.primary.NextPath(ctx)
-	if !ok {
-		it.err = it.primary.Err()
-	}
-	return ok
+func (it *linksToNext) Paths(ctx context.Context) iterator.Scanner {
+	return iterator.SubPaths(ctx, it.primary, it.result, nil)
 }
 
 // A LinksTo has a reference back to the graph.QuadStore (to create the iterators
@@ -295,6 +333,6 @@ func (it *linksToContains) Close() error {
 }
 
 // We won't ever have a new result, but our subiterators might.
-func (it *linksToContains) NextPath(ctx context.Context) bool {
-	return it.primary.NextPath(ctx)
+func (it *linksToContains) Paths(ctx context.Context) iterator.Scanner {
+	return iterator.SubPaths(ctx, it.primary, it.result, nil)
 }
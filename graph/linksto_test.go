@@ -45,3 +45,35 @@ func TestLinksTo(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, q, qv)
 }
+
+func TestLinksToHasABatch(t *testing.T) {
+	ctx := context.TODO()
+	var data []quad.Quad
+	for i := 0; i < 10; i++ {
+		data = append(data,
+			quad.Make(quad.IRI("alice"), quad.IRI("knows"), i, nil),
+			quad.Make(quad.IRI("bob"), quad.IRI("knows"), i, nil),
+		)
+	}
+	qs := &graphmock.Store{Data: data}
+	alice, err := qs.ValueOf(quad.IRI("alice"))
+	require.NoError(t, err)
+	bob, err := qs.ValueOf(quad.IRI("bob"))
+	require.NoError(t, err)
+
+	// objects of all quads with alice or bob as a subject
+	objects := graph.NewHasA(qs, graph.NewLinksTo(qs, iterator.NewFixed(alice, bob), quad.Subject), quad.Object)
+	expect, err := iterator.Iterate(ctx, objects).UnOptimized().All()
+	require.NoError(t, err)
+	require.Len(t, expect, len(data))
+
+	it := iterator.AsBatchScanner(objects.Iterate())
+	defer it.Close()
+	var got []graph.Ref
+	buf := make([]graph.Ref, 3)
+	for n := it.NextBatch(ctx, buf); n > 0; n = it.NextBatch(ctx, buf) {
+		got = append(got, buf[:n]...)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, expect, got)
+}
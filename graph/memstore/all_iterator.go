@@ -16,6 +16,7 @@ package memstore
 
 import (
 	"context"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -67,12 +68,12 @@ func (it *allIterator) Stats(ctx context.Context) (iterator.Costs, error) {
 	}, nil
 }
 
-func (p *Primitive) filter(isNode bool, maxid int64) bool {
+func (p *Primitive) filter(isNode bool, maxid, now int64) bool {
 	if p.ID > maxid {
 		return false
 	} else if isNode && p.Value != nil {
 		return true
-	} else if !isNode && !p.Quad.Zero() {
+	} else if !isNode && !p.Quad.Zero() && !p.expired(now) {
 		return true
 	}
 	return false
@@ -83,28 +84,36 @@ type allIteratorNext struct {
 	all   []*Primitive
 	maxid int64 // id of last observed insert (prim id)
 	nodes bool
+	now   int64 // quads expired at this time are skipped
 
 	i    int // index into qs.all
 	cur  *Primitive
 	done bool
+	err  error
 }
 
 func (qs *QuadStore) newAllIteratorNext(nodes bool, maxid int64, all []*Primitive) *allIteratorNext {
 	return &allIteratorNext{
 		qs: qs, all: all, nodes: nodes,
-		i: -1, maxid: maxid,
+		i: -1, maxid: maxid, now: time.Now().UnixNano(),
 	}
 }
 
 func (it *allIteratorNext) ok(p *Primitive) bool {
-	return p.filter(it.nodes, it.maxid)
+	return p.filter(it.nodes, it.maxid, it.now)
 }
 
 func (it *allIteratorNext) Next(ctx context.Context) bool {
 	it.cur = nil
 	if it.done {
 		return false
+	} else if err := ctx.Err(); err != nil {
+		it.err = err
+		it.done = true
+		return false
 	}
+	it.qs.mu.RLock()
+	defer it.qs.mu.RUnlock()
 	all := it.all
 	if it.i >= len(all) {
 		it.done = true
@@ -125,17 +134,57 @@ func (it *allIteratorNext) Next(ctx context.Context) bool {
 	return false
 }
 
+var _ iterator.BatchScanner = (*allIteratorNext)(nil)
+
+// NextBatch implements iterator.BatchScanner. It copies primitives from the snapshot of the store under a single lock.
+func (it *allIteratorNext) NextBatch(ctx context.Context, buf []graph.Ref) int {
+	it.cur = nil
+	if it.done {
+		return 0
+	} else if err := ctx.Err(); err != nil {
+		it.err = err
+		it.done = true
+		return 0
+	}
+	it.qs.mu.RLock()
+	defer it.qs.mu.RUnlock()
+	all := it.all
+	n := 0
+	for it.i+1 < len(all) && n < len(buf) {
+		p := all[it.i+1]
+		if p.ID > it.maxid {
+			it.done = true
+			break
+		}
+		it.i++
+		if it.ok(p) {
+			it.cur = p
+			buf[n] = refOf(p)
+			n++
+		}
+	}
+	if it.i+1 >= len(all) {
+		it.done = true
+	}
+	return n
+}
+
 func (it *allIteratorNext) Result() graph.Ref {
 	if it.cur == nil {
 		return nil
 	}
-	if !it.cur.Quad.Zero() {
-		return qprim{p: it.cur}
+	return refOf(it.cur)
+}
+
+// refOf returns a reference to a quad or a node primitive.
+func refOf(p *Primitive) graph.Ref {
+	if !p.Quad.Zero() {
+		return qprim{p: p}
 	}
-	return bnode(it.cur.ID)
+	return bnode(p.ID)
 }
 
-func (it *allIteratorNext) Err() error { return nil }
+func (it *allIteratorNext) Err() error { return it.err }
 func (it *allIteratorNext) Close() error {
 	it.done = true
 	it.all = nil
@@ -147,12 +196,12 @@ func (it *allIteratorNext) TagResults(dst map[string]graph.Ref) {}
 func (it *allIteratorNext) String() string {
 	return "MemStoreAllNext"
 }
-func (it *allIteratorNext) NextPath(ctx context.Context) bool { return false }
 
 type allIteratorContains struct {
 	qs    *QuadStore
 	maxid int64 // id of last observed insert (prim id)
 	nodes bool
+	now   int64 // quads expired at this time are skipped
 
 	cur  *Primitive
 	done bool
@@ -161,12 +210,12 @@ type allIteratorContains struct {
 func (qs *QuadStore) newAllIteratorContains(nodes bool, maxid int64) *allIteratorContains {
 	return &allIteratorContains{
 		qs: qs, nodes: nodes,
-		maxid: maxid,
+		maxid: maxid, now: time.Now().UnixNano(),
 	}
 }
 
 func (it *allIteratorContains) ok(p *Primitive) bool {
-	return p.filter(it.nodes, it.maxid)
+	return p.filter(it.nodes, it.maxid, it.now)
 }
 
 func (it *allIteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
@@ -178,8 +227,10 @@ func (it *allIteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 	if !ok {
 		return false
 	}
+	it.qs.mu.RLock()
 	p := it.qs.prim[id]
-	if p.ID > it.maxid {
+	it.qs.mu.RUnlock()
+	if p == nil || p.ID > it.maxid {
 		return false
 	}
 	if !it.ok(p) {
@@ -209,4 +260,3 @@ func (it *allIteratorContains) TagResults(dst map[string]graph.Ref) {}
 func (it *allIteratorContains) String() string {
 	return "MemStoreAllContains"
 }
-func (it *allIteratorContains) NextPath(ctx context.Context) bool { return false }
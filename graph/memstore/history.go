@@ -0,0 +1,300 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+var (
+	_ graph.Historian = (*QuadStore)(nil)
+	_ graph.Historian = (*Snapshot)(nil)
+	_ graph.QuadStore = (*Snapshot)(nil)
+)
+
+// stamp records when a change was made.
+type stamp struct {
+	horizon int64
+	ts      time.Time
+}
+
+// span is a validity interval of a quad.
+type span struct {
+	added   stamp
+	deleted *stamp // nil if quad is still valid
+}
+
+func (s *span) validAt(ver graph.Version) bool {
+	if !ver.Includes(s.added.horizon, s.added.ts) {
+		return false
+	}
+	return s.deleted == nil || !ver.Includes(s.deleted.horizon, s.deleted.ts)
+}
+
+// history keeps all versions of quads written to a versioned quad store.
+//
+// Every quad that was ever added is kept in the archive, which is never modified other than by adding quads.
+// Snapshots reuse archive indexes and only filter quads by their validity intervals.
+type history struct {
+	archive *QuadStore
+	spans   map[int64][]*span // validity intervals, by archive quad id
+	live    map[int64]*span   // current intervals, by live quad id
+	tx      *stamp            // stamp of the current transaction
+}
+
+// NewVersioned creates a new in-memory quad store that records the history of all changes, and loads provided quads.
+//
+// Deleted quads are kept as tombstones with validity intervals, and the store can be queried as it was
+// at any past time or horizon with AsOf.
+func NewVersioned(quads ...quad.Quad) *QuadStore {
+	qs := newQuadStore()
+	qs.history = &history{
+		archive: newQuadStore(),
+		spans:   make(map[int64][]*span),
+		live:    make(map[int64]*span),
+	}
+	qs.WriteQuads(quads)
+	return qs
+}
+
+// begin starts a new transaction, if history is recorded.
+func (qs *QuadStore) begin() {
+	if qs.history != nil && qs.history.tx == nil {
+		qs.history.tx = &stamp{horizon: qs.horizon + 1, ts: time.Now()}
+	}
+}
+
+// commit finishes the current transaction. Horizon is advanced even if history is not recorded.
+func (qs *QuadStore) commit() {
+	qs.horizon++
+	if qs.history != nil {
+		qs.history.tx = nil
+	}
+}
+
+func (h *history) added(id int64, q quad.Quad) {
+	aid, _ := h.archive.AddQuad(q)
+	s := &span{added: *h.tx}
+	h.spans[aid] = append(h.spans[aid], s)
+	h.live[id] = s
+}
+
+func (h *history) deleted(id int64) {
+	s, ok := h.live[id]
+	if !ok {
+		return
+	}
+	st := *h.tx
+	s.deleted = &st
+	delete(h.live, id)
+}
+
+// validAt checks if an archived quad was valid at a given version.
+func (h *history) validAt(id int64, v graph.Version) bool {
+	for _, s := range h.spans[id] {
+		if s.validAt(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Horizon returns the horizon of the last transaction.
+func (qs *QuadStore) Horizon() int64 {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	return qs.horizon
+}
+
+// AsOf returns a read-only snapshot of the graph as it was at a given version.
+// It returns graph.ErrNoHistory if the store was not created with NewVersioned.
+//
+// The snapshot shares indexes with the quad store and is created in constant time.
+func (qs *QuadStore) AsOf(ctx context.Context, v graph.Version) (graph.QuadStore, error) {
+	if qs.history == nil {
+		return nil, graph.ErrNoHistory
+	} else if v.IsZero() {
+		return qs, nil
+	}
+	return &Snapshot{qs: qs, archive: qs.history.archive, parent: qs, version: v}, nil
+}
+
+// NewSnapshot creates a read-only in-memory snapshot of a graph at a given version from quads that were valid
+// at that version. It can be used by quad stores that keep history in a persistent log.
+//
+// AsOf calls on the snapshot are delegated to the parent.
+func NewSnapshot(parent graph.Historian, v graph.Version, quads ...quad.Quad) *Snapshot {
+	return &Snapshot{archive: New(quads...), parent: parent, version: v}
+}
+
+// Snapshot is a read-only view of a versioned quad store at a given version.
+type Snapshot struct {
+	qs      *QuadStore // versioned quad store; nil if all archived quads are valid
+	archive *QuadStore
+	parent  graph.Historian
+	version graph.Version
+}
+
+// Version returns the version of the graph this snapshot represents.
+func (s *Snapshot) Version() graph.Version {
+	return s.version
+}
+
+// AsOf returns a snapshot of the original quad store at a different version.
+func (s *Snapshot) AsOf(ctx context.Context, v graph.Version) (graph.QuadStore, error) {
+	return s.parent.AsOf(ctx, v)
+}
+
+// quadValid checks if an archived quad was valid at the snapshot version.
+func (s *Snapshot) quadValid(r graph.Ref) bool {
+	if s.qs == nil {
+		return true
+	}
+	id, ok := asID(r)
+	if !ok {
+		return false
+	}
+	s.qs.mu.RLock()
+	defer s.qs.mu.RUnlock()
+	return s.qs.history.validAt(id, s.version)
+}
+
+// nodeValid checks if any quad that references a node was valid at the snapshot version.
+func (s *Snapshot) nodeValid(r graph.Ref) bool {
+	if s.qs == nil {
+		return true
+	}
+	id, ok := asID(r)
+	if !ok {
+		return false
+	}
+	s.qs.mu.RLock()
+	defer s.qs.mu.RUnlock()
+	s.archive.mu.RLock()
+	defer s.archive.mu.RUnlock()
+	for _, d := range quad.Directions {
+		t, ok := s.archive.index.Get(d, id)
+		if !ok {
+			continue
+		}
+		if s.anyValid(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Snapshot) anyValid(t *Tree) bool {
+	e, err := t.SeekFirst()
+	if err != nil {
+		return false
+	}
+	defer e.Close()
+	for {
+		id, _, err := e.Next()
+		if err != nil {
+			return false
+		} else if s.qs.history.validAt(id, s.version) {
+			return true
+		}
+	}
+}
+
+func (s *Snapshot) filter(it iterator.Shape, name string, valid func(graph.Ref) bool) iterator.Shape {
+	if s.qs == nil {
+		return it
+	}
+	return &versionFilter{sub: it, name: name, valid: valid}
+}
+
+func (s *Snapshot) ValueOf(v quad.Value) (graph.Ref, error) {
+	r, err := s.archive.ValueOf(v)
+	if err != nil || r == nil || !s.nodeValid(r) {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *Snapshot) NameOf(v graph.Ref) (quad.Value, error) {
+	return s.archive.NameOf(v)
+}
+
+func (s *Snapshot) Quad(r graph.Ref) (quad.Quad, error) {
+	return s.archive.Quad(r)
+}
+
+func (s *Snapshot) QuadDirection(r graph.Ref, d quad.Direction) (graph.Ref, error) {
+	return s.archive.QuadDirection(r, d)
+}
+
+func (s *Snapshot) QuadIterator(d quad.Direction, v graph.Ref) iterator.Shape {
+	return s.filter(s.archive.QuadIterator(d, v), fmt.Sprintf("MemStoreAsOf(%v)", d), s.quadValid)
+}
+
+func (s *Snapshot) QuadIteratorSize(ctx context.Context, d quad.Direction, v graph.Ref) (refs.Size, error) {
+	sz, err := s.archive.QuadIteratorSize(ctx, d, v)
+	if s.qs != nil {
+		sz.Exact = false
+	}
+	return sz, err
+}
+
+func (s *Snapshot) QuadsAllIterator() iterator.Shape {
+	return s.filter(s.archive.QuadsAllIterator(), "MemStoreAllAsOf", s.quadValid)
+}
+
+func (s *Snapshot) NodesAllIterator() iterator.Shape {
+	return s.filter(s.archive.NodesAllIterator(), "MemStoreAllAsOf", s.nodeValid)
+}
+
+// Stats returns the stats of the archive, unless exact stats are requested.
+func (s *Snapshot) Stats(ctx context.Context, exact bool) (graph.Stats, error) {
+	st, err := s.archive.Stats(ctx, exact)
+	if err != nil || s.qs == nil {
+		return st, err
+	}
+	st.Nodes.Exact = false
+	st.Quads.Exact = false
+	if !exact {
+		return st, nil
+	}
+	nodes, err := iterator.Iterate(ctx, s.NodesAllIterator()).Count()
+	if err != nil {
+		return st, err
+	}
+	quads, err := iterator.Iterate(ctx, s.QuadsAllIterator()).Count()
+	if err != nil {
+		return st, err
+	}
+	st.Nodes = refs.Size{Value: nodes, Exact: true}
+	st.Quads = refs.Size{Value: quads, Exact: true}
+	return st, nil
+}
+
+func (s *Snapshot) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return graph.ErrReadOnly
+}
+
+func (s *Snapshot) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return graph.ErrReadOnly
+}
+
+func (s *Snapshot) NewQuadWriter() (quad.WriteCloser, error) {
+	return nil, graph.ErrReadOnly
+}
+
+func (s *Snapshot) WriteQuad(q quad.Quad) error {
+	return graph.ErrReadOnly
+}
+
+func (s *Snapshot) WriteQuads(buf []quad.Quad) (int, error) {
+	return 0, graph.ErrReadOnly
+}
+
+func (s *Snapshot) Close() error { return nil }
@@ -0,0 +1,143 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+var (
+	_ iterator.Shape     = (*versionFilter)(nil)
+	_ iterator.Composite = (*versionFilter)(nil)
+)
+
+// versionFilter hides archived quads and nodes that were not valid at the snapshot version.
+type versionFilter struct {
+	sub   iterator.Shape
+	name  string
+	valid func(refs.Ref) bool
+}
+
+func (it *versionFilter) Iterate() iterator.Scanner {
+	return &versionFilterNext{sub: it.sub.Iterate(), name: it.name, valid: it.valid}
+}
+
+func (it *versionFilter) Lookup() iterator.Index {
+	return &versionFilterContains{sub: it.sub.Lookup(), name: it.name, valid: it.valid}
+}
+
+func (it *versionFilter) SubIterators() []iterator.Shape {
+	return []iterator.Shape{it.sub}
+}
+
+// MapSubIterators implements iterator.Composite.
+func (it *versionFilter) MapSubIterators(m iterator.Morphism) iterator.Shape {
+	nit := *it
+	nit.sub = m(it.sub)
+	return &nit
+}
+
+func (it *versionFilter) String() string {
+	return it.name
+}
+
+// Optimize only optimizes the sub-iterator. The filter itself must never be removed.
+func (it *versionFilter) Optimize(ctx context.Context) (iterator.Shape, bool) {
+	sub, changed := it.sub.Optimize(ctx)
+	if changed {
+		it.sub = sub
+	}
+	return it, true
+}
+
+// Stats returns the stats of the sub-iterator, since it's not known how many values are filtered.
+func (it *versionFilter) Stats(ctx context.Context) (iterator.Costs, error) {
+	st, err := it.sub.Stats(ctx)
+	st.NextCost *= 2
+	st.ContainsCost *= 2
+	st.Size.Exact = false
+	return st, err
+}
+
+type versionFilterNext struct {
+	sub    iterator.Scanner
+	name   string
+	valid  func(refs.Ref) bool
+	result refs.Ref
+}
+
+func (it *versionFilterNext) Next(ctx context.Context) bool {
+	for it.sub.Next(ctx) {
+		if v := it.sub.Result(); it.valid(v) {
+			it.result = v
+			return true
+		}
+	}
+	it.result = nil
+	return false
+}
+
+func (it *versionFilterNext) Paths(ctx context.Context) iterator.Scanner {
+	return iterator.Paths(ctx, it.sub)
+}
+
+func (it *versionFilterNext) TagResults(dst map[string]refs.Ref) {
+	it.sub.TagResults(dst)
+}
+
+func (it *versionFilterNext) Result() refs.Ref {
+	return it.result
+}
+
+func (it *versionFilterNext) Err() error {
+	return it.sub.Err()
+}
+
+func (it *versionFilterNext) Close() error {
+	return it.sub.Close()
+}
+
+func (it *versionFilterNext) String() string {
+	return it.name + "Next"
+}
+
+type versionFilterContains struct {
+	sub    iterator.Index
+	name   string
+	valid  func(refs.Ref) bool
+	result refs.Ref
+}
+
+func (it *versionFilterContains) Contains(ctx context.Context, v refs.Ref) bool {
+	it.result = nil
+	if !it.valid(v) || !it.sub.Contains(ctx, v) {
+		return false
+	}
+	it.result = it.sub.Result()
+	return true
+}
+
+func (it *versionFilterContains) Paths(ctx context.Context) iterator.Scanner {
+	return iterator.Paths(ctx, it.sub)
+}
+
+func (it *versionFilterContains) TagResults(dst map[string]refs.Ref) {
+	it.sub.TagResults(dst)
+}
+
+func (it *versionFilterContains) Result() refs.Ref {
+	return it.result
+}
+
+func (it *versionFilterContains) Err() error {
+	return it.sub.Err()
+}
+
+func (it *versionFilterContains) Close() error {
+	return it.sub.Close()
+}
+
+func (it *versionFilterContains) String() string {
+	return it.name + "Contains"
+}
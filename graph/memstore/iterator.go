@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -61,17 +62,47 @@ func (it *Iterator) String() string {
 
 func (it *Iterator) Sorted() bool { return true }
 
+var _ iterator.OrderedShape = (*Iterator)(nil)
+
+// Order implements iterator.OrderedShape. Quads in the index are sorted by their IDs.
+func (it *Iterator) Order() iterator.Order {
+	if it.d == 0 {
+		return nil
+	}
+	return quadOrder{}
+}
+
+// quadOrder is the order of quads in indexes, which is the order of their IDs.
+type quadOrder struct{}
+
+func (quadOrder) Compare(a, b graph.Ref) int {
+	x, y := a.(qprim).p.ID, b.(qprim).p.ID
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
 func (it *Iterator) Optimize(ctx context.Context) (iterator.Shape, bool) {
 	return it, false
 }
 
 func (it *Iterator) Stats(ctx context.Context) (iterator.Costs, error) {
+	it.qs.mu.RLock()
+	defer it.qs.mu.RUnlock()
+	containsCost := int64(1) // quads are checked by their direction, without a lookup
+	if it.d == 0 {
+		containsCost = int64(math.Log(float64(it.tree.Len()))) + 1
+	}
 	return iterator.Costs{
-		ContainsCost: int64(math.Log(float64(it.tree.Len()))) + 1,
+		ContainsCost: containsCost,
 		NextCost:     1,
 		Size: refs.Size{
 			Value: int64(it.tree.Len()),
-			Exact: true,
+			Exact: len(it.qs.expiring) == 0, // may include expired quads
 		},
 	}, nil
 }
@@ -82,6 +113,7 @@ type iteratorNext struct {
 	tree  *Tree
 	d     quad.Direction
 
+	now  int64 // quads expired at this time are skipped
 	iter *Enumerator
 	cur  *Primitive
 	err  error
@@ -93,6 +125,7 @@ func (qs *QuadStore) newIteratorNext(tree *Tree, d quad.Direction) *iteratorNext
 		d:     d,
 		qs:    qs,
 		tree:  tree,
+		now:   time.Now().UnixNano(),
 	}
 }
 
@@ -103,6 +136,12 @@ func (it *iteratorNext) Close() error {
 }
 
 func (it *iteratorNext) Next(ctx context.Context) bool {
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	it.qs.mu.RLock()
+	defer it.qs.mu.RUnlock()
 	if it.iter == nil {
 		it.iter, it.err = it.tree.SeekFirst()
 		if it.err == io.EOF || it.iter == nil {
@@ -119,12 +158,51 @@ func (it *iteratorNext) Next(ctx context.Context) bool {
 				it.err = err
 			}
 			return false
+		} else if p.expired(it.now) {
+			continue
 		}
 		it.cur = p
 		return true
 	}
 }
 
+var _ iterator.BatchScanner = (*iteratorNext)(nil)
+
+// NextBatch implements iterator.BatchScanner. It copies quads from the index tree under a single lock.
+func (it *iteratorNext) NextBatch(ctx context.Context, buf []graph.Ref) int {
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return 0
+	}
+	it.qs.mu.RLock()
+	defer it.qs.mu.RUnlock()
+	if it.iter == nil {
+		it.iter, it.err = it.tree.SeekFirst()
+		if it.err == io.EOF || it.iter == nil {
+			it.err = nil
+			return 0
+		} else if it.err != nil {
+			return 0
+		}
+	}
+	n := 0
+	for n < len(buf) {
+		_, p, err := it.iter.Next()
+		if err != nil {
+			if err != io.EOF {
+				it.err = err
+			}
+			break
+		} else if p.expired(it.now) {
+			continue
+		}
+		it.cur = p
+		buf[n] = qprim{p: p}
+		n++
+	}
+	return n
+}
+
 func (it *iteratorNext) Err() error {
 	return it.err
 }
@@ -136,10 +214,6 @@ func (it *iteratorNext) Result() graph.Ref {
 	return qprim{p: it.cur}
 }
 
-func (it *iteratorNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *iteratorNext) String() string {
 	return fmt.Sprintf("MemStoreNext(%v)", it.d)
 }
@@ -152,6 +226,7 @@ type iteratorContains struct {
 	tree  *Tree
 
 	cur *Primitive
+	now int64 // quads expired at this time are skipped
 
 	d     quad.Direction
 	value int64
@@ -164,6 +239,7 @@ func (qs *QuadStore) newIteratorContains(tree *Tree, d quad.Direction, value int
 		tree:  tree,
 		d:     d,
 		value: value,
+		now:   time.Now().UnixNano(),
 	}
 }
 
@@ -184,22 +260,20 @@ func (it *iteratorContains) Result() graph.Ref {
 	return qprim{p: it.cur}
 }
 
-func (it *iteratorContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *iteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 	if v == nil {
 		return false
 	}
+	it.qs.mu.RLock()
+	defer it.qs.mu.RUnlock()
 	switch v := v.(type) {
 	case bnode:
-		if p, ok := it.tree.Get(int64(v)); ok {
+		if p, ok := it.tree.Get(int64(v)); ok && !p.expired(it.now) {
 			it.cur = p
 			return true
 		}
 	case qprim:
-		if v.p.Quad.Dir(it.d) == it.value {
+		if v.p.Quad.Dir(it.d) == it.value && !v.p.expired(it.now) {
 			it.cur = v.p
 			return true
 		}
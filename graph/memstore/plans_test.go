@@ -0,0 +1,185 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/query/path"
+)
+
+func namedNode(qs *QuadStore, name string) *path.Path {
+	return path.StartPath(qs, quad.String(name)).In(quad.IRI("name"))
+}
+
+func filmsOf(qs *QuadStore, actor string) *path.Path {
+	return namedNode(qs, actor).In(quad.IRI("/film/performance/actor")).In(quad.IRI("/film/film/starring"))
+}
+
+// planCorpus is a set of queries on the movie dataset with plans chosen by the optimizer.
+// It shows how the optimizer uses statistics of the quad store, thus changes to the optimizer
+// or to statistics are expected to update these plans.
+//
+// Each line of the plan is an iterator with its estimated size; "~" means that the size is not exact.
+// The first sub-iterator of And is scanned, and the rest are checked in order.
+var planCorpus = []struct {
+	name    string
+	path    func(qs *QuadStore) *path.Path
+	plan    string
+	results int
+}{
+	{
+		name: "actors of a film",
+		path: func(qs *QuadStore) *path.Path {
+			return namedNode(qs, "Speed").Has(quad.IRI("type"), quad.IRI("/film/film")).
+				Out(quad.IRI("/film/film/starring")).Out(quad.IRI("/film/performance/actor")).
+				Has(quad.IRI("type"), quad.IRI("/people/person")).Out(quad.IRI("name"))
+		},
+		plan: `
+HasA(object) ~108
+  And ~108
+    LinksTo(subject) ~108
+      And ~36
+        HasA(object) ~36
+          And ~36
+            LinksTo(subject) ~36
+              HasA(object) ~12
+                And ~12
+                  LinksTo(subject) ~12
+                    And ~4
+                      HasA(subject) ~4
+                        And 4
+                          MemStore(object) 4
+                          MemStore(predicate) 74950
+                      HasA(subject) ~30000
+                        And 30000
+                          MemStore(object) 30000
+                          MemStore(predicate) 74956
+                  MemStore(predicate) 136737
+            MemStore(predicate) 136694
+        HasA(subject) ~44956
+          And 44956
+            MemStore(object) 44956
+            MemStore(predicate) 74956
+    MemStore(predicate) 74950
+`,
+		results: 28,
+	},
+	{
+		name: "films of two actors",
+		path: func(qs *QuadStore) *path.Path {
+			return filmsOf(qs, "Keanu Reeves").And(filmsOf(qs, "Sandra Bullock")).Out(quad.IRI("name"))
+		},
+		plan: `
+HasA(object) ~12
+  And ~12
+    LinksTo(subject) ~12
+      HashJoin ~4
+        HasA(subject) ~4
+          And ~4
+            LinksTo(object) ~4
+              HasA(subject) ~2
+                And ~2
+                  LinksTo(object) ~2
+                    HasA(subject) ~1
+                      And 1
+                        MemStore(object) 1
+                        MemStore(predicate) 74950
+                  MemStore(predicate) 136694
+            MemStore(predicate) 136737
+        HasA(subject) ~4
+          And ~4
+            LinksTo(object) ~4
+              HasA(subject) ~2
+                And ~2
+                  LinksTo(object) ~2
+                    HasA(subject) ~1
+                      And 1
+                        MemStore(object) 1
+                        MemStore(predicate) 74950
+                  MemStore(predicate) 136694
+            MemStore(predicate) 136737
+    MemStore(predicate) 74950
+`,
+		results: 2,
+	},
+	{
+		name: "films of a director",
+		path: func(qs *QuadStore) *path.Path {
+			return namedNode(qs, "Steven Spielberg").In(quad.IRI("/film/film/directed_by")).
+				Has(quad.IRI("type"), quad.IRI("/film/film")).Out(quad.IRI("name"))
+		},
+		plan: `
+HasA(object) ~6
+  And ~6
+    LinksTo(subject) ~6
+      And ~2
+        HasA(subject) ~2
+          And ~2
+            LinksTo(object) ~2
+              HasA(subject) ~1
+                And 1
+                  MemStore(object) 1
+                  MemStore(predicate) 74950
+            MemStore(predicate) 33310
+        HasA(subject) ~30000
+          And 30000
+            MemStore(object) 30000
+            MemStore(predicate) 74956
+    MemStore(predicate) 74950
+`,
+		results: 28,
+	},
+}
+
+func describePlan(ctx context.Context, s iterator.Shape) string {
+	var b strings.Builder
+	var describe func(s iterator.Shape, indent string)
+	describe = func(s iterator.Shape, indent string) {
+		st, _ := s.Stats(ctx)
+		approx := "~"
+		if st.Size.Exact {
+			approx = ""
+		}
+		fmt.Fprintf(&b, "%s%s %s%d\n", indent, s.String(), approx, st.Size.Value)
+		for _, sub := range s.SubIterators() {
+			describe(sub, indent+"  ")
+		}
+	}
+	describe(s, "")
+	return b.String()
+}
+
+func TestPlans(t *testing.T) {
+	// loading the dataset takes most of the time of the package tests, thus it only runs with integration tests
+	if testing.Short() || os.Getenv("RUN_INTEGRATION") != "true" {
+		t.Skip("skipping query plan tests; set RUN_INTEGRATION=true to run them")
+	}
+	qs := New()
+	qw, err := qs.NewQuadWriter()
+	require.NoError(t, err)
+	err = internal.Load(qw, 0, "../../data/30kmoviedata.nq.gz", "nquads")
+	require.NoError(t, err)
+	require.NoError(t, qw.Close())
+
+	ctx := context.TODO()
+	for _, c := range planCorpus {
+		t.Run(c.name, func(t *testing.T) {
+			s := c.path(qs).BuildIterator(ctx)
+			s, _ = s.Optimize(ctx)
+			require.Equal(t, strings.TrimPrefix(c.plan, "\n"), describePlan(ctx, s))
+
+			n, err := iterator.Iterate(ctx, s).UnOptimized().Count()
+			require.NoError(t, err)
+			require.Equal(t, c.results, int(n))
+		})
+	}
+}
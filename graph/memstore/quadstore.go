@@ -16,9 +16,14 @@ package memstore
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -30,7 +35,13 @@ const QuadStoreType = "memstore"
 
 func init() {
 	graph.RegisterQuadStore(QuadStoreType, graph.QuadStoreRegistration{
-		NewFunc: func(string, graph.Options) (graph.QuadStore, error) {
+		NewFunc: func(_ string, opt graph.Options) (graph.QuadStore, error) {
+			versioned, err := opt.BoolKey("versioned", false)
+			if err != nil {
+				return nil, err
+			} else if versioned {
+				return NewVersioned(), nil
+			}
 			return newQuadStore(), nil
 		},
 		UpgradeFunc:  nil,
@@ -57,10 +68,12 @@ func cmp(a, b int64) int {
 
 type QuadDirectionIndex struct {
 	index [4]map[int64]*Tree
+	// sp indexes quads by subject and predicate; it is nil until the first use, see QuadStore.indexSubjectPredicate
+	sp map[[2]int64]*Tree
 }
 
 func NewQuadDirectionIndex() QuadDirectionIndex {
-	return QuadDirectionIndex{[...]map[int64]*Tree{
+	return QuadDirectionIndex{index: [...]map[int64]*Tree{
 		quad.Subject - 1:   make(map[int64]*Tree),
 		quad.Predicate - 1: make(map[int64]*Tree),
 		quad.Object - 1:    make(map[int64]*Tree),
@@ -88,11 +101,33 @@ func (qdi QuadDirectionIndex) Get(d quad.Direction, id int64) (*Tree, bool) {
 	return tree, ok
 }
 
+// SubjectPredicateTree returns an index of quads with a given subject and predicate, creating it if necessary.
+func (qdi QuadDirectionIndex) SubjectPredicateTree(s, p int64) *Tree {
+	tree, ok := qdi.sp[[2]int64{s, p}]
+	if !ok {
+		tree = TreeNew(cmp)
+		qdi.sp[[2]int64{s, p}] = tree
+	}
+	return tree
+}
+
+// GetSubjectPredicate returns an index of quads with a given subject and predicate.
+func (qdi QuadDirectionIndex) GetSubjectPredicate(s, p int64) (*Tree, bool) {
+	tree, ok := qdi.sp[[2]int64{s, p}]
+	return tree, ok
+}
+
 type Primitive struct {
-	ID    int64
-	Quad  internalQuad
-	Value quad.Value
-	refs  int
+	ID      int64
+	Quad    internalQuad
+	Value   quad.Value
+	refs    int
+	expires int64 // expiry time of the quad in unix nanoseconds; zero if it never expires
+}
+
+// expired checks if the quad has expired at a given time.
+func (p *Primitive) expired(now int64) bool {
+	return p.expires != 0 && p.expires <= now
 }
 
 type internalQuad struct {
@@ -132,16 +167,29 @@ func (q internalQuad) Dir(d quad.Direction) int64 {
 	return n
 }
 
+// QuadStore is an in-memory quad store. It is safe for concurrent use.
+//
+// Iterators acquire a read lock for each call, so writes may be applied between calls of the same iterator.
 type QuadStore struct {
+	mu   sync.RWMutex // guards the fields below, except stats
 	last int64
 	// TODO: string -> quad.Value once Raw -> typed resolution is unnecessary
 	vals    map[string]int64
 	quads   map[internalQuad]int64
 	prim    map[int64]*Primitive
 	all     []*Primitive // might not be sorted by id
-	reading bool         // someone else might be reading "all" slice - next insert/delete should clone it
+	reading atomic.Bool  // someone else might be reading "all" slice - next insert/delete should clone it
 	index   QuadDirectionIndex
-	horizon int64 // used only to assign ids to tx
+	horizon int64    // used only to assign ids to tx
+	history *history // nil if history is not recorded
+	// expiring is a set of quads with an expiry time
+	expiring map[int64]*Primitive
+	// changes counts changes of indexes; used to invalidate cached direction statistics
+	changes int64
+	stats   struct {
+		sync.Mutex
+		dirs [4]*dirStats
+	}
 	// vip_index map[string]map[int64]map[string]map[int64]*b.Tree
 }
 
@@ -156,15 +204,16 @@ func New(quads ...quad.Quad) *QuadStore {
 
 func newQuadStore() *QuadStore {
 	return &QuadStore{
-		vals:  make(map[string]int64),
-		quads: make(map[internalQuad]int64),
-		prim:  make(map[int64]*Primitive),
-		index: NewQuadDirectionIndex(),
+		vals:     make(map[string]int64),
+		quads:    make(map[internalQuad]int64),
+		prim:     make(map[int64]*Primitive),
+		index:    NewQuadDirectionIndex(),
+		expiring: make(map[int64]*Primitive),
 	}
 }
 
 func (qs *QuadStore) cloneAll() []*Primitive {
-	qs.reading = true
+	qs.reading.Store(true)
 	return qs.all
 }
 
@@ -179,12 +228,12 @@ func (qs *QuadStore) addPrimitive(p *Primitive) int64 {
 
 func (qs *QuadStore) appendPrimitive(p *Primitive) {
 	qs.prim[p.ID] = p
-	if !qs.reading {
+	if !qs.reading.Load() {
 		qs.all = append(qs.all, p)
 	} else {
 		n := len(qs.all)
 		qs.all = append(qs.all[:n:n], p) // reallocate slice
-		qs.reading = false               // this is a new slice
+		qs.reading.Store(false)          // this is a new slice
 	}
 }
 
@@ -259,18 +308,23 @@ func (qs *QuadStore) lookupQuadDirs(p internalQuad) quad.Quad {
 
 // AddNode adds a blank node (with no value) to quad store. It returns an id of the node.
 func (qs *QuadStore) AddBNode() int64 {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
 	return qs.addPrimitive(&Primitive{})
 }
 
 // AddNode adds a value to quad store. It returns an id of the value.
 // False is returned as a second parameter if value exists already.
 func (qs *QuadStore) AddValue(v quad.Value) (int64, bool) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
 	id, exists := qs.resolveVal(v, true)
 	return id, !exists
 }
 
 func (qs *QuadStore) indexesForQuad(q internalQuad) []*Tree {
-	trees := make([]*Tree, 0, 4)
+	qs.changes++
+	trees := make([]*Tree, 0, 5)
 	for dir := quad.Subject; dir <= quad.Label; dir++ {
 		v := q.Dir(dir)
 		if v == 0 {
@@ -278,27 +332,75 @@ func (qs *QuadStore) indexesForQuad(q internalQuad) []*Tree {
 		}
 		trees = append(trees, qs.index.Tree(dir, v))
 	}
+	if q.S != 0 && q.P != 0 && qs.index.sp != nil {
+		trees = append(trees, qs.index.SubjectPredicateTree(q.S, q.P))
+	}
 	return trees
 }
 
 // AddQuad adds a quad to quad store. It returns an id of the quad.
 // False is returned as a second parameter if quad exists already.
 func (qs *QuadStore) AddQuad(q quad.Quad) (int64, bool) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if qs.history != nil {
+		qs.begin()
+		defer qs.commit()
+	}
+	return qs.addQuad(q)
+}
+
+func (qs *QuadStore) addQuad(q quad.Quad) (int64, bool) {
+	return qs.addExpiringQuad(q, 0, 0)
+}
+
+// addExpiringQuad adds a quad with a given expiry time (zero if it never expires).
+// The expiry time of an existing quad is updated according to the rules described in graph.Expirer.
+func (qs *QuadStore) addExpiringQuad(q quad.Quad, expires, now int64) (int64, bool) {
 	p, _ := qs.resolveQuad(q, false)
 	if id := qs.quads[p]; id != 0 {
+		if pr := qs.prim[id]; expires != 0 || pr.expired(now) {
+			qs.setExpiry(pr, expires)
+		}
 		return id, false
 	}
 	p, _ = qs.resolveQuad(q, true)
 	pr := &Primitive{Quad: p}
 	id := qs.addPrimitive(pr)
+	qs.setExpiry(pr, expires)
 	qs.quads[p] = id
 	for _, t := range qs.indexesForQuad(p) {
 		t.Set(id, pr)
 	}
+	if qs.history != nil {
+		qs.history.added(id, q)
+	}
 	// TODO(barakmich): Add VIP indexing
 	return id, true
 }
 
+func (qs *QuadStore) setExpiry(p *Primitive, expires int64) {
+	p.expires = expires
+	if expires != 0 {
+		qs.expiring[p.ID] = p
+	} else {
+		delete(qs.expiring, p.ID)
+	}
+}
+
+// writeQuads adds quads in a single transaction.
+func (qs *QuadStore) writeQuads(buf []quad.Quad) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if qs.history != nil {
+		qs.begin()
+		defer qs.commit()
+	}
+	for _, q := range buf {
+		qs.addQuad(q)
+	}
+}
+
 // WriteQuad adds a quad to quad store.
 //
 // Deprecated: use AddQuad instead.
@@ -309,9 +411,7 @@ func (qs *QuadStore) WriteQuad(q quad.Quad) error {
 
 // WriteQuads implements quad.Writer.
 func (qs *QuadStore) WriteQuads(buf []quad.Quad) (int, error) {
-	for _, q := range buf {
-		qs.AddQuad(q)
-	}
+	qs.writeQuads(buf)
 	return len(buf), nil
 }
 
@@ -329,9 +429,7 @@ func (w *quadWriter) WriteQuad(q quad.Quad) error {
 }
 
 func (w *quadWriter) WriteQuads(buf []quad.Quad) (int, error) {
-	for _, q := range buf {
-		w.qs.AddQuad(q)
-	}
+	w.qs.writeQuads(buf)
 	return len(buf), nil
 }
 
@@ -350,16 +448,32 @@ func (qs *QuadStore) deleteQuadNodes(q internalQuad) {
 			if p.refs < 0 {
 				panic("remove of deleted node")
 			} else if p.refs == 0 {
-				qs.Delete(id)
+				qs.delete(id)
 			}
 		}
 	}
 }
+
+// Delete removes a quad or a node with a given id.
 func (qs *QuadStore) Delete(id int64) bool {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	return qs.delete(id)
+}
+
+func (qs *QuadStore) delete(id int64) bool {
 	p := qs.prim[id]
 	if p == nil {
 		return false
 	}
+	if qs.history != nil && !p.Quad.Zero() {
+		// keep a tombstone
+		if qs.history.tx == nil {
+			qs.begin()
+			defer qs.commit()
+		}
+		qs.history.deleted(id)
+	}
 	// remove from value index
 	if p.Value != nil {
 		delete(qs.vals, p.Value.String())
@@ -369,6 +483,7 @@ func (qs *QuadStore) Delete(id int64) bool {
 		t.Delete(id)
 	}
 	delete(qs.quads, p.Quad)
+	delete(qs.expiring, id)
 	// remove primitive
 	delete(qs.prim, id)
 	di := -1
@@ -379,14 +494,14 @@ func (qs *QuadStore) Delete(id int64) bool {
 		}
 	}
 	if di >= 0 {
-		if !qs.reading {
+		if !qs.reading.Load() {
 			qs.all = append(qs.all[:di], qs.all[di+1:]...)
 		} else {
 			all := make([]*Primitive, 0, len(qs.all)-1)
 			all = append(all, qs.all[:di]...)
 			all = append(all, qs.all[di+1:]...)
 			qs.all = all
-			qs.reading = false // this is a new slice
+			qs.reading.Store(false) // this is a new slice
 		}
 	}
 	qs.deleteQuadNodes(p.Quad)
@@ -402,20 +517,98 @@ func (qs *QuadStore) findQuad(q quad.Quad) (int64, internalQuad, bool) {
 	return id, p, id != 0
 }
 
+// hasQuad checks if a quad exists and has not expired at a given time.
+func (qs *QuadStore) hasQuad(q quad.Quad, now int64) bool {
+	id, _, ok := qs.findQuad(q)
+	return ok && !qs.prim[id].expired(now)
+}
+
+// indexSubjectPredicate builds an index of quads by subject and predicate, unless it exists already.
+// Only preconditions and replace deltas need it, so it is built on their first use and then kept up to date.
+func (qs *QuadStore) indexSubjectPredicate() {
+	if qs.index.sp != nil {
+		return
+	}
+	qs.index.sp = make(map[[2]int64]*Tree)
+	for q, id := range qs.quads {
+		if q.S != 0 && q.P != 0 {
+			qs.index.SubjectPredicateTree(q.S, q.P).Set(id, qs.prim[id])
+		}
+	}
+}
+
+// objects returns objects of all quads with a given subject, predicate and label.
+func (qs *QuadStore) objects(s, p, l quad.Value) ([]quad.Value, error) {
+	qs.indexSubjectPredicate()
+	sid, ok := qs.resolveVal(s, false)
+	if !ok {
+		return nil, nil
+	}
+	pid, ok := qs.resolveVal(p, false)
+	if !ok {
+		return nil, nil
+	}
+	var lid int64
+	if l != nil {
+		if lid, ok = qs.resolveVal(l, false); !ok {
+			return nil, nil
+		}
+	}
+	tree, ok := qs.index.GetSubjectPredicate(sid, pid)
+	if !ok {
+		return nil, nil
+	}
+	it, err := tree.SeekFirst()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	now := time.Now().UnixNano()
+	var out []quad.Value
+	for {
+		_, prim, err := it.Next()
+		if err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if q := prim.Quad; q.L == lid && !prim.expired(now) {
+			out = append(out, qs.lookupVal(q.O))
+		}
+	}
+}
+
 func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, ignoreOpts)
+}
+
+// ApplyDeltasIf implements graph.ConditionalApplier.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if err := graph.CheckPreconditions(conds, qs.objects); err != nil {
+		return err
+	}
+	deltas, err := graph.ExpandReplace(deltas, qs.objects)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
 	// Precheck the whole transaction (if required)
 	if !ignoreOpts.IgnoreDup || !ignoreOpts.IgnoreMissing {
 		for _, d := range deltas {
 			switch d.Action {
 			case graph.Add:
-				if !ignoreOpts.IgnoreDup {
-					if _, _, ok := qs.findQuad(d.Quad); ok {
+				if !ignoreOpts.IgnoreDup && d.Expires.IsZero() {
+					if qs.hasQuad(d.Quad, now) {
 						return &graph.DeltaError{Delta: d, Err: graph.ErrQuadExists}
 					}
 				}
 			case graph.Delete:
 				if !ignoreOpts.IgnoreMissing {
-					if _, _, ok := qs.findQuad(d.Quad); !ok {
+					if !qs.hasQuad(d.Quad, now) {
 						return &graph.DeltaError{Delta: d, Err: graph.ErrQuadNotExist}
 					}
 				}
@@ -425,23 +618,62 @@ func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOp
 		}
 	}
 
+	qs.begin()
+	defer qs.commit()
 	for _, d := range deltas {
 		switch d.Action {
 		case graph.Add:
-			qs.AddQuad(d.Quad)
+			var expires int64
+			if !d.Expires.IsZero() {
+				expires = d.Expires.UnixNano()
+			}
+			qs.addExpiringQuad(d.Quad, expires, now)
 		case graph.Delete:
 			if id, _, ok := qs.findQuad(d.Quad); ok {
-				qs.Delete(id)
+				qs.delete(id)
 			}
 		default:
 			// TODO: ideally we should rollback it
 			return &graph.DeltaError{Delta: d, Err: graph.ErrInvalidAction}
 		}
 	}
-	qs.horizon++
 	return nil
 }
 
+var _ graph.Expirer = (*QuadStore)(nil)
+
+// ExpiredQuads implements graph.Expirer.
+func (qs *QuadStore) ExpiredQuads(ctx context.Context, now time.Time, n int) ([]quad.Quad, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	t := now.UnixNano()
+	var out []quad.Quad
+	for _, p := range qs.expiring {
+		if len(out) >= n {
+			break
+		} else if p.expired(t) {
+			out = append(out, qs.lookupQuadDirs(p.Quad))
+		}
+	}
+	return out, nil
+}
+
+// NextExpiry implements graph.Expirer.
+func (qs *QuadStore) NextExpiry(ctx context.Context) (time.Time, bool, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	var next int64
+	for _, p := range qs.expiring {
+		if next == 0 || p.expires < next {
+			next = p.expires
+		}
+	}
+	if next == 0 {
+		return time.Time{}, false, nil
+	}
+	return time.Unix(0, next), true, nil
+}
+
 func asID(v graph.Ref) (int64, bool) {
 	switch v := v.(type) {
 	case bnode:
@@ -470,6 +702,8 @@ func (qs *QuadStore) quad(v graph.Ref) (q internalQuad, ok bool) {
 }
 
 func (qs *QuadStore) Quad(index graph.Ref) (quad.Quad, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	q, ok := qs.quad(index)
 	if !ok {
 		return quad.Quad{}, nil
@@ -478,6 +712,8 @@ func (qs *QuadStore) Quad(index graph.Ref) (quad.Quad, error) {
 }
 
 func (qs *QuadStore) QuadIterator(d quad.Direction, value graph.Ref) iterator.Shape {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	id, ok := asID(value)
 	if !ok {
 		return iterator.NewNull()
@@ -490,6 +726,8 @@ func (qs *QuadStore) QuadIterator(d quad.Direction, value graph.Ref) iterator.Sh
 }
 
 func (qs *QuadStore) QuadIteratorSize(ctx context.Context, d quad.Direction, v graph.Ref) (refs.Size, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	id, ok := asID(v)
 	if !ok {
 		return refs.Size{Value: 0, Exact: true}, nil
@@ -502,6 +740,8 @@ func (qs *QuadStore) QuadIteratorSize(ctx context.Context, d quad.Direction, v g
 }
 
 func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	return graph.Stats{
 		Nodes: refs.Size{
 			Value: int64(len(qs.vals)),
@@ -514,11 +754,51 @@ func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error)
 	}, nil
 }
 
+type dirStats struct {
+	graph.DirectionStats
+	changes int64 // value of QuadStore.changes at the time of calculation
+}
+
+var _ graph.Statistician = (*QuadStore)(nil)
+
+// DirectionStats calculates statistics from the index of a given direction.
+// Results are cached until the next change of the quad store.
+func (qs *QuadStore) DirectionStats(ctx context.Context, d quad.Direction, exact bool) (graph.DirectionStats, error) {
+	if d < quad.Subject || d > quad.Label {
+		return graph.DirectionStats{}, fmt.Errorf("memstore: invalid direction: %v", d)
+	}
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	qs.stats.Lock()
+	defer qs.stats.Unlock()
+	if c := qs.stats.dirs[d-1]; c != nil && c.changes == qs.changes {
+		return c.DirectionStats, nil
+	}
+	var (
+		st  = graph.DirectionStats{Quads: refs.Size{Exact: true}, Values: refs.Size{Exact: true}}
+		top graph.TopValues
+	)
+	for id, tree := range qs.index.index[d-1] {
+		n := int64(tree.Len())
+		if n == 0 {
+			continue
+		}
+		st.Values.Value++
+		st.Quads.Value += n
+		top.Add(bnode(id), n)
+	}
+	st.Top = top
+	qs.stats.dirs[d-1] = &dirStats{DirectionStats: st, changes: qs.changes}
+	return st, nil
+}
+
 func (qs *QuadStore) ValueOf(name quad.Value) (graph.Ref, error) {
 	if name == nil {
 		return nil, nil
 	}
+	qs.mu.RLock()
 	id := qs.vals[name.String()]
+	qs.mu.RUnlock()
 	if id == 0 {
 		return nil, nil
 	}
@@ -535,17 +815,62 @@ func (qs *QuadStore) NameOf(v graph.Ref) (quad.Value, error) {
 	if !ok {
 		return nil, nil
 	}
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	if _, ok = qs.prim[n]; !ok {
 		return nil, nil
 	}
 	return qs.lookupVal(n), nil
 }
 
+var _ refs.Codec = (*QuadStore)(nil)
+
+// AppendRef implements refs.Codec.
+func (qs *QuadStore) AppendRef(buf []byte, r graph.Ref) ([]byte, error) {
+	switch r := r.(type) {
+	case bnode:
+		buf = append(buf, 'n')
+		return binary.AppendVarint(buf, int64(r)), nil
+	case qprim:
+		buf = append(buf, 'q')
+		return binary.AppendVarint(buf, r.p.ID), nil
+	}
+	return nil, fmt.Errorf("memstore: unsupported reference: %T", r)
+}
+
+// DecodeRef implements refs.Codec.
+func (qs *QuadStore) DecodeRef(p []byte) (graph.Ref, error) {
+	if len(p) == 0 {
+		return nil, fmt.Errorf("memstore: invalid reference")
+	}
+	id, n := binary.Varint(p[1:])
+	if n <= 0 || n != len(p)-1 {
+		return nil, fmt.Errorf("memstore: invalid reference")
+	}
+	switch p[0] {
+	case 'n':
+		return bnode(id), nil
+	case 'q':
+		qs.mu.RLock()
+		prim := qs.prim[id]
+		qs.mu.RUnlock()
+		if prim == nil {
+			return nil, fmt.Errorf("memstore: quad %d does not exist", id)
+		}
+		return qprim{p: prim}, nil
+	}
+	return nil, fmt.Errorf("memstore: invalid reference")
+}
+
 func (qs *QuadStore) QuadsAllIterator() iterator.Shape {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	return qs.newAllIterator(false, qs.last)
 }
 
 func (qs *QuadStore) QuadDirection(val graph.Ref, d quad.Direction) (graph.Ref, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	q, ok := qs.quad(val)
 	if !ok {
 		return nil, nil
@@ -558,6 +883,8 @@ func (qs *QuadStore) QuadDirection(val graph.Ref, d quad.Direction) (graph.Ref,
 }
 
 func (qs *QuadStore) NodesAllIterator() iterator.Shape {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
 	return qs.newAllIterator(true, qs.last)
 }
 
@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -87,6 +88,7 @@ func TestMemstore(t *testing.T) {
 		return New(), nil
 	}, &graphtest.Config{
 		AlwaysRunIntegration: true,
+		NativeBatches:        true,
 	})
 }
 
@@ -98,6 +100,40 @@ func BenchmarkMemstore(b *testing.B) {
 	})
 }
 
+// BenchmarkWriterHighDegree writes many quads of the same subject in batches. The cost of a batch must not
+// depend on the number of quads the subject already has, regardless of whether the changes are watched.
+func BenchmarkWriterHighDegree(b *testing.B) {
+	const (
+		total = 20000
+		batch = 1000
+	)
+	for _, watch := range []bool{false, true} {
+		name := "unwatched"
+		if watch {
+			name = "watched"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				w, err := writer.NewSingle(New(), graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+				require.NoError(b, err)
+				if watch {
+					w.(graph.ChangeSource).Changes()
+				}
+				set := make([]quad.Quad, 0, batch)
+				for j := 0; j < total; j++ {
+					set = append(set, quad.Make("s", "p", j, nil))
+					if len(set) == batch {
+						err = w.AddQuadSet(set)
+						require.NoError(b, err)
+						set = set[:0]
+					}
+				}
+				w.Close()
+			}
+		})
+	}
+}
+
 type pair struct {
 	query string
 	value int64
@@ -148,41 +184,32 @@ func TestIteratorsAndNextResultOrderA(t *testing.T) {
 	)
 
 	hasa := graph.NewHasA(qs, innerAnd, quad.Subject)
-	outerAnd := iterator.NewAnd(fixed, hasa).Iterate()
-
-	if !outerAnd.Next(ctx) {
-		t.Error("Expected one matching subtree")
-	}
-	val := outerAnd.Result()
-	vn, err := qs.NameOf(val)
-	require.NoError(t, err)
-	if vn != quad.Raw("C") {
-		t.Errorf("Matching subtree should be %s, got %s", "barak", vn)
-	}
+	// each path of the only matching subtree is returned as a separate result
+	outerAnd := iterator.Bindings(iterator.NewAnd(fixed, hasa).Iterate())
+	defer outerAnd.Close()
 
 	var (
 		got    []string
 		expect = []string{"B", "D"}
 	)
-	for {
+	for outerAnd.Next(ctx) {
+		vn, err := qs.NameOf(outerAnd.Result())
+		require.NoError(t, err)
+		if vn != quad.Raw("C") {
+			t.Errorf("Matching subtree should be %s, got %s", "C", vn)
+		}
 		m := make(map[string]graph.Ref, 1)
 		outerAnd.TagResults(m)
 		mv, err := qs.NameOf(m[allTag])
 		require.NoError(t, err)
 		got = append(got, quad.ToString(mv))
-		if !outerAnd.NextPath(ctx) {
-			break
-		}
 	}
+	require.NoError(t, outerAnd.Err())
 	sort.Strings(got)
 
 	if !reflect.DeepEqual(got, expect) {
 		t.Errorf("Unexpected result, got:%q expect:%q", got, expect)
 	}
-
-	if outerAnd.Next(ctx) {
-		t.Error("More than one possible top level output?")
-	}
 }
 
 func TestLinksToOptimization(t *testing.T) {
@@ -239,6 +266,39 @@ func TestRemoveQuad(t *testing.T) {
 	}
 }
 
+func TestObjects(t *testing.T) {
+	qs := New(
+		quad.MakeIRI("a", "name", "x", ""),
+		quad.MakeIRI("a", "name", "y", "g"),
+		quad.MakeIRI("a", "age", "z", ""),
+		quad.MakeIRI("b", "name", "w", ""),
+	)
+	// the index is only built on first use
+	require.Nil(t, qs.index.sp)
+	objects := func(s, p string, l quad.Value) []quad.Value {
+		out, err := qs.objects(quad.IRI(s), quad.IRI(p), l)
+		require.NoError(t, err)
+		return out
+	}
+	require.Equal(t, []quad.Value{quad.IRI("x")}, objects("a", "name", nil))
+	require.Equal(t, []quad.Value{quad.IRI("y")}, objects("a", "name", quad.IRI("g")))
+	require.Equal(t, []quad.Value{quad.IRI("z")}, objects("a", "age", nil))
+	require.Empty(t, objects("b", "age", nil))
+
+	// quads added after the index is built are indexed as well
+	qs.AddQuad(quad.MakeIRI("b", "age", "v", ""))
+	require.Equal(t, []quad.Value{quad.IRI("v")}, objects("b", "age", nil))
+
+	err := qs.ApplyDeltas([]graph.Delta{
+		{Quad: quad.MakeIRI("a", "name", "x", ""), Action: graph.Delete},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.Empty(t, objects("a", "name", nil))
+	tree, ok := qs.index.GetSubjectPredicate(qs.vals[quad.IRI("a").String()], qs.vals[quad.IRI("name").String()])
+	require.True(t, ok)
+	require.Equal(t, 1, tree.Len())
+}
+
 func TestTransaction(t *testing.T) {
 	qs, w, _ := makeTestStore(simpleGraph)
 	st, err := qs.Stats(context.Background(), true)
@@ -264,3 +324,115 @@ func TestTransaction(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, st, st2, "Appended a new quad in a failed transaction")
 }
+
+func TestConcurrentReads(t *testing.T) {
+	ctx := context.TODO()
+	qs := New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			q := quad.Make("a", "b", i, nil)
+			qs.AddQuad(q)
+			if i%2 == 0 {
+				err := qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Delete}}, graph.IgnoreOpts{})
+				require.NoError(t, err)
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			all, err := iterator.Iterate(ctx, qs.QuadsAllIterator()).All()
+			require.NoError(t, err)
+			require.Len(t, all, 250)
+			return
+		default:
+		}
+		_, err := iterator.Iterate(ctx, qs.QuadsAllIterator()).All()
+		require.NoError(t, err)
+		if s, err := qs.ValueOf(quad.String("a")); err == nil && s != nil {
+			_, err = iterator.Iterate(ctx, qs.QuadIterator(quad.Subject, s)).All()
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestMemstoreVersioned(t *testing.T) {
+	// the same queries are already tested by TestMemstore, thus integration tests are not forced here
+	graphtest.TestAll(t, func(t testing.TB) (graph.QuadStore, graph.Options) {
+		return NewVersioned(), nil
+	}, &graphtest.Config{
+		Versioned:     true,
+		NativeBatches: true,
+	})
+}
+
+func TestAsOf(t *testing.T) {
+	ctx := context.TODO()
+	qs := NewVersioned(simpleGraph...)
+	w, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h1 := qs.Horizon()
+
+	err = w.RemoveQuad(quad.MakeRaw("E", "follows", "F", ""))
+	require.NoError(t, err)
+	h2 := qs.Horizon()
+
+	t2 := time.Now()
+	err = w.AddQuad(quad.MakeRaw("E", "follows", "G", ""))
+	require.NoError(t, err)
+
+	follows := func(qs graph.QuadStore) []quad.Value {
+		var out []quad.Value
+		p := shape.BuildIterator(ctx, qs, shape.NodesFrom{
+			Dir: quad.Object,
+			Quads: shape.Quads{
+				{Dir: quad.Subject, Values: shape.Lookup{quad.Raw("E")}},
+			},
+		})
+		err := iterator.Iterate(ctx, p).EachValue(qs, func(v quad.Value) error {
+			out = append(out, v)
+			return nil
+		})
+		require.NoError(t, err)
+		return out
+	}
+	require.Equal(t, []quad.Value{quad.Raw("G")}, follows(qs))
+
+	for _, c := range []struct {
+		name   string
+		v      graph.Version
+		expect []quad.Value
+		size   int64
+		nodes  int64
+	}{
+		{"horizon 1", graph.Version{Horizon: h1}, []quad.Value{quad.Raw("F")}, 11, 11},
+		{"horizon 2", graph.Version{Horizon: h2}, nil, 10, 10},
+		{"time", graph.Version{Time: t2}, nil, 10, 10},
+		{"now", graph.Version{Time: time.Now()}, []quad.Value{quad.Raw("G")}, 11, 11},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			snap, err := graph.AsOf(ctx, qs, c.v)
+			require.NoError(t, err)
+			require.Equal(t, c.expect, follows(snap))
+			st, err := snap.Stats(ctx, true)
+			require.NoError(t, err)
+			require.Equal(t, c.size, st.Quads.Value)
+			require.Equal(t, c.nodes, st.Nodes.Value)
+
+			// nodes without valid quads are not visible in the snapshot
+			e, err := snap.ValueOf(quad.Raw("E"))
+			require.NoError(t, err)
+			require.Equal(t, c.nodes == 11, e != nil)
+
+			err = snap.ApplyDeltas([]graph.Delta{
+				{Quad: quad.MakeRaw("A", "follows", "E", ""), Action: graph.Add},
+			}, graph.IgnoreOpts{})
+			require.Equal(t, graph.ErrReadOnly, err)
+		})
+	}
+
+	_, err = graph.AsOf(ctx, New(), graph.Version{Horizon: 1})
+	require.Equal(t, graph.ErrNoHistory, err)
+}
@@ -4,4 +4,6 @@ import (
 	_ "github.com/hidal-go/hidalgo/legacy/nosql/all"
 
 	_ "github.com/cayleygraph/cayley/graph/nosql"
+	_ "github.com/cayleygraph/cayley/graph/nosql/docbolt"
+	_ "github.com/cayleygraph/cayley/graph/nosql/mongo"
 )
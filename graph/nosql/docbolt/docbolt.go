@@ -0,0 +1,355 @@
+// Package docbolt implements an embedded NoSQL document store on top of Bolt.
+//
+// It allows to run the NoSQL quad store layout without any external database server.
+package docbolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hidal-go/hidalgo/legacy/nosql"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	gnosql "github.com/cayleygraph/cayley/graph/nosql"
+)
+
+const Type = "docbolt"
+
+func init() {
+	gnosql.Register(Type, gnosql.Registration{
+		NewFunc:      Open,
+		InitFunc:     Create,
+		IsPersistent: true,
+	})
+}
+
+var (
+	bucketCols = []byte("collections")
+	bucketDocs = []byte("docs")
+	bucketIdx  = []byte("indexes")
+	keyMeta    = []byte("meta")
+)
+
+var (
+	_ nosql.Database   = (*DB)(nil)
+	_ gnosql.Pipeliner = (*DB)(nil)
+)
+
+func getBoltFile(path string) string {
+	return filepath.Join(path, "documents.bolt")
+}
+
+// Create creates a new database in a given directory.
+func Create(path string, opt graph.Options) (nosql.Database, error) {
+	if path == "" {
+		return nil, fmt.Errorf("docbolt: empty path")
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	return Open(path, opt)
+}
+
+// Open opens an existing database in a given directory.
+func Open(path string, opt graph.Options) (nosql.Database, error) {
+	db, err := bolt.Open(getBoltFile(path), 0600, nil)
+	if err != nil {
+		clog.Errorf("Error: couldn't open Bolt database: %v", err)
+		return nil, err
+	}
+	db.NoSync, err = opt.BoolKey("nosync", false)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	db.NoGrowSync = db.NoSync
+	if db.NoSync {
+		clog.Infof("Running in nosync mode")
+	}
+	return New(db), nil
+}
+
+// New wraps an opened Bolt database.
+func New(db *bolt.DB) *DB {
+	return &DB{db: db, cols: make(map[string]*collection)}
+}
+
+// DB is a document database stored in a single Bolt file.
+//
+// Each collection is stored in a separate bucket that contains documents indexed by a primary key
+// and a bucket for each secondary index. Secondary indexes map field values to primary keys.
+type DB struct {
+	db *bolt.DB
+
+	mu   sync.RWMutex
+	cols map[string]*collection
+}
+
+// collection stores index definitions of a collection.
+type collection struct {
+	Primary   nosql.Index   `json:"primary"`
+	Secondary []nosql.Index `json:"secondary"`
+}
+
+// indexName returns a bucket name for a secondary index.
+func indexName(ind nosql.Index) []byte {
+	return []byte(strings.Join(ind.Fields, ","))
+}
+
+// setKey sets primary key fields in the document.
+func (c *collection) setKey(d nosql.Document, key nosql.Key) {
+	if c == nil || len(c.Primary.Fields) != len(key) {
+		return
+	}
+	for i, f := range c.Primary.Fields {
+		d[f] = nosql.String(key[i])
+	}
+}
+
+// col returns index definitions for a collection. It returns nil if collection does not exist.
+func (db *DB) col(tx *bolt.Tx, name string) (*collection, error) {
+	db.mu.RLock()
+	c, ok := db.cols[name]
+	db.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+	b := colBucket(tx, name)
+	if b == nil {
+		return nil, nil
+	}
+	c = new(collection)
+	if data := b.Get(keyMeta); data != nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, err
+		}
+	}
+	db.mu.Lock()
+	db.cols[name] = c
+	db.mu.Unlock()
+	return c, nil
+}
+
+func colBucket(tx *bolt.Tx, name string) *bolt.Bucket {
+	b := tx.Bucket(bucketCols)
+	if b == nil {
+		return nil
+	}
+	return b.Bucket([]byte(name))
+}
+
+// createCol creates all buckets for a collection, if they do not exist.
+func createCol(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists(bucketCols)
+	if err != nil {
+		return nil, err
+	}
+	b, err = b.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = b.CreateBucketIfNotExists(bucketDocs); err != nil {
+		return nil, err
+	}
+	if _, err = b.CreateBucketIfNotExists(bucketIdx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (db *DB) EnsureIndex(ctx context.Context, col string, primary nosql.Index, secondary []nosql.Index) error {
+	c := &collection{Primary: primary, Secondary: secondary}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		b, err := createCol(tx, col)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(b.Get(keyMeta), data) {
+			// indexes are up to date
+			return nil
+		} else if err = b.Put(keyMeta, data); err != nil {
+			return err
+		}
+		// rebuild secondary indexes from scratch
+		idx := b.Bucket(bucketIdx)
+		var names [][]byte
+		err = idx.ForEach(func(k, _ []byte) error {
+			names = append(names, append([]byte{}, k...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err = idx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		for _, ind := range secondary {
+			if _, err = idx.CreateBucket(indexName(ind)); err != nil {
+				return err
+			}
+		}
+		return b.Bucket(bucketDocs).ForEach(func(k, v []byte) error {
+			d, err := decodeDoc(v)
+			if err != nil {
+				return err
+			}
+			return c.index(b, k, d, true)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	db.mu.Lock()
+	db.cols[col] = c
+	db.mu.Unlock()
+	return nil
+}
+
+// index adds or removes entries for a document in all secondary indexes of a collection.
+func (c *collection) index(b *bolt.Bucket, key []byte, d nosql.Document, add bool) error {
+	if c == nil || d == nil {
+		return nil
+	}
+	idx := b.Bucket(bucketIdx)
+	for _, ind := range c.Secondary {
+		vals := make([]string, 0, len(ind.Fields))
+		for _, f := range ind.Fields {
+			s, ok := d[f].(nosql.String)
+			if !ok {
+				break
+			}
+			vals = append(vals, string(s))
+		}
+		if len(vals) != len(ind.Fields) {
+			// only documents with all fields set are indexed
+			continue
+		}
+		ib := idx.Bucket(indexName(ind))
+		if ib == nil {
+			continue
+		}
+		k := append(encodeKey(vals), key...)
+		var err error
+		if add {
+			err = ib.Put(k, key)
+		} else {
+			err = ib.Delete(k)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put writes a document and updates secondary indexes. Old version of the document must be passed, if it exists.
+func (c *collection) put(b *bolt.Bucket, key []byte, old, d nosql.Document) error {
+	data, err := encodeDoc(d)
+	if err != nil {
+		return err
+	}
+	if err = c.index(b, key, old, false); err != nil {
+		return err
+	}
+	if err = b.Bucket(bucketDocs).Put(key, data); err != nil {
+		return err
+	}
+	return c.index(b, key, d, true)
+}
+
+// remove deletes a document and its secondary index entries.
+func (c *collection) remove(b *bolt.Bucket, key []byte, d nosql.Document) error {
+	if err := c.index(b, key, d, false); err != nil {
+		return err
+	}
+	return b.Bucket(bucketDocs).Delete(key)
+}
+
+func (db *DB) Insert(ctx context.Context, col string, key nosql.Key, d nosql.Document) (nosql.Key, error) {
+	if key == nil {
+		key = nosql.GenKey()
+	}
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		b, err := createCol(tx, col)
+		if err != nil {
+			return err
+		}
+		c, err := db.col(tx, col)
+		if err != nil {
+			return err
+		}
+		k := encodeKey(key)
+		if b.Bucket(bucketDocs).Get(k) != nil {
+			return fmt.Errorf("document %v already exists", key)
+		}
+		d = copyDoc(d)
+		c.setKey(d, key)
+		return c.put(b, k, nil, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (db *DB) FindByKey(ctx context.Context, col string, key nosql.Key) (nosql.Document, error) {
+	var d nosql.Document
+	err := db.db.View(func(tx *bolt.Tx) error {
+		b := colBucket(tx, col)
+		if b == nil {
+			return nosql.ErrNotFound
+		}
+		data := b.Bucket(bucketDocs).Get(encodeKey(key))
+		if data == nil {
+			return nosql.ErrNotFound
+		}
+		var err error
+		d, err = decodeDoc(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (db *DB) Query(col string) nosql.Query {
+	return &Query{db: db, col: col}
+}
+
+func (db *DB) Update(col string, key nosql.Key) nosql.Update {
+	return &Update{db: db, col: col, key: key}
+}
+
+func (db *DB) Delete(col string) nosql.Delete {
+	return &Delete{db: db, col: col}
+}
+
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+func copyDoc(d nosql.Document) nosql.Document {
+	out := make(nosql.Document, len(d))
+	for k, v := range d {
+		if sub, ok := v.(nosql.Document); ok {
+			v = copyDoc(sub)
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package docbolt
+
+import (
+	"testing"
+
+	"github.com/hidal-go/hidalgo/legacy/nosql"
+	hnosqltest "github.com/hidal-go/hidalgo/legacy/nosql/nosqltest"
+
+	"github.com/cayleygraph/cayley/graph/nosql/nosqltest"
+)
+
+var gen = hnosqltest.Database{
+	Run: func(t testing.TB) nosql.Database {
+		db, err := Create(t.TempDir(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			db.Close()
+		})
+		return db
+	},
+}
+
+func TestDocBolt(t *testing.T) {
+	hnosqltest.TestNoSQL(t, gen)
+}
+
+func TestQuadStore(t *testing.T) {
+	nosqltest.TestAll(t, gen)
+}
+
+func BenchmarkQuadStore(b *testing.B) {
+	nosqltest.BenchmarkAll(b, gen)
+}
@@ -0,0 +1,174 @@
+package docbolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hidal-go/hidalgo/legacy/nosql"
+)
+
+// encodeKey encodes a set of strings in a way that preserves the prefix relation between keys:
+// each part is prefixed by its length.
+func encodeKey(key []string) []byte {
+	n := 0
+	for _, s := range key {
+		n += binary.MaxVarintLen64 + len(s)
+	}
+	buf := make([]byte, 0, n)
+	for _, s := range key {
+		buf = binary.AppendUvarint(buf, uint64(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+func decodeKey(p []byte) (nosql.Key, error) {
+	var key nosql.Key
+	for len(p) != 0 {
+		n, sz := binary.Uvarint(p)
+		if sz <= 0 || uint64(len(p)-sz) < n {
+			return nil, fmt.Errorf("invalid key encoding")
+		}
+		p = p[sz:]
+		key = append(key, string(p[:n]))
+		p = p[n:]
+	}
+	return key, nil
+}
+
+// value types of encoded document fields
+const (
+	typDoc     = "d"
+	typString  = "s"
+	typInt     = "i"
+	typFloat   = "f"
+	typBool    = "b"
+	typTime    = "t"
+	typBytes   = "x"
+	typStrings = "a"
+)
+
+// encodeDoc encodes a document as JSON. Each value is encoded as a pair of type and value,
+// thus all types are preserved.
+func encodeDoc(d nosql.Document) ([]byte, error) {
+	v, err := toJSON(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func toJSON(v nosql.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case nosql.Document:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			if s == nil {
+				continue
+			}
+			js, err := toJSON(s)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = js
+		}
+		return m, nil
+	case nosql.String:
+		return [2]interface{}{typString, string(v)}, nil
+	case nosql.Int:
+		return [2]interface{}{typInt, strconv.FormatInt(int64(v), 10)}, nil
+	case nosql.Float:
+		return [2]interface{}{typFloat, strconv.FormatFloat(float64(v), 'g', -1, 64)}, nil
+	case nosql.Bool:
+		return [2]interface{}{typBool, bool(v)}, nil
+	case nosql.Time:
+		return [2]interface{}{typTime, time.Time(v).Format(time.RFC3339Nano)}, nil
+	case nosql.Bytes:
+		return [2]interface{}{typBytes, []byte(v)}, nil
+	case nosql.Strings:
+		return [2]interface{}{typStrings, []string(v)}, nil
+	}
+	return nil, fmt.Errorf("unsupported value type: %T", v)
+}
+
+func decodeDoc(data []byte) (nosql.Document, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return fromJSONDoc(m)
+}
+
+func fromJSONDoc(m map[string]json.RawMessage) (nosql.Document, error) {
+	d := make(nosql.Document, len(m))
+	for k, data := range m {
+		v, err := fromJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		d[k] = v
+	}
+	return d, nil
+}
+
+func fromJSON(data json.RawMessage) (nosql.Value, error) {
+	var (
+		typ string
+		val json.RawMessage
+	)
+	// sub-documents are encoded as objects, other values as a two-element array
+	if len(data) != 0 && data[0] == '{' {
+		typ, val = typDoc, data
+	} else if err := json.Unmarshal(data, &[]interface{}{&typ, &val}); err != nil {
+		return nil, err
+	}
+	switch typ {
+	case typDoc:
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(val, &m); err != nil {
+			return nil, err
+		}
+		return fromJSONDoc(m)
+	case typString:
+		var s string
+		err := json.Unmarshal(val, &s)
+		return nosql.String(s), err
+	case typInt:
+		var s string
+		if err := json.Unmarshal(val, &s); err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		return nosql.Int(v), err
+	case typFloat:
+		var s string
+		if err := json.Unmarshal(val, &s); err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		return nosql.Float(v), err
+	case typBool:
+		var v bool
+		err := json.Unmarshal(val, &v)
+		return nosql.Bool(v), err
+	case typTime:
+		var s string
+		if err := json.Unmarshal(val, &s); err != nil {
+			return nil, err
+		}
+		v, err := time.Parse(time.RFC3339Nano, s)
+		return nosql.Time(v), err
+	case typBytes:
+		var v []byte
+		err := json.Unmarshal(val, &v)
+		return nosql.Bytes(v), err
+	case typStrings:
+		var v []string
+		err := json.Unmarshal(val, &v)
+		return nosql.Strings(v), err
+	}
+	return nil, fmt.Errorf("unsupported value type: %q", typ)
+}
@@ -0,0 +1,165 @@
+package docbolt
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hidal-go/hidalgo/legacy/nosql"
+	bolt "go.etcd.io/bbolt"
+
+	gnosql "github.com/cayleygraph/cayley/graph/nosql"
+)
+
+// names of quad collection and fields, as they are stored by gnosql package
+const (
+	colQuads       = "quads"
+	fldQuadAdded   = "added"
+	fldQuadDeleted = "deleted"
+)
+
+// Traverse runs a traversal pipeline in a single read transaction.
+//
+// Each step uses a secondary index on the From direction of quads to find the next set of nodes.
+func (db *DB) Traverse(ctx context.Context, start []string, steps []gnosql.Step) (gnosql.Nodes, error) {
+	// nodes and the number of paths that lead to them; nil means all nodes
+	var cur map[string]int
+	if start != nil {
+		cur = make(map[string]int, len(start))
+		for _, h := range start {
+			cur[h]++
+		}
+	}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		for _, st := range steps {
+			if cur != nil && len(cur) == 0 {
+				return nil
+			} else if err := ctx.Err(); err != nil {
+				return err
+			}
+			var err error
+			if st.Recursive {
+				cur, err = db.recurse(tx, cur, st)
+			} else {
+				cur, err = db.follow(tx, cur, st)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := sortedKeys(cur)
+	var out []string
+	for _, h := range keys {
+		for i := 0; i < cur[h]; i++ {
+			out = append(out, h)
+		}
+	}
+	return gnosql.NodeList(out), nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// stepFilters returns filters that select valid quads matching a step, optionally starting from a given node.
+func stepFilters(st gnosql.Step, from *string) []nosql.FieldFilter {
+	filters := make([]nosql.FieldFilter, 0, len(st.Links)+1)
+	if from != nil {
+		filters = append(filters, nosql.FieldFilter{
+			Path: []string{st.From.String()}, Filter: nosql.Equal, Value: nosql.String(*from),
+		})
+	}
+	for _, l := range st.Links {
+		filters = append(filters, nosql.FieldFilter{
+			Path: []string{l.Dir.String()}, Filter: nosql.Equal, Value: nosql.String(l.Val),
+		})
+	}
+	return filters
+}
+
+func isValid(d nosql.Document) bool {
+	added, _ := d[fldQuadAdded].(nosql.Int)
+	deleted, _ := d[fldQuadDeleted].(nosql.Int)
+	return added > deleted
+}
+
+func field(d nosql.Document, name string) string {
+	s, _ := d[name].(nosql.String)
+	return string(s)
+}
+
+// edges calls fn for each valid quad matching the step.
+func (db *DB) edges(tx *bolt.Tx, st gnosql.Step, from *string, fn func(from, to string)) error {
+	f, t := st.From.String(), st.To.String()
+	_, err := db.scan(tx, colQuads, stepFilters(st, from), nil, func(_ []byte, d nosql.Document) (bool, error) {
+		if isValid(d) {
+			fn(field(d, f), field(d, t))
+		}
+		return true, nil
+	})
+	return err
+}
+
+// follow runs a single non-recursive step.
+func (db *DB) follow(tx *bolt.Tx, cur map[string]int, st gnosql.Step) (map[string]int, error) {
+	out := make(map[string]int)
+	if cur == nil {
+		err := db.edges(tx, st, nil, func(_, to string) {
+			out[to]++
+		})
+		return out, err
+	}
+	for _, h := range sortedKeys(cur) {
+		n := cur[h]
+		err := db.edges(tx, st, &h, func(_, to string) {
+			out[to] += n
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// recurse runs a recursive step, looking up neighbours of each new node in the index.
+func (db *DB) recurse(tx *bolt.Tx, cur map[string]int, st gnosql.Step) (map[string]int, error) {
+	var start []string
+	if cur != nil {
+		start = sortedKeys(cur)
+	} else {
+		// all nodes that have at least one matching quad
+		seen := make(map[string]int)
+		err := db.edges(tx, st, nil, func(from, _ string) {
+			seen[from] = 1
+		})
+		if err != nil {
+			return nil, err
+		}
+		start = sortedKeys(seen)
+	}
+	res, err := gnosql.Recurse(start, func(n string) ([]string, error) {
+		var next []string
+		err := db.edges(tx, st, &n, func(_, to string) {
+			next = append(next, to)
+		})
+		sort.Strings(next)
+		return next, err
+	}, st.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int, len(res))
+	for _, h := range res {
+		out[h] = 1
+	}
+	return out, nil
+}
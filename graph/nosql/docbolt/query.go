@@ -0,0 +1,392 @@
+package docbolt
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/hidal-go/hidalgo/legacy/nosql"
+	bolt "go.etcd.io/bbolt"
+)
+
+// batchSize is the number of documents read in a single transaction by iterators.
+//
+// Iterators do not keep a transaction open between calls, since Bolt may deadlock if the same goroutine
+// writes to the database while a read transaction is still open.
+const batchSize = 256
+
+func matches(d nosql.Document, filters []nosql.FieldFilter) bool {
+	for _, f := range filters {
+		if !f.Matches(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// plan describes how documents matching a set of filters can be found.
+type plan struct {
+	// key is set if all primary key fields are fixed
+	key []byte
+	// index is set if a secondary index can be used, prefix is a prefix of index entries to scan
+	index  []byte
+	prefix []byte
+}
+
+func (c *collection) plan(filters []nosql.FieldFilter) plan {
+	if c == nil {
+		return plan{}
+	}
+	eq := make(map[string]string)
+	for _, f := range filters {
+		if f.Filter != nosql.Equal || len(f.Path) != 1 {
+			continue
+		}
+		if s, ok := f.Value.(nosql.String); ok {
+			eq[f.Path[0]] = string(s)
+		}
+	}
+	if len(eq) == 0 {
+		return plan{}
+	}
+	if vals, ok := fieldValues(c.Primary, eq); ok {
+		return plan{key: encodeKey(vals)}
+	}
+	// prefer indexes on fields that are filtered first
+	for _, f := range filters {
+		for _, ind := range c.Secondary {
+			if len(f.Path) != 1 || len(ind.Fields) == 0 || ind.Fields[0] != f.Path[0] {
+				continue
+			}
+			if vals, ok := fieldValues(ind, eq); ok {
+				return plan{index: indexName(ind), prefix: encodeKey(vals)}
+			}
+		}
+	}
+	return plan{}
+}
+
+func fieldValues(ind nosql.Index, eq map[string]string) ([]string, bool) {
+	if len(ind.Fields) == 0 {
+		return nil, false
+	}
+	vals := make([]string, 0, len(ind.Fields))
+	for _, f := range ind.Fields {
+		v, ok := eq[f]
+		if !ok {
+			return nil, false
+		}
+		vals = append(vals, v)
+	}
+	return vals, true
+}
+
+// scan calls fn for each document matching the filters, starting after a given position.
+// It returns the position of the last visited document, or nil if there are no more documents.
+func (db *DB) scan(tx *bolt.Tx, col string, filters []nosql.FieldFilter, after []byte, fn func(k []byte, d nosql.Document) (bool, error)) ([]byte, error) {
+	b := colBucket(tx, col)
+	if b == nil {
+		return nil, nil
+	}
+	c, err := db.col(tx, col)
+	if err != nil {
+		return nil, err
+	}
+	docs := b.Bucket(bucketDocs)
+	visit := func(k, data []byte) (bool, error) {
+		d, err := decodeDoc(data)
+		if err != nil {
+			return false, err
+		}
+		if !matches(d, filters) {
+			return true, nil
+		}
+		return fn(k, d)
+	}
+	p := c.plan(filters)
+	if p.key != nil {
+		if after != nil {
+			return nil, nil
+		}
+		if data := docs.Get(p.key); data != nil {
+			if _, err = visit(p.key, data); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+	cur, prefix := docs.Cursor(), []byte(nil)
+	if p.index != nil {
+		ib := b.Bucket(bucketIdx).Bucket(p.index)
+		if ib == nil {
+			return nil, nil
+		}
+		cur, prefix = ib.Cursor(), p.prefix
+	}
+	var k, v []byte
+	if after == nil {
+		k, v = cur.Seek(prefix)
+	} else if k, v = cur.Seek(after); bytes.Equal(k, after) {
+		k, v = cur.Next()
+	}
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, v = cur.Next() {
+		dk, data := k, v
+		if p.index != nil {
+			dk, data = v, docs.Get(v)
+			if data == nil {
+				continue
+			}
+		}
+		more, err := visit(dk, data)
+		if err != nil {
+			return nil, err
+		} else if !more {
+			return append([]byte{}, k...), nil
+		}
+	}
+	return nil, nil
+}
+
+type Query struct {
+	db      *DB
+	col     string
+	filters []nosql.FieldFilter
+	limit   int
+}
+
+func (q *Query) WithFields(filters ...nosql.FieldFilter) nosql.Query {
+	q.filters = append(q.filters, filters...)
+	return q
+}
+
+func (q *Query) Limit(n int) nosql.Query {
+	q.limit = n
+	return q
+}
+
+func (q *Query) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := q.db.db.View(func(tx *bolt.Tx) error {
+		_, err := q.db.scan(tx, q.col, q.filters, nil, func(_ []byte, _ nosql.Document) (bool, error) {
+			n++
+			return q.limit <= 0 || n < int64(q.limit), nil
+		})
+		return err
+	})
+	return n, err
+}
+
+func (q *Query) One(ctx context.Context) (nosql.Document, error) {
+	var out nosql.Document
+	err := q.db.db.View(func(tx *bolt.Tx) error {
+		_, err := q.db.scan(tx, q.col, q.filters, nil, func(_ []byte, d nosql.Document) (bool, error) {
+			out = d
+			return false, nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	} else if out == nil {
+		return nil, nosql.ErrNotFound
+	}
+	return out, nil
+}
+
+func (q *Query) Iterate(ctx context.Context) nosql.DocIterator {
+	return &Iterator{q: *q, i: -1}
+}
+
+type document struct {
+	key []byte
+	doc nosql.Document
+}
+
+// Iterator reads query results in batches.
+type Iterator struct {
+	q    Query
+	last []byte // position of the last document in the batch
+	done bool
+	n    int // number of documents returned
+	buf  []document
+	i    int
+	err  error
+}
+
+func (it *Iterator) fetch(ctx context.Context) error {
+	it.buf, it.i = it.buf[:0], -1
+	return it.q.db.db.View(func(tx *bolt.Tx) error {
+		last, err := it.q.db.scan(tx, it.q.col, it.q.filters, it.last, func(k []byte, d nosql.Document) (bool, error) {
+			it.buf = append(it.buf, document{key: append([]byte{}, k...), doc: d})
+			return len(it.buf) < batchSize, nil
+		})
+		if err != nil {
+			return err
+		}
+		it.last = last
+		it.done = last == nil
+		return nil
+	})
+}
+
+func (it *Iterator) Next(ctx context.Context) bool {
+	if it.err != nil || (it.q.limit > 0 && it.n >= it.q.limit) {
+		return false
+	}
+	if it.i+1 >= len(it.buf) {
+		if it.done {
+			return false
+		} else if err := ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		if it.err = it.fetch(ctx); it.err != nil || len(it.buf) == 0 {
+			return false
+		}
+	}
+	it.i++
+	it.n++
+	return true
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) Close() error {
+	it.buf, it.done = nil, true
+	return nil
+}
+
+func (it *Iterator) Key() nosql.Key {
+	key, err := decodeKey(it.buf[it.i].key)
+	if err != nil {
+		it.err = err
+	}
+	return key
+}
+
+func (it *Iterator) Doc() nosql.Document {
+	return it.buf[it.i].doc
+}
+
+type Update struct {
+	db     *DB
+	col    string
+	key    nosql.Key
+	inc    map[string]int
+	upsert nosql.Document
+}
+
+func (u *Update) Inc(field string, dn int) nosql.Update {
+	if u.inc == nil {
+		u.inc = make(map[string]int)
+	}
+	u.inc[field] += dn
+	return u
+}
+
+func (u *Update) Upsert(d nosql.Document) nosql.Update {
+	u.upsert = d
+	return u
+}
+
+func (u *Update) Do(ctx context.Context) error {
+	return u.db.db.Update(func(tx *bolt.Tx) error {
+		b, err := createCol(tx, u.col)
+		if err != nil {
+			return err
+		}
+		c, err := u.db.col(tx, u.col)
+		if err != nil {
+			return err
+		}
+		k := encodeKey(u.key)
+		var old, d nosql.Document
+		if data := b.Bucket(bucketDocs).Get(k); data != nil {
+			if old, err = decodeDoc(data); err != nil {
+				return err
+			}
+			d = copyDoc(old)
+		} else if u.upsert != nil {
+			d = copyDoc(u.upsert)
+			c.setKey(d, u.key)
+		} else {
+			return nil
+		}
+		for f, dn := range u.inc {
+			switch v := d[f].(type) {
+			case nosql.Float:
+				d[f] = v + nosql.Float(dn)
+			default:
+				n, _ := v.(nosql.Int)
+				d[f] = n + nosql.Int(dn)
+			}
+		}
+		return c.put(b, k, old, d)
+	})
+}
+
+type Delete struct {
+	db      *DB
+	col     string
+	filters []nosql.FieldFilter
+	keys    []nosql.Key
+}
+
+func (d *Delete) WithFields(filters ...nosql.FieldFilter) nosql.Delete {
+	d.filters = append(d.filters, filters...)
+	return d
+}
+
+func (d *Delete) Keys(keys ...nosql.Key) nosql.Delete {
+	if d.keys == nil {
+		d.keys = make([]nosql.Key, 0, len(keys))
+	}
+	d.keys = append(d.keys, keys...)
+	return d
+}
+
+func (d *Delete) Do(ctx context.Context) error {
+	return d.db.db.Update(func(tx *bolt.Tx) error {
+		b := colBucket(tx, d.col)
+		if b == nil {
+			return nil
+		}
+		c, err := d.db.col(tx, d.col)
+		if err != nil {
+			return err
+		}
+		var del []document
+		if d.keys == nil {
+			_, err = d.db.scan(tx, d.col, d.filters, nil, func(k []byte, doc nosql.Document) (bool, error) {
+				del = append(del, document{key: append([]byte{}, k...), doc: doc})
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			docs := b.Bucket(bucketDocs)
+			for _, key := range d.keys {
+				k := encodeKey(key)
+				data := docs.Get(k)
+				if data == nil {
+					continue
+				}
+				doc, err := decodeDoc(data)
+				if err != nil {
+					return err
+				}
+				if matches(doc, d.filters) {
+					del = append(del, document{key: k, doc: doc})
+				}
+			}
+		}
+		for _, doc := range del {
+			if err = c.remove(b, doc.key, doc.doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -205,10 +205,6 @@ func (it *iteratorNext) Result() graph.Ref {
 	return it.result
 }
 
-func (it *iteratorNext) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *iteratorNext) Sorted() bool { return true }
 
 func (it *iteratorNext) String() string {
@@ -265,10 +261,6 @@ func (it *iteratorContains) Result() graph.Ref {
 	return it.result
 }
 
-func (it *iteratorContains) NextPath(ctx context.Context) bool {
-	return false
-}
-
 func (it *iteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 	if len(it.links) != 0 {
 		qh := v.(QuadHash)
@@ -0,0 +1,353 @@
+package nosql_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hidal-go/hidalgo/legacy/nosql"
+
+	gnosql "github.com/cayleygraph/cayley/graph/nosql"
+)
+
+// memDB is an in-memory document store used as a stand-in for databases that support traversal pipelines.
+type memDB struct {
+	mu   sync.RWMutex
+	cols map[string]*memCollection
+}
+
+type memCollection struct {
+	primary []string
+	docs    map[string]memDoc
+}
+
+type memDoc struct {
+	key nosql.Key
+	doc nosql.Document
+}
+
+var (
+	_ nosql.Database   = (*memDB)(nil)
+	_ gnosql.Pipeliner = (*memDB)(nil)
+)
+
+func newMemDB() *memDB {
+	return &memDB{cols: make(map[string]*memCollection)}
+}
+
+func keyString(key nosql.Key) string {
+	return strings.Join(key, "\x00")
+}
+
+func copyDoc(d nosql.Document) nosql.Document {
+	out := make(nosql.Document, len(d))
+	for k, v := range d {
+		if sub, ok := v.(nosql.Document); ok {
+			v = copyDoc(sub)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func (db *memDB) col(name string) *memCollection {
+	c, ok := db.cols[name]
+	if !ok {
+		c = &memCollection{docs: make(map[string]memDoc)}
+		db.cols[name] = c
+	}
+	return c
+}
+
+func (c *memCollection) setKey(d nosql.Document, key nosql.Key) {
+	if len(c.primary) != len(key) {
+		return
+	}
+	for i, f := range c.primary {
+		d[f] = nosql.String(key[i])
+	}
+}
+
+// sorted returns all documents from the collection, ordered by keys.
+func (c *memCollection) sorted() []memDoc {
+	keys := make([]string, 0, len(c.docs))
+	for k := range c.docs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]memDoc, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, c.docs[k])
+	}
+	return out
+}
+
+func (db *memDB) Insert(ctx context.Context, col string, key nosql.Key, d nosql.Document) (nosql.Key, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if key == nil {
+		key = nosql.GenKey()
+	}
+	c := db.col(col)
+	k := keyString(key)
+	if _, ok := c.docs[k]; ok {
+		return nil, fmt.Errorf("document %v already exists", key)
+	}
+	d = copyDoc(d)
+	c.setKey(d, key)
+	c.docs[k] = memDoc{key: key, doc: d}
+	return key, nil
+}
+
+func (db *memDB) FindByKey(ctx context.Context, col string, key nosql.Key) (nosql.Document, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	d, ok := db.col(col).docs[keyString(key)]
+	if !ok {
+		return nil, nosql.ErrNotFound
+	}
+	return copyDoc(d.doc), nil
+}
+
+func (db *memDB) Query(col string) nosql.Query {
+	return &memQuery{db: db, col: col}
+}
+
+func (db *memDB) Update(col string, key nosql.Key) nosql.Update {
+	return &memUpdate{db: db, col: col, key: key}
+}
+
+func (db *memDB) Delete(col string) nosql.Delete {
+	return &memDelete{db: db, col: col}
+}
+
+func (db *memDB) EnsureIndex(ctx context.Context, col string, primary nosql.Index, secondary []nosql.Index) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.col(col).primary = primary.Fields
+	return nil
+}
+
+func (db *memDB) Close() error {
+	return nil
+}
+
+func matches(d nosql.Document, filters []nosql.FieldFilter) bool {
+	for _, f := range filters {
+		if !f.Matches(d) {
+			return false
+		}
+	}
+	return true
+}
+
+type memQuery struct {
+	db      *memDB
+	col     string
+	filters []nosql.FieldFilter
+	limit   int
+}
+
+func (q *memQuery) WithFields(filters ...nosql.FieldFilter) nosql.Query {
+	q.filters = append(q.filters, filters...)
+	return q
+}
+
+func (q *memQuery) Limit(n int) nosql.Query {
+	q.limit = n
+	return q
+}
+
+func (q *memQuery) find() []memDoc {
+	q.db.mu.RLock()
+	defer q.db.mu.RUnlock()
+	var out []memDoc
+	for _, d := range q.db.col(q.col).sorted() {
+		if !matches(d.doc, q.filters) {
+			continue
+		}
+		out = append(out, memDoc{key: d.key, doc: copyDoc(d.doc)})
+		if q.limit > 0 && len(out) >= q.limit {
+			break
+		}
+	}
+	return out
+}
+
+func (q *memQuery) Count(ctx context.Context) (int64, error) {
+	return int64(len(q.find())), nil
+}
+
+func (q *memQuery) One(ctx context.Context) (nosql.Document, error) {
+	q.limit = 1
+	docs := q.find()
+	if len(docs) == 0 {
+		return nil, nosql.ErrNotFound
+	}
+	return docs[0].doc, nil
+}
+
+func (q *memQuery) Iterate(ctx context.Context) nosql.DocIterator {
+	return &memIterator{docs: q.find(), i: -1}
+}
+
+type memIterator struct {
+	docs []memDoc
+	i    int
+}
+
+func (it *memIterator) Next(ctx context.Context) bool {
+	if it.i+1 >= len(it.docs) {
+		return false
+	}
+	it.i++
+	return true
+}
+
+func (it *memIterator) Err() error          { return nil }
+func (it *memIterator) Close() error        { return nil }
+func (it *memIterator) Key() nosql.Key      { return it.docs[it.i].key }
+func (it *memIterator) Doc() nosql.Document { return it.docs[it.i].doc }
+
+type memUpdate struct {
+	db     *memDB
+	col    string
+	key    nosql.Key
+	inc    map[string]int
+	upsert nosql.Document
+}
+
+func (u *memUpdate) Inc(field string, dn int) nosql.Update {
+	if u.inc == nil {
+		u.inc = make(map[string]int)
+	}
+	u.inc[field] += dn
+	return u
+}
+
+func (u *memUpdate) Upsert(d nosql.Document) nosql.Update {
+	u.upsert = d
+	return u
+}
+
+func (u *memUpdate) Do(ctx context.Context) error {
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	c := u.db.col(u.col)
+	k := keyString(u.key)
+	d, ok := c.docs[k]
+	if !ok {
+		if u.upsert == nil {
+			return nil
+		}
+		d = memDoc{key: u.key, doc: copyDoc(u.upsert)}
+		c.setKey(d.doc, u.key)
+	}
+	for f, dn := range u.inc {
+		v, _ := d.doc[f].(nosql.Int)
+		d.doc[f] = v + nosql.Int(dn)
+	}
+	c.docs[k] = d
+	return nil
+}
+
+type memDelete struct {
+	db      *memDB
+	col     string
+	filters []nosql.FieldFilter
+	keys    []nosql.Key
+	byKeys  bool
+}
+
+func (d *memDelete) WithFields(filters ...nosql.FieldFilter) nosql.Delete {
+	d.filters = append(d.filters, filters...)
+	return d
+}
+
+func (d *memDelete) Keys(keys ...nosql.Key) nosql.Delete {
+	d.keys = append(d.keys, keys...)
+	d.byKeys = true
+	return d
+}
+
+func (d *memDelete) Do(ctx context.Context) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	c := d.db.col(d.col)
+	if !d.byKeys {
+		for k, doc := range c.docs {
+			if matches(doc.doc, d.filters) {
+				delete(c.docs, k)
+			}
+		}
+		return nil
+	}
+	for _, key := range d.keys {
+		k := keyString(key)
+		if doc, ok := c.docs[k]; ok && matches(doc.doc, d.filters) {
+			delete(c.docs, k)
+		}
+	}
+	return nil
+}
+
+// Traverse evaluates traversal steps one by one over all valid quads.
+func (db *memDB) Traverse(ctx context.Context, start []string, steps []gnosql.Step) (gnosql.Nodes, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var quads []nosql.Document
+	for _, d := range db.col("quads").sorted() {
+		added, _ := d.doc["added"].(nosql.Int)
+		deleted, _ := d.doc["deleted"].(nosql.Int)
+		if added > deleted {
+			quads = append(quads, d.doc)
+		}
+	}
+	field := func(d nosql.Document, name string) string {
+		s, _ := d[name].(nosql.String)
+		return string(s)
+	}
+	cur := start
+	if cur == nil {
+		for _, d := range db.col("nodes").sorted() {
+			cur = append(cur, field(d.doc, "hash"))
+		}
+	}
+	for _, st := range steps {
+		from, to := st.From.String(), st.To.String()
+		var links []nosql.FieldFilter
+		for _, l := range st.Links {
+			links = append(links, nosql.FieldFilter{
+				Path: []string{l.Dir.String()}, Filter: nosql.Equal, Value: nosql.String(l.Val),
+			})
+		}
+		if st.Recursive {
+			next := make(map[string][]string)
+			for _, q := range quads {
+				if matches(q, links) {
+					next[field(q, from)] = append(next[field(q, from)], field(q, to))
+				}
+			}
+			var err error
+			cur, err = gnosql.Recurse(cur, func(n string) ([]string, error) {
+				return next[n], nil
+			}, st.MaxDepth)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var out []string
+		for _, h := range cur {
+			for _, q := range quads {
+				if field(q, from) == h && matches(q, links) {
+					out = append(out, field(q, to))
+				}
+			}
+		}
+		cur = out
+	}
+	return gnosql.NodeList(cur), nil
+}
@@ -2,11 +2,15 @@ package mongo
 
 import (
 	"context"
+	"net/url"
+	"strings"
 
 	"github.com/cayleygraph/cayley/graph"
 
 	"github.com/hidal-go/hidalgo/legacy/nosql"
 	"github.com/hidal-go/hidalgo/legacy/nosql/mongo"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	//import hidal-go first so the registration of the no sql stores occurs before quadstore iterates for registration
 	gnosql "github.com/cayleygraph/cayley/graph/nosql"
@@ -14,10 +18,57 @@ import (
 
 const Type = mongo.Name
 
+func init() {
+	gnosql.RegisterPipelines(Type, Open, Create)
+}
+
 func Create(addr string, opt graph.Options) (nosql.Database, error) {
-	return mongo.Dial(context.TODO(), addr, gnosql.DefaultDBName, nosql.Options(opt))
+	return Dial(context.TODO(), addr, gnosql.DefaultDBName, opt)
 }
 
 func Open(addr string, opt graph.Options) (nosql.Database, error) {
-	return mongo.Dial(context.TODO(), addr, gnosql.DefaultDBName, nosql.Options(opt))
+	return Dial(context.TODO(), addr, gnosql.DefaultDBName, opt)
+}
+
+var _ gnosql.Pipeliner = (*DB)(nil)
+
+// DB is a MongoDB database that supports traversal pipelines.
+type DB struct {
+	*mongo.DB
+	db *driver.Database
+}
+
+// Dial connects to MongoDB. The client is shared between generic NoSQL implementation and traversal pipelines.
+func Dial(ctx context.Context, addr, dbName string, opt graph.Options) (*DB, error) {
+	nopt := nosql.Options(opt)
+	dbName = nopt.GetString("database_name", dbName)
+	client, ok := nopt["session"].(*driver.Client)
+	if !ok {
+		var err error
+		client, err = driver.NewClient(options.Client().ApplyURI(connString(addr, dbName, nopt)))
+		if err != nil {
+			return nil, err
+		}
+		if err = client.Connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	hdb, err := mongo.New(client, dbName)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{DB: hdb, db: client.Database(dbName)}, nil
+}
+
+// connString converts database address to MongoDB connection string.
+func connString(addr, dbName string, opt nosql.Options) string {
+	if strings.HasPrefix(addr, "mongodb://") || strings.ContainsAny(addr, `@/\`) {
+		// full mongodb url
+		return addr
+	}
+	s := "mongodb://"
+	if user := opt.GetString("username", ""); user != "" {
+		s += url.QueryEscape(user) + ":" + url.QueryEscape(opt.GetString("password", "")) + "@"
+	}
+	return s + addr + "/" + dbName
 }
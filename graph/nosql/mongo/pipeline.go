@@ -0,0 +1,342 @@
+package mongo
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+
+	gnosql "github.com/cayleygraph/cayley/graph/nosql"
+)
+
+// names of quad collection and fields, as they are stored by gnosql package
+const (
+	colQuads       = "quads"
+	fldQuadAdded   = "added"
+	fldQuadDeleted = "deleted"
+)
+
+// Traverse runs a traversal pipeline using aggregations.
+//
+// Consecutive non-recursive steps are executed as a single aggregation with $lookup stages.
+// Recursive steps run one aggregation per level of recursion, since deleted quads cannot be
+// excluded from $graphLookup. Results of the last step are streamed from the aggregation cursor,
+// so only the nodes visited by recursive steps are kept in memory.
+func (db *DB) Traverse(ctx context.Context, start []string, steps []gnosql.Step) (gnosql.Nodes, error) {
+	// nodes and the number of paths that lead to them; nil means all nodes
+	var cur map[string]int
+	if start != nil {
+		cur = make(map[string]int, len(start))
+		for _, h := range start {
+			cur[h]++
+		}
+	}
+	if len(steps) == 0 {
+		return gnosql.NodeList(expand(cur)), nil
+	}
+	for {
+		if cur != nil && len(cur) == 0 {
+			return gnosql.NodeList(nil), nil
+		}
+		var (
+			it  nodeCounts
+			err error
+		)
+		if steps[0].Recursive {
+			it = db.recurse(cur, steps[0])
+			steps = steps[1:]
+		} else {
+			n := 1
+			for n < len(steps) && !steps[n].Recursive {
+				n++
+			}
+			it, err = db.follow(ctx, cur, steps[:n])
+			steps = steps[n:]
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(steps) == 0 {
+			return &nodeStream{it: it}, nil
+		}
+		cur, err = collect(ctx, it)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nodeCounts is a stream of nodes and the number of paths that lead to them.
+type nodeCounts interface {
+	Next(ctx context.Context) bool
+	Node() (string, int)
+	Err() error
+	Close() error
+}
+
+// collect reads all nodes from the stream, so they can be used as a start of the next step.
+func collect(ctx context.Context, it nodeCounts) (map[string]int, error) {
+	defer it.Close()
+	out := make(map[string]int)
+	for it.Next(ctx) {
+		h, n := it.Node()
+		out[h] += n
+	}
+	return out, it.Err()
+}
+
+func expand(cur map[string]int) []string {
+	var out []string
+	for _, h := range sortedKeys(cur) {
+		for i := 0; i < cur[h]; i++ {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// nodeStream returns each node as many times as there are paths leading to it.
+type nodeStream struct {
+	it   nodeCounts
+	node string
+	left int
+}
+
+func (s *nodeStream) Next(ctx context.Context) bool {
+	if s.left > 0 {
+		s.left--
+		return true
+	}
+	for s.it.Next(ctx) {
+		h, n := s.it.Node()
+		if n <= 0 {
+			continue
+		}
+		s.node, s.left = h, n-1
+		return true
+	}
+	return false
+}
+
+func (s *nodeStream) Node() string {
+	return s.node
+}
+
+func (s *nodeStream) Err() error {
+	return s.it.Err()
+}
+
+func (s *nodeStream) Close() error {
+	return s.it.Close()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isValid returns an expression that checks that quad was not deleted.
+func isValid(prefix string) bson.M {
+	return bson.M{"$gt": bson.A{
+		bson.M{"$ifNull": bson.A{"$" + prefix + fldQuadAdded, 0}},
+		bson.M{"$ifNull": bson.A{"$" + prefix + fldQuadDeleted, 0}},
+	}}
+}
+
+func addLinks(m bson.M, prefix string, links []gnosql.Linkage) {
+	for _, l := range links {
+		m[prefix+l.Dir.String()] = string(l.Val)
+	}
+}
+
+// follow runs consecutive non-recursive steps in a single aggregation.
+func (db *DB) follow(ctx context.Context, cur map[string]int, steps []gnosql.Step) (nodeCounts, error) {
+	first := steps[0]
+	match := bson.M{"$expr": isValid("")}
+	if cur != nil {
+		match[first.From.String()] = bson.M{"$in": sortedKeys(cur)}
+	}
+	addLinks(match, "", first.Links)
+	pipe := driver.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.M{
+			"_id":  0,
+			"root": "$" + first.From.String(),
+			"node": "$" + first.To.String(),
+		}}},
+	}
+	for _, st := range steps[1:] {
+		match := bson.M{"$expr": isValid("q.")}
+		addLinks(match, "q.", st.Links)
+		pipe = append(pipe,
+			bson.D{{Key: "$lookup", Value: bson.M{
+				"from":         colQuads,
+				"localField":   "node",
+				"foreignField": st.From.String(),
+				"as":           "q",
+			}}},
+			bson.D{{Key: "$unwind", Value: "$q"}},
+			bson.D{{Key: "$match", Value: match}},
+			bson.D{{Key: "$project", Value: bson.M{
+				"root": 1,
+				"node": "$q." + st.To.String(),
+			}}},
+		)
+	}
+	pipe = append(pipe, bson.D{{Key: "$group", Value: bson.M{
+		"_id": bson.M{"root": "$root", "node": "$node"},
+		"n":   bson.M{"$sum": 1},
+	}}})
+	c, err := db.db.Collection(colQuads).Aggregate(ctx, pipe)
+	if err != nil {
+		return nil, err
+	}
+	return &followCursor{c: c, cur: cur}, nil
+}
+
+// followCursor streams results of a follow aggregation.
+type followCursor struct {
+	c    *driver.Cursor
+	cur  map[string]int
+	node string
+	n    int
+	err  error
+}
+
+func (it *followCursor) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	for it.c.Next(ctx) {
+		var r struct {
+			ID struct {
+				Root string `bson:"root"`
+				Node string `bson:"node"`
+			} `bson:"_id"`
+			N int `bson:"n"`
+		}
+		if it.err = it.c.Decode(&r); it.err != nil {
+			return false
+		}
+		n := r.N
+		if it.cur != nil {
+			n *= it.cur[r.ID.Root]
+		}
+		if n == 0 {
+			continue
+		}
+		it.node, it.n = r.ID.Node, n
+		return true
+	}
+	it.err = it.c.Err()
+	return false
+}
+
+func (it *followCursor) Node() (string, int) {
+	return it.node, it.n
+}
+
+func (it *followCursor) Err() error {
+	return it.err
+}
+
+func (it *followCursor) Close() error {
+	return it.c.Close(context.Background())
+}
+
+// recurse evaluates a recursive step level by level. Each level is a single aggregation
+// that follows valid quads from the nodes found on the previous level.
+func (db *DB) recurse(cur map[string]int, st gnosql.Step) nodeCounts {
+	it := &recurseCursor{db: db, st: st, seen: make(map[string]struct{})}
+	if cur != nil {
+		it.frontier = sortedKeys(cur)
+	}
+	return it
+}
+
+// recurseCursor streams unique nodes found by a recursive step.
+type recurseCursor struct {
+	db *DB
+	st gnosql.Step
+
+	seen     map[string]struct{}
+	frontier []string // nil means all nodes
+	next     []string
+	depth    int
+
+	c    *driver.Cursor
+	node string
+	err  error
+}
+
+func (it *recurseCursor) level(ctx context.Context) (*driver.Cursor, error) {
+	from, to := it.st.From.String(), it.st.To.String()
+	match := bson.M{"$expr": isValid("")}
+	if it.frontier != nil {
+		match[from] = bson.M{"$in": it.frontier}
+	}
+	addLinks(match, "", it.st.Links)
+	return it.db.db.Collection(colQuads).Aggregate(ctx, driver.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + to}}},
+	})
+}
+
+func (it *recurseCursor) Next(ctx context.Context) bool {
+	for it.err == nil {
+		if it.c == nil {
+			if it.depth > 0 && len(it.frontier) == 0 {
+				return false
+			} else if it.st.MaxDepth > 0 && it.depth >= it.st.MaxDepth {
+				return false
+			}
+			it.depth++
+			if it.c, it.err = it.level(ctx); it.err != nil {
+				return false
+			}
+		}
+		for it.c.Next(ctx) {
+			var r struct {
+				ID string `bson:"_id"`
+			}
+			if it.err = it.c.Decode(&r); it.err != nil {
+				return false
+			}
+			if _, ok := it.seen[r.ID]; ok {
+				continue
+			}
+			it.seen[r.ID] = struct{}{}
+			it.next = append(it.next, r.ID)
+			it.node = r.ID
+			return true
+		}
+		it.err = it.c.Err()
+		it.c.Close(ctx)
+		it.c = nil
+		sort.Strings(it.next)
+		it.frontier, it.next = it.next, nil
+	}
+	return false
+}
+
+func (it *recurseCursor) Node() (string, int) {
+	return it.node, 1
+}
+
+func (it *recurseCursor) Err() error {
+	return it.err
+}
+
+func (it *recurseCursor) Close() error {
+	if it.c == nil {
+		return nil
+	}
+	err := it.c.Close(context.Background())
+	it.c = nil
+	return err
+}
@@ -0,0 +1,467 @@
+package nosql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/refs"
+	"github.com/cayleygraph/cayley/query/shape"
+)
+
+// Step is a single step of a traversal pipeline. It selects valid quads that have one of the current nodes
+// in the From direction and match all Links, and continues from nodes in the To direction of these quads.
+type Step struct {
+	From  quad.Direction
+	To    quad.Direction
+	Links []Linkage // additional constraints for quads, for example a fixed predicate
+
+	// Recursive step is repeated for newly found nodes until no new nodes are found or MaxDepth is reached.
+	// Only unique nodes are returned from recursive step. Start nodes are returned only if they are reachable.
+	Recursive bool
+	MaxDepth  int // zero or negative means no limit
+}
+
+// Pipeliner is an optional interface for databases that can run a multi-step traversal as a single query.
+type Pipeliner interface {
+	// Traverse runs a traversal pipeline from a given set of start nodes and returns a stream of hashes
+	// of resulting nodes. Nil start means that traversal starts from all nodes.
+	//
+	// Each node is returned as many times as there are paths leading to it, the same way as if
+	// each step was evaluated with LinksTo and HasA iterators. Recursive steps return only unique nodes.
+	Traverse(ctx context.Context, start []string, steps []Step) (Nodes, error)
+}
+
+// Nodes is a stream of node hashes returned by a traversal pipeline.
+type Nodes interface {
+	// Next advances to the next node.
+	Next(ctx context.Context) bool
+	// Node returns the hash of the current node.
+	Node() string
+	Err() error
+	Close() error
+}
+
+// NodeList returns a stream of nodes from a slice.
+func NodeList(nodes []string) Nodes {
+	return &nodeList{nodes: nodes, i: -1}
+}
+
+type nodeList struct {
+	nodes []string
+	i     int
+}
+
+func (it *nodeList) Next(ctx context.Context) bool {
+	if it.i+1 >= len(it.nodes) {
+		return false
+	}
+	it.i++
+	return true
+}
+
+func (it *nodeList) Node() string {
+	return it.nodes[it.i]
+}
+
+func (it *nodeList) Err() error {
+	return nil
+}
+
+func (it *nodeList) Close() error {
+	return nil
+}
+
+// Recurse evaluates a recursive step the same way Recursive iterator does. Function next must return
+// nodes that can be reached from a given node in one step.
+func Recurse(start []string, next func(node string) ([]string, error), maxDepth int) ([]string, error) {
+	var (
+		out  []string
+		seen = make(map[string]struct{})
+	)
+	for depth := 1; len(start) != 0 && (maxDepth <= 0 || depth <= maxDepth); depth++ {
+		var found []string
+		for _, n := range start {
+			list, err := next(n)
+			if err != nil {
+				return nil, err
+			}
+			for _, n2 := range list {
+				if _, ok := seen[n2]; ok {
+					continue
+				}
+				seen[n2] = struct{}{}
+				found = append(found, n2)
+			}
+		}
+		out = append(out, found...)
+		start = found
+	}
+	return out, nil
+}
+
+func (qs *QuadStore) pipelines() bool {
+	_, ok := qs.db.(Pipeliner)
+	return ok
+}
+
+// Traversal is a shape representing a multi-step traversal that is executed by the database as a single query.
+type Traversal struct {
+	From  shape.Shape // start nodes
+	Steps []Step
+}
+
+func (s Traversal) BuildIterator(qs graph.QuadStore) iterator.Shape {
+	db, ok := qs.(*QuadStore)
+	if !ok {
+		return iterator.NewError(fmt.Errorf("not a nosql database: %T", qs))
+	}
+	var from iterator.Shape
+	if _, ok := s.From.(shape.AllNodes); !ok {
+		from = s.From.BuildIterator(qs)
+	}
+	return db.newTraversalIterator(from, s.Steps)
+}
+
+func (s Traversal) Optimize(ctx context.Context, r shape.Optimizer) (shape.Shape, bool) {
+	var opt bool
+	s.From, opt = s.From.Optimize(ctx, r)
+	if shape.IsNull(s.From) {
+		return nil, true
+	}
+	return s, opt
+}
+
+// canStartTraversal checks if shape can be used as a start set of traversal. Start nodes are
+// collected before running the query, thus the shape must not save any tags.
+func canStartTraversal(s shape.Shape) bool {
+	ok := true
+	shape.Walk(s, func(s shape.Shape) bool {
+		switch s := s.(type) {
+		case Traversal, Shape, Quads, recursionStart,
+			shape.AllNodes, shape.Fixed, shape.Lookup, shape.Quads, shape.NodesFrom, shape.Filter,
+			shape.Intersect, shape.Union, shape.Except, shape.Unique, shape.Page, shape.Null:
+		case shape.QuadsAction:
+			if len(s.Save) != 0 {
+				ok = false
+			}
+		default:
+			// tags or unknown shape
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// oneHash returns a node hash if shape contains exactly one node.
+func oneHash(s shape.Shape) (NodeHash, bool) {
+	v, ok := shape.One(s)
+	if !ok {
+		return "", false
+	}
+	h, ok := v.(NodeHash)
+	return h, ok
+}
+
+// appendStep adds a traversal step to the start nodes or to an existing traversal.
+func appendStep(from shape.Shape, step Step) (shape.Shape, bool) {
+	if t, ok := from.(Traversal); ok {
+		t.Steps = append(t.Steps[:len(t.Steps):len(t.Steps)], step)
+		return t, true
+	}
+	if !canStartTraversal(from) {
+		return nil, false
+	}
+	return Traversal{From: from, Steps: []Step{step}}, true
+}
+
+func (qs *QuadStore) optimizeNodesFrom(s shape.NodesFrom) (shape.Shape, bool) {
+	if !qs.pipelines() {
+		return s, false
+	}
+	q, ok := s.Quads.(shape.Quads)
+	if !ok {
+		return s, false
+	}
+	var (
+		from shape.Shape
+		step = Step{To: s.Dir}
+	)
+	for _, f := range q {
+		if h, ok := oneHash(f.Values); ok {
+			step.Links = append(step.Links, Linkage{Dir: f.Dir, Val: h})
+			continue
+		}
+		if from != nil {
+			// more than one non-fixed constraint
+			return s, false
+		}
+		from, step.From = f.Values, f.Dir
+	}
+	if from == nil {
+		// all values are fixed - start from a node that is not a predicate
+		i := -1
+		for j, l := range step.Links {
+			if l.Dir != step.To && (i < 0 || step.Links[i].Dir == quad.Predicate) {
+				i = j
+			}
+		}
+		if i < 0 {
+			return s, false
+		}
+		l := step.Links[i]
+		from, step.From = shape.Fixed{l.Val}, l.Dir
+		step.Links = append(step.Links[:i:i], step.Links[i+1:]...)
+	}
+	if ns, ok := appendStep(from, step); ok {
+		return ns, true
+	}
+	return s, false
+}
+
+// recursionStart is used as a placeholder for nodes found on the previous iteration of recursive shape.
+type recursionStart struct{}
+
+func (recursionStart) BuildIterator(qs graph.QuadStore) iterator.Shape {
+	return iterator.NewError(fmt.Errorf("recursion placeholder is not replaced"))
+}
+
+func (s recursionStart) Optimize(ctx context.Context, r shape.Optimizer) (shape.Shape, bool) {
+	return s, false
+}
+
+func (qs *QuadStore) optimizeRecursive(ctx context.Context, s shape.Recursive) (shape.Shape, bool) {
+	if !qs.pipelines() || len(s.DepthTags) != 0 {
+		return s, false
+	}
+	// apply the step to a placeholder to check if it can be represented as a single traversal step
+	st, _ := shape.Optimize(ctx, s.Step(recursionStart{}), qs)
+	t, ok := st.(Traversal)
+	if !ok || len(t.Steps) != 1 || t.Steps[0].Recursive {
+		return s, false
+	} else if _, ok = t.From.(recursionStart); !ok {
+		return s, false
+	}
+	step := t.Steps[0]
+	step.Recursive = true
+	step.MaxDepth = s.MaxDepth
+	if step.MaxDepth == 0 {
+		step.MaxDepth = iterator.DefaultMaxRecursiveSteps
+	}
+	if ns, ok := appendStep(s.From, step); ok {
+		return ns, true
+	}
+	return s, false
+}
+
+// TraversalIterator runs a traversal query and iterates over the results.
+type TraversalIterator struct {
+	qs    *QuadStore
+	from  iterator.Shape // nil means all nodes
+	steps []Step
+}
+
+func (qs *QuadStore) newTraversalIterator(from iterator.Shape, steps []Step) *TraversalIterator {
+	return &TraversalIterator{qs: qs, from: from, steps: steps}
+}
+
+func (it *TraversalIterator) Iterate() iterator.Scanner {
+	return &traversalNext{traversalResults: traversalResults{it: it}}
+}
+
+func (it *TraversalIterator) Lookup() iterator.Index {
+	return &traversalContains{traversalResults: traversalResults{it: it}}
+}
+
+func (it *TraversalIterator) SubIterators() []iterator.Shape {
+	if it.from == nil {
+		return nil
+	}
+	return []iterator.Shape{it.from}
+}
+
+var _ iterator.Composite = (*TraversalIterator)(nil)
+
+// MapSubIterators implements iterator.Composite.
+func (it *TraversalIterator) MapSubIterators(m iterator.Morphism) iterator.Shape {
+	nit := *it
+	if it.from != nil {
+		nit.from = m(it.from)
+	}
+	return &nit
+}
+
+func (it *TraversalIterator) Optimize(ctx context.Context) (iterator.Shape, bool) {
+	if it.from == nil {
+		return it, false
+	}
+	from, opt := it.from.Optimize(ctx)
+	if !opt {
+		return it, false
+	}
+	nit := *it
+	nit.from = from
+	return &nit, true
+}
+
+func (it *TraversalIterator) Stats(ctx context.Context) (iterator.Costs, error) {
+	size := refs.Size{Value: it.qs.Size(), Exact: false}
+	if it.from != nil {
+		st, err := it.from.Stats(ctx)
+		if err != nil {
+			return iterator.Costs{}, err
+		}
+		size.Value = st.Size.Value
+	}
+	return iterator.Costs{
+		ContainsCost: 1,
+		NextCost:     1,
+		Size:         size,
+	}, nil
+}
+
+func (it *TraversalIterator) String() string {
+	return fmt.Sprintf("NoSQLTraversal(%d)", len(it.steps))
+}
+
+// run collects start nodes and executes traversal query. Resulting nodes are streamed from the database.
+func (it *TraversalIterator) run(ctx context.Context) (Nodes, error) {
+	var start []string
+	if it.from != nil {
+		start = make([]string, 0)
+		sc := it.from.Iterate()
+		for sc.Next(ctx) {
+			h, err := it.qs.toHash(sc.Result())
+			if err != nil {
+				sc.Close()
+				return nil, err
+			}
+			start = append(start, string(h))
+		}
+		err := sc.Err()
+		sc.Close()
+		if err != nil {
+			return nil, err
+		} else if len(start) == 0 {
+			return NodeList(nil), nil
+		}
+	}
+	return it.qs.db.(Pipeliner).Traverse(ctx, start, it.steps)
+}
+
+func (qs *QuadStore) toHash(v graph.Ref) (NodeHash, error) {
+	switch v := v.(type) {
+	case NodeHash:
+		return v, nil
+	case refs.PreFetchedValue:
+		return qs.hashOf(v.NameOf()), nil
+	}
+	return "", fmt.Errorf("unexpected node type: %T", v)
+}
+
+type traversalResults struct {
+	it  *TraversalIterator
+	res graph.Ref
+	err error
+}
+
+func (it *traversalResults) TagResults(dst map[string]graph.Ref) {}
+
+func (it *traversalResults) Result() graph.Ref {
+	return it.res
+}
+
+func (it *traversalResults) Err() error {
+	return it.err
+}
+
+func (it *traversalResults) String() string {
+	return it.it.String()
+}
+
+type traversalNext struct {
+	traversalResults
+	nodes Nodes
+	done  bool
+}
+
+func (it *traversalNext) Next(ctx context.Context) bool {
+	if it.err != nil || it.done {
+		return false
+	}
+	if it.nodes == nil {
+		it.nodes, it.err = it.it.run(ctx)
+		if it.err != nil {
+			clog.Errorf("error running traversal: %v", it.err)
+			return false
+		}
+	}
+	if !it.nodes.Next(ctx) {
+		it.res, it.done = nil, true
+		if it.err = it.nodes.Err(); it.err != nil {
+			clog.Errorf("error running traversal: %v", it.err)
+		}
+		return false
+	}
+	it.res = NodeHash(it.nodes.Node())
+	return true
+}
+
+func (it *traversalNext) Close() error {
+	if it.nodes != nil {
+		return it.nodes.Close()
+	}
+	return nil
+}
+
+type traversalContains struct {
+	traversalResults
+	set map[NodeHash]struct{}
+}
+
+func (it *traversalContains) Contains(ctx context.Context, v graph.Ref) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.set == nil {
+		// lookups need all results, so they are collected into a set
+		it.set, it.err = it.collect(ctx)
+		if it.err != nil {
+			clog.Errorf("error running traversal: %v", it.err)
+			return false
+		}
+	}
+	h, err := it.it.qs.toHash(v)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if _, ok := it.set[h]; !ok {
+		it.res = nil
+		return false
+	}
+	it.res = h
+	return true
+}
+
+func (it *traversalContains) collect(ctx context.Context) (map[NodeHash]struct{}, error) {
+	nodes, err := it.it.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer nodes.Close()
+	set := make(map[NodeHash]struct{})
+	for nodes.Next(ctx) {
+		set[NodeHash(nodes.Node())] = struct{}{}
+	}
+	return set, nodes.Err()
+}
+
+func (it *traversalContains) Close() error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package nosql_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/hidal-go/hidalgo/legacy/nosql"
+	hnosqltest "github.com/hidal-go/hidalgo/legacy/nosql/nosqltest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	gnosql "github.com/cayleygraph/cayley/graph/nosql"
+	"github.com/cayleygraph/cayley/graph/nosql/nosqltest"
+	"github.com/cayleygraph/cayley/query/path"
+	"github.com/cayleygraph/cayley/query/shape"
+)
+
+var memGen = hnosqltest.Database{
+	Run: func(t testing.TB) nosql.Database {
+		return newMemDB()
+	},
+}
+
+func TestMemDB(t *testing.T) {
+	hnosqltest.TestNoSQL(t, memGen)
+}
+
+func TestPipelines(t *testing.T) {
+	nosqltest.TestAll(t, memGen)
+}
+
+func newPipelineStore(t testing.TB, pipelines bool) *gnosql.QuadStore {
+	var db nosql.Database = newMemDB()
+	if !pipelines {
+		// hide the Pipeliner implementation
+		db = struct{ nosql.Database }{db}
+	}
+	require.NoError(t, gnosql.Init(db, nil))
+	qs, err := gnosql.NewQuadStore(db, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		qs.Close()
+	})
+	w, err := qs.NewQuadWriter()
+	require.NoError(t, err)
+	_, err = w.WriteQuads([]quad.Quad{
+		quad.MakeIRI("a", "follows", "b", ""),
+		quad.MakeIRI("a", "follows", "c", ""),
+		quad.MakeIRI("b", "follows", "d", ""),
+		quad.MakeIRI("c", "follows", "d", ""),
+		quad.MakeIRI("d", "follows", "e", ""),
+		quad.MakeIRI("e", "follows", "a", ""),
+		quad.MakeIRI("d", "status", "cool", "label"),
+	})
+	require.NoError(t, err)
+	err = qs.ApplyDeltas([]graph.Delta{
+		{Quad: quad.MakeIRI("e", "follows", "a", ""), Action: graph.Delete},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	return qs
+}
+
+func TestTraversalShapes(t *testing.T) {
+	ctx := context.Background()
+	follows := quad.IRI("follows")
+	cases := []struct {
+		name  string
+		path  func(qs graph.QuadStore) *path.Path
+		steps int
+	}{
+		{
+			name: "out chain",
+			path: func(qs graph.QuadStore) *path.Path {
+				return path.StartPath(qs, quad.IRI("a")).Out(follows).Out(follows).Out(follows)
+			},
+			steps: 3,
+		},
+		{
+			name: "in chain",
+			path: func(qs graph.QuadStore) *path.Path {
+				return path.StartPath(qs, quad.IRI("d")).In(follows).In(follows)
+			},
+			steps: 2,
+		},
+		{
+			name: "labels",
+			path: func(qs graph.QuadStore) *path.Path {
+				return path.StartPath(qs, quad.IRI("c")).Out(follows).OutWithTags(nil, quad.IRI("status")).Labels()
+			},
+			steps: 2,
+		},
+		{
+			name: "recursive",
+			path: func(qs graph.QuadStore) *path.Path {
+				return path.StartPath(qs, quad.IRI("a")).FollowRecursive(follows, 0, nil)
+			},
+			steps: 1,
+		},
+		{
+			name: "recursive depth",
+			path: func(qs graph.QuadStore) *path.Path {
+				return path.StartPath(qs, quad.IRI("b")).FollowRecursive(follows, 1, nil).Out(follows)
+			},
+			steps: 2,
+		},
+	}
+	values := func(t *testing.T, p *path.Path) []string {
+		var out []string
+		err := p.Iterate(ctx).EachValue(nil, func(v quad.Value) error {
+			out = append(out, quad.StringOf(v))
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Strings(out)
+		return out
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			qs := newPipelineStore(t, true)
+			s, _ := shape.Optimize(ctx, c.path(qs).Shape(), qs)
+			var tr *gnosql.Traversal
+			shape.Walk(s, func(s shape.Shape) bool {
+				if t, ok := s.(gnosql.Traversal); ok && tr == nil {
+					tr = &t
+				}
+				return tr == nil
+			})
+			require.NotNil(t, tr, "%#v", s)
+			require.Len(t, tr.Steps, c.steps)
+
+			expect := values(t, c.path(newPipelineStore(t, false)))
+			require.NotEmpty(t, expect)
+			require.Equal(t, expect, values(t, c.path(qs)))
+		})
+	}
+}
+
+func TestTraversalOptimize(t *testing.T) {
+	ctx := context.Background()
+	qs := newPipelineStore(t, true)
+	follows, err := qs.ValueOf(quad.IRI("follows"))
+	require.NoError(t, err)
+	a, err := qs.ValueOf(quad.IRI("a"))
+	require.NoError(t, err)
+	steps := []gnosql.Step{{From: quad.Subject, To: quad.Object, Links: []gnosql.Linkage{
+		{Dir: quad.Predicate, Val: follows.(gnosql.NodeHash)},
+	}}}
+	it := gnosql.Traversal{From: shape.Fixed{a}, Steps: steps}.BuildIterator(qs)
+	// wrap start nodes into an iterator that can be optimized away
+	it = it.(iterator.Composite).MapSubIterators(func(s iterator.Shape) iterator.Shape {
+		return iterator.NewAnd(s)
+	})
+	sub := it.SubIterators()[0]
+
+	nit, ok := it.Optimize(ctx)
+	require.True(t, ok)
+	require.NotSame(t, it, nit)
+	require.Same(t, sub, it.SubIterators()[0], "optimize must not change the receiver")
+	require.NotSame(t, sub, nit.SubIterators()[0])
+
+	var out []string
+	for _, s := range []iterator.Shape{it, nit} {
+		sc := s.Iterate()
+		out = out[:0]
+		for sc.Next(ctx) {
+			v, err := qs.NameOf(sc.Result())
+			require.NoError(t, err)
+			out = append(out, quad.StringOf(v))
+		}
+		require.NoError(t, sc.Err())
+		require.NoError(t, sc.Close())
+		sort.Strings(out)
+		require.Equal(t, []string{"<b>", "<c>"}, out)
+	}
+}
@@ -46,11 +46,18 @@ type Traits = nosql.Traits
 
 func init() {
 	for _, reg := range nosql.List() {
-		Register(reg.Name, Registration{
+		name := reg.Name
+		Register(name, Registration{
 			NewFunc: func(addr string, options graph.Options) (nosql.Database, error) {
+				if p, ok := pipelines[name]; ok {
+					return p.open(addr, options)
+				}
 				return reg.Open(context.TODO(), addr, DefaultDBName, nosql.Options(options))
 			},
 			InitFunc: func(addr string, options graph.Options) (nosql.Database, error) {
+				if p, ok := pipelines[name]; ok {
+					return p.create(addr, options)
+				}
 				return reg.New(context.TODO(), addr, DefaultDBName, nosql.Options(options))
 			},
 			IsPersistent: !reg.Volatile, Traits: reg.Traits,
@@ -61,6 +68,23 @@ func init() {
 type InitFunc func(string, graph.Options) (nosql.Database, error)
 type NewFunc func(string, graph.Options) (nosql.Database, error)
 
+type pipelineFuncs struct {
+	open   NewFunc
+	create InitFunc
+}
+
+// pipelines are functions that open generic databases with support for traversal pipelines, by database name.
+var pipelines = make(map[string]pipelineFuncs)
+
+// RegisterPipelines sets functions that open a database registered in the generic NoSQL registry
+// with support for traversal pipelines (see Pipeliner). Databases registered with Register are not affected.
+func RegisterPipelines(name string, open NewFunc, create InitFunc) {
+	if _, ok := pipelines[name]; ok {
+		panic(fmt.Sprintf("pipelines for %q are already registered", name))
+	}
+	pipelines[name] = pipelineFuncs{open: open, create: create}
+}
+
 func Register(name string, r Registration) {
 	graph.RegisterQuadStore(name, graph.QuadStoreRegistration{
 		InitFunc: func(addr string, opt graph.Options) error {
@@ -24,6 +24,10 @@ func (qs *QuadStore) OptimizeShape(ctx context.Context, s shape.Shape) (shape.Sh
 		return qs.optimizeFilter(s)
 	case shape.Page:
 		return qs.optimizePage(s)
+	case shape.NodesFrom:
+		return qs.optimizeNodesFrom(s)
+	case shape.Recursive:
+		return qs.optimizeRecursive(ctx, s)
 	case shape.Composite:
 		if s2, opt := s.Simplify().Optimize(ctx, qs); opt {
 			return s2, true
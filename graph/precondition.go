@@ -0,0 +1,182 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+var (
+	ErrPreconditionFailed       = errors.New("precondition failed")
+	ErrPreconditionsUnsupported = errors.New("quadstore: preconditions are not supported")
+)
+
+// CondKind is a kind of transaction precondition.
+type CondKind int8
+
+const (
+	// QuadExists requires a quad to exist.
+	QuadExists CondKind = iota + 1
+	// NoValue requires a subject to have no quads with a given predicate and label.
+	NoValue
+	// ValueEquals requires a subject to have exactly one quad with a given predicate and label,
+	// and the object of this quad must be equal to a given value.
+	ValueEquals
+)
+
+func (k CondKind) String() string {
+	switch k {
+	case QuadExists:
+		return "exists"
+	case NoValue:
+		return "no value"
+	case ValueEquals:
+		return "equals"
+	default:
+		return "invalid"
+	}
+}
+
+// Precondition is a condition that must hold for a transaction to be applied.
+//
+// Subject, predicate and label of the quad select a set of quads to check. Label is always matched exactly,
+// so nil label only matches quads in the default graph. Object is ignored by NoValue.
+type Precondition struct {
+	Kind CondKind
+	Quad quad.Quad
+}
+
+func (c Precondition) String() string {
+	q := c.Quad
+	if c.Kind == NoValue {
+		q.Object = nil
+	}
+	return c.Kind.String() + " " + q.String()
+}
+
+// IsPreconditionFailed returns whether an error is a DeltaError
+// with the Err field equal to ErrPreconditionFailed.
+func IsPreconditionFailed(err error) bool {
+	return errors.Is(err, ErrPreconditionFailed)
+}
+
+// ConditionalApplier is an optional interface for quad stores that can check transaction
+// preconditions atomically with applying deltas.
+type ConditionalApplier interface {
+	// ApplyDeltasIf checks all preconditions and applies deltas only if they hold.
+	// It returns a DeltaError with ErrPreconditionFailed if any of the preconditions does not hold.
+	ApplyDeltasIf(conds []Precondition, deltas []Delta, ignoreOpts IgnoreOpts) error
+}
+
+// ApplyDeltasIf applies deltas to a quad store if all preconditions hold.
+// It returns ErrPreconditionsUnsupported if the quad store cannot check preconditions.
+func ApplyDeltasIf(qs QuadStore, conds []Precondition, deltas []Delta, ignoreOpts IgnoreOpts) error {
+	if err := checkExpiry(qs, deltas); err != nil {
+		return err
+	}
+	if len(conds) == 0 {
+		return qs.ApplyDeltas(deltas, ignoreOpts)
+	}
+	ca, ok := Unwrap(qs).(ConditionalApplier)
+	if !ok {
+		return ErrPreconditionsUnsupported
+	}
+	return ca.ApplyDeltasIf(conds, deltas, ignoreOpts)
+}
+
+// ObjectsFunc returns objects of all quads with a given subject, predicate and label.
+type ObjectsFunc func(s, p, l quad.Value) ([]quad.Value, error)
+
+// CheckPreconditions is a helper for quad stores to implement ConditionalApplier.
+// It checks all preconditions using a function that lists objects in the current state of the store.
+func CheckPreconditions(conds []Precondition, objects ObjectsFunc) error {
+	for _, c := range conds {
+		q := c.Quad
+		if q.Subject == nil || q.Predicate == nil || (c.Kind != NoValue && q.Object == nil) {
+			return &DeltaError{Cond: &c, Err: fmt.Errorf("invalid precondition: %v", c)}
+		}
+		objs, err := objects(q.Subject, q.Predicate, q.Label)
+		if err != nil {
+			return err
+		}
+		ok := false
+		switch c.Kind {
+		case QuadExists:
+			h := refs.HashOf(q.Object)
+			for _, o := range objs {
+				if refs.HashOf(o) == h {
+					ok = true
+					break
+				}
+			}
+		case NoValue:
+			ok = len(objs) == 0
+		case ValueEquals:
+			ok = len(objs) == 1 && refs.HashOf(objs[0]) == refs.HashOf(q.Object)
+		default:
+			return &DeltaError{Cond: &c, Err: ErrInvalidAction}
+		}
+		if !ok {
+			return &DeltaError{Cond: &c, Err: ErrPreconditionFailed}
+		}
+	}
+	return nil
+}
+
+// ExpandReplace is a helper for quad stores to support Replace deltas.
+// It converts each Replace delta to Delete deltas for all current quads with the same subject, predicate and label,
+// followed by an Add delta for the new quad. A quad that replaces itself is left unchanged.
+//
+// Function objects must list objects in the state of the store before the transaction. Since the replace
+// overrides all values of a property, deltas for the same property made earlier in the transaction are dropped.
+func ExpandReplace(deltas []Delta, objects ObjectsFunc) ([]Delta, error) {
+	type key struct {
+		s, p, l refs.ValueHash
+	}
+	keyOf := func(q quad.Quad) key {
+		return key{refs.HashOf(q.Subject), refs.HashOf(q.Predicate), refs.HashOf(q.Label)}
+	}
+	// only the last replace for each key takes effect
+	last := make(map[key]int)
+	for i, d := range deltas {
+		if d.Action == Replace {
+			last[keyOf(d.Quad)] = i
+		}
+	}
+	if len(last) == 0 {
+		return deltas, nil
+	}
+	out := make([]Delta, 0, len(deltas)+len(last))
+	for i, d := range deltas {
+		q := d.Quad
+		if j, ok := last[keyOf(q)]; ok && i < j {
+			// overridden by the replace
+			continue
+		} else if d.Action != Replace {
+			out = append(out, d)
+			continue
+		} else if !q.IsValid() {
+			return nil, &DeltaError{Delta: d, Err: ErrInvalidAction}
+		}
+		objs, err := objects(q.Subject, q.Predicate, q.Label)
+		if err != nil {
+			return nil, err
+		}
+		h, exists := refs.HashOf(q.Object), false
+		for _, o := range objs {
+			if refs.HashOf(o) == h {
+				exists = true
+				continue
+			}
+			out = append(out, Delta{Action: Delete, Quad: quad.Quad{Subject: q.Subject, Predicate: q.Predicate, Object: o, Label: q.Label}})
+		}
+		if !exists || !d.Expires.IsZero() {
+			// adding an existing expiring quad refreshes its expiry time
+			out = append(out, Delta{Action: Add, Quad: q, Expires: d.Expires})
+		}
+	}
+	return out, nil
+}
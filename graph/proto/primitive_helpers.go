@@ -42,3 +42,8 @@ func (p *Primitive) Key() interface{} {
 func (p *Primitive) IsSameLink(q *Primitive) bool {
 	return p.Subject == q.Subject && p.Predicate == q.Predicate && p.Object == q.Object && p.Label == q.Label
 }
+
+// IsExpired checks if the quad has an expiry time and it is not after a given time in unix nanoseconds.
+func (p *Primitive) IsExpired(now int64) bool {
+	return p.Expires != 0 && p.Expires <= now
+}
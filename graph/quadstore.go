@@ -26,6 +26,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/refs"
@@ -110,6 +111,29 @@ type QuadStore interface {
 	Close() error
 }
 
+// BulkLoader is an optional interface for quad stores that support a faster import path for large datasets.
+type BulkLoader interface {
+	// NewBulkWriter starts a bulk quad import. Duplicate quads are ignored.
+	// Loaded quads might not be visible and queries may be slower until the writer is closed.
+	NewBulkWriter() (quad.WriteCloser, error)
+}
+
+// PrimaryReader is an optional interface for quad stores that may serve reads from lagging replicas.
+type PrimaryReader interface {
+	// Primary returns a view of the quad store that reads from the primary database.
+	// The view must not be closed.
+	Primary() QuadStore
+}
+
+// PrimaryOf returns a view of the quad store that reads the latest state of the graph.
+// Reads that decide what to write (for example, finding quads to remove) must use it.
+func PrimaryOf(qs QuadStore) QuadStore {
+	if p, ok := Unwrap(qs).(PrimaryReader); ok {
+		return p.Primary()
+	}
+	return qs
+}
+
 type Options map[string]interface{}
 
 var (
@@ -140,6 +164,34 @@ func (d Options) StringKey(key string, def string) (string, error) {
 	return def, nil
 }
 
+// StringsKey returns a list of strings for a given key. The value can be either a list or a comma-separated string.
+func (d Options) StringsKey(key string, def []string) ([]string, error) {
+	val, ok := d[key]
+	if !ok {
+		return def, nil
+	}
+	switch val := val.(type) {
+	case []string:
+		return val, nil
+	case string:
+		if val == "" {
+			return nil, nil
+		}
+		return strings.Split(val, ","), nil
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, v := range val {
+			s, ok := v.(string)
+			if !ok {
+				return def, fmt.Errorf("Invalid %s parameter type from config: %T", key, v)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	return def, fmt.Errorf("Invalid %s parameter type from config: %T", key, val)
+}
+
 func (d Options) BoolKey(key string, def bool) (bool, error) {
 	if val, ok := d[key]; ok {
 		if v, ok := val.(bool); ok {
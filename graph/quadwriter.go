@@ -25,6 +25,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
 	"github.com/cayleygraph/quad"
 
@@ -39,6 +40,8 @@ func (p Procedure) String() string {
 		return "add"
 	case -1:
 		return "delete"
+	case +2:
+		return "replace"
 	default:
 		return "invalid"
 	}
@@ -48,11 +51,17 @@ func (p Procedure) String() string {
 const (
 	Add    Procedure = +1
 	Delete Procedure = -1
+	// Replace removes all quads with the same subject, predicate and label and adds a given quad.
+	// Only the last Replace for each subject, predicate and label in a batch takes effect.
+	Replace Procedure = +2
 )
 
 type Delta struct {
 	Quad   quad.Quad
 	Action Procedure
+	// Expires is an expiry time of a quad added by this delta. Zero value means the quad never expires.
+	// See Expirer for details.
+	Expires time.Time
 }
 
 // Unwrap returns an original QuadStore value if it was wrapped by Handle.
@@ -86,14 +95,17 @@ var (
 	ErrNodeNotExists = errors.New("node does not exist")
 )
 
-// DeltaError records an error and the delta that caused it.
+// DeltaError records an error and the delta or the precondition that caused it.
 type DeltaError struct {
 	Delta Delta
+	Cond  *Precondition
 	Err   error
 }
 
 func (e *DeltaError) Error() string {
-	if !e.Delta.Quad.IsValid() {
+	if e.Cond != nil {
+		return e.Cond.String() + ": " + e.Err.Error()
+	} else if !e.Delta.Quad.IsValid() {
 		return e.Err.Error()
 	}
 	return e.Delta.Action.String() + " " + e.Delta.Quad.String() + ": " + e.Err.Error()
@@ -155,6 +167,12 @@ type QuadWriter interface {
 	Close() error
 }
 
+// QuadReplacer is an optional interface for quad writers that can replace values of a property in a single step.
+type QuadReplacer interface {
+	// ReplaceQuad removes all quads with the same subject, predicate and label and adds a given quad.
+	ReplaceQuad(quad.Quad) error
+}
+
 type NewQuadWriterFunc func(QuadStore, Options) (QuadWriter, error)
 
 var writerRegistry = make(map[string]NewQuadWriterFunc)
@@ -248,12 +266,20 @@ func (w *txWriter) WriteQuad(q quad.Quad) error {
 		w.tx.AddQuad(q)
 	case Delete:
 		w.tx.RemoveQuad(q)
+	case Replace:
+		w.tx.ReplaceQuad(q)
 	default:
 		return ErrInvalidAction
 	}
 	return nil
 }
 
+// ReplaceQuad implements QuadReplacer.
+func (w *txWriter) ReplaceQuad(q quad.Quad) error {
+	w.tx.ReplaceQuad(q)
+	return nil
+}
+
 func (w *txWriter) WriteQuads(buf []quad.Quad) (int, error) {
 	for i, q := range buf {
 		if err := w.WriteQuad(q); err != nil {
@@ -290,6 +316,33 @@ func (w *removeWriter) Flush() error {
 }
 func (w *removeWriter) Close() error { return nil }
 
+// NewReplacer creates a quad writer for a given QuadStore which replaces values of quad properties instead of adding them.
+// See Replace for details.
+func NewReplacer(qs QuadWriter) BatchWriter {
+	return &replaceWriter{qs: qs}
+}
+
+type replaceWriter struct {
+	qs QuadWriter
+}
+
+func (w *replaceWriter) WriteQuad(q quad.Quad) error {
+	_, err := w.WriteQuads([]quad.Quad{q})
+	return err
+}
+func (w *replaceWriter) WriteQuads(quads []quad.Quad) (int, error) {
+	tx := NewTransactionN(len(quads))
+	for _, q := range quads {
+		tx.ReplaceQuad(q)
+	}
+	if err := w.qs.ApplyTransaction(tx); err != nil {
+		return 0, err
+	}
+	return len(quads), nil
+}
+func (w *replaceWriter) Flush() error { return nil }
+func (w *replaceWriter) Close() error { return nil }
+
 // NewQuadStoreReader creates a quad reader for a given QuadStore.
 func NewQuadStoreReader(qs QuadStore) quad.ReadSkipCloser {
 	return NewResultReader(qs, nil)
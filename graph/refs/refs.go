@@ -44,6 +44,17 @@ type BatchNamer interface {
 	RefsOf(ctx context.Context, nodes []quad.Value) ([]Ref, error)
 }
 
+// Codec is an optional interface for Namer that serializes references of the quad store.
+// It allows iterators to store intermediate results outside of memory, for example in temporary files.
+//
+// Encoded references are only valid for the same instance of the quad store.
+type Codec interface {
+	// AppendRef appends a binary representation of the reference to the buffer.
+	AppendRef(buf []byte, r Ref) ([]byte, error)
+	// DecodeRef decodes a reference encoded by AppendRef.
+	DecodeRef(p []byte) (Ref, error)
+}
+
 func HashOf(s quad.Value) (out ValueHash) {
 	if s == nil {
 		return
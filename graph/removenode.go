@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+// RemoveNodeOptions controls which quads are removed together with a node.
+type RemoveNodeOptions struct {
+	// Cascade also removes blank nodes owned by the node, recursively.
+	// A blank node is owned if all quads that use it as an object are removed. Such nodes are
+	// created by the schema package for nested structs.
+	Cascade bool
+	// Label restricts removal to quads with a given label. All labels are affected if it is nil.
+	// Blank nodes referenced from other labels are never owned.
+	Label quad.Value
+	// DryRun only finds quads that would be removed, without changing the graph.
+	DryRun bool
+}
+
+// NodeRemoval is a set of quads removed together with a node.
+type NodeRemoval struct {
+	// Nodes is a list of removed nodes, starting from the requested one and followed by owned blank nodes.
+	Nodes []quad.Value
+	// Quads is a list of removed quads.
+	Quads []quad.Quad
+}
+
+type quadKey [4]refs.ValueHash
+
+func keyOfQuad(q quad.Quad) quadKey {
+	return quadKey{refs.HashOf(q.Subject), refs.HashOf(q.Predicate), refs.HashOf(q.Object), refs.HashOf(q.Label)}
+}
+
+// nodeRemoval accumulates quads to remove.
+type nodeRemoval struct {
+	qs    QuadStore
+	label refs.ValueHash
+	out   NodeRemoval
+	nodes map[refs.ValueHash]struct{}
+	quads map[quadKey]struct{}
+}
+
+// forEachQuad calls fn for each quad that uses a value in any direction.
+func (r *nodeRemoval) forEachQuad(ctx context.Context, v quad.Value, fn func(q quad.Quad)) error {
+	return r.forEachQuadIn(ctx, v, quad.Directions, fn)
+}
+
+func (r *nodeRemoval) forEachQuadIn(ctx context.Context, v quad.Value, dirs []quad.Direction, fn func(q quad.Quad)) error {
+	ref, err := r.qs.ValueOf(v)
+	if err != nil {
+		return err
+	} else if ref == nil {
+		return nil
+	}
+	for _, d := range dirs {
+		it := r.qs.QuadIterator(d, ref).Iterate()
+		for it.Next(ctx) {
+			q, err := r.qs.Quad(it.Result())
+			if err != nil {
+				it.Close()
+				return err
+			}
+			fn(q)
+		}
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addNode adds a node and all quads that use it to the removal set.
+func (r *nodeRemoval) addNode(ctx context.Context, v quad.Value) error {
+	r.nodes[refs.HashOf(v)] = struct{}{}
+	r.out.Nodes = append(r.out.Nodes, v)
+	return r.forEachQuad(ctx, v, func(q quad.Quad) {
+		if r.label.Valid() && refs.HashOf(q.Label) != r.label {
+			return
+		}
+		k := keyOfQuad(q)
+		if _, ok := r.quads[k]; ok {
+			return
+		}
+		r.quads[k] = struct{}{}
+		r.out.Quads = append(r.out.Quads, q)
+	})
+}
+
+// owned checks if a blank node is only referenced as an object by quads in the removal set.
+func (r *nodeRemoval) owned(ctx context.Context, v quad.Value) (bool, error) {
+	ok := true
+	err := r.forEachQuadIn(ctx, v, []quad.Direction{quad.Object}, func(q quad.Quad) {
+		if _, removed := r.quads[keyOfQuad(q)]; !removed {
+			ok = false
+		}
+	})
+	return ok, err
+}
+
+// cascade adds owned blank nodes to the removal set until there are no more of them.
+func (r *nodeRemoval) cascade(ctx context.Context) error {
+	for {
+		added := false
+		// new quads are appended during the loop; they will be checked on the next pass
+		for _, q := range r.out.Quads {
+			if _, ok := q.Object.(quad.BNode); !ok {
+				continue
+			} else if _, ok = r.nodes[refs.HashOf(q.Object)]; ok {
+				continue
+			}
+			ok, err := r.owned(ctx, q.Object)
+			if err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+			if err = r.addNode(ctx, q.Object); err != nil {
+				return err
+			}
+			added = true
+		}
+		if !added {
+			return nil
+		}
+	}
+}
+
+// PlanNodeRemoval finds all quads that must be removed to remove a node with given options.
+//
+// It returns ErrNodeNotExists if there are no such quads.
+func PlanNodeRemoval(ctx context.Context, qs QuadStore, v quad.Value, opts RemoveNodeOptions) (*NodeRemoval, error) {
+	r := &nodeRemoval{
+		qs:    qs,
+		nodes: make(map[refs.ValueHash]struct{}),
+		quads: make(map[quadKey]struct{}),
+	}
+	if opts.Label != nil {
+		r.label = refs.HashOf(opts.Label)
+	}
+	if err := r.addNode(ctx, v); err != nil {
+		return nil, err
+	}
+	if opts.Cascade {
+		if err := r.cascade(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.out.Quads) == 0 {
+		return nil, ErrNodeNotExists
+	}
+	return &r.out, nil
+}
+
+// NodeRemover is an optional interface for quad writers that can find and remove quads of a node
+// atomically, so concurrent writes cannot change the set of removed quads.
+type NodeRemover interface {
+	// RemoveNodeWith removes a node and all quads that use it. See Handle.RemoveNodeWith.
+	// Quads are found in a given quad store, which must be the store of the writer or a view of it.
+	RemoveNodeWith(ctx context.Context, qs QuadStore, v quad.Value, opts RemoveNodeOptions) (*NodeRemoval, error)
+}
+
+// RemoveNodeWith removes a node and all quads that use it in a single transaction.
+// See RemoveNodeOptions for details. It returns removed nodes and quads.
+//
+// If the writer does not implement NodeRemover, removed quads are found before the transaction.
+// In this case, the transaction requires all of them to still exist, if the quad store supports preconditions.
+//
+// It returns ErrNodeNotExists if there is nothing to remove.
+func (h *Handle) RemoveNodeWith(ctx context.Context, v quad.Value, opts RemoveNodeOptions) (*NodeRemoval, error) {
+	if r, ok := h.QuadWriter.(NodeRemover); ok {
+		return r.RemoveNodeWith(ctx, h.QuadStore, v, opts)
+	}
+	rm, err := PlanNodeRemoval(ctx, PrimaryOf(h.QuadStore), v, opts)
+	if err != nil || opts.DryRun {
+		return rm, err
+	}
+	_, conds := Unwrap(h.QuadStore).(ConditionalApplier)
+	tx := NewTransactionN(len(rm.Quads))
+	for _, q := range rm.Quads {
+		if conds {
+			tx.RequireQuad(q)
+		}
+		tx.RemoveQuad(q)
+	}
+	if err = h.ApplyTransaction(tx); err != nil {
+		return nil, err
+	}
+	return rm, nil
+}
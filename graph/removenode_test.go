@@ -0,0 +1,69 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphmock"
+	"github.com/cayleygraph/quad"
+)
+
+func TestPlanNodeRemoval(t *testing.T) {
+	var (
+		bob     = quad.IRI("bob")
+		alice   = quad.IRI("alice")
+		addr    = quad.BNode("addr")
+		geo     = quad.BNode("geo")
+		shared  = quad.BNode("shared")
+		private = quad.IRI("private")
+	)
+	qs := &graphmock.Store{Data: []quad.Quad{
+		quad.Make(bob, "name", "Bob", nil),
+		quad.Make(bob, "address", addr, nil),
+		quad.Make(addr, "city", "Paris", nil),
+		quad.Make(addr, "geo", geo, nil),
+		quad.Make(geo, "lat", 48.85, nil),
+		quad.Make(bob, "tag", shared, nil),
+		quad.Make(alice, "tag", shared, nil),
+		quad.Make(shared, "name", "friends", nil),
+		quad.Make(alice, "follows", bob, nil),
+		quad.Make(bob, "secret", "x", private),
+	}}
+	ctx := context.TODO()
+
+	rm, err := graph.PlanNodeRemoval(ctx, qs, bob, graph.RemoveNodeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{bob}, rm.Nodes)
+	require.ElementsMatch(t, []quad.Quad{
+		quad.Make(bob, "name", "Bob", nil),
+		quad.Make(bob, "address", addr, nil),
+		quad.Make(bob, "tag", shared, nil),
+		quad.Make(bob, "secret", "x", private),
+		quad.Make(alice, "follows", bob, nil),
+	}, rm.Quads)
+
+	// shared blank node is referenced by alice, so it is not owned by bob
+	rm, err = graph.PlanNodeRemoval(ctx, qs, bob, graph.RemoveNodeOptions{Cascade: true})
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{bob, addr, geo}, rm.Nodes)
+	require.ElementsMatch(t, []quad.Quad{
+		quad.Make(bob, "name", "Bob", nil),
+		quad.Make(bob, "address", addr, nil),
+		quad.Make(addr, "city", "Paris", nil),
+		quad.Make(addr, "geo", geo, nil),
+		quad.Make(geo, "lat", 48.85, nil),
+		quad.Make(bob, "tag", shared, nil),
+		quad.Make(bob, "secret", "x", private),
+		quad.Make(alice, "follows", bob, nil),
+	}, rm.Quads)
+
+	rm, err = graph.PlanNodeRemoval(ctx, qs, bob, graph.RemoveNodeOptions{Cascade: true, Label: private})
+	require.NoError(t, err)
+	require.Equal(t, []quad.Quad{quad.Make(bob, "secret", "x", private)}, rm.Quads)
+
+	_, err = graph.PlanNodeRemoval(ctx, qs, quad.IRI("carol"), graph.RemoveNodeOptions{})
+	require.Equal(t, graph.ErrNodeNotExists, err)
+}
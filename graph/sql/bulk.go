@@ -0,0 +1,373 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/refs"
+)
+
+const (
+	nodesLoadTable = "nodes_load"
+	quadsLoadTable = "quads_load"
+
+	// maxBulkParams is the maximal number of placeholders in a single multi-row INSERT.
+	// This is the lowest limit across supported databases (SQLite).
+	maxBulkParams = 999
+)
+
+// ErrBulkVersioned is returned when bulk loading into a versioned database. The bulk loader writes directly
+// to the tables, thus it cannot record the history of changes.
+var ErrBulkVersioned = errors.New("sql: bulk loading is not supported for versioned databases")
+
+// ErrBulkLabels is returned when bulk loading quads that only differ by the label into a database without
+// conditional indexes, which can only store one label per triple.
+var ErrBulkLabels = errors.New("sql: bulk load has quads that only differ by the label")
+
+var quadsLoadColumns = []string{
+	"subject_hash",
+	"predicate_hash",
+	"object_hash",
+	"label_hash",
+	"ts",
+}
+
+var _ graph.BulkLoader = (*QuadStore)(nil)
+
+// NewBulkWriter starts a bulk import of quads.
+//
+// Quads and nodes are first written to staging tables using COPY FROM or multi-row INSERT statements,
+// and are merged into the main tables when the writer is closed. Secondary quad indexes are dropped
+// for the duration of the load and are rebuilt on Close, thus queries will be slower until the load finishes.
+//
+// Quads are not written through a quad writer, so they are not published to change feeds and followers.
+// It returns ErrBulkVersioned for versioned databases.
+func (qs *QuadStore) NewBulkWriter() (quad.WriteCloser, error) {
+	if qs.versioned {
+		return nil, ErrBulkVersioned
+	}
+	ctx := context.Background()
+	conn, err := qs.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w := &bulkWriter{qs: qs, conn: conn}
+	if err = w.begin(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+type bulkWriter struct {
+	qs   *QuadStore
+	conn *sql.Conn
+	err  error
+}
+
+func (w *bulkWriter) exec(ctx context.Context, qu string, args ...interface{}) error {
+	_, err := w.conn.ExecContext(ctx, qu, args...)
+	if err != nil {
+		err = w.qs.flavor.Error(err)
+		clog.Errorf("bulk load: %v\nquery: %v", err, qu)
+	}
+	return err
+}
+
+func (w *bulkWriter) dropStaging(ctx context.Context) error {
+	for _, t := range []string{nodesLoadTable, quadsLoadTable} {
+		if err := w.exec(ctx, `DROP TABLE IF EXISTS `+t+`;`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *bulkWriter) begin(ctx context.Context) error {
+	// tables may be left from the previous failed load
+	if err := w.dropStaging(ctx); err != nil {
+		return err
+	}
+	fl := w.qs.flavor
+	if err := w.exec(ctx, fl.nodesLoadTable()); err != nil {
+		return err
+	}
+	if err := w.exec(ctx, fl.quadsLoadTable()); err != nil {
+		return err
+	}
+	names, _ := fl.secondaryIndexes(w.qs.options)
+	for _, name := range names {
+		// index might be already dropped by the previous failed load
+		if err := w.exec(ctx, fl.dropIndex(name)); err != nil {
+			clog.Warningf("bulk load: cannot drop index %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (w *bulkWriter) WriteQuad(q quad.Quad) error {
+	_, err := w.WriteQuads([]quad.Quad{q})
+	return err
+}
+
+func (w *bulkWriter) WriteQuads(buf []quad.Quad) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	var (
+		seen  = make(map[refs.ValueHash]struct{})
+		nodes [][]interface{}
+		quads = make([][]interface{}, 0, len(buf))
+	)
+	for _, q := range buf {
+		if !q.IsValid() {
+			continue
+		}
+		row := make([]interface{}, 0, len(quadsLoadColumns))
+		for _, d := range quad.Directions {
+			v := q.Get(d)
+			h := HashOf(v)
+			row = append(row, h.SQLValue())
+			if v == nil {
+				continue
+			}
+			if _, ok := seen[h.ValueHash]; ok {
+				continue
+			}
+			seen[h.ValueHash] = struct{}{}
+			nrow, err := nodeLoadValues(h, v)
+			if err != nil {
+				w.err = err
+				return 0, err
+			}
+			nodes = append(nodes, nrow)
+		}
+		row = append(row, now)
+		quads = append(quads, row)
+	}
+	if err := w.insert(ctx, nodesLoadTable, nodesColumns, nodes); err != nil {
+		w.err = err
+		return 0, err
+	}
+	if err := w.insert(ctx, quadsLoadTable, quadsLoadColumns, quads); err != nil {
+		w.err = err
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+// nodeLoadValues returns a row for a nodes staging table. Columns are the same as in nodesColumns.
+func nodeLoadValues(h NodeHash, v quad.Value) ([]interface{}, error) {
+	typ, values, err := NodeValues(h, v)
+	if err != nil {
+		return nil, err
+	}
+	row := make([]interface{}, len(nodesColumns))
+	row[0] = values[0]
+	for i, name := range typ.Columns() {
+		for j, col := range nodesColumns {
+			if col == name {
+				row[j] = values[i+1]
+				break
+			}
+		}
+	}
+	return row, nil
+}
+
+func (w *bulkWriter) insert(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if f := w.qs.flavor.BulkInsert; f != nil {
+		return f(ctx, w.conn, table, columns, rows)
+	}
+	return InsertRows(ctx, w.conn, w.qs.flavor.Placeholder, table, columns, rows)
+}
+
+// InsertRows inserts rows into the table using multi-row INSERT statements.
+func InsertRows(ctx context.Context, conn *sql.Conn, placeholder func(int) string, table string, columns []string, rows [][]interface{}) error {
+	per := maxBulkParams / len(columns)
+	prefix := `INSERT INTO ` + table + `(` + strings.Join(columns, ", ") + `) VALUES `
+	for len(rows) > 0 {
+		batch := rows
+		if len(batch) > per {
+			batch = batch[:per]
+		}
+		rows = rows[len(batch):]
+
+		var (
+			sb   strings.Builder
+			args = make([]interface{}, 0, len(batch)*len(columns))
+		)
+		sb.WriteString(prefix)
+		for i, row := range batch {
+			if i != 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(")
+			for j := range row {
+				if j != 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(placeholder(len(args) + 1))
+				args = append(args, row[j])
+			}
+			sb.WriteString(")")
+		}
+		sb.WriteString(";")
+		if _, err := conn.ExecContext(ctx, sb.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge moves nodes and quads from the staging tables to the main ones.
+func (w *bulkWriter) merge(ctx context.Context) error {
+	tx, err := w.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	cols := strings.Join(nodesColumns, ", ")
+	_, err = tx.ExecContext(ctx, `INSERT INTO nodes(refs, `+cols+`)
+	SELECT DISTINCT 0, `+cols+` FROM `+nodesLoadTable+` AS l
+	WHERE NOT EXISTS (SELECT 1 FROM nodes AS n WHERE n.hash = l.hash);`)
+	if err != nil {
+		tx.Rollback()
+		return w.qs.flavor.Error(err)
+	}
+	// unique indexes are defined differently, depending on the support of conditional indexes
+	var qu string
+	if w.qs.flavor.ConditionalIndexes {
+		qu = `INSERT INTO quads(subject_hash, predicate_hash, object_hash, label_hash, ts)
+	SELECT l.subject_hash, l.predicate_hash, l.object_hash, l.label_hash, MAX(l.ts) FROM ` + quadsLoadTable + ` AS l
+	WHERE NOT EXISTS (SELECT 1 FROM quads AS q WHERE
+		q.subject_hash = l.subject_hash AND q.predicate_hash = l.predicate_hash AND q.object_hash = l.object_hash AND
+		(q.label_hash = l.label_hash OR (q.label_hash IS NULL AND l.label_hash IS NULL)))
+	GROUP BY l.subject_hash, l.predicate_hash, l.object_hash, l.label_hash;`
+	} else {
+		// a triple can only have one label, the same as with regular writes
+		if err = w.checkLabels(ctx, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		qu = `INSERT INTO quads(subject_hash, predicate_hash, object_hash, label_hash, ts)
+	SELECT l.subject_hash, l.predicate_hash, l.object_hash, l.label_hash, MAX(l.ts) FROM ` + quadsLoadTable + ` AS l
+	WHERE NOT EXISTS (SELECT 1 FROM quads AS q WHERE
+		q.subject_hash = l.subject_hash AND q.predicate_hash = l.predicate_hash AND q.object_hash = l.object_hash)
+	GROUP BY l.subject_hash, l.predicate_hash, l.object_hash, l.label_hash;`
+	}
+	_, err = tx.ExecContext(ctx, qu)
+	if err != nil {
+		tx.Rollback()
+		return w.qs.flavor.Error(err)
+	}
+	committed, err := w.qs.replicas.onWrite(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	committed()
+	return nil
+}
+
+// checkLabels fails the load if it has quads that differ from each other or from existing quads only by the label.
+// It must be used for databases without conditional indexes, where unique indexes do not include labels.
+func (w *bulkWriter) checkLabels(ctx context.Context, tx *sql.Tx) error {
+	const spoEqual = `q.subject_hash = l.subject_hash AND q.predicate_hash = l.predicate_hash AND q.object_hash = l.object_hash`
+	var n int64
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM (
+	SELECT subject_hash, predicate_hash, object_hash FROM (
+		SELECT DISTINCT subject_hash, predicate_hash, object_hash, label_hash FROM `+quadsLoadTable+`
+	) AS l GROUP BY subject_hash, predicate_hash, object_hash HAVING COUNT(*) > 1
+) AS c;`).Scan(&n)
+	if err != nil {
+		return w.qs.flavor.Error(err)
+	}
+	if n == 0 {
+		err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+quadsLoadTable+` AS l
+	WHERE EXISTS (SELECT 1 FROM quads AS q WHERE `+spoEqual+`)
+	AND NOT EXISTS (SELECT 1 FROM quads AS q WHERE `+spoEqual+` AND
+		(q.label_hash = l.label_hash OR (q.label_hash IS NULL AND l.label_hash IS NULL)));`).Scan(&n)
+		if err != nil {
+			return w.qs.flavor.Error(err)
+		}
+	}
+	if n != 0 {
+		return fmt.Errorf("%w: %d quads have the same subject, predicate and object as other quads", ErrBulkLabels, n)
+	}
+	return nil
+}
+
+// updateRefs recalculates reference counters for all loaded nodes.
+func (w *bulkWriter) updateRefs(ctx context.Context) error {
+	tx, err := w.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var counts []string
+	for _, d := range []quad.Direction{quad.Subject, quad.Predicate, quad.Object} {
+		counts = append(counts, `(SELECT COUNT(*) FROM quads WHERE `+dirField(d)+` = nodes.hash)`)
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE nodes SET refs = `+strings.Join(counts, " + ")+`
+	WHERE hash IN (SELECT hash FROM `+nodesLoadTable+`);`)
+	if err != nil {
+		tx.Rollback()
+		return w.qs.flavor.Error(err)
+	}
+	// there is no index for labels, so run it only for nodes that are used as labels
+	_, err = tx.ExecContext(ctx, `UPDATE nodes SET refs = refs + (SELECT COUNT(*) FROM quads WHERE label_hash = nodes.hash)
+	WHERE hash IN (SELECT hash FROM `+nodesLoadTable+`) AND hash IN (SELECT label_hash FROM quads);`)
+	if err != nil {
+		tx.Rollback()
+		return w.qs.flavor.Error(err)
+	}
+	return tx.Commit()
+}
+
+func (w *bulkWriter) Close() error {
+	if w.conn == nil {
+		return w.err
+	}
+	defer func() {
+		w.conn.Close()
+		w.conn = nil
+	}()
+	ctx := context.Background()
+	if w.err == nil {
+		w.err = w.merge(ctx)
+	}
+	// always restore indexes, even if the load failed
+	_, indexes := w.qs.flavor.secondaryIndexes(w.qs.options)
+	for _, index := range indexes {
+		if err := w.exec(ctx, index); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	if w.err == nil {
+		w.err = w.updateRefs(ctx)
+	}
+	if err := w.dropStaging(ctx); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.qs.mu.Lock()
+	w.qs.quads = -1
+	w.qs.nodes = -1
+	w.qs.dirs = [4]*dirStats{}
+	w.qs.mu.Unlock()
+	return w.err
+}
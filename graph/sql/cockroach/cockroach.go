@@ -2,6 +2,7 @@ package cockroach
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -9,7 +10,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	_ "github.com/jackc/pgx/v5/stdlib" // registers "pgx" driver
+	"github.com/jackc/pgx/v5/stdlib" // registers "pgx" driver
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
@@ -49,6 +50,19 @@ func init() {
 		RunTx:               runTxCockroach,
 		TxRetry:             retryTxCockroach,
 		NoSchemaChangesInTx: true,
+		BulkInsert:          copyFrom,
+	})
+}
+
+// copyFrom inserts rows into the table using COPY FROM.
+func copyFrom(ctx context.Context, conn *sql.Conn, table string, columns []string, rows [][]interface{}) error {
+	return conn.Raw(func(dc interface{}) error {
+		c, ok := dc.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection: %T", dc)
+		}
+		_, err := c.Conn().CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows))
+		return err
 	})
 }
 
@@ -116,6 +130,8 @@ func convError(err error) error {
 	switch e.Code {
 	case "42P07":
 		return graph.ErrDatabaseExists
+	case "42P01":
+		return csql.ErrNoTable
 	}
 	return err
 }
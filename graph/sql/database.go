@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -18,10 +19,18 @@ func Register(name string, f Registration) {
 		panic("no sql driver in type definition")
 	}
 	types[name] = f
+	for _, m := range commonMigrations {
+		RegisterMigration(name, m)
+	}
 
 	registerQuadStore(name, name)
 }
 
+// Driver returns a name of SQL driver for a given database type.
+func Driver(typ string) string {
+	return types[typ].Driver
+}
+
 type Registration struct {
 	Driver             string // sql driver to use on dial
 	HashType           string // type for hash fields
@@ -33,18 +42,34 @@ type Registration struct {
 	FillFactor         bool   // database supports fill percent on indexes
 	NoForeignKeys      bool   // database has no support for FKs
 	CustomNullTime     bool   // driver doesn't support sql.NullTime
+	DropIndexOnTable   bool   // DROP INDEX requires ON clause with a table name
+	HorizonSequence    bool   // database has no SERIAL type; horizon uses a sequence instead
 
 	QueryDialect
 	NoOffsetWithoutLimit bool // SELECT ... OFFSET can be used only with LIMIT
 
 	Error               func(error) error         // error conversion function
 	Estimated           func(table string) string // query that string that returns an estimated number of rows in table
+	ApproxDistinct      func(field string) string // aggregate that estimates the number of distinct values of a field
 	RunTx               func(tx *sql.Tx, nodes []graphlog.NodeUpdate, quads []graphlog.QuadUpdate, opts graph.IgnoreOpts) error
 	TxRetry             func(tx *sql.Tx, stmts func() error) error
 	NoSchemaChangesInTx bool
+
+	// CondIsolation is an isolation level for write transactions with preconditions.
+	// It must prevent other transactions from changing the data read by preconditions. Default level is used if not set.
+	CondIsolation sql.IsolationLevel
+
+	// BulkInsert is an optional function that inserts a large number of rows into a table,
+	// for example with COPY FROM. Multi-row INSERT is used if it's not set.
+	BulkInsert func(ctx context.Context, conn *sql.Conn, table string, columns []string, rows [][]interface{}) error
 }
 
 func (r Registration) nodesTable() string {
+	return r.nodesTableAs("nodes")
+}
+
+// nodesTableAs returns a definition of a table with the same columns as the nodes table.
+func (r Registration) nodesTableAs(name string) string {
 	htyp := r.HashType
 	if htyp == "" {
 		htyp = "BYTEA"
@@ -61,7 +86,7 @@ func (r Registration) nodesTable() string {
 	if r.NodesTableExtra != "" {
 		end = ",\n" + r.NodesTableExtra + end
 	}
-	return `CREATE TABLE nodes (
+	return `CREATE TABLE ` + name + ` (
 	hash ` + htyp + ` PRIMARY KEY,
 	refs INT NOT NULL,
 	value ` + btyp + `,
@@ -86,6 +111,9 @@ func (r Registration) quadsTable() string {
 	if hztyp == "" {
 		hztyp = "SERIAL"
 	}
+	if r.HorizonSequence {
+		hztyp += ` DEFAULT nextval('` + horizonSequence + `')`
+	}
 	return `CREATE TABLE quads (
 	horizon ` + hztyp + ` PRIMARY KEY,
 	subject_hash ` + htyp + ` NOT NULL,
@@ -96,6 +124,16 @@ func (r Registration) quadsTable() string {
 );`
 }
 
+const horizonSequence = "quads_horizon"
+
+// schemaPrelude returns statements that must be executed before creating tables.
+func (r Registration) schemaPrelude() []string {
+	if r.HorizonSequence {
+		return []string{`CREATE SEQUENCE ` + horizonSequence + `;`}
+	}
+	return nil
+}
+
 func (r Registration) quadIndexes(options graph.Options) []string {
 	indexes := make([]string, 0, 10)
 	if r.ConditionalIndexes {
@@ -117,6 +155,13 @@ func (r Registration) quadIndexes(options graph.Options) []string {
 			`ALTER TABLE quads ADD CONSTRAINT label_hash_fk FOREIGN KEY (label_hash) REFERENCES nodes (hash);`,
 		)
 	}
+	_, secondary := r.secondaryIndexes(options)
+	indexes = append(indexes, secondary...)
+	return indexes
+}
+
+// secondaryIndexes returns names and definitions of quad indexes that are not required for constraints.
+func (r Registration) secondaryIndexes(options graph.Options) ([]string, []string) {
 	quadIndexes := [][3]quad.Direction{
 		{quad.Subject, quad.Predicate, quad.Object},
 		{quad.Object, quad.Predicate, quad.Subject},
@@ -124,6 +169,7 @@ func (r Registration) quadIndexes(options graph.Options) []string {
 		{quad.Object, quad.Subject, quad.Predicate},
 	}
 	factor, _ := options.IntKey("db_fill_factor", 50)
+	var names, indexes []string
 	for _, ind := range quadIndexes {
 		var (
 			name string
@@ -133,12 +179,67 @@ func (r Registration) quadIndexes(options graph.Options) []string {
 			name += string(d.Prefix())
 			cols = append(cols, d.String()+"_hash")
 		}
-		q := fmt.Sprintf(`CREATE INDEX %s_index ON quads (%s)`,
+		name += "_index"
+		q := fmt.Sprintf(`CREATE INDEX %s ON quads (%s)`,
 			name, strings.Join(cols, ", "))
 		if r.FillFactor {
 			q += fmt.Sprintf(" WITH (FILLFACTOR = %d)", factor)
 		}
+		names = append(names, name)
 		indexes = append(indexes, q+";")
 	}
-	return indexes
+	return names, indexes
+}
+
+func (r Registration) dropIndex(name string) string {
+	if r.DropIndexOnTable {
+		return `DROP INDEX ` + name + ` ON quads;`
+	}
+	return `DROP INDEX IF EXISTS ` + name + `;`
+}
+
+// nodesLoadTable returns a definition of a staging table for bulk loading nodes.
+// Nodes are not unique in this table.
+func (r Registration) nodesLoadTable() string {
+	htyp := r.HashType
+	if htyp == "" {
+		htyp = "BYTEA"
+	}
+	btyp := r.BytesType
+	if btyp == "" {
+		btyp = "BYTEA"
+	}
+	ttyp := r.TimeType
+	if ttyp == "" {
+		ttyp = "timestamp with time zone"
+	}
+	return `CREATE TABLE ` + nodesLoadTable + ` (
+	hash ` + htyp + ` NOT NULL,
+	value ` + btyp + `,
+	value_string TEXT,
+	datatype TEXT,
+	language TEXT,
+	iri BOOLEAN,
+	bnode BOOLEAN,
+	value_int BIGINT,
+	value_bool BOOLEAN,
+	value_float double precision,
+	value_time ` + ttyp + `
+);`
+}
+
+// quadsLoadTable returns a definition of a staging table for bulk loading quads.
+// Quads are not unique in this table.
+func (r Registration) quadsLoadTable() string {
+	htyp := r.HashType
+	if htyp == "" {
+		htyp = "BYTEA"
+	}
+	return `CREATE TABLE ` + quadsLoadTable + ` (
+	subject_hash ` + htyp + ` NOT NULL,
+	predicate_hash ` + htyp + ` NOT NULL,
+	object_hash ` + htyp + ` NOT NULL,
+	label_hash ` + htyp + `,
+	ts timestamp
+);`
 }
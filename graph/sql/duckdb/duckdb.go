@@ -0,0 +1,187 @@
+//go:build cgo
+
+// Package duckdb registers an embedded DuckDB backend for the SQL quad store.
+//
+// DuckDB is an in-process columnar database optimized for analytical queries,
+// thus it's well suited for large read-heavy graph scans that don't require a separate database service.
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/quad"
+	"github.com/marcboeker/go-duckdb"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	graphlog "github.com/cayleygraph/cayley/graph/log"
+	csql "github.com/cayleygraph/cayley/graph/sql"
+)
+
+const Type = "duckdb"
+
+var QueryDialect = csql.QueryDialect{
+	// DuckDB has no partial regexp match operator (~ matches the whole string),
+	// but it has a function with RE2 syntax, which is the same as in Go.
+	RegexpFunc: "regexp_matches",
+	FieldQuote: func(name string) string {
+		return strconv.Quote(name)
+	},
+	Placeholder: func(n int) string {
+		return "$" + strconv.Itoa(n)
+	},
+}
+
+func init() {
+	csql.Register(Type, csql.Registration{
+		Driver:          "duckdb",
+		HashType:        `BLOB`,
+		BytesType:       `BLOB`,
+		HorizonType:     `BIGINT`,
+		HorizonSequence: true,
+		TimeType:        `TIMESTAMP`,
+		QueryDialect:    QueryDialect,
+		// DuckDB doesn't allow to add constraints to existing tables
+		NoForeignKeys: true,
+		Error:         ConvError,
+		Estimated: func(table string) string {
+			return "SELECT estimated_size FROM duckdb_tables() WHERE table_name = '" + table + "';"
+		},
+		// HyperLogLog estimate, which is much cheaper than COUNT(DISTINCT) on large tables
+		ApproxDistinct: func(field string) string {
+			return "approx_count_distinct(" + field + ")"
+		},
+		RunTx:      RunTx,
+		BulkInsert: AppendRows,
+	})
+}
+
+// AppendRows inserts rows into the table using the DuckDB appender, which writes them directly to columnar storage.
+// Columns must be listed in the order of the table definition.
+func AppendRows(ctx context.Context, conn *sql.Conn, table string, columns []string, rows [][]interface{}) error {
+	return conn.Raw(func(dc interface{}) error {
+		a, err := duckdb.NewAppenderFromConn(dc.(driver.Conn), "", table)
+		if err != nil {
+			return err
+		}
+		row := make([]driver.Value, len(columns))
+		for _, r := range rows {
+			for i, v := range r {
+				row[i] = v
+			}
+			if err = a.AppendRow(row...); err != nil {
+				a.Close()
+				clog.Errorf("couldn't append a row: %v", err)
+				return err
+			}
+		}
+		return a.Close()
+	})
+}
+
+func ConvError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "Catalog Error") && strings.Contains(msg, "already exists") {
+		return graph.ErrDatabaseExists
+	} else if strings.Contains(msg, "Catalog Error") && strings.Contains(msg, "does not exist") {
+		return csql.ErrNoTable
+	}
+	return err
+}
+
+func convInsertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "Constraint Error") || strings.Contains(msg, "Duplicate key") {
+		return &graph.DeltaError{Err: graph.ErrQuadExists}
+	}
+	return err
+}
+
+func RunTx(tx *sql.Tx, nodes []graphlog.NodeUpdate, quads []graphlog.QuadUpdate, opts graph.IgnoreOpts) error {
+	// update node ref counts and insert nodes
+	// prepared statements for each value type
+	insertValue := make(map[csql.ValueType]*sql.Stmt)
+	for _, n := range nodes {
+		if n.RefInc >= 0 {
+			nodeKey, values, err := csql.NodeValues(csql.NodeHash{ValueHash: n.Hash}, n.Val)
+			if err != nil {
+				return err
+			}
+			values = append([]interface{}{n.RefInc}, values...)
+			stmt, ok := insertValue[nodeKey]
+			if !ok {
+				var ph = make([]string, len(values))
+				for i := range ph {
+					ph[i] = QueryDialect.Placeholder(i + 1)
+				}
+				stmt, err = tx.Prepare(`INSERT INTO nodes(refs, hash, ` +
+					strings.Join(nodeKey.Columns(), ", ") +
+					`) VALUES (` + strings.Join(ph, ", ") +
+					`) ON CONFLICT (hash) DO UPDATE SET refs = nodes.refs + EXCLUDED.refs;`)
+				if err != nil {
+					return err
+				}
+				insertValue[nodeKey] = stmt
+			}
+			_, err = stmt.Exec(values...)
+			err = convInsertError(err)
+			if err != nil {
+				clog.Errorf("couldn't exec INSERT statement: %v", err)
+				return err
+			}
+		} else {
+			panic("unexpected node update")
+		}
+	}
+	for _, s := range insertValue {
+		s.Close()
+	}
+	insertValue = nil
+
+	// now we can deal with quads
+	ignore := ""
+	if opts.IgnoreDup {
+		ignore = " OR IGNORE"
+	}
+
+	var (
+		insertQuad *sql.Stmt
+		err        error
+	)
+	for _, d := range quads {
+		dirs := make([]interface{}, 0, len(quad.Directions))
+		for _, h := range d.Quad.Dirs() {
+			dirs = append(dirs, csql.NodeHash{ValueHash: h}.SQLValue())
+		}
+		if !d.Del {
+			if insertQuad == nil {
+				insertQuad, err = tx.Prepare(`INSERT` + ignore + ` INTO quads(subject_hash, predicate_hash, object_hash, label_hash, ts) VALUES ($1, $2, $3, $4, now());`)
+				if err != nil {
+					return err
+				}
+				defer insertQuad.Close()
+			}
+			_, err := insertQuad.Exec(dirs...)
+			err = convInsertError(err)
+			if err != nil {
+				if _, ok := err.(*graph.DeltaError); !ok {
+					clog.Errorf("couldn't exec INSERT statement: %v", err)
+				}
+				return err
+			}
+		} else {
+			panic("unexpected quad delete")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+//go:build cgo
+
+package duckdb
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cayleygraph/quad"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	csql "github.com/cayleygraph/cayley/graph/sql"
+	"github.com/cayleygraph/cayley/graph/sql/sqltest"
+	"github.com/cayleygraph/cayley/query/shape"
+)
+
+func makeDuckDB(t testing.TB) (string, graph.Options) {
+	return filepath.Join(t.TempDir(), "cayley_test_"+Type+".db"), nil
+}
+
+var conf = &sqltest.Config{
+	// timestamps are truncated to microseconds
+	TimeRound: false,
+	TimeInMcs: true,
+}
+
+func TestDuckDB(t *testing.T) {
+	sqltest.TestAll(t, Type, makeDuckDB, conf)
+}
+
+func BenchmarkDuckDB(t *testing.B) {
+	sqltest.BenchmarkAll(t, Type, makeDuckDB, conf)
+}
+
+func newDuckDB(t *testing.T) (string, graph.QuadStore) {
+	addr, _ := makeDuckDB(t)
+	require.NoError(t, csql.Init(Type, addr, nil))
+	qs, err := csql.New(Type, addr, nil)
+	require.NoError(t, err)
+	return addr, qs
+}
+
+var typedQuads = []quad.Quad{
+	quad.Make(quad.IRI("alice"), quad.IRI("name"), quad.String("Alice"), nil),
+	quad.Make(quad.IRI("alice"), quad.IRI("name"), quad.LangString{Value: "Алиса", Lang: "ru"}, nil),
+	quad.Make(quad.IRI("alice"), quad.IRI("age"), quad.Int(42), quad.IRI("graph")),
+	quad.Make(quad.IRI("alice"), quad.IRI("height"), quad.Float(1.75), nil),
+	quad.Make(quad.IRI("alice"), quad.IRI("admin"), quad.Bool(true), nil),
+	quad.Make(quad.IRI("alice"), quad.IRI("born"), quad.Time(time.Date(1990, 1, 2, 3, 4, 5, 0, time.UTC)), nil),
+	quad.Make(quad.BNode("b1"), quad.IRI("zip"), quad.TypedString{Value: "0123", Type: "xsd:string"}, nil),
+}
+
+func readAll(t *testing.T, qs graph.QuadStore) []quad.Quad {
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(qs))
+	require.NoError(t, err)
+	return got
+}
+
+func TestAppendRows(t *testing.T) {
+	_, qs := newDuckDB(t)
+	defer qs.Close()
+	// bulk writer stages rows with the appender
+	w, err := qs.(graph.BulkLoader).NewBulkWriter()
+	require.NoError(t, err)
+	_, err = w.WriteQuads(typedQuads)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.ElementsMatch(t, typedQuads, readAll(t, qs))
+}
+
+func TestRegexpPushdown(t *testing.T) {
+	_, qs := newDuckDB(t)
+	defer qs.Close()
+	w, err := qs.(graph.BulkLoader).NewBulkWriter()
+	require.NoError(t, err)
+	_, err = w.WriteQuads(typedQuads)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	ctx := context.TODO()
+	s := shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{shape.Regexp{Re: regexp.MustCompile(`lic`)}}}
+	opt, ok := qs.(shape.Optimizer).OptimizeShape(ctx, s)
+	require.True(t, ok)
+	sel, ok := opt.(csql.Select)
+	require.True(t, ok, "%T", opt)
+	require.Contains(t, sel.SQL(csql.NewBuilder(QueryDialect)), "regexp_matches(")
+
+	it := opt.BuildIterator(qs).Iterate()
+	defer it.Close()
+	var vals []quad.Value
+	for it.Next(ctx) {
+		v, err := qs.NameOf(it.Result())
+		require.NoError(t, err)
+		vals = append(vals, v)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []quad.Value{quad.String("Alice")}, vals)
+}
+
+func TestApproxDistinct(t *testing.T) {
+	_, qs := newDuckDB(t)
+	defer qs.Close()
+	w, err := qs.(graph.BulkLoader).NewBulkWriter()
+	require.NoError(t, err)
+	_, err = w.WriteQuads(typedQuads)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	ctx := context.TODO()
+	st := qs.(graph.Statistician)
+	est, err := st.DirectionStats(ctx, quad.Subject, false)
+	require.NoError(t, err)
+	require.False(t, est.Values.Exact)
+	require.InDelta(t, 2, est.Values.Value, 1)
+
+	// estimated distinct counts are not reused for exact stats
+	ex, err := st.DirectionStats(ctx, quad.Subject, true)
+	require.NoError(t, err)
+	require.True(t, ex.Values.Exact)
+	require.Equal(t, int64(2), ex.Values.Value)
+	require.Equal(t, int64(len(typedQuads)), ex.Quads.Value)
+}
+
+func TestParquet(t *testing.T) {
+	ctx := context.TODO()
+	addr, qs := newDuckDB(t)
+	w, err := qs.(graph.BulkLoader).NewBulkWriter()
+	require.NoError(t, err)
+	_, err = w.WriteQuads(typedQuads)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, qs.Close())
+
+	dir := t.TempDir()
+	require.NoError(t, ExportParquet(ctx, addr, dir))
+
+	addr2, _ := makeDuckDB(t)
+	require.NoError(t, csql.Init(Type, addr2, nil))
+	require.NoError(t, ImportParquet(ctx, addr2, dir))
+	// only empty databases can be imported into
+	require.Error(t, ImportParquet(ctx, addr2, dir))
+
+	qs, err = csql.New(Type, addr2, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	require.ElementsMatch(t, typedQuads, readAll(t, qs))
+	st, err := qs.Stats(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(typedQuads)), st.Quads.Value)
+
+	// imported data can be changed as usual
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: typedQuads[0], Action: graph.Delete}}, graph.IgnoreOpts{}))
+	require.ElementsMatch(t, typedQuads[1:], readAll(t, qs))
+}
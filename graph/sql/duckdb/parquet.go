@@ -0,0 +1,78 @@
+//go:build cgo
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	nodesParquet = "nodes.parquet"
+	quadsParquet = "quads.parquet"
+)
+
+// quoteString quotes a string literal for DuckDB.
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ExportParquet writes nodes and quads of a DuckDB database at a given address to nodes.parquet
+// and quads.parquet files in a given directory. The files can be loaded with ImportParquet,
+// or queried directly by other analytical tools.
+//
+// The database must not be opened by a quad store at the same time.
+func ExportParquet(ctx context.Context, addr, dir string) error {
+	db, err := sql.Open(Type, addr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, q := range []string{
+		`COPY nodes TO ` + quoteString(filepath.Join(dir, nodesParquet)) + ` (FORMAT PARQUET);`,
+		// horizons are assigned again on import, but the order of quads is preserved
+		`COPY (SELECT subject_hash, predicate_hash, object_hash, label_hash, ts FROM quads ORDER BY horizon) TO ` +
+			quoteString(filepath.Join(dir, quadsParquet)) + ` (FORMAT PARQUET);`,
+	} {
+		if _, err = db.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportParquet loads files written by ExportParquet from a given directory into an empty DuckDB database
+// at a given address. Files are scanned directly by DuckDB, without decoding the values on the Cayley side.
+//
+// The database must be initialized and must not be opened by a quad store at the same time.
+func ImportParquet(ctx context.Context, addr, dir string) error {
+	db, err := sql.Open(Type, addr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var n int64
+	if err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quads;`).Scan(&n); err != nil {
+		return ConvError(err)
+	} else if n != 0 {
+		return fmt.Errorf("duckdb: cannot import into a non-empty database")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`DELETE FROM nodes;`,
+		`INSERT INTO nodes BY NAME SELECT * FROM read_parquet(` + quoteString(filepath.Join(dir, nodesParquet)) + `);`,
+		`INSERT INTO quads BY NAME SELECT * FROM read_parquet(` + quoteString(filepath.Join(dir, quadsParquet)) + `);`,
+	} {
+		if _, err = tx.ExecContext(ctx, q); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
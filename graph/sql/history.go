@@ -0,0 +1,227 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+)
+
+// OptVersioned enables recording of the history of all changes. It can only be set when the database is initialized.
+const OptVersioned = "versioned"
+
+const (
+	metaVersioned     = "versioned"
+	quadsHistoryTable = "quads_history"
+	nodesHistoryTable = "nodes_history"
+)
+
+var errHorizonVersion = errors.New("sql: history can only be queried by time")
+
+var _ graph.Historian = (*QuadStore)(nil)
+
+// historyTables returns definitions of tables that keep deleted quads and nodes.
+func (r Registration) historyTables() []string {
+	htyp := r.HashType
+	if htyp == "" {
+		htyp = "BYTEA"
+	}
+	return []string{
+		r.nodesTableAs(nodesHistoryTable),
+		`CREATE TABLE ` + quadsHistoryTable + ` (
+	subject_hash ` + htyp + ` NOT NULL,
+	predicate_hash ` + htyp + ` NOT NULL,
+	object_hash ` + htyp + ` NOT NULL,
+	label_hash ` + htyp + `,
+	ts timestamp,
+	deleted_ts timestamp NOT NULL
+);`,
+		`CREATE INDEX quads_history_deleted_index ON ` + quadsHistoryTable + ` (deleted_ts);`,
+	}
+}
+
+// initHistory creates history tables and marks the database as versioned.
+func initHistory(ctx context.Context, conn *sql.DB, fl Registration) error {
+	var (
+		tx  Execer = conn
+		stx *sql.Tx
+		err error
+	)
+	if !fl.NoSchemaChangesInTx {
+		stx, err = conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		tx = stx
+	}
+	for _, q := range fl.historyTables() {
+		if _, err = tx.ExecContext(ctx, q); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = setMetaValue(ctx, tx, fl, metaVersioned, 1)
+	}
+	if err != nil {
+		if stx != nil {
+			stx.Rollback()
+		}
+		err = fl.Error(err)
+		clog.Errorf("Cannot create history tables: %v", err)
+		return err
+	}
+	if stx != nil {
+		return stx.Commit()
+	}
+	return nil
+}
+
+// isVersioned checks if the database records the history of changes.
+func isVersioned(ctx context.Context, conn *sql.DB, fl Registration) (bool, error) {
+	v, _, err := metaValue(ctx, conn, fl, metaVersioned)
+	return v != 0, err
+}
+
+// historyWriter moves deleted quads and nodes to history tables.
+type historyWriter struct {
+	tx           *sql.Tx
+	flavor       Registration
+	now          time.Time
+	deleteQuad   *sql.Stmt
+	deleteTriple *sql.Stmt
+}
+
+func newHistoryWriter(tx *sql.Tx, fl Registration) *historyWriter {
+	return &historyWriter{tx: tx, flavor: fl, now: time.Now().UTC()}
+}
+
+// deleted records a quad that is about to be deleted. Hashes must be in the same order as for the DELETE statement.
+func (w *historyWriter) deleted(dirs []interface{}) error {
+	if w.deleteQuad == nil {
+		p := make([]string, 5)
+		for i := range p {
+			p[i] = w.flavor.Placeholder(i + 1)
+		}
+		const cols = `subject_hash, predicate_hash, object_hash, label_hash, ts`
+		insert := `INSERT INTO ` + quadsHistoryTable + ` (` + cols + `, deleted_ts) SELECT ` + cols + `, ` + p[0] + ` FROM quads WHERE subject_hash=` + p[1] + ` and predicate_hash=` + p[2] + ` and object_hash=` + p[3]
+		var err error
+		w.deleteQuad, err = w.tx.Prepare(insert + ` and label_hash=` + p[4] + `;`)
+		if err != nil {
+			return err
+		}
+		w.deleteTriple, err = w.tx.Prepare(insert + ` and label_hash is null;`)
+		if err != nil {
+			return err
+		}
+	}
+	stmt := w.deleteQuad
+	if len(dirs) == 3 {
+		stmt = w.deleteTriple
+	}
+	_, err := stmt.Exec(append([]interface{}{w.now}, dirs...)...)
+	return err
+}
+
+// nodesDeleted records all nodes that are about to be deleted.
+func (w *historyWriter) nodesDeleted() error {
+	cols := "refs, " + strings.Join(nodesColumns, ", ")
+	_, err := w.tx.Exec(`DELETE FROM ` + nodesHistoryTable + ` WHERE hash IN (SELECT hash FROM nodes WHERE refs <= 0);`)
+	if err != nil {
+		return err
+	}
+	_, err = w.tx.Exec(`INSERT INTO ` + nodesHistoryTable + ` (` + cols + `) SELECT ` + cols + ` FROM nodes WHERE refs <= 0;`)
+	return err
+}
+
+// hashRow scans a node hash before value columns.
+type hashRow struct {
+	rows *sql.Rows
+	hash *NodeHash
+}
+
+func (r hashRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append([]interface{}{r.hash}, dest...)...)
+}
+
+// AsOf implements graph.Historian. It returns graph.ErrNoHistory if the database was not initialized
+// with the versioned option. Only time versions are supported, since deletes do not advance the horizon.
+//
+// The snapshot is built from quads and history tables and is loaded into memory.
+func (qs *QuadStore) AsOf(ctx context.Context, v graph.Version) (graph.QuadStore, error) {
+	if !qs.versioned {
+		return nil, graph.ErrNoHistory
+	} else if v.IsZero() {
+		return qs, nil
+	} else if v.Horizon != 0 {
+		return nil, errHorizonVersion
+	}
+	t := v.Time.UTC()
+	const quadCols = `subject_hash, predicate_hash, object_hash, label_hash`
+	// history is always read from the primary
+	rows, err := qs.db.QueryContext(ctx, `SELECT `+quadCols+` FROM quads WHERE ts <= `+qs.flavor.Placeholder(1)+`
+UNION ALL
+SELECT `+quadCols+` FROM `+quadsHistoryTable+` WHERE ts <= `+qs.flavor.Placeholder(2)+` AND deleted_ts > `+qs.flavor.Placeholder(3)+`;`, t, t, t)
+	if err != nil {
+		return nil, err
+	}
+	var hashes [][4]NodeHash
+	vals := make(map[NodeHash]quad.Value)
+	for rows.Next() {
+		var h [4]NodeHash
+		if err = rows.Scan(&h[0], &h[1], &h[2], &h[3]); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, n := range h {
+			if n.Valid() {
+				vals[n] = nil
+			}
+		}
+		hashes = append(hashes, h)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	nodeCols := strings.Join(nodesColumns, ", ")
+	rows, err = qs.db.QueryContext(ctx, `SELECT `+nodeCols+` FROM nodes
+UNION ALL
+SELECT `+nodeCols+` FROM `+nodesHistoryTable+`;`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var h NodeHash
+		val, err := qs.scanValue(hashRow{rows: rows, hash: &h})
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if _, ok := vals[h]; ok {
+			vals[h] = val
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	quads := make([]quad.Quad, 0, len(hashes))
+	for _, h := range hashes {
+		quads = append(quads, quad.Quad{
+			Subject:   vals[h[0]],
+			Predicate: vals[h[1]],
+			Object:    vals[h[2]],
+			Label:     vals[h[3]],
+		})
+	}
+	return memstore.NewSnapshot(qs, v, quads...), nil
+}
@@ -46,12 +46,12 @@ func (qs *QuadStore) prepareQuery(s Shape) (string, []interface{}) {
 
 func (qs *QuadStore) QueryRow(ctx context.Context, s Shape) *sql.Row {
 	qu, vals := qs.prepareQuery(s)
-	return qs.db.QueryRowContext(ctx, qu, vals...)
+	return qs.reader(ctx).QueryRowContext(ctx, qu, vals...)
 }
 
 func (qs *QuadStore) Query(ctx context.Context, s Shape) (*sql.Rows, error) {
 	qu, vals := qs.prepareQuery(s)
-	rows, err := qs.db.QueryContext(ctx, qu, vals...)
+	rows, err := qs.reader(ctx).QueryContext(ctx, qu, vals...)
 	if err != nil {
 		return nil, fmt.Errorf("sql query failed: %v\nquery: %v", err, qu)
 	}
@@ -190,26 +190,28 @@ func (it *iteratorBase) scanValue(r *sql.Rows) bool {
 			it.tags[name] = nodes[i].ValueHash
 		}
 	}
+	it.res, it.err = it.resultOf(nodes)
+	return it.err == nil
+}
+
+// resultOf returns a quad or a node from a scanned row.
+func (it *iteratorBase) resultOf(nodes []NodeHash) (graph.Ref, error) {
 	if len(it.cind) > 1 {
 		var q QuadHashes
 		for _, d := range quad.Directions {
 			i, ok := it.cind[d]
 			if !ok {
-				it.err = fmt.Errorf("cannot find quad %v in query output (columns: %v)", d, it.cols)
-				return false
+				return nil, fmt.Errorf("cannot find quad %v in query output (columns: %v)", d, it.cols)
 			}
 			q.Set(d, nodes[i].ValueHash)
 		}
-		it.res = q
-		return true
+		return q, nil
 	}
 	i, ok := it.cind[quad.Any]
 	if !ok {
-		it.err = fmt.Errorf("cannot find node hash in query output (columns: %v, cind: %v)", it.cols, it.cind)
-		return false
+		return nil, fmt.Errorf("cannot find node hash in query output (columns: %v, cind: %v)", it.cols, it.cind)
 	}
-	it.res = nodes[i]
-	return true
+	return nodes[i], nil
 }
 
 func (it *iteratorBase) Err() error {
@@ -226,12 +228,13 @@ func (qs *QuadStore) newIteratorNext(s Select) *iteratorNext {
 	}
 }
 
+// iteratorNext returns each row of the query as a separate result.
+//
+// If the query joins other queries that may return multiple rows for the same node (see Select.nextPath),
+// the node is returned for each row, the same way as HasA returns a node for each quad.
 type iteratorNext struct {
 	iteratorBase
 	cursor *sql.Rows
-	// TODO(dennwc): nextPath workaround; remove when we get rid of NextPath in general
-	nextPathRes  graph.Ref
-	nextPathTags map[string]graph.Ref
 }
 
 func (it *iteratorNext) Next(ctx context.Context) bool {
@@ -240,65 +243,61 @@ func (it *iteratorNext) Next(ctx context.Context) bool {
 	}
 	if it.cursor == nil {
 		it.cursor, it.err = it.qs.Query(ctx, it.query)
-	}
-	// TODO(dennwc): this loop exists only because of nextPath workaround
-	for {
 		if it.err != nil {
 			return false
 		}
-		if it.nextPathRes != nil {
-			it.res = it.nextPathRes
-			it.tags = it.nextPathTags
-			it.nextPathRes = nil
-			it.nextPathTags = nil
-			return true
-		}
-		if !it.cursor.Next() {
-			it.err = it.cursor.Err()
-			it.cursor.Close()
-			return false
-		}
-
-		prev := it.res
-		if !it.scanValue(it.cursor) {
-			return false
-		}
-		if !it.query.nextPath {
-			return true
-		}
-		if prev == nil || prev.Key() != it.res.Key() {
-			return true
-		}
-		// skip the same main key if in nextPath mode
-		// the user should receive accept those results via NextPath of the iterator
-	}
-}
-
-func (it *iteratorNext) NextPath(ctx context.Context) bool {
-	if it.err != nil {
-		return false
-	}
-	if !it.query.nextPath {
-		return false
 	}
 	if !it.cursor.Next() {
 		it.err = it.cursor.Err()
 		it.cursor.Close()
 		return false
 	}
-	prev := it.res
-	if !it.scanValue(it.cursor) {
-		return false
+	return it.scanValue(it.cursor)
+}
+
+var _ iterator.BatchScanner = (*iteratorNext)(nil)
+
+// NextBatch implements iterator.BatchScanner. Rows are scanned directly into the buffer, without collecting tags.
+func (it *iteratorNext) NextBatch(ctx context.Context, buf []graph.Ref) int {
+	if it.err != nil || len(buf) == 0 {
+		return 0
 	}
-	if prev.Key() == it.res.Key() {
-		return true
+	if it.cursor == nil {
+		it.cursor, it.err = it.qs.Query(ctx, it.query)
+		if it.err != nil {
+			return 0
+		}
 	}
-	// different main keys - return false, but keep this results for the Next
-	it.nextPathRes = it.res
-	it.nextPathTags = it.tags
-	it.res = nil
-	it.tags = nil
-	return false
+	it.ensureColumns()
+	nodes := make([]NodeHash, len(it.cols))
+	pointers := make([]interface{}, len(nodes))
+	for i := range pointers {
+		pointers[i] = &nodes[i]
+	}
+	n := 0
+	defer func() {
+		if n > 0 {
+			it.res, it.tags = buf[n-1], nil
+		}
+	}()
+	for n < len(buf) {
+		if !it.cursor.Next() {
+			it.err = it.cursor.Err()
+			it.cursor.Close()
+			return n
+		}
+		if it.err = it.cursor.Scan(pointers...); it.err != nil {
+			return n
+		}
+		r, err := it.resultOf(nodes)
+		if err != nil {
+			it.err = err
+			return n
+		}
+		buf[n] = r
+		n++
+	}
+	return n
 }
 
 func (it *iteratorNext) Close() error {
@@ -315,10 +314,13 @@ func (qs *QuadStore) newIteratorContains(s Select) *iteratorContains {
 	}
 }
 
+var _ iterator.PathIterator = (*iteratorContains)(nil)
+
+// iteratorContains checks a value with a query. If the query returns multiple rows for the value,
+// other rows are listed as alternative paths (see Paths).
 type iteratorContains struct {
 	iteratorBase
-	// TODO(dennwc): nextPath workaround; remove when we get rid of NextPath in general
-	nextPathRows *sql.Rows
+	rows *sql.Rows // remaining rows for the last checked value
 }
 
 func (it *iteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
@@ -355,11 +357,12 @@ func (it *iteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 		it.err = err
 		return false
 	}
+	if it.rows != nil {
+		_ = it.rows.Close()
+		it.rows = nil
+	}
 	if it.query.nextPath {
-		if it.nextPathRows != nil {
-			_ = it.nextPathRows.Close()
-		}
-		it.nextPathRows = rows
+		it.rows = rows
 	} else {
 		defer rows.Close()
 	}
@@ -370,23 +373,42 @@ func (it *iteratorContains) Contains(ctx context.Context, v graph.Ref) bool {
 	return it.scanValue(rows)
 }
 
-func (it *iteratorContains) NextPath(ctx context.Context) bool {
-	if it.err != nil {
-		return false
-	}
-	if !it.query.nextPath {
-		return false
+// Paths lists other rows returned by the query for the last checked value.
+func (it *iteratorContains) Paths(ctx context.Context) iterator.Scanner {
+	if it.err != nil || it.rows == nil {
+		return nil
 	}
-	if !it.nextPathRows.Next() {
-		it.err = it.nextPathRows.Err()
-		return false
-	}
-	return it.scanValue(it.nextPathRows)
+	p := &iteratorPaths{iteratorBase: it.iteratorBase, rows: it.rows}
+	p.res, p.tags = nil, nil
+	it.rows = nil
+	return p
 }
 
 func (it *iteratorContains) Close() error {
-	if it.nextPathRows != nil {
-		return it.nextPathRows.Close()
+	if it.rows != nil {
+		err := it.rows.Close()
+		it.rows = nil
+		return err
 	}
 	return nil
 }
+
+// iteratorPaths lists the remaining rows of a query that checked a value.
+type iteratorPaths struct {
+	iteratorBase
+	rows *sql.Rows
+}
+
+func (it *iteratorPaths) Next(ctx context.Context) bool {
+	if it.err != nil || !it.rows.Next() {
+		if it.err == nil {
+			it.err = it.rows.Err()
+		}
+		return false
+	}
+	return it.scanValue(it.rows)
+}
+
+func (it *iteratorPaths) Close() error {
+	return it.rows.Close()
+}
@@ -0,0 +1,214 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+const (
+	metaTable  = "cayley_meta"
+	metaSchema = "schema_version"
+)
+
+// Migration is a single versioned change of the database schema.
+type Migration struct {
+	// Version of the schema after applying the migration. Must be unique for each dialect.
+	Version int64
+	// Description is a human-readable description of the change.
+	Description string
+	// Up applies the migration. It is run in a transaction, unless database doesn't support schema changes in transactions.
+	Up func(ctx context.Context, tx Execer, r Registration) error
+}
+
+// Execer is a common interface for sql.DB, sql.Conn and sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Statements returns a migration function that executes given SQL statements.
+func Statements(stmts ...string) func(ctx context.Context, tx Execer, r Registration) error {
+	return func(ctx context.Context, tx Execer, r Registration) error {
+		for _, s := range stmts {
+			if _, err := tx.ExecContext(ctx, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// commonMigrations are applied to all registered dialects.
+var commonMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "add index for quad labels",
+		Up:          Statements(`CREATE INDEX label_index ON quads (label_hash);`),
+	},
+}
+
+var migrations = make(map[string][]Migration)
+
+// RegisterMigration adds a schema migration for a given database type.
+// Migrations for a specific dialect should be registered after the dialect itself.
+func RegisterMigration(typ string, m Migration) {
+	if m.Up == nil {
+		panic("no migration function")
+	} else if m.Version <= 0 {
+		panic("migration version must be positive")
+	}
+	for _, m2 := range migrations[typ] {
+		if m2.Version == m.Version {
+			panic(fmt.Sprintf("migration %d is already registered for %q", m.Version, typ))
+		}
+	}
+	list := append(migrations[typ], m)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	migrations[typ] = list
+}
+
+// Migrations returns all migrations registered for a given database type, ordered by version.
+func Migrations(typ string) []Migration {
+	return append([]Migration{}, migrations[typ]...)
+}
+
+// LatestVersion returns the latest schema version for a given database type.
+func LatestVersion(typ string) int64 {
+	list := migrations[typ]
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+func (r Registration) metaTable() string {
+	return `CREATE TABLE ` + metaTable + ` (
+	name VARCHAR(64) PRIMARY KEY,
+	value BIGINT NOT NULL
+);`
+}
+
+// schemaVersion returns current schema version of the database.
+// Databases created before migrations were introduced have no metadata and are assumed to be of version zero.
+func schemaVersion(ctx context.Context, db *sql.DB, r Registration) (vers int64, hasMeta bool, err error) {
+	return metaValue(ctx, db, r, metaSchema)
+}
+
+// metaValue returns a value from the metadata table, or zero if it's not set.
+// The second value is false if the metadata table does not exist.
+func metaValue(ctx context.Context, db *sql.DB, r Registration, name string) (v int64, hasMeta bool, _ error) {
+	err := db.QueryRowContext(ctx, `SELECT value FROM `+metaTable+` WHERE name = `+r.Placeholder(1)+`;`, name).Scan(&v)
+	if err == sql.ErrNoRows {
+		return 0, true, nil
+	} else if err != nil {
+		if r.Error != nil {
+			err = r.Error(err)
+		}
+		if err == ErrNoTable {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return v, true, nil
+}
+
+func setSchemaVersion(ctx context.Context, tx Execer, r Registration, vers int64) error {
+	return setMetaValue(ctx, tx, r, metaSchema, vers)
+}
+
+func setMetaValue(ctx context.Context, tx Execer, r Registration, name string, v int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM `+metaTable+` WHERE name = `+r.Placeholder(1)+`;`, name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO `+metaTable+`(name, value) VALUES (`+r.Placeholder(1)+`, `+r.Placeholder(2)+`);`, name, v)
+	return err
+}
+
+// Upgrade applies all pending schema migrations to the database.
+func Upgrade(typ string, addr string, options graph.Options) error {
+	fl, ok := types[typ]
+	if !ok {
+		return fmt.Errorf("unsupported sql database: %q", typ)
+	}
+	conn, err := connect(addr, fl.Driver, options)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return migrate(context.Background(), conn, typ, fl)
+}
+
+func migrate(ctx context.Context, db *sql.DB, typ string, fl Registration) error {
+	vers, hasMeta, err := schemaVersion(ctx, db, fl)
+	if err != nil {
+		return err
+	}
+	if !hasMeta {
+		if _, err := db.ExecContext(ctx, fl.metaTable()); err != nil {
+			err = fl.Error(err)
+			clog.Errorf("Cannot create meta table: %v", err)
+			return err
+		}
+	}
+	for _, m := range migrations[typ] {
+		if m.Version <= vers {
+			continue
+		}
+		clog.Infof("applying sql migration %d: %s", m.Version, m.Description)
+		if err := applyMigration(ctx, db, fl, m); err != nil {
+			return fmt.Errorf("sql migration %d (%s) failed: %v", m.Version, m.Description, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, fl Registration, m Migration) error {
+	if fl.NoSchemaChangesInTx {
+		if err := m.Up(ctx, db, fl); err != nil {
+			return fl.Error(err)
+		}
+		return setSchemaVersion(ctx, db, fl, m.Version)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = m.Up(ctx, tx, fl); err != nil {
+		tx.Rollback()
+		return fl.Error(err)
+	}
+	if err = setSchemaVersion(ctx, tx, fl, m.Version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ErrNoTable is returned by Registration.Error for errors caused by a missing table.
+var ErrNoTable = errors.New("sql: table does not exist")
+
+var errOutdated = errors.New("sql: database schema is out of date. Run cayley upgrade for your config to update the schema")
+
+// checkVersion makes sure that database schema is up to date. Migrations are never applied when the database is opened,
+// since even adding an index may lock a large table for a long time. It returns errOutdated if any migration is pending.
+func checkVersion(ctx context.Context, db *sql.DB, typ string, fl Registration) error {
+	vers, _, err := schemaVersion(ctx, db, fl)
+	if err != nil {
+		return err
+	}
+	latest := LatestVersion(typ)
+	if vers > latest {
+		return fmt.Errorf("sql: database schema version %d is newer than supported (%d)", vers, latest)
+	} else if vers < latest {
+		return errOutdated
+	}
+	return nil
+}
@@ -22,6 +22,9 @@ var QueryDialect = csql.QueryDialect{
 		return "`" + name + "`"
 	},
 	Placeholder: func(n int) string { return "?" },
+	BinaryString: func(s string) string {
+		return "CAST(" + s + " AS BINARY)"
+	},
 }
 
 func init() {
@@ -34,11 +37,11 @@ func init() {
 		QueryDialect:         QueryDialect,
 		NoOffsetWithoutLimit: true,
 		CustomNullTime:       true,
-		Error: func(err error) error {
-			return err
-		},
-		Estimated: nil,
-		RunTx:     runTxMysql,
+		DropIndexOnTable:     true,
+		Error:                convError,
+		Estimated:            nil,
+		RunTx:                runTxMysql,
+		CondIsolation:        sql.LevelSerializable,
 	})
 }
 
@@ -129,6 +132,13 @@ func runTxMysql(tx *sql.Tx, nodes []graphlog.NodeUpdate, quads []graphlog.QuadUp
 	return nil
 }
 
+func convError(err error) error {
+	if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1146 { // ER_NO_SUCH_TABLE
+		return csql.ErrNoTable
+	}
+	return err
+}
+
 func convInsertError(err error) error {
 	if err == nil {
 		return nil
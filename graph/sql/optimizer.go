@@ -97,6 +97,10 @@ func (opt *Optimizer) OptimizeShape(ctx context.Context, s shape.Shape) (shape.S
 		return opt.optimizeSave(s)
 	case shape.Page:
 		return opt.optimizePage(s)
+	case shape.Sort:
+		return opt.optimizeSort(s)
+	case shape.Count:
+		return opt.optimizeCount(s)
 	default:
 		return s, false
 	}
@@ -400,7 +404,7 @@ func (opt *Optimizer) optimizeNodesFrom(s shape.NodesFrom) (shape.Shape, bool) {
 	if !found {
 		return s, false
 	}
-	// NodesFrom implies that the iterator will use NextPath
+	// NodesFrom may return multiple rows for the same node; lookups return them as paths
 	sel.nextPath = true
 	return sel, true
 }
@@ -413,7 +417,7 @@ func (opt *Optimizer) optimizeQuadsAction(s shape.QuadsAction) (shape.Shape, boo
 		From: []Source{
 			Table{Name: "quads"},
 		},
-		// NodesFrom (that is a part of QuadsAction) implies that the iterator will use NextPath
+		// NodesFrom (that is a part of QuadsAction) may return multiple rows for the same node; lookups return them as paths
 		nextPath: true,
 	}
 	var dirs []quad.Direction
@@ -492,6 +496,107 @@ func (opt *Optimizer) optimizePage(s shape.Page) (shape.Shape, bool) {
 	return sel, true
 }
 
+// asSubquery wraps the query into a subquery and selects all its fields.
+func (opt *Optimizer) asSubquery(sel Select) Select {
+	tbl := opt.nextTable()
+	out := Select{
+		From: []Source{
+			Subquery{Query: sel, Alias: tbl},
+		},
+		nextPath: sel.nextPath,
+	}
+	for _, f := range sel.Fields {
+		name := f.NameOrAlias()
+		out.Fields = append(out.Fields, Field{
+			Table: tbl, Name: name, Alias: name,
+		})
+	}
+	return out
+}
+
+func (opt *Optimizer) optimizeSort(s shape.Sort) (shape.Shape, bool) {
+	if len(s.Keys) != 0 {
+		// TODO: sort by tags in SQL
+		return s, false
+	}
+	sel, ok := s.From.(Select)
+	if !ok || !sel.isNodes() {
+		return s, false
+	}
+	if sel.Limit > 0 || sel.Offset > 0 {
+		// sorting must be applied after the page
+		sel = opt.asSubquery(sel)
+	} else {
+		sel = sel.Clone()
+		opt.ensureAliases(&sel)
+	}
+	head := sel.Fields[sel.head()]
+	// find a nodes table to sort by, or join it
+	tbl := ""
+	if head.Name == "hash" {
+		for _, src := range sel.From {
+			if t, ok := src.(Table); ok && t.Name == "nodes" && t.NameSQL() == head.Table {
+				tbl = head.Table
+				break
+			}
+		}
+	}
+	if tbl == "" {
+		tbl = opt.nextTable()
+		sel.From = append(sel.From, Table{Name: "nodes", Alias: tbl})
+		sel.Where = append(sel.Where, Where{
+			Table: tbl,
+			Field: "hash",
+			Op:    OpEqual,
+			Value: FieldName{
+				Name:  head.Name,
+				Table: head.Table,
+			},
+		})
+	}
+	sel.OrderBy = nodeOrder(tbl)
+	// keep rows for the same node (alternative paths) together
+	sel.OrderBy = append(sel.OrderBy, Order{Table: head.Table, Field: head.Name})
+	return sel, true
+}
+
+// nodeOrder returns sorting keys that order values in a nodes table the same way as iterator.Sort.
+//
+// Values are first ordered by type. Values of a single type are stored in one column and other columns are NULL,
+// thus the next keys compare values of the same type and NULLs are never compared to values.
+// Other values are compared by their binary representation, not by their string representation.
+func nodeOrder(tbl string) []Order {
+	field := func(name string) FieldName {
+		return FieldName{Table: tbl, Name: name}
+	}
+	where := func(name string, op CmpOp) Where {
+		return Where{Table: tbl, Field: name, Op: op}
+	}
+	return []Order{
+		{Expr: Rank{
+			{where("value_int", OpIsNotNull), where("value_float", OpIsNotNull)},
+			{where("value_time", OpIsNotNull)},
+			{where("value_bool", OpIsNotNull)},
+			{where("iri", OpIsTrue)},
+			{where("bnode", OpIsTrue)},
+			{where("value_string", OpIsNotNull)},
+		}},
+		{Expr: Coalesce{field("value_int"), field("value_float")}},
+		{Table: tbl, Field: "value_time"},
+		{Table: tbl, Field: "value_bool"},
+		{Table: tbl, Field: "value_string", Binary: true},
+		{Table: tbl, Field: "value"},
+	}
+}
+
+func (opt *Optimizer) optimizeCount(s shape.Count) (shape.Shape, bool) {
+	sel, ok := s.Values.(Select)
+	if !ok {
+		return s, false
+	}
+	return Count{Query: sel}, true
+}
+
 func (opt *Optimizer) optimizeIntersect(s shape.Intersect) (shape.Shape, bool) {
 	var (
 		sels  []Select
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
@@ -22,6 +23,9 @@ var QueryDialect = csql.QueryDialect{
 	Placeholder: func(n int) string {
 		return fmt.Sprintf("$%d", n)
 	},
+	BinaryString: func(s string) string {
+		return s + ` COLLATE "C"`
+	},
 }
 
 func init() {
@@ -38,7 +42,9 @@ func init() {
 		Estimated: func(table string) string {
 			return "SELECT reltuples::BIGINT AS estimate FROM pg_class WHERE relname='" + table + "';"
 		},
-		RunTx: RunTxPostgres,
+		RunTx:         RunTxPostgres,
+		BulkInsert:    CopyFrom,
+		CondIsolation: sql.LevelSerializable,
 	})
 }
 
@@ -50,6 +56,8 @@ func ConvError(err error) error {
 	switch e.Code {
 	case "42P07":
 		return graph.ErrDatabaseExists
+	case "42P01":
+		return csql.ErrNoTable
 	}
 	return err
 }
@@ -67,45 +75,38 @@ func convInsertError(err error) error {
 	return err
 }
 
-//func copyFromPG(tx *sql.Tx, in []graph.Delta, opts graph.IgnoreOpts) error {
-//	panic("broken")
-//	stmt, err := tx.Prepare(pq.CopyIn("quads", "subject", "predicate", "object", "label", "id", "ts", "subject_hash", "predicate_hash", "object_hash", "label_hash"))
-//	if err != nil {
-//		clog.Errorf("couldn't prepare COPY statement: %v", err)
-//		return err
-//	}
-//	for _, d := range in {
-//		s, p, o, l, err := marshalQuadDirections(d.Quad)
-//		if err != nil {
-//			clog.Errorf("couldn't marshal quads: %v", err)
-//			return err
-//		}
-//		_, err = stmt.Exec(
-//			s,
-//			p,
-//			o,
-//			l,
-//			d.ID.Int(),
-//			d.Timestamp,
-//			hashOf(d.Quad.Subject),
-//			hashOf(d.Quad.Predicate),
-//			hashOf(d.Quad.Object),
-//			hashOf(d.Quad.Label),
-//		)
-//		if err != nil {
-//			err = convInsertErrorPG(err)
-//			clog.Errorf("couldn't execute COPY statement: %v", err)
-//			return err
-//		}
-//	}
-//	_, err = stmt.Exec()
-//	if err != nil {
-//		err = convInsertErrorPG(err)
-//		return err
-//	}
-//	_ = stmt.Close() // COPY will be closed on last Exec, this will return non-nil error in all cases
-//	return nil
-//}
+// CopyFrom inserts rows into the table using COPY FROM.
+func CopyFrom(ctx context.Context, conn *sql.Conn, table string, columns []string, rows [][]interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		tx.Rollback()
+		clog.Errorf("couldn't prepare COPY statement: %v", err)
+		return err
+	}
+	for _, row := range rows {
+		if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			clog.Errorf("couldn't execute COPY statement: %v", err)
+			return err
+		}
+	}
+	// COPY is flushed by the last Exec without arguments
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return err
+	}
+	if err = stmt.Close(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
 
 func RunTxPostgres(tx *sql.Tx, nodes []graphlog.NodeUpdate, quads []graphlog.QuadUpdate, opts graph.IgnoreOpts) error {
 	return RunTx(tx, nodes, quads, opts, "")
@@ -34,8 +34,9 @@ func makePostgres(t testing.TB) (string, graph.Options) {
 }
 
 var conf = &sqltest.Config{
-	TimeRound: true,
-	TimeInMcs: true,
+	TimeRound:          true,
+	TimeInMcs:          true,
+	ConditionalIndexes: true,
 }
 
 func TestPostgres(t *testing.T) {
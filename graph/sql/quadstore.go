@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -25,7 +26,9 @@ func registerQuadStore(name, typ string) {
 		NewFunc: func(addr string, options graph.Options) (graph.QuadStore, error) {
 			return New(typ, addr, options)
 		},
-		UpgradeFunc: nil,
+		UpgradeFunc: func(addr string, options graph.Options) error {
+			return Upgrade(typ, addr, options)
+		},
 		InitFunc: func(addr string, options graph.Options) error {
 			return Init(typ, addr, options)
 		},
@@ -103,16 +106,31 @@ type QuadHashes struct {
 }
 
 type QuadStore struct {
-	db      *sql.DB
-	opt     *Optimizer
-	flavor  Registration
-	ids     *lru.Cache
-	sizes   *lru.Cache
-	noSizes bool
+	db       *sql.DB
+	replicas *replicaSet
+	primary  *QuadStore // view that reads from the primary; nil if there are no replicas or it is the view itself
+	opt      *Optimizer
+	flavor   Registration
+	ids      *lru.Cache
+	sizes    *lru.Cache
+	noSizes  bool
+	options  graph.Options
+	// versioned is set if deleted quads and nodes are kept in history tables, see AsOf
+	versioned bool
+
+	mu      sync.RWMutex
+	nodes   int64
+	quads   int64
+	changes int64        // number of quads written since the store was opened
+	dirs    [4]*dirStats // cached statistics for each direction
+}
 
-	mu    sync.RWMutex
-	nodes int64
-	quads int64
+// statsRefresh is a relative change of the number of quads after which cached direction statistics are recalculated.
+const statsRefresh = 10 // %
+
+type dirStats struct {
+	graph.DirectionStats
+	changes int64 // number of written quads at the time of calculation
 }
 
 func connect(addr string, flavor string, opts graph.Options) (*sql.DB, error) {
@@ -209,11 +227,23 @@ func Init(typ string, addr string, options graph.Options) error {
 	}
 	defer conn.Close()
 
+	versioned, err := options.BoolKey(OptVersioned, false)
+	if err != nil {
+		return err
+	}
+
 	nodesSQL := fl.nodesTable()
 	quadsSQL := fl.quadsTable()
 	indexes := fl.quadIndexes(options)
 
 	if fl.NoSchemaChangesInTx {
+		for _, q := range fl.schemaPrelude() {
+			if _, err = conn.Exec(q); err != nil {
+				err = fl.Error(err)
+				clog.Errorf("Cannot initialize schema: %v", err)
+				return err
+			}
+		}
 		_, err = conn.Exec(nodesSQL)
 		if err != nil {
 			err = fl.Error(err)
@@ -239,6 +269,15 @@ func Init(typ string, addr string, options graph.Options) error {
 			return err
 		}
 
+		for _, q := range fl.schemaPrelude() {
+			if _, err = tx.Exec(q); err != nil {
+				tx.Rollback()
+				err = fl.Error(err)
+				clog.Errorf("Cannot initialize schema: %v", err)
+				return err
+			}
+		}
+
 		_, err = tx.Exec(nodesSQL)
 		if err != nil {
 			tx.Rollback()
@@ -262,6 +301,13 @@ func Init(typ string, addr string, options graph.Options) error {
 		}
 		tx.Commit()
 	}
+	// tables are created with the initial schema, apply all migrations on top of it
+	if err = migrate(context.Background(), conn, typ, fl); err != nil {
+		return err
+	}
+	if versioned {
+		return initHistory(context.Background(), conn, fl)
+	}
 	return nil
 }
 
@@ -274,29 +320,79 @@ func New(typ string, addr string, options graph.Options) (graph.QuadStore, error
 	if err != nil {
 		return nil, err
 	}
+	if err = checkVersion(context.Background(), conn, typ, fl); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	replicas, err := newReplicaSet(conn, fl, options)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	qs, err := newQuadStore(conn, replicas, fl, options)
+	if err != nil {
+		replicas.Close()
+		conn.Close()
+		return nil, err
+	}
+	qs.versioned, err = isVersioned(context.Background(), conn, fl)
+	if err != nil {
+		qs.Close()
+		return nil, err
+	}
+	if len(replicas.list) != 0 {
+		qs.primary, err = newQuadStore(conn, &replicaSet{flavor: fl, primary: conn}, fl, options)
+		if err != nil {
+			replicas.Close()
+			conn.Close()
+			return nil, err
+		}
+		qs.primary.versioned = qs.versioned
+	}
+	return qs, nil
+}
+
+func newQuadStore(conn *sql.DB, replicas *replicaSet, fl Registration, options graph.Options) (*QuadStore, error) {
 	qs := &QuadStore{
-		db:      conn,
-		opt:     NewOptimizer(),
-		flavor:  fl,
-		quads:   -1,
-		nodes:   -1,
-		sizes:   lru.New(1024),
-		ids:     lru.New(1024),
-		noSizes: true, // Skip size checking by default.
-	}
-	qs.opt.SetRegexpOp(qs.flavor.RegexpOp)
+		db:       conn,
+		replicas: replicas,
+		opt:      NewOptimizer(),
+		flavor:   fl,
+		quads:    -1,
+		nodes:    -1,
+		sizes:    lru.New(1024),
+		ids:      lru.New(1024),
+		noSizes:  true, // Skip size checking by default.
+		options:  options,
+	}
+	if qs.flavor.RegexpOp == "" && qs.flavor.RegexpFunc != "" {
+		qs.opt.SetRegexpOp(OpRegexpCall)
+	} else {
+		qs.opt.SetRegexpOp(qs.flavor.RegexpOp)
+	}
 	if qs.flavor.NoOffsetWithoutLimit {
 		qs.opt.NoOffsetWithoutLimit()
 	}
 
 	if local, err := options.BoolKey("local_optimize", false); err != nil {
 		return nil, err
-	} else if ok && local {
+	} else if local {
 		qs.noSizes = false
 	}
 	return qs, nil
 }
 
+var _ graph.PrimaryReader = (*QuadStore)(nil)
+
+// Primary returns a view of the quad store that never reads from replicas.
+// It shares the connection with the quad store, thus it must not be closed.
+func (qs *QuadStore) Primary() graph.QuadStore {
+	if qs.primary == nil {
+		return qs
+	}
+	return qs.primary
+}
+
 func escapeNullByte(s string) string {
 	return strings.Replace(s, "\u0000", `\x00`, -1)
 }
@@ -395,11 +491,55 @@ func (w *quadWriter) Close() error {
 	return nil
 }
 
+// objects returns objects of all quads with a given subject, predicate and label.
+func (qs *QuadStore) objects(tx *sql.Tx, s, p, l quad.Value) ([]quad.Value, error) {
+	cols := make([]string, 0, len(nodesColumns)-1)
+	for _, c := range nodesColumns[1:] {
+		cols = append(cols, "n."+c)
+	}
+	args := []interface{}{NodeHash{refs.HashOf(s)}.SQLValue(), NodeHash{refs.HashOf(p)}.SQLValue()}
+	label := `q.label_hash is null`
+	if l != nil {
+		label = `q.label_hash=` + qs.flavor.Placeholder(3)
+		args = append(args, NodeHash{refs.HashOf(l)}.SQLValue())
+	}
+	rows, err := tx.Query(`SELECT `+strings.Join(cols, ", ")+` FROM quads q, nodes n WHERE n.hash=q.object_hash`+
+		` and q.subject_hash=`+qs.flavor.Placeholder(1)+` and q.predicate_hash=`+qs.flavor.Placeholder(2)+` and `+label+`;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []quad.Value
+	for rows.Next() {
+		v, err := qs.scanValue(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func hasReplace(deltas []graph.Delta) bool {
+	for _, d := range deltas {
+		if d.Action == graph.Replace {
+			return true
+		}
+	}
+	return false
+}
+
 func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error {
-	// first calculate values ref deltas
-	deltas := graphlog.SplitDeltas(in)
+	return qs.ApplyDeltasIf(nil, in, opts)
+}
 
-	tx, err := qs.db.Begin()
+// ApplyDeltasIf implements graph.ConditionalApplier.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, in []graph.Delta, opts graph.IgnoreOpts) error {
+	var txo *sql.TxOptions
+	if (len(conds) != 0 || hasReplace(in)) && qs.flavor.CondIsolation != sql.LevelDefault {
+		txo = &sql.TxOptions{Isolation: qs.flavor.CondIsolation}
+	}
+	tx, err := qs.db.BeginTx(context.TODO(), txo)
 	if err != nil {
 		clog.Errorf("couldn't begin write transaction: %v", err)
 		return err
@@ -416,7 +556,19 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 		p[i] = qs.flavor.Placeholder(i + 1)
 	}
 
-	err = retry(tx, func() error {
+	write := func() error {
+		objects := func(s, p, l quad.Value) ([]quad.Value, error) {
+			return qs.objects(tx, s, p, l)
+		}
+		if err = graph.CheckPreconditions(conds, objects); err != nil {
+			return err
+		}
+		in, err := graph.ExpandReplace(in, objects)
+		if err != nil {
+			return err
+		}
+		// calculate values ref deltas
+		deltas := graphlog.SplitDeltas(in)
 		err = qs.flavor.RunTx(tx, deltas.IncNode, deltas.QuadAdd, opts)
 		if err != nil {
 			return err
@@ -425,7 +577,11 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 		var (
 			deleteQuad   *sql.Stmt
 			deleteTriple *sql.Stmt
+			history      *historyWriter
 		)
+		if qs.versioned {
+			history = newHistoryWriter(tx, qs.flavor)
+		}
 		fixNodes := make(map[refs.ValueHash]int)
 		for _, d := range deltas.QuadDel {
 			dirs := make([]interface{}, 0, len(quad.Directions))
@@ -447,6 +603,12 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 				stmt = deleteTriple
 				dirs = dirs[:i]
 			}
+			if history != nil {
+				if err := history.deleted(dirs); err != nil {
+					clog.Errorf("couldn't record deleted quad: %v", err)
+					return err
+				}
+			}
 			result, err := stmt.Exec(dirs...)
 			if err != nil {
 				clog.Errorf("couldn't exec DELETE statement: %v", err)
@@ -490,12 +652,26 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 			}
 		}
 		// and remove unused nodes at last
+		if history != nil {
+			if err := history.nodesDeleted(); err != nil {
+				clog.Errorf("couldn't record deleted nodes: %v", err)
+				return err
+			}
+		}
 		_, err = tx.Exec(`DELETE FROM nodes WHERE refs <= 0;`)
 		if err != nil {
 			clog.Errorf("couldn't exec DELETE nodes statement: %v", err)
 			return err
 		}
 		return nil
+	}
+	var committed func()
+	err = retry(tx, func() error {
+		if err := write(); err != nil {
+			return err
+		}
+		committed, err = qs.replicas.onWrite(tx)
+		return err
 	})
 	if err != nil {
 		tx.Rollback()
@@ -506,8 +682,13 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 	// TODO(barakmich): Sync size with writes.
 	qs.quads = -1
 	qs.nodes = -1
+	qs.changes += int64(len(in))
 	qs.mu.Unlock()
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	committed()
+	return nil
 }
 
 func (qs *QuadStore) Quad(val graph.Ref) (quad.Quad, error) {
@@ -541,11 +722,8 @@ func (qs *QuadStore) QuadIterator(d quad.Direction, val graph.Ref) iterator.Shap
 }
 
 func (qs *QuadStore) querySize(ctx context.Context, sel Select) (refs.Size, error) {
-	sel.Fields = []Field{
-		{Name: "COUNT(*)", Raw: true}, // TODO: proper support for expressions
-	}
 	var sz int64
-	err := qs.QueryRow(ctx, sel).Scan(&sz)
+	err := qs.QueryRow(ctx, Count{Query: sel}).Scan(&sz)
 	if err != nil {
 		return refs.Size{}, err
 	}
@@ -637,19 +815,27 @@ func (qs *QuadStore) NameOf(v graph.Ref) (quad.Value, error) {
 	if val, ok := qs.ids.Get(hash.String()); ok {
 		return val.(quad.Value), nil
 	}
-	query := `SELECT
-		value,
-		value_string,
-		datatype,
-		language,
-		iri,
-		bnode,
-		value_int,
-		value_bool,
-		value_float,
-		value_time
-	FROM nodes WHERE hash = ` + qs.flavor.Placeholder(1) + ` LIMIT 1;`
-	c := qs.db.QueryRow(query, hash.SQLValue())
+	query := `SELECT ` + strings.Join(nodesColumns[1:], ", ") + ` FROM nodes WHERE hash = ` + qs.flavor.Placeholder(1) + ` LIMIT 1;`
+	c := qs.reader(context.TODO()).QueryRow(query, hash.SQLValue())
+	val, err := qs.scanValue(c)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if val != nil {
+		qs.ids.Put(hash.String(), val)
+	}
+	return val, nil
+}
+
+// rowScanner is implemented by sql.Row and sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanValue reads a value from a row with value columns of nodes table (nodesColumns without hash).
+func (qs *QuadStore) scanValue(r rowScanner) (quad.Value, error) {
 	var (
 		data        []byte
 		str         sql.NullString
@@ -677,7 +863,7 @@ func (qs *QuadStore) NameOf(v graph.Ref) (quad.Value, error) {
 		vtimeTime = &vtimeStd.Time
 		vtimeValid = &vtimeStd.Valid
 	}
-	if err := c.Scan(
+	if err := r.Scan(
 		&data,
 		&str,
 		&typ,
@@ -688,10 +874,10 @@ func (qs *QuadStore) NameOf(v graph.Ref) (quad.Value, error) {
 		&vbool,
 		&vfloat,
 		vtimeScan,
-	); err != nil {
-		if err != sql.ErrNoRows {
-			return nil, fmt.Errorf("error executing value lookup: %w", err)
-		}
+	); err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("error executing value lookup: %w", err)
 	}
 	var val quad.Value
 	if str.Valid {
@@ -727,9 +913,6 @@ func (qs *QuadStore) NameOf(v graph.Ref) (quad.Value, error) {
 		}
 		val = qv
 	}
-	if val != nil {
-		qs.ids.Put(hash.String(), val)
-	}
 	return val, nil
 }
 
@@ -753,11 +936,12 @@ func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error)
 		st.Quads.Exact = false
 		st.Nodes.Exact = false
 	}
-	err := qs.db.QueryRow(query("quads")).Scan(&st.Quads.Value)
+	db := qs.reader(ctx)
+	err := db.QueryRowContext(ctx, query("quads")).Scan(&st.Quads.Value)
 	if err != nil {
 		return graph.Stats{}, err
 	}
-	err = qs.db.QueryRow(query("nodes")).Scan(&st.Nodes.Value)
+	err = db.QueryRowContext(ctx, query("nodes")).Scan(&st.Nodes.Value)
 	if err != nil {
 		return graph.Stats{}, err
 	}
@@ -770,7 +954,74 @@ func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error)
 	return st, nil
 }
 
+var _ graph.Statistician = (*QuadStore)(nil)
+
+// DirectionStats calculates statistics for a given direction with an aggregate query.
+// Results are cached until the number of written quads changes significantly, or any quads are written
+// and exact statistics are requested. If the dialect can estimate distinct counts (see Registration.ApproxDistinct),
+// estimated statistics use it instead of an exact count.
+func (qs *QuadStore) DirectionStats(ctx context.Context, d quad.Direction, exact bool) (graph.DirectionStats, error) {
+	if d < quad.Subject || d > quad.Label {
+		return graph.DirectionStats{}, fmt.Errorf("sql: invalid direction: %v", d)
+	}
+	qs.mu.RLock()
+	c, changes := qs.dirs[d-1], qs.changes
+	qs.mu.RUnlock()
+	// estimated distinct counts are only returned for estimated stats
+	if c != nil && (!exact || c.Values.Exact) {
+		diff := changes - c.changes
+		if diff == 0 || (!exact && diff*100 <= c.Quads.Value*statsRefresh) {
+			return c.DirectionStats, nil
+		}
+	}
+	st := graph.DirectionStats{
+		Quads:  refs.Size{Exact: true},
+		Values: refs.Size{Exact: true},
+	}
+	field := dirField(d)
+	distinct := "COUNT(DISTINCT " + field + ")"
+	if !exact && qs.flavor.ApproxDistinct != nil {
+		distinct = qs.flavor.ApproxDistinct(field)
+		st.Values.Exact = false
+	}
+	db := qs.reader(ctx)
+	err := db.QueryRowContext(ctx, "SELECT COUNT("+field+"), "+distinct+" FROM quads;").
+		Scan(&st.Quads.Value, &st.Values.Value)
+	if err != nil {
+		return graph.DirectionStats{}, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+field+", COUNT(*) AS cnt FROM quads WHERE "+field+" IS NOT NULL"+
+		" GROUP BY "+field+" ORDER BY cnt DESC LIMIT "+strconv.Itoa(graph.StatsTopValues)+";")
+	if err != nil {
+		return graph.DirectionStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v graph.ValueCount
+		var h NodeHash
+		if err = rows.Scan(&h, &v.Quads); err != nil {
+			return graph.DirectionStats{}, err
+		}
+		v.Value = h
+		st.Top = append(st.Top, v)
+	}
+	if err = rows.Err(); err != nil {
+		return graph.DirectionStats{}, err
+	}
+	qs.mu.Lock()
+	qs.dirs[d-1] = &dirStats{DirectionStats: st, changes: changes}
+	qs.mu.Unlock()
+	return st, nil
+}
+
+// reader returns a database that should be used for read-only queries.
+// It might be one of the read replicas, if they are configured.
+func (qs *QuadStore) reader(ctx context.Context) *sql.DB {
+	return qs.replicas.reader(ctx)
+}
+
 func (qs *QuadStore) Close() error {
+	qs.replicas.Close()
 	return qs.db.Close()
 }
 
@@ -794,7 +1045,7 @@ func (qs *QuadStore) sizeForIterator(dir quad.Direction, hash NodeHash) int64 {
 	if clog.V(4) {
 		clog.Infof("sql: getting size for select %s, %v", dir.String(), hash)
 	}
-	err = qs.db.QueryRow(
+	err = qs.reader(context.TODO()).QueryRow(
 		fmt.Sprintf("SELECT count(*) FROM quads WHERE %s_hash = "+qs.flavor.Placeholder(1)+";", dir.String()), hash.SQLValue()).Scan(&size)
 	if err != nil {
 		clog.Errorf("Error getting size from SQL database: %v", err)
@@ -0,0 +1,231 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+const (
+	// OptReplicas is a list of read replica addresses. Can be a list or a comma-separated string.
+	OptReplicas = "replicas"
+	// OptReplicaCheckInterval is an interval between replica health checks (default is 5s).
+	OptReplicaCheckInterval = "replica_check_interval"
+	// OptReadYourWrites forces reads to go to the primary until replicas catch up with the last write of this instance.
+	OptReadYourWrites = "read_your_writes"
+)
+
+// positionRefresh is the minimal interval between checks of the commit token of a lagging replica.
+const positionRefresh = 100 * time.Millisecond
+
+type replica struct {
+	addr    string
+	db      *sql.DB
+	healthy atomic.Bool
+	// last known commit token of the replica, and the time it was checked (unix nanoseconds)
+	token   atomic.Int64
+	checked atomic.Int64
+}
+
+type replicaSet struct {
+	flavor  Registration
+	primary *sql.DB
+	list    []*replica
+	next    atomic.Uint32
+
+	readYourWrites bool
+	// commit token that replicas must reach to include the last transaction committed by this instance
+	written atomic.Int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newReplicaSet(primary *sql.DB, flavor Registration, options graph.Options) (*replicaSet, error) {
+	addrs, err := options.StringsKey(OptReplicas, nil)
+	if err != nil {
+		return nil, err
+	}
+	rs := &replicaSet{
+		flavor:  flavor,
+		primary: primary,
+	}
+	if len(addrs) == 0 {
+		return rs, nil
+	}
+	rs.readYourWrites, err = options.BoolKey(OptReadYourWrites, false)
+	if err != nil {
+		return nil, err
+	}
+	interval := 5 * time.Second
+	if s, err := options.StringKey(OptReplicaCheckInterval, ""); err != nil {
+		return nil, err
+	} else if s != "" {
+		interval, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s: %v", OptReplicaCheckInterval, err)
+		}
+	}
+	if rs.readYourWrites {
+		if err = rs.initToken(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		db, err := connect(addr, flavor.Driver, options)
+		if err != nil {
+			rs.Close()
+			return nil, fmt.Errorf("couldn't connect to replica: %v", err)
+		}
+		r := &replica{addr: addr, db: db}
+		r.healthy.Store(true)
+		rs.list = append(rs.list, r)
+	}
+	rs.stop = make(chan struct{})
+	rs.wg.Add(1)
+	go rs.checkHealth(interval)
+	return rs, nil
+}
+
+// metaCommitToken is a counter incremented by each write transaction of instances with read-your-writes enabled.
+//
+// Positions assigned on insert, such as quad horizons, are not ordered by commit: a transaction might commit
+// after another one that got a larger position. The counter row stays locked until the transaction commits,
+// so tokens are assigned in the commit order. Replicas apply transactions in the same order, thus a replica
+// that has a given token also has all transactions committed before it.
+const metaCommitToken = "commit_token"
+
+// initToken makes sure that the commit token exists, and requires replicas to catch up with the primary,
+// since this instance might have written to the primary before a restart.
+func (rs *replicaSet) initToken(ctx context.Context) error {
+	v, err := rs.tokenOf(ctx, rs.primary)
+	if err == sql.ErrNoRows {
+		// other instances might create the counter concurrently, so the insert error only matters if it's still missing
+		_, ierr := rs.primary.ExecContext(ctx, `INSERT INTO `+metaTable+`(name, value) VALUES (`+rs.flavor.Placeholder(1)+`, 0);`, metaCommitToken)
+		if v, err = rs.tokenOf(ctx, rs.primary); err == sql.ErrNoRows && ierr != nil {
+			err = ierr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	rs.written.Store(v)
+	return nil
+}
+
+// tokenOf returns the last commit token applied by the database. It returns sql.ErrNoRows if there is no token yet.
+func (rs *replicaSet) tokenOf(ctx context.Context, db *sql.DB) (int64, error) {
+	var v int64
+	err := db.QueryRowContext(ctx, `SELECT value FROM `+metaTable+` WHERE name = `+rs.flavor.Placeholder(1)+`;`, metaCommitToken).Scan(&v)
+	return v, err
+}
+
+// onWrite assigns a commit token to the write transaction. It should be called at the end of the transaction,
+// since the token serializes write transactions of all instances with read-your-writes enabled until they commit.
+//
+// The returned function must be called after the transaction is committed.
+func (rs *replicaSet) onWrite(tx *sql.Tx) (func(), error) {
+	if !rs.readYourWrites {
+		return func() {}, nil
+	}
+	p := rs.flavor.Placeholder(1)
+	if _, err := tx.Exec(`UPDATE `+metaTable+` SET value = value + 1 WHERE name = `+p+`;`, metaCommitToken); err != nil {
+		return nil, err
+	}
+	var h int64
+	if err := tx.QueryRow(`SELECT value FROM `+metaTable+` WHERE name = `+p+`;`, metaCommitToken).Scan(&h); err != nil {
+		return nil, err
+	}
+	return func() {
+		for {
+			cur := rs.written.Load()
+			if cur >= h || rs.written.CompareAndSwap(cur, h) {
+				return
+			}
+		}
+	}, nil
+}
+
+// reader returns a database connection that should be used for read-only queries.
+func (rs *replicaSet) reader(ctx context.Context) *sql.DB {
+	n := len(rs.list)
+	if n == 0 {
+		return rs.primary
+	}
+	want := rs.written.Load()
+	start := int(rs.next.Add(1))
+	for i := 0; i < n; i++ {
+		r := rs.list[(start+i)%n]
+		if !r.healthy.Load() {
+			continue
+		}
+		if rs.readYourWrites && r.token.Load() < want {
+			// the position is cached, so lagging replicas are not queried on every read
+			now := time.Now().UnixNano()
+			last := r.checked.Load()
+			if now-last < int64(positionRefresh) || !r.checked.CompareAndSwap(last, now) {
+				continue
+			}
+			h, err := rs.tokenOf(ctx, r.db)
+			if err != nil {
+				continue
+			}
+			r.token.Store(h)
+			if h < want {
+				// replica is lagging behind
+				continue
+			}
+		}
+		return r.db
+	}
+	return rs.primary
+}
+
+func (rs *replicaSet) checkHealth(interval time.Duration) {
+	defer rs.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, r := range rs.list {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := r.db.PingContext(ctx)
+			cancel()
+			if ok := err == nil; ok != r.healthy.Swap(ok) {
+				if ok {
+					clog.Infof("sql: replica %s is healthy", r.addr)
+				} else {
+					clog.Warningf("sql: replica %s is unhealthy: %v", r.addr, err)
+				}
+			}
+		}
+	}
+}
+
+func (rs *replicaSet) Close() error {
+	if rs.stop != nil {
+		close(rs.stop)
+		rs.wg.Wait()
+		rs.stop = nil
+	}
+	var last error
+	for _, r := range rs.list {
+		if err := r.db.Close(); err != nil {
+			last = err
+		}
+	}
+	rs.list = nil
+	return last
+}
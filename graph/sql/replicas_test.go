@@ -0,0 +1,91 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+func TestReadYourWritesInterleaved(t *testing.T) {
+	ctx := context.TODO()
+	r := Registration{
+		Driver:       "sqlite3",
+		QueryDialect: QueryDialect{Placeholder: func(int) string { return "?" }},
+	}
+	dir := t.TempDir()
+	paddr, raddr := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+	for _, addr := range []string{paddr, raddr} {
+		db, err := sql.Open(r.Driver, addr)
+		require.NoError(t, err)
+		_, err = db.Exec(r.metaTable())
+		require.NoError(t, err)
+		_, err = db.Exec(`CREATE TABLE data (v TEXT);`)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+	}
+	primary, err := sql.Open(r.Driver, paddr)
+	require.NoError(t, err)
+	defer primary.Close()
+	rs, err := newReplicaSet(primary, r, graph.Options{OptReplicas: raddr, OptReadYourWrites: true})
+	require.NoError(t, err)
+	defer rs.Close()
+	rep := rs.list[0]
+
+	write := func(v string) (*sql.Tx, func(), error) {
+		tx, err := primary.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err = tx.Exec(`INSERT INTO data VALUES (?);`, v); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		committed, err := rs.onWrite(tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		return tx, committed, nil
+	}
+	// the second writer can't get a commit token until the first one commits
+	txA, committedA, err := write("a")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		txC, committedC, err := write("c")
+		if err == nil {
+			if err = txC.Commit(); err == nil {
+				committedC()
+			}
+		}
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("second writer committed before the first one")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.NoError(t, txA.Commit())
+	committedA()
+	require.NoError(t, <-done)
+	require.Equal(t, int64(2), rs.written.Load())
+
+	// a replica is only used once it has the token of the last commit
+	replicate := func(token int64) *sql.DB {
+		_, err := rep.db.Exec(`DELETE FROM ` + metaTable + `;`)
+		require.NoError(t, err)
+		_, err = rep.db.Exec(`INSERT INTO `+metaTable+`(name, value) VALUES (?, ?);`, metaCommitToken, token)
+		require.NoError(t, err)
+		rep.checked.Store(0)
+		return rs.reader(ctx)
+	}
+	require.True(t, replicate(1) == primary)
+	require.True(t, replicate(2) == rep.db)
+}
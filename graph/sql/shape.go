@@ -36,9 +36,15 @@ var DefaultDialect = QueryDialect{
 }
 
 type QueryDialect struct {
-	RegexpOp    CmpOp
+	RegexpOp CmpOp
+	// RegexpFunc is a name of a function that checks if a string contains a match of a regexp.
+	// It is used if the database has no RegexpOp.
+	RegexpFunc  string
 	FieldQuote  func(string) string
 	Placeholder func(int) string
+	// BinaryString converts a string expression to compare strings byte by byte.
+	// It can be nil if the database compares strings this way by default.
+	BinaryString func(string) string
 }
 
 func NewBuilder(d QueryDialect) *Builder {
@@ -216,13 +222,17 @@ func (f Table) NameSQL() string {
 type CmpOp string
 
 const (
-	OpEqual  = CmpOp("=")
-	OpGT     = CmpOp(">")
-	OpGTE    = CmpOp(">=")
-	OpLT     = CmpOp("<")
-	OpLTE    = CmpOp("<=")
-	OpIsNull = CmpOp("IS NULL")
-	OpIsTrue = CmpOp("IS true")
+	OpEqual     = CmpOp("=")
+	OpGT        = CmpOp(">")
+	OpGTE       = CmpOp(">=")
+	OpLT        = CmpOp("<")
+	OpLTE       = CmpOp("<=")
+	OpIsNull    = CmpOp("IS NULL")
+	OpIsNotNull = CmpOp("IS NOT NULL")
+	OpIsTrue    = CmpOp("IS true")
+
+	// OpRegexpCall matches a field with a regexp using QueryDialect.RegexpFunc.
+	OpRegexpCall = CmpOp("REGEXP CALL")
 )
 
 type Expr interface {
@@ -238,6 +248,42 @@ func (Placeholder) SQL(b *Builder) string {
 	return b.Placeholder()
 }
 
+// Coalesce returns the first non-NULL value of expressions.
+type Coalesce []Expr
+
+func (Coalesce) isExpr() {}
+
+func (c Coalesce) SQL(b *Builder) string {
+	args := make([]string, 0, len(c))
+	for _, e := range c {
+		args = append(args, e.SQL(b))
+	}
+	return "COALESCE(" + strings.Join(args, ", ") + ")"
+}
+
+// Rank evaluates to the index of the first group that has a matching condition,
+// or to the number of groups if none of the conditions match.
+type Rank [][]Where
+
+func (Rank) isExpr() {}
+
+func (r Rank) SQL(b *Builder) string {
+	var buf strings.Builder
+	buf.WriteString("CASE")
+	for i, group := range r {
+		buf.WriteString(" WHEN ")
+		for j, w := range group {
+			if j > 0 {
+				buf.WriteString(" OR ")
+			}
+			buf.WriteString(w.SQL(b))
+		}
+		buf.WriteString(" THEN " + strconv.Itoa(i))
+	}
+	buf.WriteString(" ELSE " + strconv.Itoa(len(r)) + " END")
+	return buf.String()
+}
+
 type Where struct {
 	Field string
 	Table string
@@ -250,6 +296,9 @@ func (w Where) SQL(b *Builder) string {
 	if w.Table != "" {
 		name = w.Table + "." + b.EscapeField(name)
 	}
+	if w.Op == OpRegexpCall {
+		return b.d.RegexpFunc + "(" + name + ", " + w.Value.SQL(b) + ")"
+	}
 	parts := []string{name, string(w.Op)}
 	if w.Value != nil {
 		parts = append(parts, w.Value.SQL(b))
@@ -257,16 +306,47 @@ func (w Where) SQL(b *Builder) string {
 	return strings.Join(parts, " ")
 }
 
+// Order is a single sorting key of SELECT query.
+type Order struct {
+	Field string
+	Table string
+	// Expr is an expression to order by, used instead of the field.
+	Expr Expr
+	// Binary compares strings byte by byte, regardless of the collation.
+	Binary bool
+	Desc   bool
+}
+
+func (o Order) SQL(b *Builder) string {
+	var name string
+	if o.Expr != nil {
+		name = o.Expr.SQL(b)
+	} else {
+		name = b.EscapeField(o.Field)
+		if o.Table != "" {
+			name = o.Table + "." + name
+		}
+	}
+	if o.Binary && b.d.BinaryString != nil {
+		name = b.d.BinaryString(name)
+	}
+	if o.Desc {
+		name += " DESC"
+	}
+	return name
+}
+
 var _ Shape = Select{}
 
 // Select is a simplified representation of SQL SELECT query.
 type Select struct {
-	Fields []Field
-	From   []Source
-	Where  []Where
-	Params []Value
-	Limit  int64
-	Offset int64
+	Fields  []Field
+	From    []Source
+	Where   []Where
+	Params  []Value
+	OrderBy []Order
+	Limit   int64
+	Offset  int64
 
 	// TODO(dennwc): this field in unexported because we don't want it to a be a part of the API
 	//               however, it's necessary to make NodesFrom optimizations to work with SQL
@@ -278,6 +358,7 @@ func (s Select) Clone() Select {
 	s.From = append([]Source{}, s.From...)
 	s.Where = append([]Where{}, s.Where...)
 	s.Params = append([]Value{}, s.Params...)
+	s.OrderBy = append([]Order{}, s.OrderBy...)
 	return s
 }
 
@@ -288,7 +369,30 @@ func (s Select) isAll() bool {
 // onlyAsSubquery indicates that query cannot be merged into existing SELECT because of some specific properties of query.
 // An example of such properties might be LIMIT, DISTINCT, etc.
 func (s Select) onlyAsSubquery() bool {
-	return s.Limit > 0 || s.Offset > 0
+	return s.Limit > 0 || s.Offset > 0 || len(s.OrderBy) != 0
+}
+
+// head returns an index of the primary field used by iterators, or -1 if there is none.
+func (s Select) head() int {
+	for i, f := range s.Fields {
+		if f.Alias == tagNode {
+			return i
+		}
+	}
+	return -1
+}
+
+// isNodes checks if a query returns nodes and not quads.
+func (s Select) isNodes() bool {
+	if s.head() < 0 {
+		return false
+	}
+	for _, f := range s.Fields {
+		if f.Alias != tagNode && strings.HasPrefix(f.Alias, tagPref) {
+			return false
+		}
+	}
+	return true
 }
 
 func (s Select) Columns() []string {
@@ -352,6 +456,13 @@ func (s Select) SQL(b *Builder) string {
 		}
 		parts = append(parts, "WHERE "+strings.Join(wheres, " AND "))
 	}
+	if len(s.OrderBy) != 0 {
+		var order []string
+		for _, o := range s.OrderBy {
+			order = append(order, o.SQL(b))
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(order, ", "))
+	}
 	if s.Limit > 0 {
 		parts = append(parts, "LIMIT "+strconv.FormatInt(s.Limit, 10))
 	}
@@ -374,3 +485,56 @@ func (s Select) Args() []Value {
 	args = append(args, s.Params...)
 	return args
 }
+
+var _ Shape = Count{}
+
+// Count is a SELECT COUNT(*) query that returns a number of rows in a given Select.
+type Count struct {
+	Query Select
+}
+
+func (s Count) sel() Select {
+	q := s.Query
+	if q.Limit > 0 || q.Offset > 0 {
+		// LIMIT and OFFSET are applied after the COUNT, thus we should count rows of a subquery
+		return Select{
+			Fields: []Field{
+				{Name: "COUNT(*)", Raw: true}, // TODO: proper support for expressions
+			},
+			From: []Source{
+				Subquery{Query: q, Alias: "t_count"},
+			},
+		}
+	}
+	q.Fields = []Field{
+		{Name: "COUNT(*)", Raw: true}, // TODO: proper support for expressions
+	}
+	// order doesn't affect the number of rows
+	q.OrderBy = nil
+	return q
+}
+
+func (s Count) SQL(b *Builder) string {
+	return s.sel().SQL(b)
+}
+
+func (s Count) Args() []Value {
+	return s.sel().Args()
+}
+
+func (s Count) Columns() []string {
+	return s.sel().Columns()
+}
+
+func (s Count) BuildIterator(qs graph.QuadStore) iterator.Shape {
+	sq, ok := qs.(*QuadStore)
+	if !ok {
+		return iterator.NewError(fmt.Errorf("not a SQL quadstore: %T", qs))
+	}
+	// SQL iterator reports an exact size using the same COUNT query, so Count will issue a single query
+	return iterator.NewCount(sq.newIterator(s.Query), qs)
+}
+
+func (s Count) Optimize(ctx context.Context, r shape.Optimizer) (shape.Shape, bool) {
+	return s, false
+}
@@ -3,6 +3,7 @@ package sql
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/cayleygraph/cayley/graph"
@@ -34,6 +35,14 @@ func sVals(arr ...string) []Value {
 	return out
 }
 
+// nodeOrderSQL returns sorting keys for a given nodes table.
+func nodeOrderSQL(tbl string) string {
+	return strings.NewReplacer("t.", tbl+".").Replace(`CASE WHEN t.value_int IS NOT NULL OR t.value_float IS NOT NULL THEN 0` +
+		` WHEN t.value_time IS NOT NULL THEN 1 WHEN t.value_bool IS NOT NULL THEN 2 WHEN t.iri IS true THEN 3` +
+		` WHEN t.bnode IS true THEN 4 WHEN t.value_string IS NOT NULL THEN 5 ELSE 6 END,` +
+		` COALESCE(t.value_int, t.value_float), t.value_time, t.value_bool, t.value_string COLLATE "C", t.value`)
+}
+
 var shapeCases = []struct {
 	skip bool
 	name string
@@ -110,6 +119,52 @@ var shapeCases = []struct {
 	LIMIT 100
 	OFFSET 1`,
 	},
+	{
+		name: "count quads",
+		s:    shape.Count{Values: shape.Quads{}},
+		qu:   `SELECT COUNT(*) FROM quads AS t_1`,
+	},
+	{
+		name: "count quads page",
+		s:    shape.Count{Values: shape.Page{From: shape.Quads{}, Limit: 10}},
+		qu: `SELECT COUNT(*) FROM (SELECT t_1.subject_hash AS __subject, t_1.predicate_hash AS __predicate, t_1.object_hash AS __object, t_1.label_hash AS __label
+	FROM quads AS t_1
+	LIMIT 10) AS t_count`,
+	},
+	{
+		name: "sort nodes",
+		s: shape.Sort{From: shape.Filter{
+			From: shape.AllNodes{},
+			Filters: []shape.ValueFilter{
+				shape.Comparison{Op: iterator.CompareGT, Val: quad.Int(42)},
+			},
+		}},
+		qu:   `SELECT t_1.hash AS ` + tagNode + ` FROM nodes AS t_1 WHERE t_1.value_int > $1 ORDER BY ` + nodeOrderSQL("t_1") + `, t_1.hash`,
+		args: []Value{IntVal(42)},
+	},
+	{
+		name: "sort and page nodes",
+		s: shape.Page{
+			Skip: 5, Limit: 10,
+			From: shape.Sort{From: shape.QuadsAction{
+				Result: quad.Subject,
+				Filter: map[quad.Direction]graph.Ref{
+					quad.Predicate: sVal("p"),
+				},
+			}},
+		},
+		qu:   `SELECT t_1.subject_hash AS ` + tagNode + ` FROM quads AS t_1, nodes AS t_2 WHERE t_1.predicate_hash = $1 AND t_2.hash = t_1.subject_hash ORDER BY ` + nodeOrderSQL("t_2") + `, t_1.subject_hash LIMIT 10 OFFSET 5`,
+		args: sVals("p"),
+	},
+	{
+		name: "page and sort nodes",
+		s: shape.Sort{From: shape.Page{
+			Limit: 10,
+			From:  shape.AllNodes{},
+		}},
+		qu: `SELECT t_1.` + tagNode + ` AS ` + tagNode + ` FROM (SELECT hash AS ` + tagNode + ` FROM nodes LIMIT 10) AS t_1, nodes AS t_2 WHERE t_2.hash = t_1.` + tagNode +
+			` ORDER BY ` + nodeOrderSQL("t_2") + `, t_1.` + tagNode,
+	},
 	{
 		name: "quads with subject and predicate",
 		s: shape.Quads{
@@ -362,6 +417,9 @@ func TestSQLShapes(t *testing.T) {
 	dialect.Placeholder = func(i int) string {
 		return fmt.Sprintf("$%d", i)
 	}
+	dialect.BinaryString = func(s string) string {
+		return s + ` COLLATE "C"`
+	}
 	for _, c := range shapeCases {
 		t.Run(c.name, func(t *testing.T) {
 			opt := NewOptimizer()
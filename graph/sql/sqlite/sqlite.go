@@ -46,11 +46,9 @@ func init() {
 		QueryDialect:         QueryDialect,
 		NoOffsetWithoutLimit: true,
 		NoForeignKeys:        true,
-		Error: func(err error) error {
-			return err
-		},
-		Estimated: nil,
-		RunTx:     runTxSqlite,
+		Error:                convError,
+		Estimated:            nil,
+		RunTx:                runTxSqlite,
 	})
 }
 
@@ -120,7 +118,7 @@ func runTxSqlite(tx *sql.Tx, nodes []graphlog.NodeUpdate, quads []graphlog.QuadU
 		}
 		if !d.Del {
 			if insertQuad == nil {
-				insertQuad, err = tx.Prepare(`INSERT` + ignore + ` INTO quads(subject_hash, predicate_hash, object_hash, label_hash, ts) VALUES (?, ?, ?, ?, datetime());`)
+				insertQuad, err = tx.Prepare(`INSERT` + ignore + ` INTO quads(subject_hash, predicate_hash, object_hash, label_hash, ts) VALUES (?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%f', 'now'));`)
 				if err != nil {
 					return err
 				}
@@ -141,6 +139,13 @@ func runTxSqlite(tx *sql.Tx, nodes []graphlog.NodeUpdate, quads []graphlog.QuadU
 	return nil
 }
 
+func convError(err error) error {
+	if e, ok := err.(sqlite3.Error); ok && e.Code == sqlite3.ErrError && strings.HasPrefix(e.Error(), "no such table") {
+		return csql.ErrNoTable
+	}
+	return err
+}
+
 func convInsertError(err error) error {
 	if err == nil {
 		return nil
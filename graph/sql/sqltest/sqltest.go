@@ -1,6 +1,10 @@
 package sqltest
 
 import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"sort"
 	"testing"
 	"unicode/utf8"
 
@@ -11,11 +15,15 @@ import (
 	"github.com/cayleygraph/cayley/graph/graphtest"
 	"github.com/cayleygraph/cayley/graph/graphtest/testutil"
 	"github.com/cayleygraph/cayley/graph/sql"
+	"github.com/cayleygraph/cayley/query/path"
+	"github.com/cayleygraph/cayley/writer"
 )
 
 type Config struct {
 	TimeRound bool
 	TimeInMcs bool
+	// ConditionalIndexes is set if the database can store quads that only differ by the label.
+	ConditionalIndexes bool
 }
 
 func (c Config) quadStore() *graphtest.Config {
@@ -24,6 +32,7 @@ func (c Config) quadStore() *graphtest.Config {
 		TimeInMcs:           c.TimeInMcs,
 		TimeRound:           c.TimeRound,
 		OptimizesComparison: true,
+		NativeBatches:       true,
 	}
 }
 
@@ -40,6 +49,38 @@ func TestAll(t *testing.T, typ string, fnc DatabaseFunc, c *Config) {
 		t.Parallel()
 		testZeroRune(t, create)
 	})
+	t.Run("bulk load", func(t *testing.T) {
+		t.Parallel()
+		testBulkLoad(t, create, makeDatabaseFunc(typ, versioned(fnc)), c)
+	})
+	t.Run("upgrade", func(t *testing.T) {
+		t.Parallel()
+		testUpgrade(t, typ, fnc)
+	})
+	t.Run("replicas", func(t *testing.T) {
+		t.Parallel()
+		testReplicas(t, typ, fnc)
+	})
+	t.Run("next path", func(t *testing.T) {
+		t.Parallel()
+		testJoinedPaths(t, create)
+	})
+	t.Run("as of", func(t *testing.T) {
+		t.Parallel()
+		graphtest.TestAsOf(t, makeDatabaseFunc(typ, versioned(fnc)), c.quadStore())
+	})
+}
+
+// versioned enables history recording for created databases.
+func versioned(fnc DatabaseFunc) DatabaseFunc {
+	return func(t testing.TB) (string, graph.Options) {
+		addr, opts := fnc(t)
+		if opts == nil {
+			opts = make(graph.Options)
+		}
+		opts[sql.OptVersioned] = true
+		return addr, opts
+	}
 }
 
 func BenchmarkAll(t *testing.B, typ string, fnc DatabaseFunc, c *Config) {
@@ -93,3 +134,234 @@ func testZeroRune(t testing.TB, create testutil.DatabaseFunc) {
 	require.NoError(t, err)
 	require.Equal(t, obj, qsn)
 }
+
+func testBulkLoad(t testing.TB, create, createVersioned testutil.DatabaseFunc, c *Config) {
+	qs, opts := create(t)
+
+	w := testutil.MakeWriter(t, qs, opts)
+	existing := quad.MakeIRI("alice", "follows", "bob", "")
+	require.NoError(t, w.AddQuad(existing))
+
+	quads := []quad.Quad{
+		existing,
+		quad.MakeIRI("bob", "follows", "charlie", ""),
+		quad.MakeIRI("bob", "follows", "charlie", ""),
+		quad.MakeIRI("charlie", "follows", "alice", "graph"),
+		quad.Make(quad.IRI("charlie"), quad.IRI("name"), quad.String("Charlie"), nil),
+		quad.Make(quad.IRI("charlie"), quad.IRI("age"), quad.Int(42), nil),
+	}
+	bw, err := qs.(graph.BulkLoader).NewBulkWriter()
+	require.NoError(t, err)
+	_, err = bw.WriteQuads(quads[:3])
+	require.NoError(t, err)
+	_, err = bw.WriteQuads(quads[3:])
+	require.NoError(t, err)
+	require.NoError(t, bw.Close())
+
+	ctx := context.TODO()
+	st, err := qs.Stats(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), st.Quads.Value)
+	require.Equal(t, int64(9), st.Nodes.Value)
+
+	// reference counters must be correct, so nodes are removed with the last quad
+	for _, q := range []quad.Quad{quads[0], quads[1], quads[3], quads[4], quads[5]} {
+		require.NoError(t, w.RemoveQuad(q))
+	}
+	st, err = qs.Stats(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), st.Quads.Value)
+	require.Equal(t, int64(0), st.Nodes.Value)
+
+	// quads that only differ by a label are loaded, unless the database can't store them
+	require.NoError(t, w.AddQuad(existing))
+	labeled := []quad.Quad{
+		existing,
+		quad.MakeIRI("alice", "follows", "bob", "graph"),
+		quad.MakeIRI("bob", "follows", "charlie", "graph"),
+	}
+	bw, err = qs.(graph.BulkLoader).NewBulkWriter()
+	require.NoError(t, err)
+	_, err = bw.WriteQuads(labeled)
+	require.NoError(t, err)
+	if c.ConditionalIndexes {
+		require.NoError(t, bw.Close())
+		graphtest.ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), labeled, true)
+	} else {
+		require.ErrorIs(t, bw.Close(), sql.ErrBulkLabels)
+		graphtest.ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), []quad.Quad{existing}, false)
+	}
+
+	// the bulk loader does not record history
+	vqs, _ := createVersioned(t)
+	_, err = vqs.(graph.BulkLoader).NewBulkWriter()
+	require.Equal(t, sql.ErrBulkVersioned, err)
+}
+
+func testUpgrade(t testing.TB, typ string, fnc DatabaseFunc) {
+	addr, opts := fnc(t)
+	require.NoError(t, sql.Init(typ, addr, opts))
+
+	qs, err := sql.New(typ, addr, opts)
+	require.NoError(t, err)
+	require.NoError(t, qs.Close())
+
+	// no-op, if the schema is up to date
+	require.NoError(t, sql.Upgrade(typ, addr, opts))
+
+	// embedded databases may not see schema changes made by other connections,
+	// so only one connection is kept open at a time
+	exec := func(fnc func(db *gosql.DB) error) {
+		t.Helper()
+		db, err := gosql.Open(sql.Driver(typ), addr)
+		require.NoError(t, err)
+		defer db.Close()
+		require.NoError(t, fnc(db))
+	}
+	// simulate a database created before migrations were introduced
+	dropMeta := func() {
+		t.Helper()
+		exec(func(db *gosql.DB) error {
+			if _, err := db.Exec(`DROP TABLE cayley_meta;`); err != nil {
+				return err
+			}
+			if _, err := db.Exec(`DROP INDEX label_index;`); err != nil {
+				_, err = db.Exec(`DROP INDEX label_index ON quads;`)
+				return err
+			}
+			return nil
+		})
+	}
+	dropMeta()
+	require.NoError(t, graph.UpgradeQuadStore(typ, addr, opts))
+	qs, err = sql.New(typ, addr, opts)
+	require.NoError(t, err)
+	require.NoError(t, qs.Close())
+
+	// migrations are never applied when the database is opened
+	dropMeta()
+	_, err = sql.New(typ, addr, opts)
+	require.Error(t, err)
+	require.NoError(t, sql.Upgrade(typ, addr, opts))
+	qs, err = sql.New(typ, addr, opts)
+	require.NoError(t, err)
+	require.NoError(t, qs.Close())
+
+	// newer schema versions are rejected
+	exec(func(db *gosql.DB) error {
+		_, err := db.Exec(`UPDATE cayley_meta SET value = 1000 WHERE name = 'schema_version';`)
+		return err
+	})
+	_, err = sql.New(typ, addr, opts)
+	require.Error(t, err)
+}
+
+func countQuads(t testing.TB, qs graph.QuadStore) int {
+	ctx := context.TODO()
+	it := qs.QuadsAllIterator().Iterate()
+	defer it.Close()
+	n := 0
+	for it.Next(ctx) {
+		n++
+	}
+	require.NoError(t, it.Err())
+	return n
+}
+
+func testReplicas(t testing.TB, typ string, fnc DatabaseFunc) {
+	addr, opts := fnc(t)
+	require.NoError(t, sql.Init(typ, addr, opts))
+	// replica is a separate database that never receives any updates
+	raddr, _ := fnc(t)
+	require.NoError(t, sql.Init(typ, raddr, nil))
+
+	q := quad.MakeIRI("alice", "follows", "bob", "")
+	for _, ryw := range []bool{false, true} {
+		ropts := graph.Options{
+			sql.OptReplicas:       []interface{}{raddr},
+			sql.OptReadYourWrites: ryw,
+		}
+		for k, v := range opts {
+			ropts[k] = v
+		}
+		qs, err := sql.New(typ, addr, ropts)
+		require.NoError(t, err)
+
+		err = qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{IgnoreDup: true})
+		require.NoError(t, err)
+		if ryw {
+			// replica is lagging, so the primary should be used
+			require.Equal(t, 1, countQuads(t, qs))
+			require.NoError(t, qs.Close())
+
+			// replica catches up with a commit token of the primary, but has a different data to tell them apart
+			var token int64
+			exec := func(addr string, fnc func(db *gosql.DB) error) {
+				t.Helper()
+				db, err := gosql.Open(sql.Driver(typ), addr)
+				require.NoError(t, err)
+				defer db.Close()
+				require.NoError(t, fnc(db))
+			}
+			exec(addr, func(db *gosql.DB) error {
+				return db.QueryRow(`SELECT value FROM cayley_meta WHERE name = 'commit_token';`).Scan(&token)
+			})
+			replicated := []quad.Quad{quad.MakeIRI("bob", "follows", "alice", "")}
+			rqs, err := sql.New(typ, raddr, nil)
+			require.NoError(t, err)
+			err = rqs.ApplyDeltas([]graph.Delta{{Quad: replicated[0], Action: graph.Add}}, graph.IgnoreOpts{})
+			require.NoError(t, err)
+			require.NoError(t, rqs.Close())
+			exec(raddr, func(db *gosql.DB) error {
+				_, err := db.Exec(fmt.Sprintf(`INSERT INTO cayley_meta (name, value) VALUES ('commit_token', %d);`, token))
+				return err
+			})
+
+			qs, err = sql.New(typ, addr, ropts)
+			require.NoError(t, err)
+			graphtest.ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), replicated, false)
+			// a transaction that only removes quads must be visible as well
+			err = qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Delete}}, graph.IgnoreOpts{})
+			require.NoError(t, err)
+			require.Equal(t, 0, countQuads(t, qs))
+		} else {
+			// reads are served by a replica
+			require.Equal(t, 0, countQuads(t, qs))
+			// but reads that decide what to write must see the last write
+			require.Equal(t, 1, countQuads(t, graph.PrimaryOf(qs)))
+			w, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+			require.NoError(t, err)
+			require.NoError(t, w.RemoveNode(quad.IRI("alice")))
+			require.NoError(t, w.Close())
+			require.Equal(t, 0, countQuads(t, graph.PrimaryOf(qs)))
+		}
+		require.NoError(t, qs.Close())
+	}
+}
+
+func testJoinedPaths(t testing.TB, create testutil.DatabaseFunc) {
+	qs, opts := create(t)
+
+	testutil.MakeWriter(t, qs, opts,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("alice", "follows", "charlie", ""),
+		quad.MakeIRI("bob", "follows", "charlie", ""),
+		quad.MakeIRI("charlie", "follows", "alice", ""),
+	)
+	p := path.StartPath(qs, quad.IRI("alice"), quad.IRI("bob")).Save(quad.IRI("follows"), "target")
+
+	// joined rows of the same node are returned as separate results, without sorting them by node
+	vals, err := p.Iterate(context.TODO()).Paths(false).AllValues(qs)
+	require.NoError(t, err)
+	sort.Sort(quad.ByValueString(vals))
+	require.Equal(t, []quad.Value{quad.IRI("alice"), quad.IRI("alice"), quad.IRI("bob")}, vals)
+
+	var targets []string
+	err = p.Iterate(context.TODO()).Paths(true).TagValues(qs, func(m map[string]quad.Value) error {
+		targets = append(targets, quad.ToString(m["target"]))
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(targets)
+	require.Equal(t, []string{"<bob>", "<charlie>", "<charlie>"}, targets)
+}
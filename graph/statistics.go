@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph/refs"
+	"github.com/cayleygraph/quad"
+)
+
+// StatsTopValues is the number of the most frequent values that quad stores should report in DirectionStats.
+const StatsTopValues = 16
+
+// DirectionStats are statistics of values in a given direction of quads.
+type DirectionStats struct {
+	Quads  refs.Size // number of quads with a value in this direction
+	Values refs.Size // number of distinct values in this direction; zero if unknown
+	// Top is a histogram of the most frequent values in this direction, sorted by the number of quads
+	// in descending order. It may be empty or contain less than StatsTopValues entries.
+	Top []ValueCount
+}
+
+// ValueCount is a value with the number of quads it appears in.
+type ValueCount struct {
+	Value Ref
+	Quads int64
+}
+
+// Fanout returns an estimated number of quads for a single value in this direction.
+// The most frequent values are excluded from the estimation, since they are known to the optimizer by value.
+// It returns zero if the number of distinct values is unknown.
+func (s DirectionStats) Fanout() int64 {
+	quads, values := s.Quads.Value, s.Values.Value
+	if values <= 0 {
+		return 0
+	}
+	if n := int64(len(s.Top)); n < values {
+		for _, v := range s.Top {
+			quads -= v.Quads
+		}
+		values -= n
+	}
+	if quads <= 0 {
+		return 1
+	}
+	return (quads + values - 1) / values
+}
+
+// Statistician is an optional interface for quad stores that provide statistics of values per direction.
+// The query optimizer uses them to estimate sizes and costs of LinksTo and HasA iterators instead of
+// the fixed fanout factors.
+//
+// Statistics are only used as estimations, thus quad stores may cache them, as long as they are refreshed
+// after significant changes. The exact flag has the same meaning as for QuadIndexer.Stats.
+type Statistician interface {
+	DirectionStats(ctx context.Context, d quad.Direction, exact bool) (DirectionStats, error)
+}
+
+// StatsOf returns statistics of values in a given direction, if the quad store provides them.
+func StatsOf(ctx context.Context, qs QuadIndexer, d quad.Direction) (DirectionStats, bool) {
+	if h, ok := qs.(*Handle); ok {
+		qs = h.QuadStore
+	}
+	s, ok := qs.(Statistician)
+	if !ok {
+		return DirectionStats{}, false
+	}
+	st, err := s.DirectionStats(ctx, d, false)
+	if err != nil || st.Values.Value <= 0 {
+		return DirectionStats{}, false
+	}
+	return st, true
+}
+
+// TopValues is a helper for quad stores that collects the most frequent values for DirectionStats.
+type TopValues []ValueCount
+
+// Add records the number of quads for a value.
+func (t *TopValues) Add(v Ref, quads int64) {
+	top := *t
+	if len(top) >= StatsTopValues && top[len(top)-1].Quads >= quads {
+		return
+	}
+	i := sort.Search(len(top), func(i int) bool {
+		return top[i].Quads < quads
+	})
+	if len(top) < StatsTopValues {
+		top = append(top, ValueCount{})
+	}
+	copy(top[i+1:], top[i:])
+	top[i] = ValueCount{Value: v, Quads: quads}
+	*t = top
+}
@@ -14,7 +14,11 @@
 
 package graph
 
-import "github.com/cayleygraph/quad"
+import (
+	"time"
+
+	"github.com/cayleygraph/quad"
+)
 
 // Transaction stores a bunch of Deltas to apply together in an atomic step on the database.
 type Transaction struct {
@@ -22,6 +26,8 @@ type Transaction struct {
 	Deltas []Delta
 	// deltas stores the deltas in a map to avoid duplications
 	deltas map[Delta]struct{}
+	// Preconds stores conditions that must hold for the transaction to be applied
+	Preconds []Precondition
 }
 
 // NewTransaction initialize a new transaction.
@@ -64,6 +70,45 @@ func (t *Transaction) RemoveQuad(q quad.Quad) {
 	}
 }
 
+// AddExpiringQuad adds a quad to the transaction that will expire at a given time.
+// It overrides any previous add of the same quad in the transaction. See Expirer for details.
+func (t *Transaction) AddExpiringQuad(q quad.Quad, expires time.Time) {
+	if expires.IsZero() {
+		t.AddQuad(q)
+		return
+	}
+	for _, d := range t.Deltas {
+		if d.Action == Add && d.Quad == q {
+			t.deleteDelta(d)
+			break
+		}
+	}
+	t.addDelta(Delta{Quad: q, Action: Add, Expires: expires})
+}
+
+// ReplaceQuad adds a quad to the transaction that will replace all quads with the same subject, predicate and label.
+func (t *Transaction) ReplaceQuad(q quad.Quad) {
+	d := Delta{Quad: q, Action: Replace}
+	if _, ok := t.deltas[d]; !ok {
+		t.addDelta(d)
+	}
+}
+
+// RequireQuad adds a precondition that a quad must exist when the transaction is applied.
+func (t *Transaction) RequireQuad(q quad.Quad) {
+	t.Preconds = append(t.Preconds, Precondition{Kind: QuadExists, Quad: q})
+}
+
+// RequireNoValue adds a precondition that a subject must have no quads with a given predicate and label.
+func (t *Transaction) RequireNoValue(s, p, label quad.Value) {
+	t.Preconds = append(t.Preconds, Precondition{Kind: NoValue, Quad: quad.Quad{Subject: s, Predicate: p, Label: label}})
+}
+
+// RequireValue adds a precondition that a subject must have a single value o for a given predicate and label.
+func (t *Transaction) RequireValue(s, p, o, label quad.Value) {
+	t.Preconds = append(t.Preconds, Precondition{Kind: ValueEquals, Quad: quad.Quad{Subject: s, Predicate: p, Object: o, Label: label}})
+}
+
 func createDeltas(q quad.Quad) (ad, rd Delta) {
 	ad = Delta{
 		Quad:   q,
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cayleygraph/quad"
 )
@@ -59,4 +60,21 @@ func TestTransaction(t *testing.T) {
 	if len(tx.Deltas) != 1 {
 		t.Errorf("Expected [add, remove, remove]->[remove], have %d delta(s)", len(tx.Deltas))
 	}
+
+	// add, expiring add -> expiring add
+	exp := time.Now().Add(time.Hour)
+	tx = NewTransaction()
+	tx.AddQuad(quad.Make("E", "follows", "G", nil))
+	tx.AddExpiringQuad(quad.Make("E", "follows", "G", nil), exp)
+	if len(tx.Deltas) != 1 || !tx.Deltas[0].Expires.Equal(exp) {
+		t.Errorf("Expected [add, expiring add]->[expiring add], have %v", tx.Deltas)
+	}
+
+	// remove, expiring add -> remove, expiring add
+	tx = NewTransaction()
+	tx.RemoveQuad(quad.Make("E", "follows", "G", nil))
+	tx.AddExpiringQuad(quad.Make("E", "follows", "G", nil), exp)
+	if len(tx.Deltas) != 2 {
+		t.Errorf("Expected [remove, expiring add]->[remove, expiring add], have %d delta(s)", len(tx.Deltas))
+	}
 }
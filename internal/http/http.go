@@ -16,6 +16,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -24,6 +25,8 @@ import (
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/internal/gephi"
 	cayleyhttp "github.com/cayleygraph/cayley/server/http"
 	"github.com/cayleygraph/cayley/ui"
@@ -43,6 +46,32 @@ type Config struct {
 	ReadOnly bool
 	Timeout  time.Duration
 	Batch    int
+	Parallel int
+	// MemoryLimit is a memory budget in bytes for intermediate results of each query.
+	MemoryLimit int64
+	// Limits restrict the work done by each query. Queries that exceed them fail with an error.
+	Limits iterator.ResourceLimits
+}
+
+// ServeGephi streams the graph in Gephi format. The store is resolved for each request,
+// so access control applies to the stream as well.
+func (api *API) ServeGephi(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	done, err := cayleyhttp.BeginRead(api.handle)
+	if err != nil {
+		jsonResponse(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer done()
+	h, err := api.GetHandleForRequest(r)
+	if errors.Is(err, acl.ErrAccessDenied) {
+		jsonResponse(w, http.StatusForbidden, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	gs := &gephi.GraphStreamHandler{QS: h.QuadStore}
+	gs.ServeHTTP(w, r, params)
 }
 
 func SetupRoutes(handle *graph.Handle, cfg *Config) error {
@@ -63,14 +92,16 @@ func SetupRoutes(handle *graph.Handle, cfg *Config) error {
 	api.APIv1(r)
 
 	// Register Gephi API
-	gs := &gephi.GraphStreamHandler{QS: handle.QuadStore}
-	r.GET("/gephi/gs", gs.ServeHTTP)
+	r.GET("/gephi/gs", api.ServeGephi)
 
 	// Register API V2
 	api2 := cayleyhttp.NewBoundAPIv2(handle, r)
 	api2.SetReadOnly(cfg.ReadOnly)
 	api2.SetBatchSize(cfg.Batch)
 	api2.SetQueryTimeout(cfg.Timeout)
+	api2.SetQueryParallel(cfg.Parallel)
+	api2.SetQueryMemoryLimit(cfg.MemoryLimit)
+	api2.SetQueryResourceLimits(cfg.Limits)
 
 	// For non API requests serve the UI
 	r.NotFound = http.FileServer(http.FS(ui))
@@ -16,10 +16,17 @@ package http
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
 	"github.com/cayleygraph/quad"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/memstore"
 )
 
 var parseTests = []struct {
@@ -71,3 +78,35 @@ func TestParseJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestGephiACL(t *testing.T) {
+	qs := memstore.New(
+		quad.MakeIRI("alice", "knows", "bob", ""),
+		quad.MakeIRI("alice", "knows", "carol", "secret"),
+	)
+	p := &acl.Policy{Principals: map[string]acl.Rule{
+		"admin": {},
+		"alice": {DenyLabels: []string{"<secret>"}},
+	}}
+	api := &API{config: &Config{}, handle: &graph.Handle{QuadStore: acl.NewStore(qs, p)}}
+	serve := func(principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/gephi/gs", nil)
+		if principal != "" {
+			req.Header.Set(acl.DefaultHeader, principal)
+		}
+		rr := httptest.NewRecorder()
+		api.ServeGephi(rr, req, nil)
+		return rr
+	}
+	rr := serve("admin")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Contains(t, rr.Body.String(), "carol")
+
+	rr = serve("alice")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Contains(t, rr.Body.String(), "bob")
+	require.NotContains(t, rr.Body.String(), "carol")
+
+	rr = serve("")
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+}
@@ -26,7 +26,9 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/query"
+	cayleyhttp "github.com/cayleygraph/cayley/server/http"
 )
 
 type SuccessQueryWrapper struct {
@@ -87,13 +89,31 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 		return
 	default:
 	}
+	done, err := cayleyhttp.BeginRead(api.handle)
+	if err != nil {
+		jsonResponse(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer done()
 	h, err := api.GetHandleForRequest(r)
 	if err != nil {
 		errFunc(w, err)
 		return
 	}
+	opt := query.Options{
+		Collation:   query.JSON,
+		Parallel:    api.config.Parallel,
+		MemoryLimit: api.config.MemoryLimit,
+	}
+	if !api.config.Limits.IsZero() {
+		opt.Limiter = iterator.NewLimiter(api.config.Limits)
+	}
 	if l.HTTPQuery != nil {
 		defer r.Body.Close()
+		// languages with custom handlers read execution options from the context
+		ctx = query.Context(ctx, query.Options{
+			Parallel: opt.Parallel, MemoryLimit: opt.MemoryLimit, Limiter: opt.Limiter,
+		})
 		l.HTTPQuery(ctx, h.QuadStore, w, r.Body)
 		return
 	}
@@ -114,10 +134,8 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 		errFunc(w, err)
 		return
 	}
-	it, err := ses.Execute(ctx, string(bodyBytes), query.Options{
-		Collation: query.JSON,
-		Limit:     limit,
-	})
+	opt.Limit = limit
+	it, err := ses.Execute(ctx, string(bodyBytes), opt)
 	if err != nil {
 		errFunc(w, err)
 		return
@@ -23,6 +23,7 @@ import (
 
 	"github.com/dop251/goja"
 
+	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/query/path"
 	"github.com/cayleygraph/cayley/query/shape"
@@ -120,6 +121,49 @@ func (g *graphObject) Emit(call goja.FunctionCall) goja.Value {
 	return goja.Null()
 }
 
+// AsOf switches the graph to a state it had at a given time or horizon. All following queries
+// in the script will see the graph as it was at that point. Calling it without arguments returns
+// to the current state of the graph.
+//
+//	// javascript
+//	g.asOf("2020-01-01T00:00:00Z").V("<alice>").out("<follows>").all()
+//
+// Arguments:
+//
+// * `version` (Optional): A Date, an RFC 3339 time string, a date string or an integer horizon.
+//
+// Returns: graph object
+func (g *graphObject) AsOf(call goja.FunctionCall) goja.Value {
+	var v graph.Version
+	if args := exportArgs(call.Arguments); len(args) > 1 {
+		return throwErr(g.s.vm, errArgCount2{Expected: 1, Got: len(args)})
+	} else if len(args) == 1 {
+		switch a := args[0].(type) {
+		case nil:
+		case time.Time:
+			v.Time = a
+		case string:
+			var err error
+			v, err = graph.ParseVersion(a)
+			if err != nil {
+				return throwErr(g.s.vm, err)
+			}
+		default:
+			h, ok := toInt(a)
+			if !ok {
+				return throwErr(g.s.vm, fmt.Errorf("unsupported version type: %T", a))
+			}
+			v.Horizon = int64(h)
+		}
+	}
+	qs, err := graph.AsOf(g.s.ctx, g.s.live, v)
+	if err != nil {
+		return throwErr(g.s.vm, err)
+	}
+	g.s.qs = qs
+	return g.s.vm.ToValue(g)
+}
+
 // Backwards compatibility
 func (g *graphObject) CapitalizedUri(s string) quad.IRI {
 	return g.NewIRI(s)
@@ -50,7 +50,7 @@ func NewSession(qs graph.QuadStore) *Session {
 	s := &Session{
 		ctx: context.Background(),
 		sch: schema.NewConfig(),
-		qs:  qs, limit: -1,
+		qs:  qs, live: qs, limit: -1,
 	}
 	if err := s.buildEnv(); err != nil {
 		panic(err)
@@ -83,11 +83,12 @@ func (fieldNameMapper) MethodName(t reflect.Type, m reflect.Method) string {
 }
 
 type Session struct {
-	qs  graph.QuadStore
-	vm  *goja.Runtime
-	ns  voc.Namespaces
-	sch *schema.Config
-	col query.Collation
+	qs   graph.QuadStore // quad store used by queries; differs from live one if the graph is queried as of a past version
+	live graph.QuadStore
+	vm   *goja.Runtime
+	ns   voc.Namespaces
+	sch  *schema.Config
+	col  query.Collation
 
 	last string
 	p    *goja.Program
@@ -231,7 +232,7 @@ func (s *Session) runIterator(it iterator.Shape) error {
 	defer cancel()
 	stop := false
 	err := iterator.Iterate(ctx, it).Paths(true).TagEach(func(tags map[string]graph.Ref) error {
-		if !s.send(ctx, &Result{Tags: tags}) {
+		if !s.send(ctx, &Result{Tags: tags, qs: s.qs}) {
 			cancel()
 			stop = true
 		}
@@ -251,6 +252,8 @@ type Result struct {
 	Meta bool
 	Val  interface{}
 	Tags map[string]graph.Ref
+
+	qs graph.QuadStore // quad store that produced tags; the session may switch to a different one
 }
 
 func (r *Result) Result() interface{} {
@@ -293,10 +296,15 @@ func (s *Session) Execute(ctx context.Context, qu string, opt query.Options) (qu
 	if err := s.compile(qu); err != nil {
 		return nil, err
 	}
+	qs, err := graph.AsOf(ctx, s.live, opt.AsOf)
+	if err != nil {
+		return nil, err
+	}
+	s.qs = qs
 	s.limit = opt.Limit
 	s.count = 0
 	ctx, cancel := context.WithCancel(context.Background())
-	s.ctx = ctx
+	s.ctx = query.Context(ctx, opt)
 	s.col = opt.Collation
 	return &results{
 		col: opt.Collation,
@@ -391,7 +399,7 @@ func (it *results) jsonResult() interface{} {
 	}
 	sort.Strings(tagKeys)
 	for _, k := range tagKeys {
-		name, err := it.s.qs.NameOf(tags[k])
+		name, err := data.qs.NameOf(tags[k])
 		if err != nil {
 			it.err = err
 			return nil
@@ -433,7 +441,7 @@ func (it *results) replResult() interface{} {
 			if k == "$_" {
 				continue
 			}
-			knv, err := it.s.qs.NameOf(tags[k])
+			knv, err := data.qs.NameOf(tags[k])
 			if err != nil {
 				// ignore
 				continue
@@ -23,10 +23,11 @@ import (
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/graphtest/testutil"
-	_ "github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/memstore"
 	"github.com/cayleygraph/cayley/query"
 	_ "github.com/cayleygraph/cayley/writer"
 	"github.com/cayleygraph/quad"
+	"github.com/stretchr/testify/require"
 
 	// register global namespace for tests
 	_ "github.com/cayleygraph/quad/voc/rdf"
@@ -690,14 +691,51 @@ var testQueries = []struct {
 			"smart_person",
 		},
 	},
+	{
+		message: "use order by tags",
+		query: `
+			g.V("<dani>", "<bob>").tag("source").out("<follows>").tag("target").order("-target", "source").all()
+		`,
+		tag:    "target",
+		expect: []string{"<bob>", "<fred>", "<greg>"},
+	},
+	{
+		message: "use top",
+		query: `
+			g.V("<dani>", "<bob>").out("<follows>").top(2, "-").all()
+		`,
+		expect: []string{"<greg>", "<fred>"},
+	},
+	{
+		message: "use save count",
+		query: `
+			g.V("<alice>", "<bob>", "<charlie>", "<dani>").saveCount("<follows>", "degree").top(2, "-degree").all()
+		`,
+		expect: []string{"<charlie>", "<dani>"},
+	},
+	{
+		message: "use sample",
+		query: `
+			g.V("<dani>", "<bob>", "<charlie>").out("<follows>").sample(10, 1).all()
+		`,
+		expect: []string{"<bob>", "<bob>", "<fred>", "<greg>", "<dani>"},
+	},
+	{
+		message: "use sample count",
+		query: `
+			g.V().sample(3).count()
+		`,
+		expect: []string{"3"},
+	},
 }
 
-func runQueryGetTag(rec func(), g []quad.Quad, qu string, tag string, limit int) ([]string, error) {
+func runQueryGetTag(rec func(), g []quad.Quad, qu string, tag string, limit, parallel int) ([]string, error) {
 	js := makeTestSession(g)
 	ctx := context.TODO()
 	it, err := js.Execute(ctx, qu, query.Options{
 		Collation: query.Raw,
 		Limit:     limit,
+		Parallel:  parallel,
 	})
 	if err != nil {
 		return nil, err
@@ -732,7 +770,14 @@ func runQueryGetTag(rec func(), g []quad.Quad, qu string, tag string, limit int)
 }
 
 func TestGizmo(t *testing.T) {
+	testGizmo(t, 0)
+}
+
+func TestGizmoParallel(t *testing.T) {
+	testGizmo(t, 4)
+}
 
+func testGizmo(t *testing.T, parallel int) {
 	simpleGraph := testutil.LoadGraph(t, "../../data/testdata.nq")
 	multiGraph := testutil.LoadGraph(t, multiGraphTestFile)
 
@@ -760,7 +805,7 @@ func TestGizmo(t *testing.T) {
 			if limit == 0 {
 				limit = -1
 			}
-			got, err := runQueryGetTag(rec, quads, test.query, test.tag, limit)
+			got, err := runQueryGetTag(rec, quads, test.query, test.tag, limit, parallel)
 			if err != nil {
 				if test.err {
 					return //expected
@@ -845,3 +890,35 @@ func issue718Nodes() []string {
 	}
 	return nodes
 }
+
+func TestAsOf(t *testing.T) {
+	qs := memstore.NewVersioned(issue160TestGraph...)
+	h := qs.Horizon()
+	err := qs.ApplyDeltas([]graph.Delta{
+		{Quad: quad.MakeRaw("alice", "is", "cool", ""), Action: graph.Delete},
+		{Quad: quad.MakeRaw("alice", "is", "not cool", ""), Action: graph.Add},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	ctx := context.TODO()
+	run := func(qu string, opt query.Options) []string {
+		opt.Collation = query.JSON
+		it, err := NewSession(qs).Execute(ctx, qu, opt)
+		require.NoError(t, err)
+		defer it.Close()
+		var out []string
+		for it.Next(ctx) {
+			out = append(out, fmt.Sprint(it.Result()))
+		}
+		require.NoError(t, it.Err())
+		return out
+	}
+	const qu = `g.V("alice").out("is").all()`
+	require.Equal(t, []string{"map[id:not cool]"}, run(qu, query.Options{}))
+	require.Equal(t, []string{"map[id:cool]"}, run(qu, query.Options{AsOf: graph.Version{Horizon: h}}))
+	require.Equal(t, []string{"map[id:cool]"}, run(fmt.Sprintf(`g.asOf(%d).V("alice").out("is").all()`, h), query.Options{}))
+	require.Equal(t, []string{"map[id:cool]", "map[id:not cool]"}, run(fmt.Sprintf(`
+		g.asOf(%d).V("alice").out("is").all();
+		g.asOf().V("alice").out("is").all();
+	`, h), query.Options{}))
+}
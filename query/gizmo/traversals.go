@@ -702,8 +702,104 @@ func (p *pathObject) Skip(offset int) *pathObject {
 	return p.new(np)
 }
 
-func (p *pathObject) Order() *pathObject {
-	np := p.clonePath().Order()
+// Order sorts values in the path.
+//
+// Signature: ([tag], ...)
+//
+// Arguments:
+//
+// * `tag` (Optional): A tag to sort by. Tags prefixed with "-" are sorted in descending order.
+// If no tags are given, values are sorted by themselves in ascending order; use "-" to sort them in descending order.
+// Numbers, times and strings are compared according to their types.
+//
+// Example:
+// 	// javascript
+//	// Sort people by age (oldest first), then by name.
+//	g.V().has("<type>", "<person>").save("<age>", "age").save("<name>", "name").order("-age", "name").all()
+func (p *pathObject) Order(tags ...string) *pathObject {
+	if len(tags) == 0 {
+		return p.new(p.clonePath().Order())
+	}
+	return p.new(p.clonePath().OrderBy(iterator.ParseSortKeys(tags...)...))
+}
+
+// Top keeps only the first nodes of the path, sorted by given tags.
+// It is the same as order followed by limit, but never sorts more than the given number of nodes.
+//
+// Signature: (limit, [tag], ...)
+//
+// Arguments:
+//
+// * `limit`: A number of nodes to return.
+// * `tag` (Optional): A tag to sort by, as in `path.order`.
+//
+// Example:
+// 	// javascript
+//	// Three oldest people.
+//	g.V().has("<type>", "<person>").save("<age>", "age").top(3, "-age").all()
+func (p *pathObject) Top(limit int, tags ...string) *pathObject {
+	np := p.clonePath().Top(int64(limit), iterator.ParseSortKeys(tags...)...)
+	return p.new(np)
+}
+
+// SaveCount saves the number of nodes reachable from each node of the path into a tag.
+// It allows to order nodes by their degree with `path.top` or `path.order`.
+//
+// Signature: (predicate or path, tag)
+//
+// Arguments:
+//
+// * `predicate or path`: A predicate to follow (as in `path.out`), or a morphism to count nodes of.
+// * `tag`: A string for a tag key to store the number.
+//
+// Example:
+//
+//	// javascript
+//	// Two people that follow the most people.
+//	g.V().has("<follows>").saveCount("<follows>", "degree").top(2, "-degree").all()
+func (p *pathObject) SaveCount(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 2 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	tag, ok := args[1].(string)
+	if !ok {
+		return throwErr(p.s.vm, fmt.Errorf("expected string, got: %T", args[1]))
+	}
+	via := args[0]
+	if vp, ok := via.(*pathObject); ok {
+		via = vp.path
+	} else {
+		qv, err := toQuadValue(via)
+		if err != nil {
+			return throwErr(p.s.vm, err)
+		}
+		via = qv
+	}
+	np := p.clonePath().SaveCount(via, tag)
+	return p.newVal(np)
+}
+
+// Sample selects a number of random nodes from the path.
+// All nodes are read, but only the selected ones are kept in memory.
+//
+// Signature: (limit, [seed])
+//
+// Arguments:
+//
+// * `limit`: A number of nodes to select.
+// * `seed` (Optional): A non-zero seed of the random generator. The same seed selects the same nodes for the same data.
+//
+// Example:
+// 	// javascript
+//	// Two random people that follow someone.
+//	g.V().has("<follows>").sample(2).all()
+func (p *pathObject) Sample(limit int, seed ...int64) *pathObject {
+	var sd int64
+	if len(seed) != 0 {
+		sd = seed[0]
+	}
+	np := p.clonePath().Sample(int64(limit), sd)
 	return p.new(np)
 }
 
@@ -97,6 +97,15 @@ func (s *Session) Execute(ctx context.Context, input string, opt query.Options)
 	if opt.Limit < 0 {
 		return nil, fmt.Errorf("gql: limit must be non-negative, got %d", opt.Limit)
 	}
+	if !opt.AsOf.IsZero() {
+		qs, err := graph.AsOf(ctx, s.qs, opt.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		ns := *s
+		ns.qs, opt.AsOf = qs, graph.Version{}
+		return ns.Execute(ctx, input, opt)
+	}
 
 	if strings.TrimSpace(input) == "" {
 		return nil, query.ErrParseMore
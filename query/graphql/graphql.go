@@ -62,15 +62,24 @@ func (s *Session) Execute(ctx context.Context, qu string, opt query.Options) (qu
 	default:
 		return nil, &query.ErrUnsupportedCollation{Collation: opt.Collation}
 	}
+	if !opt.AsOf.IsZero() {
+		qs, err := graph.AsOf(ctx, s.qs, opt.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		ns := *s
+		ns.qs, opt.AsOf = qs, graph.Version{}
+		return ns.Execute(ctx, qu, opt)
+	}
 	q, err := Parse(strings.NewReader(qu))
 	if err != nil {
 		return nil, err
 	}
-	return &results{
+	return query.WithOptions(&results{
 		s:   s,
 		q:   q,
 		col: opt.Collation,
-	}, nil
+	}, opt), nil
 }
 
 type results struct {
@@ -150,7 +159,7 @@ type object struct {
 
 func buildIterator(ctx context.Context, qs graph.QuadStore, p *path.Path) iterator.Shape {
 	it, _ := p.BuildIterator(ctx).Optimize(ctx)
-	return it
+	return iterator.Instrument(ctx, iterator.Enforce(ctx, it))
 }
 
 func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Path) (out []map[string]interface{}, _ error) {
@@ -212,7 +221,7 @@ func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Pa
 		it := buildIterator(ctx, qs, p).Iterate()
 		defer it.Close()
 
-		// we don't care about alternative paths to nodes here, so we will not call NextPath
+		// we don't care about alternative paths to nodes here, so we will not list Paths
 		// and we haven't tagged anything, so we will not call TagResult either
 		for i := 0; limit < 0 || i < limit; i++ {
 			select {
@@ -303,23 +312,31 @@ func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Pa
 		for k, v := range tags {
 			fields[k] = []graph.Ref{v}
 		}
-		for it.NextPath(ctx) {
-			select {
-			case <-ctx.Done():
-				return out, ctx.Err()
-			default:
-			}
-			tags = make(map[string]graph.Ref)
-			it.TagResults(tags)
-		dedup:
-			for k, v := range tags {
-				vals := fields[k]
-				for _, v2 := range vals {
-					if refs.ToKey(v) == refs.ToKey(v2) {
-						continue dedup
+		if paths := iterator.Paths(ctx, it); paths != nil {
+			for paths.Next(ctx) {
+				select {
+				case <-ctx.Done():
+					paths.Close()
+					return out, ctx.Err()
+				default:
+				}
+				tags = make(map[string]graph.Ref)
+				paths.TagResults(tags)
+			dedup:
+				for k, v := range tags {
+					vals := fields[k]
+					for _, v2 := range vals {
+						if refs.ToKey(v) == refs.ToKey(v2) {
+							continue dedup
+						}
 					}
+					fields[k] = append(vals, v)
 				}
-				fields[k] = append(vals, v)
+			}
+			err := paths.Err()
+			paths.Close()
+			if err != nil {
+				return out, err
 			}
 		}
 		obj := object{id: it.Result()}
@@ -3,20 +3,29 @@ package graphql
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
 
 	"github.com/dennwc/graphql/gqlerrors"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/query"
 )
 
 type httpResult struct {
-	Data   interface{}                `json:"data"`
-	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+	Data       interface{}                `json:"data"`
+	Errors     []gqlerrors.FormattedError `json:"errors,omitempty"`
+	Extensions map[string]interface{}     `json:"extensions,omitempty"`
 }
 
 func httpError(w query.ResponseWriter, err error) {
+	var lerr *iterator.LimitError
+	if errors.As(err, &lerr) {
+		// the query is valid, but it exceeded resource limits of the server
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
 	json.NewEncoder(w).Encode(httpResult{
 		Errors: []gqlerrors.FormattedError{{
 			Message: err.Error(),
@@ -35,5 +44,10 @@ func httpQuery(ctx context.Context, qs graph.QuadStore, w query.ResponseWriter,
 		httpError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(httpResult{Data: m})
+	res := httpResult{Data: m}
+	if p := iterator.ProfileFrom(ctx); p != nil {
+		// iterator statistics are returned as a response extension, see iterator.WithProfile
+		res.Extensions = map[string]interface{}{"profile": p}
+	}
+	json.NewEncoder(w).Encode(res)
 }
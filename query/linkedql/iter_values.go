@@ -41,7 +41,7 @@ func NewValueIteratorFromPathStep(step PathStep, qs graph.QuadStore, ns *voc.Nam
 // Next implements query.Iterator.
 func (it *ValueIterator) Next(ctx context.Context) bool {
 	if it.scanner == nil {
-		it.scanner = it.path.BuildIterator(ctx).Iterate()
+		it.scanner = iterator.Instrument(ctx, iterator.Enforce(ctx, it.path.BuildIterator(ctx))).Iterate()
 	}
 	return it.scanner.Next(ctx)
 }
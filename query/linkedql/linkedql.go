@@ -45,8 +45,17 @@ func NewSession(qs graph.QuadStore) *Session {
 }
 
 // Execute for a given context, query and options return an iterator of results.
-func (s *Session) Execute(ctx context.Context, query string, opt query.Options) (query.Iterator, error) {
-	item, err := Unmarshal([]byte(query))
+func (s *Session) Execute(ctx context.Context, qu string, opt query.Options) (query.Iterator, error) {
+	if !opt.AsOf.IsZero() {
+		qs, err := graph.AsOf(ctx, s.qs, opt.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		ns := *s
+		ns.qs, opt.AsOf = qs, graph.Version{}
+		return ns.Execute(ctx, qu, opt)
+	}
+	item, err := Unmarshal([]byte(qu))
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +64,11 @@ func (s *Session) Execute(ctx context.Context, query string, opt query.Options)
 	if !ok {
 		return nil, errors.New("must execute a Step")
 	}
-	return BuildIterator(step, s.qs, &ns)
+	it, err := BuildIterator(step, s.qs, &ns)
+	if err != nil {
+		return nil, err
+	}
+	return query.WithOptions(it, opt), nil
 }
 
 // BuildIterator for given Step returns a query.Iterator
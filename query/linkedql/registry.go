@@ -191,7 +191,12 @@ func Unmarshal(data []byte) (RegistryItem, error) {
 			if el.Kind() != reflect.Interface {
 				err := json.Unmarshal(v, fv.Addr().Interface())
 				if err != nil {
-					return nil, err
+					// compacted JSON-LD has a single value instead of an array with one element
+					one := reflect.New(el)
+					if iErr := json.Unmarshal(v, one.Interface()); iErr != nil {
+						return nil, err
+					}
+					fv.Set(reflect.Append(reflect.MakeSlice(f.Type, 0, 1), one.Elem()))
 				}
 			} else {
 				var arr []json.RawMessage
@@ -38,6 +38,15 @@ var unmarshalCases = []struct {
 }`,
 		exp: &TestStep{Tags: []string{"a", "b"}},
 	},
+	{
+		name: "single tag",
+		data: `{
+	"@context": { "@vocab": "http://cayley.io/linkedql#" },
+	"@type": "TestStep",
+	"tags": ["a"]
+}`,
+		exp: &TestStep{Tags: []string{"a"}},
+	},
 	{
 		name: "nested",
 		data: `{
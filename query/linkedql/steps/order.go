@@ -2,6 +2,7 @@ package steps
 
 import (
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/query/linkedql"
 	"github.com/cayleygraph/cayley/query/path"
 	"github.com/cayleygraph/quad/voc"
@@ -16,11 +17,12 @@ var _ linkedql.PathStep = (*Order)(nil)
 // Order corresponds to .order().
 type Order struct {
 	From linkedql.PathStep `json:"from"`
+	By   []string          `json:"by,omitempty"`
 }
 
 // Description implements Step.
 func (s *Order) Description() string {
-	return "sorts the results in ascending order according to the current entity / value"
+	return "sorts the results in ascending order according to the current entity / value. If by is set, sorts the results by values of given tags; tags prefixed with \"-\" are sorted in descending order"
 }
 
 // BuildPath implements linkedql.PathStep.
@@ -29,5 +31,8 @@ func (s *Order) BuildPath(qs graph.QuadStore, ns *voc.Namespaces) (*path.Path, e
 	if err != nil {
 		return nil, err
 	}
-	return fromPath.Order(), nil
+	if len(s.By) == 0 {
+		return fromPath.Order(), nil
+	}
+	return fromPath.OrderBy(iterator.ParseSortKeys(s.By...)...), nil
 }
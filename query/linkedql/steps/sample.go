@@ -0,0 +1,35 @@
+package steps
+
+import (
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/query/linkedql"
+	"github.com/cayleygraph/cayley/query/path"
+	"github.com/cayleygraph/quad/voc"
+)
+
+func init() {
+	linkedql.Register(&Sample{})
+}
+
+var _ linkedql.PathStep = (*Sample)(nil)
+
+// Sample corresponds to .sample().
+type Sample struct {
+	From  linkedql.PathStep `json:"from"`
+	Limit int64             `json:"limit"`
+	Seed  int64             `json:"seed,omitempty"`
+}
+
+// Description implements Step.
+func (s *Sample) Description() string {
+	return "selects a number of random nodes for current path. If seed is set, the same nodes are selected for the same data"
+}
+
+// BuildPath implements linkedql.PathStep.
+func (s *Sample) BuildPath(qs graph.QuadStore, ns *voc.Namespaces) (*path.Path, error) {
+	fromPath, err := s.From.BuildPath(qs, ns)
+	if err != nil {
+		return nil, err
+	}
+	return fromPath.Sample(s.Limit, s.Seed), nil
+}
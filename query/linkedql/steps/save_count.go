@@ -0,0 +1,39 @@
+package steps
+
+import (
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/query/linkedql"
+	"github.com/cayleygraph/cayley/query/path"
+	"github.com/cayleygraph/quad/voc"
+)
+
+func init() {
+	linkedql.Register(&SaveCount{})
+}
+
+var _ linkedql.PathStep = (*SaveCount)(nil)
+
+// SaveCount corresponds to .saveCount().
+type SaveCount struct {
+	From       linkedql.PathStep      `json:"from"`
+	Properties *linkedql.PropertyPath `json:"properties"`
+	Name       string                 `json:"name"`
+}
+
+// Description implements Step.
+func (s *SaveCount) Description() string {
+	return "saves the number of values of the given property or properties of the current objects under the given name. Can be used with Top or Order to sort objects by their degree"
+}
+
+// BuildPath implements linkedql.PathStep.
+func (s *SaveCount) BuildPath(qs graph.QuadStore, ns *voc.Namespaces) (*path.Path, error) {
+	fromPath, err := s.From.BuildPath(qs, ns)
+	if err != nil {
+		return nil, err
+	}
+	viaPath, err := s.Properties.BuildPath(qs, ns)
+	if err != nil {
+		return nil, err
+	}
+	return fromPath.SaveCount(viaPath, s.Name), nil
+}
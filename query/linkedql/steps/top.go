@@ -0,0 +1,36 @@
+package steps
+
+import (
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/query/linkedql"
+	"github.com/cayleygraph/cayley/query/path"
+	"github.com/cayleygraph/quad/voc"
+)
+
+func init() {
+	linkedql.Register(&Top{})
+}
+
+var _ linkedql.PathStep = (*Top)(nil)
+
+// Top corresponds to .top().
+type Top struct {
+	From  linkedql.PathStep `json:"from"`
+	Limit int64             `json:"limit"`
+	By    []string          `json:"by,omitempty"`
+}
+
+// Description implements Step.
+func (s *Top) Description() string {
+	return "keeps only the first nodes for current path, sorted the same way as Order. Only the given number of nodes is kept in memory while sorting"
+}
+
+// BuildPath implements linkedql.PathStep.
+func (s *Top) BuildPath(qs graph.QuadStore, ns *voc.Namespaces) (*path.Path, error) {
+	fromPath, err := s.From.BuildPath(qs, ns)
+	if err != nil {
+		return nil, err
+	}
+	return fromPath.Top(s.Limit, iterator.ParseSortKeys(s.By...)...), nil
+}
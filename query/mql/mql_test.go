@@ -187,3 +187,56 @@ func TestMQL(t *testing.T) {
 		})
 	}
 }
+
+var limitQueries = []struct {
+	message string
+	query   string
+	limit   int
+	expect  string
+}{
+	{
+		message: "limit results",
+		query:   `[{"id": null, "<follows>": []}]`,
+		limit:   2,
+		expect: `
+			[
+				{"id": "<alice>", "<follows>": ["<bob>"]},
+				{"id": "<follows>", "<follows>": null}
+			]
+		`,
+	},
+	{
+		// alternative paths are counted towards the limit, thus a result may be returned
+		// with only some of its values, and fewer results are returned
+		message: "limit counts paths",
+		query:   `[{"id": null, "!<follows>": {"id": null}}]`,
+		limit:   3,
+		expect: `
+			[
+				{"id": "<bob>", "!<follows>": {"id": "<dani>"}},
+				{"id": "<fred>", "!<follows>": {"id": "<bob>"}}
+			]
+		`,
+	},
+}
+
+func TestMQLLimit(t *testing.T) {
+	simpleGraph := testutil.LoadGraph(t, "../../data/testdata.nq")
+	for _, test := range limitQueries {
+		t.Run(test.message, func(t *testing.T) {
+			s := makeTestSession(simpleGraph)
+			ctx := context.TODO()
+			it, err := s.Execute(ctx, test.query, query.Options{Collation: query.JSON, Limit: test.limit})
+			require.NoError(t, err)
+			defer it.Close()
+			var got []interface{}
+			for it.Next(ctx) {
+				got = append(got, it.Result())
+			}
+			require.NoError(t, it.Err())
+			var expect interface{}
+			json.Unmarshal([]byte(test.expect), &expect)
+			require.Equal(t, expect, got)
+		})
+	}
+}
@@ -62,11 +62,6 @@ func (it *mqlIterator) Next(ctx context.Context) bool {
 		m := make(map[string]graph.Ref)
 		it.it.TagResults(m)
 		it.q.treeifyResult(m)
-		for it.it.NextPath(ctx) {
-			m = make(map[string]graph.Ref, len(m))
-			it.it.TagResults(m)
-			it.q.treeifyResult(m)
-		}
 	}
 	if err := it.it.Err(); err != nil {
 		return false
@@ -97,6 +92,15 @@ func (s *Session) Execute(ctx context.Context, input string, opt query.Options)
 	default:
 		return nil, &query.ErrUnsupportedCollation{Collation: opt.Collation}
 	}
+	if !opt.AsOf.IsZero() {
+		qs, err := graph.AsOf(ctx, s.qs, opt.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		ns := *s
+		ns.qs, opt.AsOf = qs, graph.Version{}
+		return ns.Execute(ctx, input, opt)
+	}
 	var mqlQuery interface{}
 	if err := json.Unmarshal([]byte(input), &mqlQuery); err != nil {
 		return nil, err
@@ -107,15 +111,18 @@ func (s *Session) Execute(ctx context.Context, input string, opt query.Options)
 		return nil, q.err
 	}
 
-	it := q.it.Iterate()
+	it := opt.Profile.Wrap(iterator.Enforce(iterator.WithLimiter(ctx, opt.Limiter), q.it)).Iterate()
 	if opt.Limit > 0 {
+		// alternative paths of a result are counted towards the limit, the same as with NextPath before
 		it = iterator.NewLimitNext(it, int64(opt.Limit))
 	}
-	return &mqlIterator{
+	// each path adds its bindings to the result tree
+	it = iterator.Bindings(it)
+	return query.WithOptions(&mqlIterator{
 		q:   q,
 		col: opt.Collation,
 		it:  it,
-	}, nil
+	}, opt), nil
 }
 
 func (s *Session) Clear() {
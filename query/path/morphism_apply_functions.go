@@ -308,29 +308,18 @@ func followMorphism(p *Path) morphism {
 	}
 }
 
-type iteratorBuilder func(qs graph.QuadStore) iterator.Shape
-
-func (s iteratorBuilder) BuildIterator(qs graph.QuadStore) iterator.Shape {
-	return s(qs)
-}
-func (s iteratorBuilder) Optimize(ctx context.Context, r shape.Optimizer) (shape.Shape, bool) {
-	return s, false
-}
-
 func followRecursiveMorphism(p *Path, maxDepth int, depthTags []string) morphism {
 	return morphism{
 		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
 			return followRecursiveMorphism(p.Reverse(), maxDepth, depthTags), ctx
 		},
 		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
-			return iteratorBuilder(func(qs graph.QuadStore) iterator.Shape {
-				in := in.BuildIterator(qs)
-				it := iterator.NewRecursive(in, p.MorphismFor(qs), maxDepth)
-				for _, s := range depthTags {
-					it.AddDepthTag(s)
-				}
-				return it
-			}), ctx
+			return shape.Recursive{
+				From:      in,
+				Step:      p.ShapeFrom,
+				MaxDepth:  maxDepth,
+				DepthTags: depthTags,
+			}, ctx
 		},
 	}
 }
@@ -437,11 +426,44 @@ func skipMorphism(v int64) morphism {
 	}
 }
 
-func orderMorphism() morphism {
+func orderMorphism(keys []iterator.SortKey) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return orderMorphism(keys), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return shape.Sort{From: in, Keys: keys}, ctx
+		},
+	}
+}
+
+// topMorphism keeps only the first k values ordered by given keys.
+func topMorphism(k int64, keys []iterator.SortKey) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return topMorphism(k, keys), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			if k <= 0 {
+				return shape.Null{}, ctx
+			}
+			return shape.Page{From: shape.Sort{From: in, Keys: keys}, Limit: k}, ctx
+		},
+	}
+}
+
+// saveCountMorphism tags each value with the number of values returned by p for it.
+func saveCountMorphism(p *Path, tag string) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return saveCountMorphism(p, tag), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return shape.SaveCount{From: in, Step: p.ShapeFrom, Tag: tag}, ctx
+		},
+	}
+}
+
+// sampleMorphism selects n random values.
+func sampleMorphism(n, seed int64) morphism {
 	return morphism{
-		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return orderMorphism(), ctx },
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return sampleMorphism(n, seed), ctx },
 		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
-			return shape.Sort{From: in}, ctx
+			return shape.Sample{From: in, N: n, Seed: seed}, ctx
 		},
 	}
 }
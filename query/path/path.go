@@ -413,6 +413,22 @@ func (p *Path) Save(via interface{}, tag string) *Path {
 	return np
 }
 
+// SaveCount saves the number of values reachable from each value of the path to a given tag.
+// Via is either a path, or a predicate (or a list of predicates) to follow, in which case it is the same as Out(via).
+//
+// For example:
+//  // Will tag each node with the number of outgoing "follows" links as "degree"
+//  StartPath(qs).SaveCount("follows", "degree")
+func (p *Path) SaveCount(via interface{}, tag string) *Path {
+	path, ok := via.(*Path)
+	if !ok {
+		path = StartMorphism().Out(via)
+	}
+	np := p.clone()
+	np.stack = append(np.stack, saveCountMorphism(path, tag))
+	return np
+}
+
 // SaveReverse is the same as Save, only in the reverse direction
 // (the subject of the linkage should be tagged, instead of the object).
 func (p *Path) SaveReverse(via interface{}, tag string) *Path {
@@ -543,8 +559,16 @@ func (p *Path) Skip(v int64) *Path {
 	return p
 }
 
+// Order sorts values in the result set. See OrderBy to sort by tags.
 func (p *Path) Order() *Path {
-	p.stack = append(p.stack, orderMorphism())
+	p.stack = append(p.stack, orderMorphism(nil))
+	return p
+}
+
+// OrderBy sorts values in the result set by given keys. Each key refers to a tag,
+// or to the value itself if the tag is empty. See iterator.Sort for details.
+func (p *Path) OrderBy(keys ...iterator.SortKey) *Path {
+	p.stack = append(p.stack, orderMorphism(keys))
 	return p
 }
 
@@ -554,6 +578,20 @@ func (p *Path) Limit(v int64) *Path {
 	return p
 }
 
+// Top keeps only the first k values of the result set ordered by given keys.
+// It is the same as OrderBy followed by Limit: only k values are kept in memory while sorting.
+func (p *Path) Top(k int64, keys ...iterator.SortKey) *Path {
+	p.stack = append(p.stack, topMorphism(k, keys))
+	return p
+}
+
+// Sample selects up to n random values from the result set.
+// The same seed returns the same values for the same data. Zero seed selects a random seed.
+func (p *Path) Sample(n, seed int64) *Path {
+	p.stack = append(p.stack, sampleMorphism(n, seed))
+	return p
+}
+
 // Count will count a number of results as it's own result set.
 func (p *Path) Count() *Path {
 	p.stack = append(p.stack, countMorphism())
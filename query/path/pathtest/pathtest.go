@@ -463,8 +463,9 @@ func testSet(qs graph.QuadStore) []test {
 			expect:  nil,
 		},
 		{
-			message: "use order",
-			path:    path.StartPath(qs).Order(),
+			message:  "use order",
+			path:     path.StartPath(qs).Order(),
+			unsorted: true,
 			expect: []quad.Value{
 				vAlice,
 				vAre,
@@ -483,9 +484,10 @@ func testSet(qs graph.QuadStore) []test {
 			},
 		},
 		{
-			message: "use order tags",
-			path:    path.StartPath(qs).Tag("target").Order(),
-			tag:     "target",
+			message:  "use order tags",
+			path:     path.StartPath(qs).Tag("target").Order(),
+			tag:      "target",
+			unsorted: true,
 			expect: []quad.Value{
 				vAlice,
 				vAre,
@@ -514,7 +516,56 @@ func testSet(qs graph.QuadStore) []test {
 			path:     path.StartPath(qs).Order().Has(vFollows, vBob),
 			expect:   []quad.Value{vAlice, vCharlie, vDani},
 			unsorted: true,
-			skip:     true, // TODO(dennwc): optimize Order in And properly
+		},
+		{
+			message:  "order by tags",
+			path:     path.StartPath(qs, vDani, vBob, vCharlie).Save(vFollows, "target").OrderBy(iterator.SortKey{Tag: "target", Desc: true}),
+			tag:      "target",
+			expect:   []quad.Value{vGreg, vFred, vDani, vBob, vBob},
+			unsorted: true,
+		},
+		{
+			message: "order by tags with limit",
+			path: path.StartPath(qs, vDani, vBob, vCharlie).Save(vFollows, "target").
+				OrderBy(iterator.SortKey{Tag: "target", Desc: true}).Limit(2),
+			tag:      "target",
+			expect:   []quad.Value{vGreg, vFred},
+			unsorted: true,
+		},
+		{
+			message:  "top by tags",
+			path:     path.StartPath(qs, vDani, vBob, vCharlie).Save(vFollows, "target").Top(2, iterator.SortKey{Tag: "target", Desc: true}),
+			tag:      "target",
+			expect:   []quad.Value{vGreg, vFred},
+			unsorted: true,
+		},
+		{
+			message: "save count",
+			path:    path.StartPath(qs, vAlice, vCharlie, vGreg).SaveCount(vFollows, "degree"),
+			tag:     "degree",
+			expect:  []quad.Value{quad.Int(1), quad.Int(2), quad.Int(0)},
+		},
+		{
+			message: "save count of a path",
+			path:    path.StartPath(qs, vBob, vGreg).SaveCount(path.StartMorphism().In(vFollows).In(vFollows), "degree"),
+			tag:     "degree",
+			expect:  []quad.Value{quad.Int(1), quad.Int(3)},
+		},
+		{
+			message:  "top by degree",
+			path:     path.StartPath(qs, vAlice, vBob, vCharlie, vDani, vFred).SaveCount(vFollows, "degree").Top(2, iterator.SortKey{Tag: "degree", Desc: true}),
+			expect:   []quad.Value{vCharlie, vDani},
+			unsorted: true,
+		},
+		{
+			message: "sample everything",
+			path:    path.StartPath(qs, vDani, vBob, vCharlie).Out(vFollows).Sample(10, 1),
+			expect:  []quad.Value{vBob, vBob, vFred, vGreg, vDani},
+		},
+		{
+			message: "sample nothing",
+			path:    path.StartPath(qs, vDani, vBob, vCharlie).Out(vFollows).Sample(0, 1),
+			expect:  nil,
 		},
 		{
 			message: "optional path",
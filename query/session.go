@@ -22,6 +22,7 @@ import (
 	"io"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
 )
 
 var ErrParseMore = errors.New("query: more input required")
@@ -49,6 +50,65 @@ type Iterator interface {
 	Close() error
 }
 
+// WithOptions wraps a query iterator to apply execution options that are passed to graph iterators
+// through the context: Options.Parallel, Options.MemoryLimit, Options.Profile and Options.Limiter.
+// It returns the iterator unchanged if none of these options are set.
+func WithOptions(it Iterator, opt Options) Iterator {
+	if opt.Parallel < 2 && opt.MemoryLimit <= 0 && opt.Profile == nil && opt.Limiter == nil {
+		return it
+	}
+	return &optionsIterator{Iterator: it, opt: newExecOptions(opt)}
+}
+
+// Context returns a context that applies execution options to graph iterators. See WithOptions.
+func Context(ctx context.Context, opt Options) context.Context {
+	return newExecOptions(opt).apply(ctx)
+}
+
+// execOptions holds per-query state for execution options.
+type execOptions struct {
+	workers *iterator.Workers
+	budget  *iterator.MemoryBudget
+	profile *iterator.Profile
+	limiter *iterator.Limiter
+}
+
+func newExecOptions(opt Options) execOptions {
+	o := execOptions{profile: opt.Profile, limiter: opt.Limiter}
+	if opt.Parallel >= 2 {
+		o.workers = iterator.NewWorkers(opt.Parallel)
+	}
+	if opt.MemoryLimit > 0 {
+		o.budget = iterator.NewMemoryBudget(opt.MemoryLimit)
+	}
+	return o
+}
+
+func (o execOptions) apply(ctx context.Context) context.Context {
+	if o.workers != nil {
+		ctx = iterator.WithWorkers(ctx, o.workers)
+	}
+	if o.budget != nil {
+		ctx = iterator.WithMemoryBudget(ctx, o.budget)
+	}
+	if o.profile != nil {
+		ctx = iterator.WithProfile(ctx, o.profile)
+	}
+	if o.limiter != nil {
+		ctx = iterator.WithLimiter(ctx, o.limiter)
+	}
+	return ctx
+}
+
+type optionsIterator struct {
+	Iterator
+	opt execOptions
+}
+
+func (it *optionsIterator) Next(ctx context.Context) bool {
+	return it.Iterator.Next(it.opt.apply(ctx))
+}
+
 // Collation of results.
 type Collation int
 
@@ -67,6 +127,22 @@ const (
 type Options struct {
 	Limit     int
 	Collation Collation
+	// AsOf runs the query against the graph as it was at a given version.
+	// Zero value means the current state. Quad store must record history (see graph.Historian).
+	AsOf graph.Version
+	// Parallel sets the maximal number of concurrent workers used to evaluate the query.
+	// Values less than 2 disable parallel execution. See iterator.WithWorkers for details.
+	Parallel int
+	// MemoryLimit sets an approximate memory budget in bytes for intermediate results of the query,
+	// such as sorted or deduplicated values. Results that exceed the budget are spilled to temporary files.
+	// Zero value means no limit. See iterator.WithMemoryBudget for details.
+	MemoryLimit int64
+	// Profile collects runtime statistics of iterators used by the query, if set.
+	// It can be read after the query is completed. See iterator.Profile for details.
+	Profile *iterator.Profile
+	// Limiter stops the query with an error when it exceeds resource limits, if set.
+	// The limiter must not be shared between queries. See iterator.Limiter for details.
+	Limiter *iterator.Limiter
 }
 
 type Session interface {
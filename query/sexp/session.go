@@ -78,38 +78,38 @@ func (s *Session) Execute(ctx context.Context, input string, opt query.Options)
 	default:
 		return nil, &query.ErrUnsupportedCollation{Collation: opt.Collation}
 	}
-	it := BuildIteratorTreeForQuery(ctx, s.qs, input).Iterate()
+	if !opt.AsOf.IsZero() {
+		qs, err := graph.AsOf(ctx, s.qs, opt.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		ns := *s
+		ns.qs, opt.AsOf = qs, graph.Version{}
+		return ns.Execute(ctx, input, opt)
+	}
+	it := iterator.Bindings(opt.Profile.Wrap(iterator.Enforce(iterator.WithLimiter(ctx, opt.Limiter), BuildIteratorTreeForQuery(ctx, s.qs, input))).Iterate())
 	if err := it.Err(); err != nil {
 		return nil, err
 	}
 	if opt.Limit > 0 {
 		it = iterator.NewLimitNext(it, int64(opt.Limit))
 	}
-	return &results{
+	return query.WithOptions(&results{
 		s:   s,
 		col: opt.Collation,
 		it:  it,
-	}, nil
+	}, opt), nil
 }
 
 type results struct {
-	s        *Session
-	col      query.Collation
-	it       iterator.Scanner
-	nextPath bool
-	err      error
+	s   *Session
+	col query.Collation
+	it  iterator.Scanner
+	err error
 }
 
 func (it *results) Next(ctx context.Context) bool {
-	if it.nextPath && it.it.NextPath(ctx) {
-		return true
-	}
-	it.nextPath = false
-	if it.it.Next(ctx) {
-		it.nextPath = true
-		return true
-	}
-	return false
+	return it.it.Next(ctx)
 }
 
 func (it *results) Result() interface{} {
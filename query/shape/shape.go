@@ -2,6 +2,7 @@ package shape
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"reflect"
 	"regexp"
@@ -936,6 +937,17 @@ func (s Intersect) Optimize(ctx context.Context, r Optimizer) (sout Shape, opt b
 	if len(s) == 0 {
 		return nil, true
 	}
+	// sorting only defines the order of the primary iterator, thus apply it to the whole intersection
+	for i, c := range s {
+		if so, ok := c.(Sort); ok {
+			arr := make(Intersect, len(s))
+			copy(arr, s)
+			arr[i] = so.From
+			so.From = arr
+			ns, _ := so.Optimize(ctx, r)
+			return ns, true
+		}
+	}
 	// function to lazily reallocate a copy of Intersect slice
 	realloc := func() {
 		if !opt {
@@ -1448,8 +1460,11 @@ func FilterQuads(subject, predicate, object, label []quad.Value) Shape {
 	return q
 }
 
+// Sort orders values from From by Keys. Values are ordered by themselves if no keys are set.
+// Analog of Sort iterator.
 type Sort struct {
 	From Shape
+	Keys []iterator.SortKey
 }
 
 func (s Sort) BuildIterator(qs graph.QuadStore) iterator.Shape {
@@ -1457,7 +1472,7 @@ func (s Sort) BuildIterator(qs graph.QuadStore) iterator.Shape {
 		return iterator.NewNull()
 	}
 	it := s.From.BuildIterator(qs)
-	return iterator.NewSort(qs, it)
+	return iterator.NewSort(qs, it, s.Keys...)
 }
 func (s Sort) Optimize(ctx context.Context, r Optimizer) (Shape, bool) {
 	if IsNull(s.From) {
@@ -1474,3 +1489,123 @@ func (s Sort) Optimize(ctx context.Context, r Optimizer) (Shape, bool) {
 	}
 	return s, opt
 }
+
+// Sample selects up to N random values from From. Zero Seed means a random seed.
+// Analog of Sample iterator.
+type Sample struct {
+	From Shape
+	N    int64
+	Seed int64
+}
+
+func (s Sample) BuildIterator(qs graph.QuadStore) iterator.Shape {
+	if IsNull(s.From) || s.N <= 0 {
+		return iterator.NewNull()
+	}
+	it := s.From.BuildIterator(qs)
+	return iterator.NewSample(it, s.N, s.Seed)
+}
+func (s Sample) Optimize(ctx context.Context, r Optimizer) (Shape, bool) {
+	if IsNull(s.From) || s.N <= 0 {
+		return nil, true
+	}
+	var opt bool
+	s.From, opt = s.From.Optimize(ctx, r)
+	if IsNull(s.From) {
+		return nil, true
+	}
+	if r != nil {
+		ns, nopt := r.OptimizeShape(ctx, s)
+		return ns, opt || nopt
+	}
+	return s, opt
+}
+
+// SaveCount tags each value from From with the number of values returned by Step for it.
+// Analog of SaveCount iterator.
+type SaveCount struct {
+	From Shape
+	Step func(from Shape) Shape // builds a traversal from a single value
+	Tag  string
+}
+
+func (s SaveCount) BuildIterator(qs graph.QuadStore) iterator.Shape {
+	if IsNull(s.From) {
+		return iterator.NewNull()
+	}
+	in := s.From.BuildIterator(qs)
+	return iterator.NewSaveCount(in, func(it iterator.Shape) iterator.Shape {
+		return s.Step(&iteratorShape{it: it}).BuildIterator(qs)
+	}, s.Tag)
+}
+func (s SaveCount) Optimize(ctx context.Context, r Optimizer) (Shape, bool) {
+	if IsNull(s.From) {
+		return nil, true
+	}
+	var opt bool
+	s.From, opt = s.From.Optimize(ctx, r)
+	if IsNull(s.From) {
+		return nil, true
+	}
+	if r != nil {
+		ns, nopt := r.OptimizeShape(ctx, s)
+		return ns, opt || nopt
+	}
+	return s, opt
+}
+
+// Recursive applies a Step to the nodes from the previous iteration, starting from nodes in From,
+// until no new nodes are found or MaxDepth is reached. Analog of Recursive iterator.
+type Recursive struct {
+	From      Shape
+	Step      func(from Shape) Shape // builds a single step of traversal from a given set of nodes
+	MaxDepth  int                    // max depth of the recursion; zero means default
+	DepthTags []string               // tags to save the depth of each result to
+}
+
+func (s Recursive) BuildIterator(qs graph.QuadStore) iterator.Shape {
+	if IsNull(s.From) {
+		return iterator.NewNull()
+	}
+	in := s.From.BuildIterator(qs)
+	it := iterator.NewRecursive(in, func(it iterator.Shape) iterator.Shape {
+		return s.Step(&iteratorShape{it: it}).BuildIterator(qs)
+	}, s.MaxDepth)
+	for _, t := range s.DepthTags {
+		it.AddDepthTag(t)
+	}
+	return it
+}
+func (s Recursive) Optimize(ctx context.Context, r Optimizer) (Shape, bool) {
+	if IsNull(s.From) {
+		return nil, true
+	}
+	var opt bool
+	s.From, opt = s.From.Optimize(ctx, r)
+	if IsNull(s.From) {
+		return nil, true
+	}
+	if r != nil {
+		ns, nopt := r.OptimizeShape(ctx, s)
+		return ns, opt || nopt
+	}
+	return s, opt
+}
+
+// iteratorShape wraps an existing iterator into a Shape. It can be built only once.
+type iteratorShape struct {
+	it   iterator.Shape
+	sent bool
+}
+
+func (s *iteratorShape) BuildIterator(qs graph.QuadStore) iterator.Shape {
+	if s.sent {
+		return iterator.NewError(fmt.Errorf("iterator already used in query"))
+	}
+	it := s.it
+	s.it, s.sent = nil, true
+	return it
+}
+func (s *iteratorShape) Optimize(ctx context.Context, r Optimizer) (Shape, bool) {
+	return s, false
+}
@@ -502,17 +502,25 @@ func (l *loader) loadIteratorToDepth(ctx context.Context, dst reflect.Value, dep
 		for k, v := range mp {
 			mo[k] = []graph.Ref{v}
 		}
-		for it.NextPath(ctx) {
-			if ctxDone() {
-				return ctx.Err()
+		if paths := iterator.Paths(ctx, it); paths != nil {
+			for paths.Next(ctx) {
+				if ctxDone() {
+					paths.Close()
+					return ctx.Err()
+				}
+				mp = make(map[string]graph.Ref)
+				paths.TagResults(mp)
+				if len(mp) == 0 {
+					continue
+				}
+				// TODO(dennwc): replace with something more efficient
+				mergeMap(mo, mp)
 			}
-			mp = make(map[string]graph.Ref)
-			it.TagResults(mp)
-			if len(mp) == 0 {
-				continue
+			err = paths.Err()
+			paths.Close()
+			if err != nil {
+				return err
 			}
-			// TODO(dennwc): replace with something more efficient
-			mergeMap(mo, mp)
 		}
 		if id != nil {
 			sv := cur
@@ -5,6 +5,8 @@ import (
 	"reflect"
 
 	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph"
 )
 
 // WriteAsQuads writes a single value in form of quads into specified quad writer.
@@ -13,6 +15,9 @@ import (
 // an annotated ID field, it's value will be converted to quad.Value and returned.
 // Otherwise, a new BNode will be generated using GenerateID function.
 //
+// If the writer implements graph.QuadReplacer, values of single-valued fields replace existing values
+// of corresponding properties, so the object is upserted. Zero optional fields are left unchanged.
+//
 // See LoadTo for a list of quads mapping rules.
 func (c *Config) WriteAsQuads(w quad.Writer, o interface{}) (quad.Value, error) {
 	wr := c.newWriter(w)
@@ -36,8 +41,17 @@ func (w *writer) writeQuad(s, p, o quad.Value, rev bool) error {
 	return w.w.WriteQuad(quad.Quad{Subject: s, Predicate: p, Object: o, Label: w.c.Label})
 }
 
+// replaceQuad writes a quad that replaces existing values of a property, if the writer supports it.
+func (w *writer) replaceQuad(s, p, o quad.Value) error {
+	if rw, ok := w.w.(graph.QuadReplacer); ok {
+		return rw.ReplaceQuad(quad.Quad{Subject: s, Predicate: p, Object: o, Label: w.c.Label})
+	}
+	return w.writeQuad(s, p, o, false)
+}
+
 // writeOneValReflect writes a set of quads corresponding to a value. It may omit writing quads if value is zero.
-func (w *writer) writeOneValReflect(id quad.Value, pred quad.Value, rv reflect.Value, rev bool) error {
+// If single is set, the value replaces existing values of the property.
+func (w *writer) writeOneValReflect(id quad.Value, pred quad.Value, rv reflect.Value, rev, single bool) error {
 	if isZero(rv) {
 		return nil
 	}
@@ -47,6 +61,9 @@ func (w *writer) writeOneValReflect(id quad.Value, pred quad.Value, rv reflect.V
 		return err
 	}
 	// write a quad pointing to this value
+	if single && !rev {
+		return w.replaceQuad(id, pred, sid)
+	}
 	return w.writeQuad(id, pred, sid, rev)
 }
 
@@ -95,7 +112,7 @@ func (w *writer) writeValueAs(id quad.Value, rv reflect.Value, pref string, rule
 			if f.Type.Kind() == reflect.Slice {
 				sl := rv.Field(i)
 				for j := 0; j < sl.Len(); j++ {
-					if err := w.writeOneValReflect(id, r.Pred, sl.Index(j), r.Rev); err != nil {
+					if err := w.writeOneValReflect(id, r.Pred, sl.Index(j), r.Rev, false); err != nil {
 						return err
 					}
 				}
@@ -104,7 +121,7 @@ func (w *writer) writeValueAs(id quad.Value, rv reflect.Value, pref string, rule
 				if !r.Opt && isZero(fv) {
 					return ErrReqFieldNotSet{Field: f.Name}
 				}
-				if err := w.writeOneValReflect(id, r.Pred, fv, r.Rev); err != nil {
+				if err := w.writeOneValReflect(id, r.Pred, fv, r.Rev, true); err != nil {
 					return err
 				}
 			}
@@ -5,7 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/memstore"
 	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/writer"
 	"github.com/cayleygraph/quad"
 )
 
@@ -48,6 +52,35 @@ func TestWriteAsQuads(t *testing.T) {
 	}
 }
 
+func TestWriteAsQuadsUpsert(t *testing.T) {
+	type person struct {
+		ID   quad.IRI `quad:"@id"`
+		Name string   `quad:"name"`
+		Tags []string `quad:"tags"`
+	}
+	sch := schema.NewConfig()
+	qs := memstore.New()
+	w, err := writer.NewSingle(qs, graph.IgnoreOpts{IgnoreDup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upsert := func(p person) {
+		tx := graph.NewTransaction()
+		if _, err := sch.WriteAsQuads(graph.NewTxWriter(tx, graph.Add), p); err != nil {
+			t.Fatal(err)
+		} else if err = w.ApplyTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	upsert(person{ID: "bob", Name: "Bob", Tags: []string{"a"}})
+	upsert(person{ID: "bob", Name: "Bobby", Tags: []string{"b"}})
+	graphtest.ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), []quad.Quad{
+		quad.Make(quad.IRI("bob"), quad.IRI("name"), "Bobby", nil),
+		quad.Make(quad.IRI("bob"), quad.IRI("tags"), "a", nil),
+		quad.Make(quad.IRI("bob"), quad.IRI("tags"), "b", nil),
+	}, true)
+}
+
 var testWriteValueCases = []struct {
 	name   string
 	obj    interface{}
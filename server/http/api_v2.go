@@ -23,6 +23,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,11 +31,11 @@ import (
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/query"
 	"github.com/cayleygraph/cayley/query/shape"
-
-	// Writer is imported for writers to be registered
-	_ "github.com/cayleygraph/cayley/writer"
+	"github.com/cayleygraph/cayley/writer"
 	"github.com/cayleygraph/quad"
 	"github.com/cayleygraph/quad/voc"
 )
@@ -82,8 +83,11 @@ type APIv2 struct {
 	wopt graph.Options
 
 	// query
-	timeout time.Duration
-	limit   int
+	timeout  time.Duration
+	limit    int
+	parallel int
+	memLimit int64
+	limits   iterator.ResourceLimits
 }
 
 // SetReadOnly sets read-only mode for the request
@@ -106,6 +110,23 @@ func (api *APIv2) SetQueryLimit(n int) {
 	api.limit = n
 }
 
+// SetQueryParallel sets the number of concurrent workers used to evaluate a query (see query.Options.Parallel)
+func (api *APIv2) SetQueryParallel(n int) {
+	api.parallel = n
+}
+
+// SetQueryMemoryLimit sets the memory budget in bytes for intermediate results of a query (see query.Options.MemoryLimit).
+// Requests may lower the limit with the memory_limit parameter.
+func (api *APIv2) SetQueryMemoryLimit(n int64) {
+	api.memLimit = n
+}
+
+// SetQueryResourceLimits sets limits on the work done by each query (see query.Options.Limiter).
+// Queries that exceed them fail with 422 Unprocessable Entity.
+func (api *APIv2) SetQueryResourceLimits(l iterator.ResourceLimits) {
+	api.limits = l
+}
+
 // ServeHTTP implements http.Handler
 func (api *APIv2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	api.handler.ServeHTTP(w, r)
@@ -126,11 +147,16 @@ func (api *APIv2) registerDataOn(r *httprouter.Router) {
 	if !api.ro {
 		r.POST(prefix+"/write", toHandle(api.ServeWrite))
 		r.POST(prefix+"/delete", toHandle(api.ServeDelete))
-		r.POST(prefix+"/node/delete", toHandle(api.ServeNodeDelete))
 	}
+	// dry runs are allowed in read-only mode
+	r.POST(prefix+"/node/delete", toHandle(api.ServeNodeDelete))
 	r.POST(prefix+"/read", toHandle(api.ServeRead))
 	r.GET(prefix+"/read", toHandle(api.ServeRead))
 	r.GET(prefix+"/formats", toHandle(api.ServeFormats))
+	r.GET(prefix+"/changes", toHandle(api.ServeChanges))
+	// followers accept replication in read-only mode, other databases reject it
+	r.GET(writer.ReplicatePath, toHandle(api.ServeReplicate))
+	r.POST(writer.ReplicatePath, toHandle(api.ServeReplicate))
 }
 
 func (api *APIv2) registerQueryOn(r *httprouter.Router) {
@@ -151,6 +177,8 @@ const (
 	hdrAcceptEncoding  = "Accept-Encoding"
 	contentTypeJSON    = "application/json"
 	contentTypeJSONLD  = "application/ld+json"
+	contentTypeSSE     = "text/event-stream"
+	hdrLastEventID     = "Last-Event-ID"
 )
 
 func getFormat(r *http.Request, formKey string, acceptName string) *quad.Format {
@@ -221,7 +249,9 @@ func newWriteResponse(count int) writeResponse {
 	}
 }
 
-// ServeWrite writes data received in the request body to the database
+// ServeWrite writes data received in the request body to the database.
+// If "replace" parameter is set, each quad replaces existing values of its subject, predicate and label.
+// If "ttl" parameter is set, quads expire after a given duration.
 func (api *APIv2) ServeWrite(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	if api.ro {
@@ -239,23 +269,54 @@ func (api *APIv2) ServeWrite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer rd.Close()
+	replace := false
+	if v := r.URL.Query().Get("replace"); v != "" {
+		replace, err = strconv.ParseBool(v)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	var ttl time.Duration
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		ttl, err = time.ParseDuration(v)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		} else if ttl <= 0 {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("ttl must be positive: %v", ttl))
+			return
+		} else if replace {
+			jsonResponse(w, http.StatusBadRequest, errors.New("ttl cannot be used with replace"))
+			return
+		}
+	}
 	qr := format.Reader(rd)
 	defer qr.Close()
 	h, err := api.handleForRequest(r)
 	if err != nil {
-		jsonResponse(w, http.StatusBadRequest, err)
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
 		return
 	}
 	qw := graph.NewWriter(h.QuadWriter)
+	if replace {
+		// each quad replaces all values of its subject and predicate
+		qw = graph.NewReplacer(h.QuadWriter)
+	} else if ttl != 0 {
+		qw = graph.NewExpiringWriter(h.QuadWriter, ttl)
+	}
 	defer qw.Close()
 	n, err := quad.CopyBatch(qw, qr, api.batch)
-	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+	if errors.Is(err, graph.ErrExpiryUnsupported) {
+		jsonResponse(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, errorStatus(err, http.StatusInternalServerError), err)
 		return
 	}
 	err = qw.Close()
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, errorStatus(err, http.StatusInternalServerError), err)
 		return
 	}
 	w.Header().Set(hdrContentType, contentTypeJSON)
@@ -287,28 +348,39 @@ func (api *APIv2) ServeDelete(w http.ResponseWriter, r *http.Request) {
 	defer qr.Close()
 	h, err := api.handleForRequest(r)
 	if err != nil {
-		jsonResponse(w, http.StatusBadRequest, err)
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
 		return
 	}
 	qw := graph.NewRemover(h.QuadWriter)
 	defer qw.Close()
 	n, err := quad.CopyBatch(qw, qr, api.batch)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, errorStatus(err, http.StatusInternalServerError), err)
 		return
 	}
 	w.Header().Set(hdrContentType, contentTypeJSON)
 	fmt.Fprintf(w, `{"result": "Successfully deleted %d quads.", "count": %d}`+"\n", n, n)
 }
 
+// nodeDeleteResponse is a response of ServeNodeDelete.
+type nodeDeleteResponse struct {
+	Result string `json:"result"`
+	// Count is a number of removed nodes, including cascaded blank nodes.
+	Count int `json:"count"`
+	// Removed is a number of removed quads.
+	Removed int `json:"removed"`
+	// Quads is a list of quads that would be removed. Only set in dry-run mode.
+	Quads []quad.Quad `json:"quads,omitempty"`
+}
+
 // ServeNodeDelete deletes all data associated with a node (an entity).
-// Responds with how many quads were deleted.
+// Responds with how many nodes and quads were deleted.
+//
+// Removal can cascade to blank nodes owned by the node ("cascade" parameter) and can be restricted
+// to a single label ("label" parameter). If "dry_run" parameter is set, it responds with quads
+// that would be removed, without changing the graph. Dry runs are allowed in read-only mode.
 func (api *APIv2) ServeNodeDelete(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	if api.ro {
-		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
-		return
-	}
 	format := getFormat(r, "", hdrContentType)
 	if format == nil || format.UnmarshalValue == nil {
 		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("format is not supported for reading nodes"))
@@ -332,19 +404,207 @@ func (api *APIv2) ServeNodeDelete(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("cannot remove nil value"))
 		return
 	}
+	var opts graph.RemoveNodeOptions
+	params := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		val  *bool
+	}{
+		{"cascade", &opts.Cascade},
+		{"dry_run", &opts.DryRun},
+	} {
+		if s := params.Get(p.name); s != "" {
+			*p.val, err = strconv.ParseBool(s)
+			if err != nil {
+				jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid %s parameter: %v", p.name, err))
+				return
+			}
+		}
+	}
+	if s := params.Get("label"); s != "" {
+		opts.Label = quad.StringToValue(s)
+	}
+	if api.ro && !opts.DryRun {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	}
 	h, err := api.handleForRequest(r)
 	if err != nil {
-		jsonResponse(w, http.StatusBadRequest, err)
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	rm, err := h.RemoveNodeWith(r.Context(), v, opts)
+	if err == graph.ErrNodeNotExists {
+		jsonResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	resp := nodeDeleteResponse{
+		Result:  fmt.Sprintf("Successfully deleted %d nodes.", len(rm.Nodes)),
+		Count:   len(rm.Nodes),
+		Removed: len(rm.Quads),
+	}
+	if opts.DryRun {
+		resp.Result = fmt.Sprintf("Would delete %d nodes.", len(rm.Nodes))
+		resp.Quads = rm.Quads
+	}
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// changeDelta is a JSON representation of graph.Delta
+type changeDelta struct {
+	Action  string     `json:"action"`
+	Quad    quad.Quad  `json:"quad"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// changeEvent is a JSON representation of graph.Change
+type changeEvent struct {
+	Feed   string        `json:"feed"`
+	Seq    uint64        `json:"seq"`
+	Time   time.Time     `json:"time"`
+	Deltas []changeDelta `json:"deltas"`
+}
+
+func newChangeEvent(feed string, c graph.Change) changeEvent {
+	ev := changeEvent{Feed: feed, Seq: c.Seq, Time: c.Time, Deltas: make([]changeDelta, 0, len(c.Deltas))}
+	for _, d := range c.Deltas {
+		cd := changeDelta{Action: d.Action.String(), Quad: d.Quad}
+		if !d.Expires.IsZero() {
+			exp := d.Expires
+			cd.Expires = &exp
+		}
+		ev.Deltas = append(ev.Deltas, cd)
+	}
+	return ev
+}
+
+// ServeChanges streams batches of committed deltas as Server-Sent Events.
+// Clients may resume from a given sequence number with "since" parameter or Last-Event-ID header.
+// Only new changes are sent if neither is set.
+//
+// Event IDs have the "<feed>:<seq>" form. If the feed ID does not match the current feed (for example,
+// after a restart), sequence numbers are no longer valid and the request fails with 410 Gone.
+// A plain sequence number is accepted as well, in which case the feed is not checked.
+func (api *APIv2) ServeChanges(w http.ResponseWriter, r *http.Request) {
+	feed, err := api.h.Changes()
+	if err != nil {
+		jsonResponse(w, http.StatusNotImplemented, err)
 		return
 	}
-	err = h.RemoveNode(v)
+	filter, err := deltaFilterForRequest(api.h, r)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	since := feed.Seq()
+	s := r.FormValue("since")
+	if s == "" {
+		s = r.Header.Get(hdrLastEventID)
+	}
+	if s != "" {
+		if i := strings.LastIndexByte(s, ':'); i >= 0 {
+			if s[:i] != feed.ID() {
+				jsonResponse(w, http.StatusGone, graph.ErrChangesReset)
+				return
+			}
+			s = s[i+1:]
+		}
+		since, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err = feed.Since(since); err == graph.ErrChangesTruncated {
+			jsonResponse(w, http.StatusGone, err)
+			return
+		} else if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		jsonResponse(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set(hdrContentType, contentTypeSSE)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fl.Flush()
+	ctx := r.Context()
+	err = feed.Watch(ctx, since, func(c graph.Change) error {
+		if filter != nil {
+			// skip batches that are completely hidden from the client
+			if c.Deltas = filter(c.Deltas); len(c.Deltas) == 0 {
+				return nil
+			}
+		}
+		data, err := json.Marshal(newChangeEvent(feed.ID(), c))
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "id: %s:%d\ndata: %s\n\n", feed.ID(), c.Seq, data); err != nil {
+			return err
+		}
+		fl.Flush()
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		// stream has already started, so report an error as an event
+		data, _ := json.Marshal(err.Error())
+		fmt.Fprintf(w, "event: error\ndata: {\"error\": %s}\n\n", data)
+		fl.Flush()
+	}
+}
+
+// ServeReplicate applies a batch of deltas shipped by the leader, or returns the replication state on GET.
+// It is only available if the database is opened with a "follower" writer, and requests must carry
+// the replication secret in writer.SecretHeader.
+func (api *APIv2) ServeReplicate(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	f, ok := api.h.QuadWriter.(*writer.Follower)
+	if !ok && api.ro && r.Method == http.MethodPost {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	} else if !ok {
+		jsonResponse(w, http.StatusNotImplemented, errors.New("database is not a replication follower"))
 		return
 	}
+	if !f.CheckSecret(r.Header.Get(writer.SecretHeader)) {
+		jsonResponse(w, http.StatusUnauthorized, errors.New("invalid replication secret"))
+		return
+	}
+	// replication bypasses per-request stores, so it requires access to the whole database
+	if h, err := api.handleForRequest(r); err != nil {
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	} else if h != api.h {
+		jsonResponse(w, http.StatusForbidden, acl.ErrAccessDenied)
+		return
+	}
+	st := f.State()
+	if r.Method == http.MethodPost {
+		var b writer.ReplicaBatch
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		var err error
+		st, err = f.Replicate(&b)
+		if err == writer.ErrReplicaConflict {
+			jsonResponse(w, http.StatusConflict, err)
+			return
+		} else if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
 	w.Header().Set(hdrContentType, contentTypeJSON)
-	const n = 1
-	fmt.Fprintf(w, `{"result": "Successfully deleted %d nodes.", "count": %d}`+"\n", n, n)
+	json.NewEncoder(w).Encode(st)
 }
 
 type checkWriter struct {
@@ -376,9 +636,15 @@ func (api *APIv2) ServeRead(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("format is not supported for reading data"))
 		return
 	}
+	done, err := BeginRead(api.h)
+	if err != nil {
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	defer done()
 	h, err := api.handleForRequest(r)
 	if err != nil {
-		jsonResponse(w, http.StatusBadRequest, err)
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
 		return
 	}
 	values := shape.FilterQuads(
@@ -473,6 +739,16 @@ func defaultErrorFunc(w query.ResponseWriter, err error) {
 	w.Write([]byte("}\n"))
 }
 
+// queryError responds with an error of the query. Errors caused by resource limits are reported
+// with their own status code, other errors are written by the language-specific errFunc.
+func queryError(w http.ResponseWriter, errFunc func(query.ResponseWriter, error), err error) {
+	if code := errorStatus(err, 0); code != 0 {
+		jsonResponse(w, code, err)
+		return
+	}
+	errFunc(w, err)
+}
+
 func writeResults(w io.Writer, r interface{}) {
 	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(false)
@@ -481,6 +757,16 @@ func writeResults(w io.Writer, r interface{}) {
 	})
 }
 
+// writeProfile writes query results together with iterator statistics collected during the query.
+func writeProfile(w io.Writer, r interface{}, p *iterator.Profile) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(map[string]interface{}{
+		"result":  r,
+		"profile": p,
+	})
+}
+
 const maxQuerySize = 1024 * 1024 // 1 MB
 func readLimit(r io.Reader) ([]byte, error) {
 	lr := io.LimitReader(r, maxQuerySize).(*io.LimitedReader)
@@ -516,14 +802,68 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	default:
 	}
-	h, err := api.handleForRequest(r)
+	done, err := BeginRead(api.h)
 	if err != nil {
+		jsonResponse(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	defer done()
+	h, err := api.handleForRequest(r)
+	if errors.Is(err, acl.ErrAccessDenied) {
+		jsonResponse(w, http.StatusForbidden, err)
+		return
+	} else if err != nil {
 		errFunc(w, err)
 		return
 	}
+	asOf, err := graph.ParseVersion(vals.Get("as_of"))
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	memLimit := api.memLimit
+	if s := vals.Get("memory_limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid memory limit: %q", s))
+			return
+		}
+		if memLimit <= 0 || n < memLimit {
+			memLimit = n
+		}
+	}
+	var profile *iterator.Profile
+	if s := vals.Get("profile"); s != "" {
+		ok, err := strconv.ParseBool(s)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid profile flag: %q", s))
+			return
+		}
+		if ok {
+			profile = iterator.NewProfile()
+		}
+	}
+	opt := query.Options{
+		Collation:   query.JSON, // TODO: switch to JSON-LD by default when the time comes
+		Limit:       api.limit,
+		AsOf:        asOf,
+		Parallel:    api.parallel,
+		MemoryLimit: memLimit,
+		Profile:     profile,
+	}
+	if !api.limits.IsZero() {
+		opt.Limiter = iterator.NewLimiter(api.limits)
+	}
 	if l.HTTPQuery != nil {
+		qs, err := graph.AsOf(ctx, h.QuadStore, asOf)
+		if err != nil {
+			errFunc(w, err)
+			return
+		}
 		defer r.Body.Close()
-		l.HTTPQuery(ctx, h.QuadStore, w, r.Body)
+		// languages with custom handlers read execution options from the context
+		ctx = query.Context(ctx, opt)
+		l.HTTPQuery(ctx, qs, w, r.Body)
 		return
 	}
 	if l.Session == nil {
@@ -550,10 +890,6 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		clog.Infof("query: %s: %q", lang, qu)
 	}
 
-	opt := query.Options{
-		Collation: query.JSON, // TODO: switch to JSON-LD by default when the time comes
-		Limit:     api.limit,
-	}
 	if specs := ParseAccept(r.Header, hdrAccept); len(specs) != 0 {
 		// TODO: sort by Q
 		switch specs[0].Value {
@@ -565,7 +901,7 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 	}
 	it, err := ses.Execute(ctx, qu, opt)
 	if err != nil {
-		errFunc(w, err)
+		queryError(w, errFunc, err)
 		return
 	}
 	defer it.Close()
@@ -575,7 +911,7 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		out = append(out, it.Result())
 	}
 	if err = it.Err(); err != nil {
-		errFunc(w, err)
+		queryError(w, errFunc, err)
 		return
 	}
 	if opt.Collation == query.JSONLD {
@@ -583,6 +919,10 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 	} else {
 		w.Header().Set(hdrContentType, contentTypeJSON)
 	}
+	if profile != nil {
+		writeProfile(w, out, profile)
+		return
+	}
 	writeResults(w, out)
 }
 
@@ -1,19 +1,31 @@
 package cayleyhttp
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/memstore"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	_ "github.com/cayleygraph/cayley/query/graphql"
 	"github.com/cayleygraph/cayley/writer"
 	"github.com/cayleygraph/quad"
 	"github.com/cayleygraph/quad/jsonld"
+	_ "github.com/cayleygraph/quad/nquads"
 	"github.com/stretchr/testify/require"
 )
 
@@ -161,3 +173,562 @@ func TestV2PostNamespaceRules(t *testing.T) {
 	require.Equal(t, contentTypeJSON, rr.Header().Get(hdrContentType))
 	require.Contains(t, rules, rule)
 }
+
+func TestV2QueryAsOf(t *testing.T) {
+	qs := memstore.NewVersioned(quads...)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := qs.Horizon()
+	err = wr.RemoveQuad(quad.MakeIRI("http://example.com/bob", "http://example.com/likes", "http://example.com/alice", ""))
+	require.NoError(t, err)
+	api := NewAPIv2(&graph.Handle{QuadStore: qs, QuadWriter: wr})
+
+	run := func(asOf string) (int, string) {
+		vals := url.Values{
+			"lang":  {"gizmo"},
+			"qu":    {`g.V("<http://example.com/bob>").out("<http://example.com/likes>").all()`},
+			"as_of": {asOf},
+		}
+		req, err := http.NewRequest(http.MethodGet, prefix+"/query?"+vals.Encode(), nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.ServeQuery).ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+	code, body := run("")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"result":null}`, body)
+
+	code, body = run(strconv.FormatInt(h, 10))
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"result":[{"id":"<http://example.com/alice>"}]}`, body)
+
+	code, body = run("yesterday")
+	require.Equal(t, http.StatusBadRequest, code, body)
+}
+
+func TestV2QueryAsOfACL(t *testing.T) {
+	qs := memstore.NewVersioned(quads...)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := qs.Horizon()
+	err = wr.RemoveQuad(quad.MakeIRI("http://example.com/bob", "http://example.com/likes", "http://example.com/alice", ""))
+	require.NoError(t, err)
+	p := &acl.Policy{Principals: map[string]acl.Rule{
+		"admin": {},
+		"alice": {DenyPredicates: []string{"<http://example.com/likes>"}},
+	}}
+	api := NewAPIv2(&graph.Handle{QuadStore: acl.NewStore(qs, p), QuadWriter: wr})
+
+	run := func(principal string) (int, string) {
+		vals := url.Values{
+			"lang":  {"gizmo"},
+			"qu":    {`g.V("<http://example.com/bob>").out("<http://example.com/likes>").all()`},
+			"as_of": {strconv.FormatInt(h, 10)},
+		}
+		req, err := http.NewRequest(http.MethodGet, prefix+"/query?"+vals.Encode(), nil)
+		require.NoError(t, err)
+		req.Header.Set(acl.DefaultHeader, principal)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.ServeQuery).ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+	code, body := run("admin")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"result":[{"id":"<http://example.com/alice>"}]}`, body)
+
+	code, body = run("alice")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"result":null}`, body)
+}
+
+func TestV2QueryProfile(t *testing.T) {
+	api := makeServerV2(t, quads...)
+
+	run := func(profile string) (int, string) {
+		vals := url.Values{
+			"lang":    {"gizmo"},
+			"qu":      {`g.V("<http://example.com/bob>").out("<http://example.com/likes>").all()`},
+			"profile": {profile},
+		}
+		req, err := http.NewRequest(http.MethodGet, prefix+"/query?"+vals.Encode(), nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.ServeQuery).ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+	code, body := run("false")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"result":[{"id":"<http://example.com/alice>"}]}`, body)
+
+	code, body = run("true")
+	require.Equal(t, http.StatusOK, code, body)
+	var resp struct {
+		Result  []interface{}
+		Profile []struct {
+			Name    string `json:"name"`
+			Results int64  `json:"results"`
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &resp))
+	require.Len(t, resp.Result, 1)
+	require.NotEmpty(t, resp.Profile)
+	require.Equal(t, int64(1), resp.Profile[0].Results)
+
+	code, body = run("maybe")
+	require.Equal(t, http.StatusBadRequest, code, body)
+}
+
+func TestV2QueryLimits(t *testing.T) {
+	run := func(lang, qu string, l iterator.ResourceLimits) (int, string) {
+		api := makeServerV2(t, quads...)
+		api.SetQueryResourceLimits(l)
+		vals := url.Values{"lang": {lang}, "qu": {qu}}
+		req, err := http.NewRequest(http.MethodGet, prefix+"/query?"+vals.Encode(), nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.ServeQuery).ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+	const qu = `g.V().out("<http://example.com/likes>").all()`
+	code, body := run("gizmo", qu, iterator.ResourceLimits{MaxScanned: 100})
+	require.Equal(t, http.StatusOK, code, body)
+
+	code, body = run("gizmo", qu, iterator.ResourceLimits{MaxScanned: 1})
+	require.Equal(t, http.StatusUnprocessableEntity, code, body)
+	require.Contains(t, body, "scanned values")
+
+	code, body = run("gizmo", qu, iterator.ResourceLimits{MaxResults: 3})
+	require.Equal(t, http.StatusUnprocessableEntity, code, body)
+	require.Contains(t, body, "intermediate results")
+}
+
+// serveGraphQL runs a GraphQL query with given request parameters.
+func serveGraphQL(t testing.TB, api *APIv2, qu string, vals url.Values) (int, string) {
+	t.Helper()
+	if vals == nil {
+		vals = url.Values{}
+	}
+	vals.Set("lang", "graphql")
+	req, err := http.NewRequest(http.MethodPost, prefix+"/query?"+vals.Encode(), strings.NewReader(qu))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(api.ServeQuery).ServeHTTP(rr, req)
+	return rr.Code, rr.Body.String()
+}
+
+const graphQLLikes = `{ likes: nodes(id: <http://example.com/bob>) { <http://example.com/likes> { id } } }`
+
+func TestV2GraphQLParallel(t *testing.T) {
+	api := makeServerV2(t, quads...)
+	api.SetQueryParallel(4)
+	code, body := serveGraphQL(t, api, graphQLLikes, nil)
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"data":{"likes":{"<http://example.com/likes>":{"id":"http://example.com/alice"}}}}`, body)
+}
+
+func TestV2GraphQLMemoryLimit(t *testing.T) {
+	api := makeServerV2(t, quads...)
+	api.SetQueryMemoryLimit(1 << 20)
+	code, body := serveGraphQL(t, api, graphQLLikes, url.Values{"memory_limit": {"1"}})
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"data":{"likes":{"<http://example.com/likes>":{"id":"http://example.com/alice"}}}}`, body)
+
+	code, body = serveGraphQL(t, api, graphQLLikes, url.Values{"memory_limit": {"-1"}})
+	require.Equal(t, http.StatusBadRequest, code, body)
+}
+
+func TestV2GraphQLLimits(t *testing.T) {
+	api := makeServerV2(t, quads...)
+	const qu = `{ nodes { <http://example.com/likes> { id } } }`
+	code, body := serveGraphQL(t, api, qu, nil)
+	require.Equal(t, http.StatusOK, code, body)
+
+	api.SetQueryResourceLimits(iterator.ResourceLimits{MaxScanned: 1})
+	code, body = serveGraphQL(t, api, qu, nil)
+	require.Equal(t, http.StatusUnprocessableEntity, code, body)
+	require.Contains(t, body, "scanned values")
+	require.Contains(t, body, `"errors"`)
+}
+
+func TestV2GraphQLProfile(t *testing.T) {
+	api := makeServerV2(t, quads...)
+	code, body := serveGraphQL(t, api, graphQLLikes, url.Values{"profile": {"true"}})
+	require.Equal(t, http.StatusOK, code, body)
+	var resp struct {
+		Data       json.RawMessage `json:"data"`
+		Extensions struct {
+			Profile []struct {
+				Name    string `json:"name"`
+				Results int64  `json:"results"`
+			} `json:"profile"`
+		} `json:"extensions"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &resp))
+	require.JSONEq(t, `{"likes":{"<http://example.com/likes>":{"id":"http://example.com/alice"}}}`, string(resp.Data))
+	require.NotEmpty(t, resp.Extensions.Profile)
+
+	code, body = serveGraphQL(t, api, graphQLLikes, nil)
+	require.Equal(t, http.StatusOK, code, body)
+	require.NotContains(t, body, "extensions")
+}
+
+func TestV2Changes(t *testing.T) {
+	h := makeHandle(t)
+	// changes are only recorded once the feed is requested
+	feed, err := h.Changes()
+	require.NoError(t, err)
+	require.NoError(t, h.AddQuad(quads[0]))
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+prefix+"/changes?since=0", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, contentTypeSSE, resp.Header.Get(hdrContentType))
+
+	sc := bufio.NewScanner(resp.Body)
+	next := func() changeEvent {
+		var ev changeEvent
+		for sc.Scan() {
+			if line := sc.Text(); strings.HasPrefix(line, "data: ") {
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev))
+				return ev
+			}
+		}
+		require.NoError(t, sc.Err())
+		t.Fatal("unexpected end of stream")
+		return ev
+	}
+	ev := next()
+	require.Equal(t, feed.ID(), ev.Feed)
+	require.Equal(t, uint64(1), ev.Seq)
+	require.Equal(t, []changeDelta{{Action: "add", Quad: quads[0]}}, ev.Deltas)
+
+	require.NoError(t, h.RemoveQuad(quads[0]))
+	ev = next()
+	require.Equal(t, uint64(2), ev.Seq)
+	require.Equal(t, []changeDelta{{Action: "delete", Quad: quads[0]}}, ev.Deltas)
+
+	resp2, err := http.Get(srv.URL + prefix + "/changes?since=5")
+	require.NoError(t, err)
+	resp2.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp2.StatusCode)
+
+	// sequence numbers of another feed are rejected
+	resp2, err = http.Get(srv.URL + prefix + "/changes?since=" + url.QueryEscape("0123456789abcdef:1"))
+	require.NoError(t, err)
+	resp2.Body.Close()
+	require.Equal(t, http.StatusGone, resp2.StatusCode)
+}
+
+func TestV2WriteReplace(t *testing.T) {
+	h := makeHandle(t, quad.MakeIRI("http://example.com/bob", "http://example.com/likes", "http://example.com/alice", ""))
+	api := NewAPIv2(h)
+	q := quad.MakeIRI("http://example.com/bob", "http://example.com/likes", "http://example.com/carol", "")
+	buf := bytes.NewBuffer(nil)
+	qw := jsonld.NewWriter(buf)
+	require.NoError(t, qw.WriteQuad(q))
+	require.NoError(t, qw.Close())
+
+	req, err := http.NewRequest(http.MethodPost, prefix+"/write?replace=true", buf)
+	require.NoError(t, err)
+	req.Header.Set(hdrContentType, mime)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(api.ServeWrite).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(h.QuadStore))
+	require.NoError(t, err)
+	require.Equal(t, []quad.Quad{q}, got)
+}
+
+func TestV2WriteTTL(t *testing.T) {
+	h := makeHandle(t)
+	api := NewAPIv2(h)
+	q := quad.MakeIRI("http://example.com/bob", "http://example.com/session", "http://example.com/s1", "")
+	write := func(query string) *httptest.ResponseRecorder {
+		buf := bytes.NewBuffer(nil)
+		qw := jsonld.NewWriter(buf)
+		require.NoError(t, qw.WriteQuad(q))
+		require.NoError(t, qw.Close())
+		req, err := http.NewRequest(http.MethodPost, prefix+"/write?"+query, buf)
+		require.NoError(t, err)
+		req.Header.Set(hdrContentType, mime)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.ServeWrite).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := write("ttl=1h")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(h.QuadStore))
+	require.NoError(t, err)
+	require.Equal(t, []quad.Quad{q}, got)
+
+	exp := h.QuadStore.(graph.Expirer)
+	expired, err := exp.ExpiredQuads(context.TODO(), time.Now().Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Quad{q}, expired)
+
+	rr = write("ttl=1h&replace=true")
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+	rr = write("ttl=-1s")
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+}
+
+func TestV2Replication(t *testing.T) {
+	const secret = "secret"
+	// follower data is replaced with a snapshot from the leader
+	fqs := memstore.New(quad.Make(quad.IRI("stale"), quad.IRI("p"), quad.IRI("o"), nil))
+	follower, err := writer.NewFollower(fqs, secret)
+	require.NoError(t, err)
+	fh := &graph.Handle{QuadStore: fqs, QuadWriter: follower}
+	var down atomic.Bool
+	fapi := NewAPIv2(fh, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	fsrv := httptest.NewServer(fapi)
+	defer fsrv.Close()
+
+	lqs := memstore.New()
+	newLeader := func(changes int) *writer.Leader {
+		t.Helper()
+		lw, err := writer.NewLeaderReplication(lqs, graph.Options{
+			writer.OptFollowers:         fsrv.URL,
+			writer.OptReplicationRetry:  "10ms",
+			writer.OptReplicationSecret: secret,
+			"change_buffer":             changes,
+		})
+		require.NoError(t, err)
+		return lw.(*writer.Leader)
+	}
+	leader := newLeader(graph.DefaultChangeBuffer)
+	defer func() {
+		leader.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	expect := func() {
+		t.Helper()
+		require.NoError(t, leader.Wait(ctx))
+		exp, err := quad.ReadAll(graph.NewQuadStoreReader(lqs))
+		require.NoError(t, err)
+		got, err := quad.ReadAll(graph.NewQuadStoreReader(fqs))
+		require.NoError(t, err)
+		require.ElementsMatch(t, exp, got)
+	}
+
+	q := quad.Make(quad.IRI("bob"), quad.IRI("age"), quad.Int(30), nil)
+	require.NoError(t, leader.AddQuadSet(quads))
+	require.NoError(t, leader.AddQuad(q))
+	expect()
+
+	// follower catches up from the leader's log when it becomes available again
+	down.Store(true)
+	require.NoError(t, leader.RemoveQuad(quads[0]))
+	q2 := quad.Make(quad.IRI("bob"), quad.IRI("age"), quad.Int(31), nil)
+	require.NoError(t, leader.ReplaceQuad(q2))
+	down.Store(false)
+	expect()
+	st := leader.Followers()
+	require.Len(t, st, 1)
+	require.Equal(t, uint64(4), st[0].Seq)
+	require.Equal(t, writer.ReplicaState{Leader: leader.ID(), Seq: 4}, follower.State())
+
+	// followers reject writes from clients
+	require.Equal(t, writer.ErrFollower, follower.AddQuad(q))
+
+	// batches must be applied in order
+	_, err = follower.Replicate(&writer.ReplicaBatch{Leader: leader.ID(), Seq: 6})
+	require.Equal(t, writer.ErrReplicaConflict, err)
+	_, err = follower.Replicate(&writer.ReplicaBatch{Leader: leader.ID(), Seq: 6, Snapshot: true, Part: 1})
+	require.Equal(t, writer.ErrReplicaConflict, err)
+
+	// a new leader instance resyncs the follower with a snapshot, as well as when the follower falls behind the log
+	require.NoError(t, leader.Close())
+	leader = newLeader(1)
+	require.NoError(t, leader.AddQuad(quads[0]))
+	expect()
+	down.Store(true)
+	require.NoError(t, leader.RemoveQuad(q2))
+	require.NoError(t, leader.RemoveQuad(quads[1]))
+	require.NoError(t, leader.AddQuad(q))
+	down.Store(false)
+	expect()
+	require.Equal(t, writer.ReplicaState{Leader: leader.ID(), Seq: 4}, follower.State())
+
+	// a new leader must start with a snapshot
+	_, err = follower.Replicate(&writer.ReplicaBatch{Leader: "other", Seq: 1})
+	require.Equal(t, writer.ErrReplicaConflict, err)
+
+	// reads are refused until the snapshot is complete
+	read := func() int {
+		rr := httptest.NewRecorder()
+		fapi.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, prefix+"/read", nil))
+		return rr.Code
+	}
+	require.Equal(t, http.StatusOK, read())
+	_, err = follower.Replicate(&writer.ReplicaBatch{Leader: "other", Seq: 1, Snapshot: true})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, read())
+	_, err = follower.BeginRead()
+	require.Equal(t, writer.ErrReplicaSyncing, err)
+	_, err = follower.Replicate(&writer.ReplicaBatch{Leader: "other", Seq: 1, Snapshot: true, Part: 1, Last: true,
+		Deltas: []graph.Delta{{Action: graph.Add, Quad: q}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, read())
+
+	// replication requires a secret
+	for _, sec := range []string{"", "wrong"} {
+		req := httptest.NewRequest(http.MethodGet, writer.ReplicatePath, nil)
+		req.Header.Set(writer.SecretHeader, sec)
+		rr := httptest.NewRecorder()
+		fapi.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	}
+	_, err = writer.NewFollower(fqs, "")
+	require.Equal(t, writer.ErrNoSecret, err)
+	_, err = writer.NewLeaderReplication(lqs, graph.Options{writer.OptFollowers: fsrv.URL})
+	require.Equal(t, writer.ErrNoSecret, err)
+
+	// databases that are not followers reject replication, read-only ones with 403
+	api := NewAPIv2(&graph.Handle{QuadStore: lqs, QuadWriter: leader})
+	api.SetReadOnly(true)
+	rr := httptest.NewRecorder()
+	api.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, writer.ReplicatePath, strings.NewReader(`{}`)))
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+	api.SetReadOnly(false)
+	rr = httptest.NewRecorder()
+	api.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, writer.ReplicatePath, strings.NewReader(`{}`)))
+	require.Equal(t, http.StatusNotImplemented, rr.Code, rr.Body.String())
+}
+
+func TestV2NodeDelete(t *testing.T) {
+	bob, addr := quad.IRI("bob"), quad.BNode("addr")
+	data := []quad.Quad{
+		quad.Make(bob, quad.IRI("name"), "Bob", nil),
+		quad.Make(bob, quad.IRI("address"), addr, nil),
+		quad.Make(addr, quad.IRI("city"), "Paris", nil),
+	}
+	h := makeHandle(t, data...)
+	api := NewAPIv2(h)
+	serve := func(params string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, prefix+"/node/delete?"+params, strings.NewReader(`<bob>`))
+		require.NoError(t, err)
+		req.Header.Set(hdrContentType, quad.FormatByName("nquads").Mime[0])
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.ServeNodeDelete).ServeHTTP(rr, req)
+		return rr
+	}
+	deleteNode := func(params string) nodeDeleteResponse {
+		t.Helper()
+		rr := serve(params)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp nodeDeleteResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// only dry runs are allowed in read-only mode
+	api.SetReadOnly(true)
+	rr := serve("cascade=true")
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+	resp := deleteNode("cascade=true&dry_run=true")
+	require.Equal(t, 2, resp.Count)
+	require.Equal(t, 3, resp.Removed)
+	require.Len(t, resp.Quads, 3)
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(h.QuadStore))
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	api.SetReadOnly(false)
+
+	resp = deleteNode("cascade=true")
+	require.Equal(t, 2, resp.Count)
+	require.Equal(t, 3, resp.Removed)
+	require.Empty(t, resp.Quads)
+	got, err = quad.ReadAll(graph.NewQuadStoreReader(h.QuadStore))
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestV2ACL(t *testing.T) {
+	secret := quad.MakeIRI("http://example.com/bob", "http://example.com/likes", "http://example.com/carol", "http://example.com/secret")
+	h := makeHandle(t, append([]quad.Quad{secret}, quads...)...)
+	p := &acl.Policy{Principals: map[string]acl.Rule{
+		"admin": {},
+		"alice": {DenyLabels: []string{"<http://example.com/secret>"}},
+	}}
+	api := NewAPIv2(&graph.Handle{QuadStore: acl.NewStore(h.QuadStore, p), QuadWriter: h.QuadWriter})
+	serve := func(fnc http.HandlerFunc, principal, path string, body []quad.Quad) *httptest.ResponseRecorder {
+		t.Helper()
+		buf := bytes.NewBuffer(nil)
+		qw := jsonld.NewWriter(buf)
+		_, err := qw.WriteQuads(body)
+		require.NoError(t, err)
+		require.NoError(t, qw.Close())
+		req, err := http.NewRequest(http.MethodPost, prefix+path, buf)
+		require.NoError(t, err)
+		req.Header.Set(hdrContentType, mime)
+		req.Header.Set(hdrAccept, mime)
+		if principal != "" {
+			req.Header.Set(acl.DefaultHeader, principal)
+		}
+		rr := httptest.NewRecorder()
+		fnc.ServeHTTP(rr, req)
+		return rr
+	}
+	read := func(principal string) []quad.Quad {
+		t.Helper()
+		rr := serve(api.ServeRead, principal, "/read", nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		got, err := quad.ReadAll(jsonld.NewReader(rr.Body))
+		require.NoError(t, err)
+		return got
+	}
+
+	require.ElementsMatch(t, quads, read("alice"))
+	require.ElementsMatch(t, append([]quad.Quad{secret}, quads...), read("admin"))
+	rr := serve(api.ServeRead, "", "/read", nil)
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = serve(api.ServeWrite, "alice", "/write", []quad.Quad{secret})
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+	rr = serve(api.ServeDelete, "alice", "/delete", []quad.Quad{secret})
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	// writes of restricted principals go through the database writer
+	feed, err := h.Changes()
+	require.NoError(t, err)
+	seq := feed.Seq()
+	q := quad.MakeIRI("http://example.com/alice", "http://example.com/likes", "http://example.com/carol", "")
+	rr = serve(api.ServeWrite, "alice", "/write", []quad.Quad{q})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	changes, err := feed.Since(seq)
+	require.NoError(t, err)
+	var added []quad.Quad
+	for _, c := range changes {
+		for _, d := range c.Deltas {
+			added = append(added, d.Quad)
+		}
+	}
+	require.Equal(t, []quad.Quad{q}, added)
+	require.ElementsMatch(t, append([]quad.Quad{q}, quads...), read("alice"))
+
+	rr = serve(api.ServeChanges, "", "/changes", nil)
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+}
@@ -2,11 +2,15 @@ package cayleyhttp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
 	httpgraph "github.com/cayleygraph/cayley/graph/http"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/writer"
 )
 
 func jsonResponse(w http.ResponseWriter, code int, err interface{}) {
@@ -37,6 +41,11 @@ func HandleForRequest(h *graph.Handle, wtyp string, wopt graph.Options, r *http.
 	if err != nil {
 		return nil, err
 	}
+	if qs == h.QuadStore {
+		return h, nil
+	} else if ww, ok := qs.(httpgraph.WriterWrapper); ok {
+		return &graph.Handle{QuadStore: qs, QuadWriter: ww.WrapWriter(h.QuadWriter)}, nil
+	}
 	qw, err := graph.NewQuadWriter(wtyp, qs, wopt)
 	if err != nil {
 		qs.Close()
@@ -44,3 +53,44 @@ func HandleForRequest(h *graph.Handle, wtyp string, wopt graph.Options, r *http.
 	}
 	return &graph.Handle{QuadStore: qs, QuadWriter: qw}, nil
 }
+
+// BeginRead checks if the database of a handle can serve reads. Followers refuse them with writer.ErrReplicaSyncing
+// while they are resynced by the leader. The returned function must be called when the read is done.
+func BeginRead(h *graph.Handle) (done func(), err error) {
+	if f, ok := h.QuadWriter.(*writer.Follower); ok {
+		return f.BeginRead()
+	}
+	return func() {}, nil
+}
+
+// deltaFilterForRequest returns a function that hides deltas from the change feed of a given handle
+// for a request. It returns nil if the request can see all deltas, and acl.ErrAccessDenied if the feed
+// is not available for the request.
+func deltaFilterForRequest(h *graph.Handle, r *http.Request) (func([]graph.Delta) []graph.Delta, error) {
+	g, ok := h.QuadStore.(httpgraph.QuadStore)
+	if !ok {
+		return nil, nil
+	}
+	qs, err := g.ForRequest(r)
+	if err != nil {
+		return nil, err
+	} else if qs == h.QuadStore {
+		return nil, nil
+	} else if f, ok := qs.(httpgraph.DeltaFilter); ok {
+		return f.FilterDeltas, nil
+	}
+	return nil, acl.ErrAccessDenied
+}
+
+// errorStatus returns an HTTP status code for an error, or a given default code.
+func errorStatus(err error, def int) int {
+	var lerr *iterator.LimitError
+	if errors.Is(err, acl.ErrAccessDenied) {
+		return http.StatusForbidden
+	} else if errors.As(err, &lerr) {
+		return http.StatusUnprocessableEntity
+	} else if errors.Is(err, writer.ErrReplicaSyncing) {
+		return http.StatusServiceUnavailable
+	}
+	return def
+}
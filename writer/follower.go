@@ -0,0 +1,201 @@
+package writer
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+func init() {
+	graph.RegisterWriter("follower", NewFollowerReplication)
+}
+
+var _ graph.ChangeSource = (*Follower)(nil)
+
+// Follower is a writer for read replicas. It rejects all writes with ErrFollower and only applies batches
+// shipped by the leader, in the order of their sequence numbers.
+//
+// Replication state is not persisted, so after a restart the follower is resynced by the leader with a snapshot,
+// which replaces all data of the follower. The store only has a part of the leader's data until the snapshot
+// is complete, so reads must be started with BeginRead, which refuses them during the resync.
+type Follower struct {
+	s      *Single
+	secret string
+
+	mu    sync.Mutex
+	state ReplicaState
+	snap  *ReplicaBatch // last applied part of a snapshot in progress
+
+	reads   sync.RWMutex // held for reading by reads in progress
+	syncing atomic.Bool  // a snapshot is in progress
+}
+
+// NewFollower creates a follower writer for a given quad store.
+// The secret must match the one configured on the leader.
+func NewFollower(qs graph.QuadStore, secret string) (*Follower, error) {
+	return newFollower(qs, secret, graph.DefaultChangeBuffer)
+}
+
+func newFollower(qs graph.QuadStore, secret string, changes int) (*Follower, error) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+	// batches may be resent by the leader, so applying them must be idempotent;
+	// expired quads are removed by the leader, which ships the deletes as usual
+	s := newSingle(qs, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}, changes, false)
+	return &Follower{s: s, secret: secret}, nil
+}
+
+func NewFollowerReplication(qs graph.QuadStore, opts graph.Options) (graph.QuadWriter, error) {
+	secret, err := replicationSecret(opts)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := opts.IntKey("change_buffer", graph.DefaultChangeBuffer)
+	if err != nil {
+		return nil, err
+	}
+	return newFollower(qs, secret, changes)
+}
+
+// CheckSecret checks if a secret sent with a replication request matches the one of the follower.
+func (f *Follower) CheckSecret(secret string) bool {
+	return checkSecret(f.secret, secret)
+}
+
+// State returns the current replication state.
+func (f *Follower) State() ReplicaState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// BeginRead starts a read from the follower's store. It returns ErrReplicaSyncing if a snapshot is in progress.
+// Otherwise, the returned function must be called when the read is done. A new snapshot waits for reads
+// in progress before it removes the data of the follower.
+func (f *Follower) BeginRead() (done func(), err error) {
+	f.reads.RLock()
+	if f.syncing.Load() {
+		f.reads.RUnlock()
+		return nil, ErrReplicaSyncing
+	}
+	return f.reads.RUnlock, nil
+}
+
+// Replicate applies a batch received from the leader and returns the new replication state.
+//
+// Batches that were already applied are ignored. It returns ErrReplicaConflict if the batch does not directly
+// follow the last applied one. A new leader must start with a snapshot.
+func (f *Follower) Replicate(b *ReplicaBatch) (ReplicaState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if b.Snapshot {
+		return f.state, f.replicateSnapshot(b)
+	}
+	if b.Leader != f.state.Leader {
+		return f.state, ErrReplicaConflict
+	} else if b.Seq <= f.state.Seq {
+		return f.state, nil
+	} else if b.Seq != f.state.Seq+1 {
+		return f.state, ErrReplicaConflict
+	}
+	if err := f.s.apply(b.Deltas); err != nil {
+		return f.state, err
+	}
+	f.state.Seq = b.Seq
+	return f.state, nil
+}
+
+// replicateSnapshot applies a part of a snapshot. The first part removes all data from the store, and the last one
+// switches the follower to the leader. The follower has no leader while the snapshot is in progress, so the leader
+// restarts the snapshot if it is interrupted. Reads are refused until the last part is applied.
+func (f *Follower) replicateSnapshot(b *ReplicaBatch) error {
+	if b.Part == 0 {
+		f.state, f.snap = ReplicaState{}, nil
+		f.reads.Lock()
+		f.syncing.Store(true)
+		f.reads.Unlock()
+		if err := f.clear(); err != nil {
+			return err
+		}
+	} else if p := f.snap; p == nil || p.Leader != b.Leader || p.Seq != b.Seq || p.Part+1 != b.Part {
+		return ErrReplicaConflict
+	}
+	if err := f.s.apply(b.Deltas); err != nil {
+		return err
+	}
+	if b.Last {
+		f.state, f.snap = ReplicaState{Leader: b.Leader, Seq: b.Seq}, nil
+		f.syncing.Store(false)
+	} else {
+		f.snap = &ReplicaBatch{Leader: b.Leader, Seq: b.Seq, Part: b.Part}
+	}
+	return nil
+}
+
+// clear removes all quads from the store, including expired ones. Deletes are published to the change feed as usual.
+func (f *Follower) clear() error {
+	exp, _ := graph.Unwrap(f.s.qs).(graph.Expirer)
+	buf := make([]quad.Quad, quad.DefaultBatch)
+	for {
+		// deleted quads are not returned by a new reader, so it always starts from the beginning
+		r := graph.NewQuadStoreReader(graph.PrimaryOf(f.s.qs))
+		n, err := readQuads(r, buf)
+		r.Close()
+		quads := buf[:n]
+		if err == io.EOF && exp != nil {
+			// expired quads are hidden from readers, but they are still kept in the store
+			quads, err = exp.ExpiredQuads(context.Background(), time.Now(), quad.DefaultBatch)
+			if err == nil && len(quads) == 0 {
+				err = io.EOF
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		deltas := make([]graph.Delta, 0, len(quads))
+		for _, q := range quads {
+			deltas = append(deltas, graph.Delta{Action: graph.Delete, Quad: q})
+		}
+		if err = f.s.apply(deltas); err != nil {
+			return err
+		}
+	}
+}
+
+// Changes returns a feed of changes replicated from the leader.
+func (f *Follower) Changes() *graph.ChangeFeed {
+	return f.s.Changes()
+}
+
+func (f *Follower) AddQuad(quad.Quad) error {
+	return ErrFollower
+}
+
+func (f *Follower) AddQuadSet([]quad.Quad) error {
+	return ErrFollower
+}
+
+func (f *Follower) RemoveQuad(quad.Quad) error {
+	return ErrFollower
+}
+
+func (f *Follower) RemoveNode(quad.Value) error {
+	return ErrFollower
+}
+
+func (f *Follower) ApplyTransaction(*graph.Transaction) error {
+	return ErrFollower
+}
+
+func (f *Follower) Close() error {
+	return nil
+}
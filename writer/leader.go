@@ -0,0 +1,316 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+func init() {
+	graph.RegisterWriter("leader", NewLeaderReplication)
+}
+
+const (
+	// OptFollowers is a list of base URLs of follower instances. Can be a list or a comma-separated string.
+	OptFollowers = "followers"
+	// OptReplicationRetry is an interval between attempts to reach a failed follower (default is 5s).
+	OptReplicationRetry = "replication_retry"
+)
+
+// DefaultReplicationRetry is the default interval between attempts to reach a failed follower.
+const DefaultReplicationRetry = 5 * time.Second
+
+var _ graph.ChangeSource = (*Leader)(nil)
+
+// Leader is a writer that applies changes to a local quad store and ships committed batches
+// to follower instances over HTTP.
+//
+// Replication is asynchronous: writes are acknowledged once they are committed locally. Each follower
+// is fed from the change log of the leader, starting from the last batch acknowledged by this follower.
+// A follower that falls behind the log (see "change_buffer") or that replicated from another leader instance
+// is resynced with a snapshot of the leader's data, after which it continues from the log.
+//
+// Requests to followers are authenticated with a shared secret (see OptReplicationSecret).
+type Leader struct {
+	*Single
+	id     string
+	secret string
+	cli    *http.Client
+	retry  time.Duration
+
+	followers []*follower
+	cancel    func()
+	wg        sync.WaitGroup
+}
+
+// follower is a replication state of a single follower, as seen by the leader.
+type follower struct {
+	addr string
+
+	mu     sync.Mutex
+	seq    uint64 // last acknowledged batch
+	err    error  // last replication error
+	notify chan struct{}
+}
+
+// ack records a batch acknowledged by the follower and clears the last error.
+func (f *follower) ack(seq uint64) {
+	f.mu.Lock()
+	f.seq, f.err = seq, nil
+	notify := f.notify
+	f.notify = make(chan struct{})
+	f.mu.Unlock()
+	close(notify)
+}
+
+// NewLeader creates a leader writer that replicates changes to followers at given addresses.
+// The secret must match the one configured on followers.
+func NewLeader(qs graph.QuadStore, opts graph.IgnoreOpts, secret string, followers ...string) (*Leader, error) {
+	return newLeader(newSingle(qs, opts, graph.DefaultChangeBuffer, true), secret, followers, DefaultReplicationRetry)
+}
+
+func newLeader(s *Single, secret string, addrs []string, retry time.Duration) (*Leader, error) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	// followers are fed from the change log, so it must record all changes
+	s.Changes()
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Leader{
+		Single: s,
+		id:     hex.EncodeToString(id[:]),
+		secret: secret,
+		cli:    &http.Client{Timeout: time.Minute},
+		retry:  retry,
+		cancel: cancel,
+	}
+	for _, addr := range addrs {
+		addr = strings.TrimSuffix(strings.TrimSpace(addr), "/")
+		if addr == "" {
+			continue
+		}
+		f := &follower{addr: addr, notify: make(chan struct{})}
+		l.followers = append(l.followers, f)
+		l.wg.Add(1)
+		go l.run(ctx, f)
+	}
+	return l, nil
+}
+
+func NewLeaderReplication(qs graph.QuadStore, opts graph.Options) (graph.QuadWriter, error) {
+	secret, err := replicationSecret(opts)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewSingleReplication(qs, opts)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := opts.StringsKey(OptFollowers, nil)
+	if err != nil {
+		return nil, err
+	}
+	retry := DefaultReplicationRetry
+	if s, err := opts.StringKey(OptReplicationRetry, ""); err != nil {
+		return nil, err
+	} else if s != "" {
+		retry, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s: %v", OptReplicationRetry, err)
+		}
+	}
+	return newLeader(w.(*Single), secret, addrs, retry)
+}
+
+// ID returns a random id of this leader instance. Followers use it to detect leader restarts.
+func (l *Leader) ID() string {
+	return l.id
+}
+
+// run ships changes to a follower until the context is cancelled.
+func (l *Leader) run(ctx context.Context, f *follower) {
+	defer l.wg.Done()
+	for {
+		err := l.sync(ctx, f)
+		if ctx.Err() != nil {
+			return
+		}
+		f.mu.Lock()
+		f.err = err
+		f.mu.Unlock()
+		clog.Warningf("replication: follower %s: %v", f.addr, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.retry):
+		}
+	}
+}
+
+// sync fetches the state of a follower and ships all changes it has not applied yet.
+// The follower is resynced with a snapshot if it cannot be caught up from the change log.
+// It only returns on error.
+func (l *Leader) sync(ctx context.Context, f *follower) error {
+	var st ReplicaState
+	if err := l.call(ctx, http.MethodGet, f.addr, nil, &st); err != nil {
+		return err
+	}
+	since, synced := st.Seq, st.Leader == l.id
+	for {
+		if !synced {
+			clog.Infof("replication: sending a snapshot to follower %s", f.addr)
+			var err error
+			since, err = l.snapshot(ctx, f)
+			if err != nil {
+				return err
+			}
+		}
+		f.ack(since)
+		err := l.changes.Watch(ctx, since, func(c graph.Change) error {
+			b := ReplicaBatch{Leader: l.id, Seq: c.Seq, Deltas: c.Deltas}
+			if err := l.call(ctx, http.MethodPost, f.addr, b, &st); err != nil {
+				return err
+			}
+			f.ack(st.Seq)
+			return nil
+		})
+		if err != graph.ErrChangesTruncated {
+			return err
+		}
+		synced = false
+	}
+}
+
+// snapshot sends all quads of the leader to a follower and returns the sequence number of the last change
+// included in the snapshot.
+//
+// Writes are not blocked while the snapshot is sent, so it may also include some of the later changes.
+// These changes are resent from the log afterwards, which is safe, since followers apply batches idempotently.
+// Expiring quads are sent as permanent ones; the follower removes them once the leader ships their deletes.
+func (l *Leader) snapshot(ctx context.Context, f *follower) (uint64, error) {
+	// the store is updated before changes are published, so it already has all changes up to seq
+	seq := l.changes.Seq()
+	r := graph.NewQuadStoreReader(graph.PrimaryOf(l.qs))
+	defer r.Close()
+	buf := make([]quad.Quad, quad.DefaultBatch)
+	b := ReplicaBatch{Leader: l.id, Seq: seq, Snapshot: true}
+	for !b.Last {
+		n, err := readQuads(r, buf)
+		if err == io.EOF {
+			b.Last = true
+		} else if err != nil {
+			return 0, err
+		}
+		b.Deltas = b.Deltas[:0]
+		for _, q := range buf[:n] {
+			b.Deltas = append(b.Deltas, graph.Delta{Action: graph.Add, Quad: q})
+		}
+		var st ReplicaState
+		if err = l.call(ctx, http.MethodPost, f.addr, b, &st); err != nil {
+			return 0, err
+		}
+		b.Part++
+	}
+	return seq, nil
+}
+
+// call sends a replication request to a follower and decodes the response.
+func (l *Leader) call(ctx context.Context, method, addr string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, addr+ReplicatePath, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(SecretHeader, l.secret)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := l.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return fmt.Errorf("replication: %s", e.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Wait blocks until all followers acknowledge all changes committed before the call.
+func (l *Leader) Wait(ctx context.Context) error {
+	seq := l.changes.Seq()
+	for _, f := range l.followers {
+		for {
+			f.mu.Lock()
+			done, notify := f.seq >= seq, f.notify
+			f.mu.Unlock()
+			if done {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-notify:
+			}
+		}
+	}
+	return nil
+}
+
+// FollowerStatus is a replication status of a follower.
+type FollowerStatus struct {
+	Addr string
+	// Seq is a sequence number of the last batch acknowledged by the follower.
+	Seq uint64
+	// Err is the last replication error. It is reset when the follower becomes reachable.
+	Err error
+}
+
+// Followers returns the replication status of all followers.
+func (l *Leader) Followers() []FollowerStatus {
+	out := make([]FollowerStatus, 0, len(l.followers))
+	for _, f := range l.followers {
+		f.mu.Lock()
+		out = append(out, FollowerStatus{Addr: f.addr, Seq: f.seq, Err: f.err})
+		f.mu.Unlock()
+	}
+	return out
+}
+
+// Close stops replication. The change log is not persisted, so followers are resynced with a snapshot
+// after the leader restarts.
+func (l *Leader) Close() error {
+	l.cancel()
+	l.wg.Wait()
+	return l.Single.Close()
+}
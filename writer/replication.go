@@ -0,0 +1,160 @@
+package writer
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cayleygraph/quad"
+	"github.com/cayleygraph/quad/pquads"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+const (
+	// ReplicatePath is a path of HTTP endpoint of a follower that accepts batches from the leader.
+	ReplicatePath = "/api/v2/replicate"
+	// SecretHeader is an HTTP header that carries the replication secret.
+	SecretHeader = "X-Replication-Secret"
+)
+
+// OptReplicationSecret is a secret shared by the leader and its followers. It is required by both of them.
+const OptReplicationSecret = "replication_secret"
+
+var (
+	ErrFollower        = errors.New("writer: follower is read-only, writes must be sent to the leader")
+	ErrReplicaConflict = errors.New("replication: batch does not follow the last applied batch")
+	ErrNoSecret        = errors.New("replication: " + OptReplicationSecret + " is not set")
+	ErrReplicaSyncing  = errors.New("replication: follower is being resynced with a snapshot")
+)
+
+// replicationSecret reads a mandatory replication secret from options.
+func replicationSecret(opts graph.Options) (string, error) {
+	secret, err := opts.StringKey(OptReplicationSecret, "")
+	if err != nil {
+		return "", err
+	} else if secret == "" {
+		return "", ErrNoSecret
+	}
+	return secret, nil
+}
+
+// checkSecret compares secrets in constant time. An empty secret never matches.
+func checkSecret(secret, s string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s)) == 1
+}
+
+// ReplicaState is a replication state of a follower.
+type ReplicaState struct {
+	// Leader is an id of the leader instance the follower replicates from.
+	Leader string `json:"leader"`
+	// Seq is a sequence number of the last batch applied by the follower.
+	Seq uint64 `json:"seq"`
+}
+
+// ReplicaBatch is a batch of deltas shipped from the leader to followers.
+//
+// Followers that cannot be caught up from the change log receive a snapshot: a full copy of the leader's data
+// as of the change Seq, split into batches with Snapshot flag set. The first part (Part 0) removes all data
+// of the follower, and the follower switches to the leader once the Last part is applied.
+type ReplicaBatch struct {
+	Leader   string
+	Seq      uint64
+	Snapshot bool
+	Part     int
+	Last     bool
+	Deltas   []graph.Delta
+}
+
+// replicaDelta is a wire representation of graph.Delta. Quads are encoded with pquads to preserve value types.
+type replicaDelta struct {
+	Action  string `json:"action"`
+	Quad    []byte `json:"quad"`
+	Expires int64  `json:"expires,omitempty"` // unix nanoseconds
+}
+
+type replicaBatch struct {
+	Leader   string         `json:"leader"`
+	Seq      uint64         `json:"seq"`
+	Snapshot bool           `json:"snapshot,omitempty"`
+	Part     int            `json:"part,omitempty"`
+	Last     bool           `json:"last,omitempty"`
+	Deltas   []replicaDelta `json:"deltas"`
+}
+
+// readQuads reads up to len(buf) quads. It returns io.EOF only if no quads were read.
+func readQuads(r quad.Reader, buf []quad.Quad) (int, error) {
+	n := 0
+	for ; n < len(buf); n++ {
+		q, err := r.ReadQuad()
+		if err == io.EOF && n != 0 {
+			break
+		} else if err != nil {
+			return n, err
+		}
+		buf[n] = q
+	}
+	return n, nil
+}
+
+func parseProcedure(s string) (graph.Procedure, error) {
+	for _, p := range []graph.Procedure{graph.Add, graph.Delete, graph.Replace} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("replication: invalid action: %q", s)
+}
+
+func (b ReplicaBatch) MarshalJSON() ([]byte, error) {
+	out := replicaBatch{
+		Leader: b.Leader, Seq: b.Seq,
+		Snapshot: b.Snapshot, Part: b.Part, Last: b.Last,
+		Deltas: make([]replicaDelta, 0, len(b.Deltas)),
+	}
+	for _, d := range b.Deltas {
+		data, err := proto.Marshal(pquads.MakeQuad(d.Quad))
+		if err != nil {
+			return nil, err
+		}
+		rd := replicaDelta{Action: d.Action.String(), Quad: data}
+		if !d.Expires.IsZero() {
+			rd.Expires = d.Expires.UnixNano()
+		}
+		out.Deltas = append(out.Deltas, rd)
+	}
+	return json.Marshal(out)
+}
+
+func (b *ReplicaBatch) UnmarshalJSON(data []byte) error {
+	var in replicaBatch
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	deltas := make([]graph.Delta, 0, len(in.Deltas))
+	for _, d := range in.Deltas {
+		act, err := parseProcedure(d.Action)
+		if err != nil {
+			return err
+		}
+		var q pquads.Quad
+		if err = proto.Unmarshal(d.Quad, &q); err != nil {
+			return err
+		}
+		nd := graph.Delta{Action: act, Quad: q.ToNative()}
+		if d.Expires != 0 {
+			nd.Expires = time.Unix(0, d.Expires)
+		}
+		deltas = append(deltas, nd)
+	}
+	*b = ReplicaBatch{
+		Leader: in.Leader, Seq: in.Seq,
+		Snapshot: in.Snapshot, Part: in.Part, Last: in.Last,
+		Deltas: deltas,
+	}
+	return nil
+}
@@ -15,6 +15,10 @@
 package writer
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
+
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/quad"
 )
@@ -23,16 +27,44 @@ func init() {
 	graph.RegisterWriter("single", NewSingleReplication)
 }
 
+var (
+	_ graph.ChangeSource = (*Single)(nil)
+	_ graph.QuadReplacer = (*Single)(nil)
+	_ graph.NodeRemover  = (*Single)(nil)
+)
+
+// Single is a writer that applies changes to a local quad store.
+//
+// If the quad store supports expiring quads (see graph.Expirer), the writer removes expired quads
+// in the background and publishes these deletes to its change feed.
+//
+// Changes are only published after the feed was requested with Changes. Until then, the writer does not
+// compute effective deltas, which requires reading the store before each write.
 type Single struct {
 	qs         graph.QuadStore
 	ignoreOpts graph.IgnoreOpts
+	changes    *graph.ChangeFeed
+	watched    atomic.Bool // set once the feed is requested; changed under the write lock
+
+	mu    sync.Mutex // serializes writes, so changes are published in the order they were applied
+	sweep *sweeper   // nil if the store has no expiring quads or they are removed by someone else
 }
 
 func NewSingle(qs graph.QuadStore, opts graph.IgnoreOpts) (graph.QuadWriter, error) {
-	return &Single{
+	return newSingle(qs, opts, graph.DefaultChangeBuffer, true), nil
+}
+
+func newSingle(qs graph.QuadStore, opts graph.IgnoreOpts, changes int, sweep bool) *Single {
+	s := &Single{
 		qs:         qs,
 		ignoreOpts: opts,
-	}, nil
+		changes:    graph.NewChangeFeed(changes),
+	}
+	if exp, ok := graph.Unwrap(qs).(graph.Expirer); ok && sweep {
+		s.sweep = newSweeper(exp)
+		s.sweep.startPending(s)
+	}
+	return s
 }
 
 func NewSingleReplication(qs graph.QuadStore, opts graph.Options) (graph.QuadWriter, error) {
@@ -46,10 +78,62 @@ func NewSingleReplication(qs graph.QuadStore, opts graph.Options) (graph.QuadWri
 		return nil, err
 	}
 
-	return NewSingle(qs, graph.IgnoreOpts{
+	changes, err := opts.IntKey("change_buffer", graph.DefaultChangeBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSingle(qs, graph.IgnoreOpts{
 		IgnoreMissing: ignoreMissing,
 		IgnoreDup:     ignoreDuplicate,
-	})
+	}, changes, true), nil
+}
+
+// apply applies deltas to the quad store and publishes them to the change feed.
+func (s *Single) apply(deltas []graph.Delta) error {
+	return s.applyIf(nil, deltas)
+}
+
+// applyIf is similar to apply, but checks preconditions atomically with applying deltas.
+func (s *Single) applyIf(conds []graph.Precondition, deltas []graph.Delta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyLocked(conds, deltas)
+}
+
+// applyLocked is similar to applyIf, but must be called while holding the write lock.
+func (s *Single) applyLocked(conds []graph.Precondition, deltas []graph.Delta) error {
+	var changed []graph.Delta
+	if s.watched.Load() {
+		// the store is only changed under the write lock, so it can be read before applying deltas
+		var err error
+		changed, err = graph.EffectiveDeltas(graph.PrimaryOf(s.qs), deltas, s.ignoreOpts)
+		if err != nil {
+			return err
+		}
+	}
+	if err := graph.ApplyDeltasIf(s.qs, conds, deltas, s.ignoreOpts); err != nil {
+		return err
+	}
+	if len(changed) != 0 {
+		s.changes.Publish(changed)
+	}
+	if s.sweep != nil && graph.HasExpiring(deltas) {
+		s.sweep.start(s)
+	}
+	return nil
+}
+
+// Changes returns a feed of changes committed by this writer.
+// Only changes committed after the first call are published to the feed.
+func (s *Single) Changes() *graph.ChangeFeed {
+	if !s.watched.Load() {
+		// wait for a write in progress, so it is not missing from the feed when the caller reads its sequence
+		s.mu.Lock()
+		s.watched.Store(true)
+		s.mu.Unlock()
+	}
+	return s.changes
 }
 
 func (s *Single) AddQuad(q quad.Quad) error {
@@ -58,7 +142,7 @@ func (s *Single) AddQuad(q quad.Quad) error {
 		Quad:   q,
 		Action: graph.Add,
 	}
-	return s.qs.ApplyDeltas(deltas, s.ignoreOpts)
+	return s.apply(deltas)
 }
 
 func (s *Single) AddQuadSet(set []quad.Quad) error {
@@ -66,7 +150,7 @@ func (s *Single) AddQuadSet(set []quad.Quad) error {
 	for _, q := range set {
 		tx.AddQuad(q)
 	}
-	return s.qs.ApplyDeltas(tx.Deltas, s.ignoreOpts)
+	return s.apply(tx.Deltas)
 }
 
 func (s *Single) RemoveQuad(q quad.Quad) error {
@@ -75,14 +159,20 @@ func (s *Single) RemoveQuad(q quad.Quad) error {
 		Quad:   q,
 		Action: graph.Delete,
 	}
-	return s.qs.ApplyDeltas(deltas, s.ignoreOpts)
+	return s.apply(deltas)
+}
+
+// ReplaceQuad removes all quads with the same subject, predicate and label and adds a given quad.
+func (s *Single) ReplaceQuad(q quad.Quad) error {
+	return s.apply([]graph.Delta{{Quad: q, Action: graph.Replace}})
 }
 
 // RemoveNode removes all quads with the given value.
 //
 // It returns ErrNodeNotExists if node is missing.
 func (s *Single) RemoveNode(v quad.Value) error {
-	gv, err := s.qs.ValueOf(v)
+	qs := graph.PrimaryOf(s.qs)
+	gv, err := qs.ValueOf(v)
 	if err != nil {
 		return err
 	}
@@ -95,7 +185,7 @@ func (s *Single) RemoveNode(v quad.Value) error {
 	total := 0
 	// TODO(dennwc): QuadStore may remove node without iterations. Consider optional interface for this.
 	for _, d := range []quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label} {
-		r := graph.NewResultReader(s.qs, s.qs.QuadIterator(d, gv).Iterate())
+		r := graph.NewResultReader(qs, qs.QuadIterator(d, gv).Iterate())
 		n, err := quad.Copy(del, r)
 		r.Close()
 		if err != nil {
@@ -112,11 +202,32 @@ func (s *Single) RemoveNode(v quad.Value) error {
 	return nil
 }
 
+// RemoveNodeWith removes a node and all quads that use it in a single transaction.
+// Quads are found while holding the write lock, thus concurrent writes cannot change the set of removed quads.
+func (s *Single) RemoveNodeWith(ctx context.Context, qs graph.QuadStore, v quad.Value, opts graph.RemoveNodeOptions) (*graph.NodeRemoval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rm, err := graph.PlanNodeRemoval(ctx, graph.PrimaryOf(qs), v, opts)
+	if err != nil || opts.DryRun {
+		return rm, err
+	}
+	deltas := make([]graph.Delta, 0, len(rm.Quads))
+	for _, q := range rm.Quads {
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Delete})
+	}
+	if err = s.applyLocked(nil, deltas); err != nil {
+		return nil, err
+	}
+	return rm, nil
+}
+
 func (s *Single) Close() error {
-	// Nothing to clean up locally.
+	if s.sweep != nil {
+		s.sweep.stop()
+	}
 	return nil
 }
 
 func (s *Single) ApplyTransaction(t *graph.Transaction) error {
-	return s.qs.ApplyDeltas(t.Deltas, s.ignoreOpts)
+	return s.applyIf(t.Preconds, t.Deltas)
 }
@@ -0,0 +1,135 @@
+package writer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/quad"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// sweepRetry is a delay before the next sweep, if the previous one did not remove all expired quads.
+const sweepRetry = time.Second
+
+// sweeper removes expired quads from a quad store in the background.
+// Quads are removed with regular deletes, so they are published to the change feed of the writer.
+// The store is only accessed under the write lock of the writer, but reads of other goroutines are not
+// synchronized with it, thus the store must be safe for concurrent use (see graph.Expirer).
+type sweeper struct {
+	exp graph.Expirer
+
+	once   sync.Once
+	wake   chan struct{}
+	cancel func()
+	done   chan struct{}
+}
+
+func newSweeper(exp graph.Expirer) *sweeper {
+	return &sweeper{exp: exp, wake: make(chan struct{}, 1)}
+}
+
+// start runs the sweeper, if it is not running yet, and makes it reschedule the next sweep.
+func (sw *sweeper) start(s *Single) {
+	sw.once.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		sw.cancel = cancel
+		sw.done = make(chan struct{})
+		go sw.run(ctx, s)
+	})
+	select {
+	case sw.wake <- struct{}{}:
+	default:
+	}
+}
+
+// startPending starts the sweeper if the store already has expiring quads.
+func (sw *sweeper) startPending(s *Single) {
+	_, ok, err := sw.exp.NextExpiry(context.Background())
+	if err != nil {
+		clog.Errorf("expiry: cannot check expiring quads: %v", err)
+		ok = true
+	}
+	if ok {
+		sw.start(s)
+	}
+}
+
+// run sleeps until the next quad expires and removes all expired quads, until the context is cancelled.
+func (sw *sweeper) run(ctx context.Context, s *Single) {
+	defer close(sw.done)
+	retry := false
+	for {
+		var wait <-chan time.Time
+		s.mu.Lock()
+		next, ok, err := sw.exp.NextExpiry(ctx)
+		s.mu.Unlock()
+		if err != nil {
+			clog.Errorf("expiry: %v", err)
+			ok, next = true, time.Now().Add(sweepRetry)
+		}
+		if ok {
+			d := time.Until(next)
+			if d <= 0 && !retry {
+				retry = true
+				if err = sw.sweep(ctx, s); err != nil && ctx.Err() == nil {
+					clog.Errorf("expiry: cannot remove expired quads: %v", err)
+				}
+				continue
+			} else if d <= 0 {
+				// some quads were not removed by the last sweep, don't spin on them
+				d = sweepRetry
+			}
+			wait = time.After(d)
+		}
+		retry = false
+		select {
+		case <-ctx.Done():
+			return
+		case <-sw.wake:
+		case <-wait:
+		}
+	}
+}
+
+// sweep removes all quads that expired by now.
+func (sw *sweeper) sweep(ctx context.Context, s *Single) error {
+	for {
+		n, err := sw.sweepBatch(ctx, s)
+		if err != nil || n < quad.DefaultBatch {
+			return err
+		}
+	}
+}
+
+// sweepBatch removes a single batch of expired quads and returns the number of removed quads.
+func (sw *sweeper) sweepBatch(ctx context.Context, s *Single) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quads, err := sw.exp.ExpiredQuads(ctx, time.Now(), quad.DefaultBatch)
+	if err != nil || len(quads) == 0 {
+		return 0, err
+	}
+	deltas := make([]graph.Delta, 0, len(quads))
+	for _, q := range quads {
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Delete})
+	}
+	if err = graph.ApplyDeltasIf(s.qs, nil, deltas, graph.IgnoreOpts{IgnoreMissing: true}); err != nil {
+		return 0, err
+	}
+	if s.watched.Load() {
+		s.changes.Publish(deltas)
+	}
+	return len(quads), nil
+}
+
+// stop stops the sweeper and waits for it to finish.
+func (sw *sweeper) stop() {
+	sw.once.Do(func() {}) // prevent starting it later
+	if sw.cancel != nil {
+		sw.cancel()
+		<-sw.done
+	}
+}